@@ -0,0 +1,89 @@
+package analytics
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/dhruv15803/echo-blog-app/storage"
+)
+
+const (
+	defaultRollupInterval = time.Hour
+	// view events are kept this long after they were rolled up, trending compares the views of
+	// the last 7 days to the 7 days before
+	defaultEventRetention = time.Hour * 24 * 14
+	// a view counts as a read once the viewer got through this much of the blog
+	ReadPercent = 75
+)
+
+// Roller periodically rolls view events up into hourly views, reads and referrers of every blog,
+// and those along with likes, comments, bookmarks and follows into daily stats for author dashboards
+type Roller struct {
+	storage  *storage.Storage
+	interval time.Duration
+	// hours before the latest rolled up hour that are computed again, as reads in a
+	// dedupe window update events of earlier hours
+	lookback  time.Duration
+	retention time.Duration
+}
+
+func NewRoller(storage *storage.Storage, dedupeWindow time.Duration) *Roller {
+	return &Roller{
+		storage:   storage,
+		interval:  defaultRollupInterval,
+		lookback:  dedupeWindow.Truncate(time.Hour) + time.Hour,
+		retention: defaultEventRetention,
+	}
+}
+
+// Run rolls up view events every interval until ctx is cancelled
+func (r *Roller) Run(ctx context.Context) {
+
+	for {
+		r.rollup()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.interval):
+		}
+	}
+}
+
+func (r *Roller) rollup() {
+
+	latest, err := r.storage.GetLatestBlogViewRollupHour()
+	if err != nil {
+		log.Printf("failed to get latest blog view rollup :- %v\n", err.Error())
+		return
+	}
+
+	// the first rollup covers every event there is
+	since := time.Time{}
+	if !latest.IsZero() {
+		since = latest.Add(-r.lookback)
+	}
+
+	if err := r.storage.RollupBlogViews(since, ReadPercent, time.Now().Add(-r.retention)); err != nil {
+		log.Printf("failed to roll up blog views :- %v\n", err.Error())
+		return
+	}
+
+	latestDay, err := r.storage.GetLatestDailyStatsDay()
+	if err != nil {
+		log.Printf("failed to get latest daily stats :- %v\n", err.Error())
+		return
+	}
+
+	// the days from the latest rolled up day are computed again, and from further back when
+	// the hourly views that were just computed again reach into an earlier day
+	dailySince := latestDay
+	if since.Before(dailySince) {
+		dailySince = since
+	}
+
+	if err := r.storage.RollupDailyStats(dailySince); err != nil {
+		log.Printf("failed to roll up daily stats :- %v\n", err.Error())
+	}
+}
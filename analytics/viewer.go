@@ -0,0 +1,49 @@
+package analytics
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var botPattern = regexp.MustCompile(`(?i)bot|crawl|spider|slurp|fetch|scan|monitor|preview|headless|lighthouse|python|curl|wget|java/|go-http-client|okhttp|axios|node-fetch|facebookexternalhit|embedly|whatsapp`)
+
+// IsBot reports whether the user agent is a crawler, preview fetcher or script rather than a reader,
+// a request without a user agent is treated as one
+func IsBot(userAgent string) bool {
+	return strings.TrimSpace(userAgent) == "" || botPattern.MatchString(userAgent)
+}
+
+// ViewerHash identifies a viewer within the dedupe window starting at windowStart. the window start is
+// part of the hashed message, so the same viewer has an unrelated hash in every window
+func ViewerHash(secret []byte, viewer string, windowStart time.Time) string {
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(windowStart.UTC().Format(time.RFC3339) + "|" + viewer))
+
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// ReferrerDomain is the host of the referrer url without a www. prefix, empty for an invalid referrer
+// or one of the own hosts, e.g. the frontend when the reader came from another page of the site
+func ReferrerDomain(referrer string, ownHosts ...string) string {
+
+	u, err := url.Parse(strings.TrimSpace(referrer))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+
+	for _, own := range ownHosts {
+		if host == strings.TrimPrefix(strings.ToLower(own), "www.") {
+			return ""
+		}
+	}
+
+	return host
+}
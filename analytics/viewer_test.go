@@ -0,0 +1,100 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsBot(t *testing.T) {
+
+	tests := []struct {
+		userAgent string
+		want      bool
+	}{
+		{userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0 Safari/537.36", want: false},
+		{userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 18_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.0 Mobile/15E148 Safari/604.1", want: false},
+		{userAgent: "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", want: true},
+		{userAgent: "Mozilla/5.0 (compatible; bingbot/2.0)", want: true},
+		{userAgent: "facebookexternalhit/1.1", want: true},
+		{userAgent: "WhatsApp/2.23.20.0", want: true},
+		{userAgent: "Mozilla/5.0 HeadlessChrome/129.0", want: true},
+		{userAgent: "curl/8.5.0", want: true},
+		{userAgent: "python-requests/2.32", want: true},
+		{userAgent: "Go-http-client/1.1", want: true},
+		{userAgent: "", want: true},
+		{userAgent: "   ", want: true},
+	}
+
+	for _, tt := range tests {
+		if got := IsBot(tt.userAgent); got != tt.want {
+			t.Errorf("IsBot(%q) = %v, want %v", tt.userAgent, got, tt.want)
+		}
+	}
+}
+
+func TestReferrerDomain(t *testing.T) {
+
+	ownHosts := []string{"echo-blog.example.com", "www.api.example.com"}
+
+	tests := []struct {
+		referrer string
+		want     string
+	}{
+		{referrer: "https://news.ycombinator.com/item?id=1", want: "news.ycombinator.com"},
+		{referrer: "https://www.google.com/", want: "google.com"},
+		{referrer: "http://WWW.Reddit.com/r/golang", want: "reddit.com"},
+		{referrer: "https://t.co:443/abc", want: "t.co"},
+		// the reader came from another page of the site
+		{referrer: "https://echo-blog.example.com/blogs/1", want: ""},
+		{referrer: "https://www.echo-blog.example.com/", want: ""},
+		{referrer: "https://api.example.com/", want: ""},
+		// only the www. prefix is stripped
+		{referrer: "https://blog.echo-blog.example.com/", want: "blog.echo-blog.example.com"},
+		{referrer: "", want: ""},
+		{referrer: "android-app://com.google.android.gm/", want: ""},
+		{referrer: "javascript:alert(1)", want: ""},
+		{referrer: "not a url", want: ""},
+		{referrer: "https://%zz", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := ReferrerDomain(tt.referrer, ownHosts...); got != tt.want {
+			t.Errorf("ReferrerDomain(%q) = %q, want %q", tt.referrer, got, tt.want)
+		}
+	}
+}
+
+func TestViewerHash(t *testing.T) {
+
+	secret := []byte("secret")
+	window := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	hash := ViewerHash(secret, "user:1", window)
+
+	if len(hash) != 32 {
+		t.Errorf("ViewerHash() = %q, want 32 hex characters", hash)
+	}
+
+	// the window start is compared in utc
+	if got := ViewerHash(secret, "user:1", window.In(time.FixedZone("IST", 5*60*60+30*60))); got != hash {
+		t.Errorf("ViewerHash() in another zone = %q, want %q", got, hash)
+	}
+
+	differing := []struct {
+		name        string
+		secret      []byte
+		viewer      string
+		windowStart time.Time
+	}{
+		{name: "next window", secret: secret, viewer: "user:1", windowStart: window.Add(30 * time.Minute)},
+		{name: "previous window", secret: secret, viewer: "user:1", windowStart: window.Add(-30 * time.Minute)},
+		{name: "other viewer", secret: secret, viewer: "user:2", windowStart: window},
+		{name: "other secret", secret: []byte("other"), viewer: "user:1", windowStart: window},
+	}
+
+	for _, tt := range differing {
+		if got := ViewerHash(tt.secret, tt.viewer, tt.windowStart); got == hash {
+			t.Errorf("ViewerHash() for the %s = %q, want a different hash", tt.name, got)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Package analytics records blog views and reads and rolls them up into hourly statistics
+package analytics
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/dhruv15803/echo-blog-app/storage"
+)
+
+const (
+	defaultBufferSize    = 10000
+	defaultBatchSize     = 500
+	defaultFlushInterval = time.Second * 5
+)
+
+// ViewWriter buffers view events in memory and writes them to the database in batches, so recording
+// a view never waits on the database. events that arrive while the buffer is full are dropped
+type ViewWriter struct {
+	storage       *storage.Storage
+	events        chan storage.BlogViewEvent
+	batchSize     int
+	flushInterval time.Duration
+}
+
+func NewViewWriter(store *storage.Storage) *ViewWriter {
+	return &ViewWriter{
+		storage:       store,
+		events:        make(chan storage.BlogViewEvent, defaultBufferSize),
+		batchSize:     defaultBatchSize,
+		flushInterval: defaultFlushInterval,
+	}
+}
+
+// Record queues the event, it reports false when the buffer is full and the event was dropped
+func (v *ViewWriter) Record(event storage.BlogViewEvent) bool {
+
+	select {
+	case v.events <- event:
+		return true
+	default:
+		return false
+	}
+}
+
+// Run writes a batch whenever it is full or the flush interval passes, until ctx is cancelled.
+// the events still buffered then are written before it returns
+func (v *ViewWriter) Run(ctx context.Context) {
+
+	batch := make([]storage.BlogViewEvent, 0, v.batchSize)
+	ticker := time.NewTicker(v.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event := <-v.events:
+			batch = append(batch, event)
+			if len(batch) >= v.batchSize {
+				batch = v.flush(batch)
+			}
+
+		case <-ticker.C:
+			batch = v.flush(batch)
+
+		case <-ctx.Done():
+			for {
+				select {
+				case event := <-v.events:
+					batch = append(batch, event)
+					if len(batch) >= v.batchSize {
+						batch = v.flush(batch)
+					}
+				default:
+					v.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush writes the batch and returns it emptied, a batch that fails to write is logged and dropped
+func (v *ViewWriter) flush(batch []storage.BlogViewEvent) []storage.BlogViewEvent {
+
+	if len(batch) == 0 {
+		return batch
+	}
+
+	if err := v.storage.InsertBlogViewEvents(batch); err != nil {
+		log.Printf("failed to write %d blog view events :- %v\n", len(batch), err.Error())
+	}
+
+	return batch[:0]
+}
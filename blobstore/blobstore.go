@@ -0,0 +1,77 @@
+package blobstore
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var ErrInvalidKey = errors.New("invalid blob key")
+
+// Blob is a stored file
+type Blob struct {
+	Key         string `json:"key"`
+	Url         string `json:"url"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+}
+
+// BlobStore stores uploaded files. keys are always generated by the server with NewKey,
+// never taken from the client, so they cannot escape the store or collide
+type BlobStore interface {
+	// Put stores size bytes read from r under key, replacing any blob with that key
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (*Blob, error)
+	Delete(ctx context.Context, key string) error
+	// Open reads the blob stored under key, the caller closes it
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Size is the size in bytes of the blob stored under key, without reading it
+	Size(ctx context.Context, key string) (int64, error)
+	// Url is the public url of the blob stored under key
+	Url(key string) string
+}
+
+// UploadTarget tells a client how to upload a file straight to the store, without going through the api
+type UploadTarget struct {
+	// PUT with the file as the body, or POST as multipart/form-data with the file in the "file" field
+	Method string `json:"method"`
+	Url    string `json:"url"`
+	// headers the client must send exactly as given, they are part of the signature
+	Headers map[string]string `json:"headers,omitempty"`
+	// form fields to send along the file in a multipart POST
+	Fields    map[string]string `json:"fields,omitempty"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+// Presigner is implemented by stores that clients can upload to directly with a short lived signed request
+type Presigner interface {
+	// PresignPut authorizes one upload of size bytes of contentType under key until expires has passed
+	PresignPut(key string, contentType string, size int64, expires time.Duration) (*UploadTarget, error)
+}
+
+// NewKey returns a random key like "media/2026/10/<random>.png", ext includes the leading dot
+func NewKey(prefix string, ext string) (string, error) {
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s/%s/%s%s", prefix, time.Now().UTC().Format("2006/01"), hex.EncodeToString(b), ext), nil
+}
+
+// validateKey rejects keys that are absolute, contain ".." or are not in clean form
+func validateKey(key string) error {
+
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key || !filepath.IsLocal(filepath.FromSlash(key)) {
+		return ErrInvalidKey
+	}
+
+	return nil
+}
@@ -0,0 +1,171 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudinary/cloudinary-go/v2"
+	"github.com/cloudinary/cloudinary-go/v2/api"
+	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
+)
+
+// CloudinaryStore keeps blobs on cloudinary, the key without its extension is the public id
+type CloudinaryStore struct {
+	cld *cloudinary.Cloudinary
+}
+
+func NewCloudinaryStore(cld *cloudinary.Cloudinary) *CloudinaryStore {
+	return &CloudinaryStore{cld: cld}
+}
+
+func publicId(key string) string {
+	return strings.TrimSuffix(key, path.Ext(key))
+}
+
+func (s *CloudinaryStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (*Blob, error) {
+
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	overwrite := true
+
+	result, err := s.cld.Upload.Upload(ctx, r, uploader.UploadParams{PublicID: publicId(key), Overwrite: &overwrite})
+	if err != nil {
+		return nil, err
+	}
+
+	// api errors are reported in the result, not as an error
+	if result.Error.Message != "" {
+		return nil, errors.New(result.Error.Message)
+	}
+
+	return &Blob{Key: key, Url: result.SecureURL, Size: int64(result.Bytes), ContentType: contentType}, nil
+}
+
+func (s *CloudinaryStore) Delete(ctx context.Context, key string) error {
+
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	result, err := s.cld.Upload.Destroy(ctx, uploader.DestroyParams{PublicID: publicId(key)})
+	if err != nil {
+		return err
+	}
+
+	if result.Error.Message != "" {
+		return errors.New(result.Error.Message)
+	}
+
+	return nil
+}
+
+// Open downloads the original file from its delivery url
+func (s *CloudinaryStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.Url(key), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("cloudinary responded with status %d for %s", res.StatusCode, key)
+	}
+
+	return res.Body, nil
+}
+
+// Size reads the content length of a HEAD request for the original file's delivery url
+func (s *CloudinaryStore) Size(ctx context.Context, key string) (int64, error) {
+
+	if err := validateKey(key); err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, s.Url(key), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("cloudinary responded with status %d for %s", res.StatusCode, key)
+	}
+
+	if res.ContentLength < 0 {
+		return 0, fmt.Errorf("cloudinary responded without a content length for %s", key)
+	}
+
+	return res.ContentLength, nil
+}
+
+// PresignPut returns signed params for cloudinary's upload api. cloudinary cannot restrict the size
+// or type of a signed upload, both have to be checked once the upload is completed
+func (s *CloudinaryStore) PresignPut(key string, contentType string, size int64, expires time.Duration) (*UploadTarget, error) {
+
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	params := url.Values{}
+	params.Set("public_id", publicId(key))
+	params.Set("timestamp", strconv.FormatInt(now.Unix(), 10))
+
+	signature, err := api.SignParameters(params, s.cld.Config.Cloud.APISecret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UploadTarget{
+		Method: http.MethodPost,
+		Url:    fmt.Sprintf("%s/v1_1/%s/image/upload", s.cld.Config.API.UploadPrefix, s.cld.Config.Cloud.CloudName),
+		Fields: map[string]string{
+			"api_key":   s.cld.Config.Cloud.APIKey,
+			"public_id": params.Get("public_id"),
+			"timestamp": params.Get("timestamp"),
+			"signature": signature,
+		},
+		ExpiresAt: now.Add(expires),
+	}, nil
+}
+
+func (s *CloudinaryStore) Url(key string) string {
+
+	image, err := s.cld.Image(publicId(key))
+	if err != nil {
+		return ""
+	}
+
+	url, err := image.String()
+	if err != nil {
+		return ""
+	}
+
+	return url
+}
@@ -0,0 +1,223 @@
+package blobstore
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalStore keeps blobs on the local filesystem, for development and single server deployments.
+// files are served by the store itself, mounted at baseUrl, which also accepts direct uploads
+// to urls presigned with an HMAC of signingSecret
+type LocalStore struct {
+	dir           string
+	baseUrl       string
+	signingSecret []byte
+}
+
+func NewLocalStore(dir string, baseUrl string, signingSecret []byte) (*LocalStore, error) {
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &LocalStore{dir: dir, baseUrl: strings.TrimSuffix(baseUrl, "/"), signingSecret: signingSecret}, nil
+}
+
+func (s *LocalStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (*Blob, error) {
+
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	blobPath := filepath.Join(s.dir, filepath.FromSlash(key))
+
+	if err := os.MkdirAll(filepath.Dir(blobPath), 0o755); err != nil {
+		return nil, err
+	}
+
+	// write to a temp file next to the blob and rename it into place,
+	// so readers never see a partially written file
+	tmp, err := os.CreateTemp(filepath.Dir(blobPath), ".upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	written, err := io.Copy(tmp, r)
+	if err != nil {
+		return nil, err
+	}
+
+	if size >= 0 && written != size {
+		return nil, fmt.Errorf("expected %d bytes , got %d", size, written)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := os.Rename(tmp.Name(), blobPath); err != nil {
+		return nil, err
+	}
+
+	return &Blob{Key: key, Url: s.Url(key), Size: written, ContentType: contentType}, nil
+}
+
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	if err := os.Remove(filepath.Join(s.dir, filepath.FromSlash(key))); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *LocalStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	return os.Open(filepath.Join(s.dir, filepath.FromSlash(key)))
+}
+
+func (s *LocalStore) Size(ctx context.Context, key string) (int64, error) {
+
+	if err := validateKey(key); err != nil {
+		return 0, err
+	}
+
+	info, err := os.Stat(filepath.Join(s.dir, filepath.FromSlash(key)))
+	if err != nil {
+		return 0, err
+	}
+
+	return info.Size(), nil
+}
+
+// PresignPut returns a url of the store itself, its query carries the upload's limits and an HMAC of them
+func (s *LocalStore) PresignPut(key string, contentType string, size int64, expires time.Duration) (*UploadTarget, error) {
+
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(expires)
+
+	query := url.Values{}
+	query.Set("content_type", contentType)
+	query.Set("size", strconv.FormatInt(size, 10))
+	query.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+	query.Set("signature", s.uploadSignature(key, contentType, size, expiresAt.Unix()))
+
+	return &UploadTarget{
+		Method:    http.MethodPut,
+		Url:       s.Url(key) + "?" + query.Encode(),
+		Headers:   map[string]string{"Content-Type": contentType},
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+func (s *LocalStore) uploadSignature(key string, contentType string, size int64, expires int64) string {
+
+	mac := hmac.New(sha256.New, s.signingSecret)
+	fmt.Fprintf(mac, "PUT\n%s\n%s\n%d\n%d", key, contentType, size, expires)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *LocalStore) Url(key string) string {
+	return s.baseUrl + "/" + key
+}
+
+// ServeHTTP serves stored blobs by key, mount it with the base url prefix stripped.
+// directories are never listed
+func (s *LocalStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	key := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+
+	if validateKey(key) != nil || strings.HasPrefix(path.Base(key), ".") {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		s.servePresignedPut(w, r, key)
+		return
+	}
+
+	blobPath := filepath.Join(s.dir, filepath.FromSlash(key))
+
+	info, err := os.Stat(blobPath)
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	http.ServeFile(w, r, blobPath)
+}
+
+// servePresignedPut stores the body of a PUT to a url from PresignPut
+func (s *LocalStore) servePresignedPut(w http.ResponseWriter, r *http.Request, key string) {
+
+	query := r.URL.Query()
+	contentType := query.Get("content_type")
+
+	size, err := strconv.ParseInt(query.Get("size"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid upload url", http.StatusForbidden)
+		return
+	}
+
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid upload url", http.StatusForbidden)
+		return
+	}
+
+	signature := s.uploadSignature(key, contentType, size, expires)
+	if !hmac.Equal([]byte(signature), []byte(query.Get("signature"))) {
+		http.Error(w, "invalid upload url", http.StatusForbidden)
+		return
+	}
+
+	if time.Now().Unix() > expires {
+		http.Error(w, "upload url expired", http.StatusForbidden)
+		return
+	}
+
+	if r.Header.Get("Content-Type") != contentType || r.ContentLength != size {
+		http.Error(w, "content type and length must match the upload url", http.StatusBadRequest)
+		return
+	}
+
+	// large files take longer than the server's read timeout
+	http.NewResponseController(w).SetReadDeadline(time.Time{})
+
+	if _, err := s.Put(r.Context(), key, http.MaxBytesReader(w, r.Body, size), size, contentType); err != nil {
+		http.Error(w, "failed to store upload", http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,378 @@
+package blobstore
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	// sha256 of an empty body
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// S3Config configures an S3 compatible store, e.g. AWS S3, MinIO or Cloudflare R2
+type S3Config struct {
+	// e.g. https://s3.us-east-1.amazonaws.com or http://localhost:9000 for MinIO
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyId     string
+	SecretAccessKey string
+	// MinIO and most self hosted stores need path style urls, endpoint/bucket/key
+	UsePathStyle bool
+	// optional public base url of the bucket, e.g. a CDN in front of it
+	PublicUrl string
+}
+
+// S3Store keeps blobs in an S3 compatible bucket, requests are signed with AWS signature v4
+type S3Store struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+}
+
+func NewS3Store(cfg S3Config, client *http.Client) (*S3Store, error) {
+
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", cfg.Endpoint)
+	}
+
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("s3 bucket is required")
+	}
+
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+
+	if client == nil {
+		client = &http.Client{Timeout: time.Minute * 5}
+	}
+
+	return &S3Store{cfg: cfg, endpoint: endpoint, client: client}, nil
+}
+
+// objectUrl is where the api serves the object
+func (s *S3Store) objectUrl(key string) *url.URL {
+
+	objectUrl := *s.endpoint
+
+	if s.cfg.UsePathStyle {
+		objectUrl.Path = "/" + s.cfg.Bucket + "/" + key
+	} else {
+		objectUrl.Host = s.cfg.Bucket + "." + s.endpoint.Host
+		objectUrl.Path = "/" + key
+	}
+
+	return &objectUrl
+}
+
+// the payload is streamed, so it is sent unsigned instead of being hashed up front
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (*Blob, error) {
+
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectUrl(key).String(), r)
+	if err != nil {
+		return nil, err
+	}
+
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+
+	s.sign(req, unsignedPayload, time.Now())
+
+	if err := s.do(req); err != nil {
+		return nil, err
+	}
+
+	return &Blob{Key: key, Url: s.Url(key), Size: size, ContentType: contentType}, nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectUrl(key).String(), nil)
+	if err != nil {
+		return err
+	}
+
+	s.sign(req, emptyPayloadHash, time.Now())
+
+	return s.do(req)
+}
+
+func (s *S3Store) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectUrl(key).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	s.sign(req, emptyPayloadHash, time.Now())
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		defer res.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return nil, fmt.Errorf("s3 GET %s responded with status %d :- %s", req.URL.Path, res.StatusCode, body)
+	}
+
+	return res.Body, nil
+}
+
+// Size reads the content length of a HEAD request for the object
+func (s *S3Store) Size(ctx context.Context, key string) (int64, error) {
+
+	if err := validateKey(key); err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, s.objectUrl(key).String(), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	s.sign(req, emptyPayloadHash, time.Now())
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return 0, fmt.Errorf("s3 HEAD %s responded with status %d", req.URL.Path, res.StatusCode)
+	}
+
+	if res.ContentLength < 0 {
+		return 0, fmt.Errorf("s3 HEAD %s responded without a content length", req.URL.Path)
+	}
+
+	return res.ContentLength, nil
+}
+
+// PresignPut signs the content length and type, so the client cannot upload anything bigger or of another type
+func (s *S3Store) PresignPut(key string, contentType string, size int64, expires time.Duration) (*UploadTarget, error) {
+
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	headers := map[string]string{
+		"content-length": strconv.FormatInt(size, 10),
+		"content-type":   contentType,
+	}
+
+	return &UploadTarget{
+		Method:    http.MethodPut,
+		Url:       s.presign(http.MethodPut, s.objectUrl(key), headers, expires, now),
+		Headers:   map[string]string{"Content-Type": contentType},
+		ExpiresAt: now.Add(expires),
+	}, nil
+}
+
+func (s *S3Store) Url(key string) string {
+
+	if s.cfg.PublicUrl != "" {
+		return strings.TrimSuffix(s.cfg.PublicUrl, "/") + "/" + key
+	}
+
+	return s.objectUrl(key).String()
+}
+
+func (s *S3Store) do(req *http.Request) error {
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("s3 %s %s responded with status %d :- %s", req.Method, req.URL.Path, res.StatusCode, body)
+	}
+
+	return nil
+}
+
+// sign adds AWS signature v4 headers to req, signing the host and every header already set on it
+func (s *S3Store) sign(req *http.Request, payloadHash string, now time.Time) {
+
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.Join(values, ",")
+	}
+
+	headerNames := make([]string, 0, len(headers))
+	for name := range headers {
+		headerNames = append(headerNames, name)
+	}
+	slices.Sort(headerNames)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range headerNames {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(headerNames, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKeyId, s.scope(date), signedHeaders, s.signature(amzDate, canonicalRequest)))
+}
+
+// presign returns objectUrl with an AWS signature v4 in its query, valid for expires.
+// headers (lower case names) are signed too, the client has to send them unchanged
+func (s *S3Store) presign(method string, objectUrl *url.URL, headers map[string]string, expires time.Duration, now time.Time) string {
+
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+
+	headers["host"] = objectUrl.Host
+
+	headerNames := make([]string, 0, len(headers))
+	for name := range headers {
+		headerNames = append(headerNames, name)
+	}
+	slices.Sort(headerNames)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range headerNames {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(headerNames, ";")
+
+	query := objectUrl.Query()
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", s.cfg.AccessKeyId+"/"+s.scope(date))
+	query.Set("X-Amz-Date", amzDate)
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expires.Seconds())))
+	query.Set("X-Amz-SignedHeaders", signedHeaders)
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		uriEncode(objectUrl.Path, false),
+		canonicalQuery(query),
+		canonicalHeaders.String(),
+		signedHeaders,
+		unsignedPayload,
+	}, "\n")
+
+	presigned := *objectUrl
+	presigned.RawQuery = canonicalQuery(query) + "&X-Amz-Signature=" + s.signature(amzDate, canonicalRequest)
+
+	return presigned.String()
+}
+
+func (s *S3Store) scope(date string) string {
+	return date + "/" + s.cfg.Region + "/s3/aws4_request"
+}
+
+// signature signs a canonical request made at amzDate
+func (s *S3Store) signature(amzDate string, canonicalRequest string) string {
+
+	date := amzDate[:8]
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + s.scope(date) + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	return hex.EncodeToString(hmacSHA256(s.signingKey(date), stringToSign))
+}
+
+func (s *S3Store) signingKey(date string) []byte {
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretAccessKey), date)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	return hmacSHA256(key, "aws4_request")
+}
+
+func canonicalQuery(query url.Values) string {
+
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	var pairs []string
+	for _, key := range keys {
+		values := slices.Clone(query[key])
+		slices.Sort(values)
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent encodes everything but the unreserved characters of RFC 3986,
+// slashes are kept unless encodeSlash is set
+func uriEncode(value string, encodeSlash bool) string {
+
+	var b strings.Builder
+
+	for _, c := range []byte(value) {
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
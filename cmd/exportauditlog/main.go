@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/dhruv15803/echo-blog-app/db"
+	"github.com/dhruv15803/echo-blog-app/scripts"
+	"github.com/dhruv15803/echo-blog-app/storage"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+
+	if err := godotenv.Load(); err != nil {
+		log.Fatal(err)
+	}
+
+	dbPostgresConnStr := os.Getenv("DB_CONN")
+
+	db, err := db.ConnectToPostgres(dbPostgresConnStr)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	out := flag.String("out", "", "Output file (defaults to stdout)")
+	action := flag.String("action", "", "Only export entries with this action")
+	targetType := flag.String("target-type", "", "Only export entries with this target type")
+	actorId := flag.Int("actor-id", 0, "Only export entries by this actor")
+	from := flag.String("from", "", "Only export entries created at or after this RFC3339 time")
+	to := flag.String("to", "", "Only export entries created before this RFC3339 time")
+	flag.Parse()
+
+	filter := storage.AuditLogFilter{
+		ActorId:    *actorId,
+		Action:     *action,
+		TargetType: *targetType,
+	}
+
+	if *from != "" {
+		if filter.From, err = time.Parse(time.RFC3339, *from); err != nil {
+			log.Fatalf("invalid -from time :- %v\n", err.Error())
+		}
+	}
+
+	if *to != "" {
+		if filter.To, err = time.Parse(time.RFC3339, *to); err != nil {
+			log.Fatalf("invalid -to time :- %v\n", err.Error())
+		}
+	}
+
+	outFile := os.Stdout
+	if *out != "" {
+		outFile, err = os.Create(*out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer outFile.Close()
+	}
+
+	writer := bufio.NewWriter(outFile)
+
+	storage := storage.NewStorage(db)
+	scripts := scripts.NewScripts(storage)
+
+	exportedCount, err := scripts.ExportAuditLog(writer, filter)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := writer.Flush(); err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("exported %d audit log entries\n", exportedCount)
+}
@@ -0,0 +1,18 @@
+ALTER TABLE users
+DROP COLUMN IF EXISTS suspended_until;
+
+ALTER TABLE blog_comments
+DROP COLUMN IF EXISTS is_hidden;
+
+ALTER TABLE blogs
+DROP COLUMN IF EXISTS is_hidden;
+
+DROP TABLE IF EXISTS user_warnings;
+
+DROP TABLE IF EXISTS reports;
+
+DROP TYPE IF EXISTS report_status;
+
+DROP TYPE IF EXISTS report_reason;
+
+DROP TYPE IF EXISTS report_target_type;
@@ -0,0 +1,56 @@
+ALTER TYPE user_role ADD VALUE IF NOT EXISTS 'moderator';
+
+CREATE TYPE report_target_type AS ENUM ('blog', 'blog_comment', 'user');
+
+CREATE TYPE report_reason AS ENUM (
+    'spam',
+    'harassment',
+    'hate_speech',
+    'violence',
+    'sexual_content',
+    'misinformation',
+    'other'
+);
+
+CREATE TYPE report_status AS ENUM ('pending', 'dismissed', 'actioned');
+
+CREATE TABLE
+    IF NOT EXISTS reports (
+        id SERIAL PRIMARY KEY,
+        reporter_id INTEGER NOT NULL,
+        target_type report_target_type NOT NULL,
+        target_id INTEGER NOT NULL,
+        reason report_reason NOT NULL,
+        details TEXT,
+        status report_status NOT NULL DEFAULT 'pending',
+        resolution_action TEXT,
+        resolution_note TEXT,
+        resolved_by_id INTEGER,
+        resolved_at TIMESTAMP,
+        reported_at TIMESTAMP DEFAULT NOW (),
+        FOREIGN KEY (reporter_id) REFERENCES users (id) ON DELETE CASCADE,
+        FOREIGN KEY (resolved_by_id) REFERENCES users (id) ON DELETE SET NULL,
+        UNIQUE (reporter_id, target_type, target_id)
+    );
+
+CREATE INDEX IF NOT EXISTS reports_target_idx ON reports (target_type, target_id, status);
+
+CREATE TABLE
+    IF NOT EXISTS user_warnings (
+        id SERIAL PRIMARY KEY,
+        user_id INTEGER NOT NULL,
+        issued_by_id INTEGER,
+        reason TEXT,
+        issued_at TIMESTAMP DEFAULT NOW (),
+        FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+        FOREIGN KEY (issued_by_id) REFERENCES users (id) ON DELETE SET NULL
+    );
+
+ALTER TABLE blogs
+ADD COLUMN IF NOT EXISTS is_hidden BOOLEAN DEFAULT FALSE;
+
+ALTER TABLE blog_comments
+ADD COLUMN IF NOT EXISTS is_hidden BOOLEAN DEFAULT FALSE;
+
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS suspended_until TIMESTAMP;
@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS audit_log_no_update ON audit_log;
+
+DROP FUNCTION IF EXISTS audit_log_prevent_change;
+
+DROP TABLE IF EXISTS audit_log;
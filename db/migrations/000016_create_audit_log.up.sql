@@ -0,0 +1,29 @@
+CREATE TABLE
+    IF NOT EXISTS audit_log (
+        id BIGSERIAL PRIMARY KEY,
+        actor_id INTEGER,
+        action TEXT NOT NULL,
+        target_type TEXT NOT NULL,
+        target_id INTEGER,
+        before_data JSONB,
+        after_data JSONB,
+        ip_address TEXT,
+        user_agent TEXT,
+        request_id TEXT,
+        created_at TIMESTAMP DEFAULT NOW ()
+    );
+
+CREATE INDEX IF NOT EXISTS audit_log_actor_idx ON audit_log (actor_id, created_at);
+
+CREATE INDEX IF NOT EXISTS audit_log_target_idx ON audit_log (target_type, target_id, created_at);
+
+-- the audit log is append only, rows can never be changed or removed.
+-- actor_id has no foreign key so entries outlive deleted users
+CREATE OR REPLACE FUNCTION audit_log_prevent_change () RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE OR DELETE ON audit_log FOR EACH ROW
+EXECUTE FUNCTION audit_log_prevent_change ();
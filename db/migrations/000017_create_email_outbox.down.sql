@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS email_outbox;
+
+DROP TYPE IF EXISTS email_outbox_status;
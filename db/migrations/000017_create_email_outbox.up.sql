@@ -0,0 +1,21 @@
+CREATE TYPE email_outbox_status AS ENUM ('pending', 'sending', 'sent', 'dead');
+
+CREATE TABLE
+    IF NOT EXISTS email_outbox (
+        id BIGSERIAL PRIMARY KEY,
+        idempotency_key TEXT UNIQUE NOT NULL,
+        template TEXT NOT NULL,
+        to_email TEXT NOT NULL,
+        subject TEXT NOT NULL,
+        payload JSONB,
+        status email_outbox_status NOT NULL DEFAULT 'pending',
+        attempts INTEGER NOT NULL DEFAULT 0,
+        max_attempts INTEGER NOT NULL DEFAULT 8,
+        next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW (),
+        locked_until TIMESTAMP,
+        last_error TEXT,
+        created_at TIMESTAMP DEFAULT NOW (),
+        sent_at TIMESTAMP
+    );
+
+CREATE INDEX IF NOT EXISTS email_outbox_due_idx ON email_outbox (status, next_attempt_at);
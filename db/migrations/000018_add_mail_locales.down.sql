@@ -0,0 +1,10 @@
+UPDATE email_outbox SET subject = template WHERE subject IS NULL;
+
+ALTER TABLE email_outbox
+ALTER COLUMN subject SET NOT NULL;
+
+ALTER TABLE email_outbox
+DROP COLUMN IF EXISTS locale;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS language;
@@ -0,0 +1,9 @@
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS language TEXT NOT NULL DEFAULT 'en';
+
+ALTER TABLE email_outbox
+ADD COLUMN IF NOT EXISTS locale TEXT NOT NULL DEFAULT 'en';
+
+-- the subject is rendered from the localized template unless explicitly overridden
+ALTER TABLE email_outbox
+ALTER COLUMN subject DROP NOT NULL;
@@ -0,0 +1,37 @@
+CREATE TYPE notification_type AS ENUM (
+    'blog_like',
+    'blog_comment',
+    'comment_reply',
+    'comment_like',
+    'follow'
+);
+
+-- one row per recipient and group key, e.g. every like on one blog aggregates into a single notification
+CREATE TABLE
+    IF NOT EXISTS notifications (
+        id SERIAL PRIMARY KEY,
+        recipient_id INTEGER NOT NULL,
+        notification_type notification_type NOT NULL,
+        group_key TEXT NOT NULL,
+        blog_id INTEGER,
+        comment_id INTEGER,
+        is_read BOOLEAN NOT NULL DEFAULT FALSE,
+        notification_created_at TIMESTAMP DEFAULT NOW (),
+        notification_updated_at TIMESTAMP DEFAULT NOW (),
+        FOREIGN KEY (recipient_id) REFERENCES users (id) ON DELETE CASCADE,
+        FOREIGN KEY (blog_id) REFERENCES blogs (id) ON DELETE CASCADE,
+        FOREIGN KEY (comment_id) REFERENCES blog_comments (id) ON DELETE CASCADE,
+        UNIQUE (recipient_id, group_key)
+    );
+
+CREATE INDEX IF NOT EXISTS notifications_recipient_idx ON notifications (recipient_id, is_read, notification_updated_at);
+
+CREATE TABLE
+    IF NOT EXISTS notification_actors (
+        notification_id INTEGER NOT NULL,
+        actor_id INTEGER NOT NULL,
+        acted_at TIMESTAMP DEFAULT NOW (),
+        FOREIGN KEY (notification_id) REFERENCES notifications (id) ON DELETE CASCADE,
+        FOREIGN KEY (actor_id) REFERENCES users (id) ON DELETE CASCADE,
+        UNIQUE (notification_id, actor_id)
+    );
@@ -0,0 +1,9 @@
+ALTER TABLE users
+DROP COLUMN IF EXISTS last_digest_sent_at,
+DROP COLUMN IF EXISTS digest_frequency;
+
+DROP TYPE IF EXISTS digest_frequency;
+
+DROP TABLE IF EXISTS notification_preferences;
+
+DROP TYPE IF EXISTS notification_channel;
@@ -0,0 +1,17 @@
+CREATE TYPE notification_channel AS ENUM ('in_app', 'email', 'off');
+
+-- a missing row means the default channel, in_app
+CREATE TABLE
+    IF NOT EXISTS notification_preferences (
+        user_id INTEGER NOT NULL,
+        notification_type notification_type NOT NULL,
+        channel notification_channel NOT NULL DEFAULT 'in_app',
+        FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+        UNIQUE (user_id, notification_type)
+    );
+
+CREATE TYPE digest_frequency AS ENUM ('off', 'daily', 'weekly');
+
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS digest_frequency digest_frequency NOT NULL DEFAULT 'off',
+ADD COLUMN IF NOT EXISTS last_digest_sent_at TIMESTAMP;
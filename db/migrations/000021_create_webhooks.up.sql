@@ -0,0 +1,59 @@
+-- global endpoints are registered by admins and receive every event,
+-- user endpoints only receive events about the owner's own blogs and followers
+CREATE TABLE
+    IF NOT EXISTS webhook_endpoints (
+        id SERIAL PRIMARY KEY,
+        owner_id INTEGER NOT NULL,
+        url TEXT NOT NULL,
+        secret TEXT NOT NULL,
+        event_types TEXT[] NOT NULL,
+        is_global BOOLEAN NOT NULL DEFAULT FALSE,
+        is_active BOOLEAN NOT NULL DEFAULT TRUE,
+        consecutive_failures INTEGER NOT NULL DEFAULT 0,
+        disabled_at TIMESTAMP,
+        created_at TIMESTAMP DEFAULT NOW (),
+        updated_at TIMESTAMP DEFAULT NOW (),
+        FOREIGN KEY (owner_id) REFERENCES users (id) ON DELETE CASCADE
+    );
+
+CREATE INDEX IF NOT EXISTS webhook_endpoints_owner_idx ON webhook_endpoints (owner_id);
+
+CREATE TYPE webhook_delivery_status AS ENUM ('pending', 'sending', 'succeeded', 'dead');
+
+CREATE TABLE
+    IF NOT EXISTS webhook_deliveries (
+        id BIGSERIAL PRIMARY KEY,
+        endpoint_id INTEGER NOT NULL,
+        event_id TEXT NOT NULL,
+        event_type TEXT NOT NULL,
+        payload JSONB NOT NULL,
+        status webhook_delivery_status NOT NULL DEFAULT 'pending',
+        attempts INTEGER NOT NULL DEFAULT 0,
+        max_attempts INTEGER NOT NULL DEFAULT 8,
+        next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW (),
+        locked_until TIMESTAMP,
+        last_response_code INTEGER,
+        last_error TEXT,
+        replay_of BIGINT,
+        created_at TIMESTAMP DEFAULT NOW (),
+        delivered_at TIMESTAMP,
+        FOREIGN KEY (endpoint_id) REFERENCES webhook_endpoints (id) ON DELETE CASCADE,
+        FOREIGN KEY (replay_of) REFERENCES webhook_deliveries (id) ON DELETE SET NULL
+    );
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (status, next_attempt_at);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_endpoint_idx ON webhook_deliveries (endpoint_id, created_at);
+
+CREATE TABLE
+    IF NOT EXISTS webhook_delivery_attempts (
+        id BIGSERIAL PRIMARY KEY,
+        delivery_id BIGINT NOT NULL,
+        attempt INTEGER NOT NULL,
+        response_code INTEGER,
+        response_body TEXT,
+        error TEXT,
+        duration_ms INTEGER NOT NULL,
+        attempted_at TIMESTAMP DEFAULT NOW (),
+        FOREIGN KEY (delivery_id) REFERENCES webhook_deliveries (id) ON DELETE CASCADE
+    );
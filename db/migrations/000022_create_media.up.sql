@@ -0,0 +1,25 @@
+-- every upload is one media row, its variants (original, medium, thumbnail) are stored
+-- under storage_key and listed in variants. size is the total of all variants and counts
+-- towards the owner's quota
+CREATE TABLE
+    IF NOT EXISTS media (
+        id SERIAL PRIMARY KEY,
+        owner_id INTEGER NOT NULL,
+        storage_key TEXT NOT NULL UNIQUE,
+        url TEXT NOT NULL,
+        content_hash TEXT NOT NULL,
+        mime_type TEXT NOT NULL,
+        size BIGINT NOT NULL,
+        width INTEGER NOT NULL,
+        height INTEGER NOT NULL,
+        blurhash TEXT,
+        variants JSONB NOT NULL DEFAULT '{}',
+        -- set by the garbage collector when it first finds the media unreferenced
+        unreferenced_since TIMESTAMP,
+        created_at TIMESTAMP DEFAULT NOW (),
+        FOREIGN KEY (owner_id) REFERENCES users (id) ON DELETE CASCADE,
+        -- the same file uploaded twice by a user is stored once
+        UNIQUE (owner_id, content_hash)
+    );
+
+CREATE INDEX IF NOT EXISTS media_owner_created_at_idx ON media (owner_id, created_at DESC);
@@ -0,0 +1,21 @@
+-- a direct upload the client was allowed to make to the blob store, the file at storage_key
+-- becomes media once the upload is completed. expired intents that were never completed are
+-- deleted along with their file by the media garbage collector
+CREATE TABLE
+    IF NOT EXISTS upload_intents (
+        id SERIAL PRIMARY KEY,
+        owner_id INTEGER NOT NULL,
+        storage_key TEXT NOT NULL UNIQUE,
+        content_type TEXT NOT NULL,
+        size BIGINT NOT NULL,
+        media_id INTEGER,
+        expires_at TIMESTAMP NOT NULL,
+        completed_at TIMESTAMP,
+        created_at TIMESTAMP DEFAULT NOW (),
+        FOREIGN KEY (owner_id) REFERENCES users (id) ON DELETE CASCADE,
+        FOREIGN KEY (media_id) REFERENCES media (id) ON DELETE SET NULL
+    );
+
+CREATE INDEX IF NOT EXISTS upload_intents_expires_at_idx ON upload_intents (expires_at)
+WHERE
+    completed_at IS NULL;
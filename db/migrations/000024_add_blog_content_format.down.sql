@@ -0,0 +1,7 @@
+ALTER TABLE blogs
+DROP COLUMN IF EXISTS blog_content_markdown;
+
+ALTER TABLE blogs
+DROP COLUMN IF EXISTS blog_content_format;
+
+DROP TYPE IF EXISTS blog_content_format;
@@ -0,0 +1,9 @@
+CREATE TYPE blog_content_format AS ENUM ('json', 'markdown');
+
+-- blog_content is always the document, blogs written in markdown also keep their source
+-- so it can be edited again
+ALTER TABLE blogs
+ADD COLUMN IF NOT EXISTS blog_content_format blog_content_format NOT NULL DEFAULT 'json';
+
+ALTER TABLE blogs
+ADD COLUMN IF NOT EXISTS blog_content_markdown TEXT;
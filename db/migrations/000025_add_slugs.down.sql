@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS blog_slug_history;
+
+DROP INDEX IF EXISTS topics_topic_slug_idx;
+
+ALTER TABLE topics
+DROP COLUMN IF EXISTS topic_slug;
+
+DROP INDEX IF EXISTS blogs_blog_slug_idx;
+
+ALTER TABLE blogs
+DROP COLUMN IF EXISTS blog_slug;
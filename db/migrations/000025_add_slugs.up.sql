@@ -0,0 +1,85 @@
+-- existing blogs and topics get a slug from their title, a title that is already taken
+-- or has no letters or digits gets the id appended
+ALTER TABLE blogs
+ADD COLUMN IF NOT EXISTS blog_slug TEXT;
+
+UPDATE blogs AS b
+SET
+    blog_slug = s.slug
+FROM
+    (
+        SELECT
+            id,
+            CASE
+                WHEN base = '' THEN 'blog-' || id
+                WHEN ROW_NUMBER() OVER (
+                    PARTITION BY base
+                    ORDER BY id
+                ) > 1 THEN base || '-' || id
+                ELSE base
+            END AS slug
+        FROM
+            (
+                SELECT
+                    id,
+                    TRIM(BOTH '-' FROM LEFT(TRIM(BOTH '-' FROM LOWER(REGEXP_REPLACE(blog_title, '[^[:alnum:]]+', '-', 'g'))), 80)) AS base
+                FROM
+                    blogs
+            ) AS t
+    ) AS s
+WHERE
+    b.id = s.id
+    AND b.blog_slug IS NULL;
+
+ALTER TABLE blogs
+ALTER COLUMN blog_slug SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS blogs_blog_slug_idx ON blogs (blog_slug);
+
+ALTER TABLE topics
+ADD COLUMN IF NOT EXISTS topic_slug TEXT;
+
+UPDATE topics AS tp
+SET
+    topic_slug = s.slug
+FROM
+    (
+        SELECT
+            id,
+            CASE
+                WHEN base = '' THEN 'topic-' || id
+                WHEN ROW_NUMBER() OVER (
+                    PARTITION BY base
+                    ORDER BY id
+                ) > 1 THEN base || '-' || id
+                ELSE base
+            END AS slug
+        FROM
+            (
+                SELECT
+                    id,
+                    TRIM(BOTH '-' FROM LEFT(TRIM(BOTH '-' FROM LOWER(REGEXP_REPLACE(topic_title, '[^[:alnum:]]+', '-', 'g'))), 80)) AS base
+                FROM
+                    topics
+            ) AS t
+    ) AS s
+WHERE
+    tp.id = s.id
+    AND tp.topic_slug IS NULL;
+
+ALTER TABLE topics
+ALTER COLUMN topic_slug SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS topics_topic_slug_idx ON topics (topic_slug);
+
+-- slugs a blog had before its title changed, so old links redirect to the current slug.
+-- a slug stays reserved for its blog until the blog is deleted
+CREATE TABLE
+    IF NOT EXISTS blog_slug_history (
+        slug TEXT PRIMARY KEY,
+        blog_id INTEGER NOT NULL,
+        replaced_at TIMESTAMP DEFAULT NOW (),
+        FOREIGN KEY (blog_id) REFERENCES blogs (id) ON DELETE CASCADE
+    );
+
+CREATE INDEX IF NOT EXISTS blog_slug_history_blog_id_idx ON blog_slug_history (blog_id);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS blog_referrer_rollups;
+
+DROP TABLE IF EXISTS blog_view_rollups;
+
+DROP TABLE IF EXISTS blog_view_events;
@@ -0,0 +1,38 @@
+-- one row per viewer of a blog per dedupe window, a read in the same window raises read_percent.
+-- viewer_hash is a keyed hash of the viewer that changes every window, so viewers can not be
+-- followed across windows. events are rolled up hourly and deleted after a while
+CREATE TABLE
+    IF NOT EXISTS blog_view_events (
+        id BIGSERIAL PRIMARY KEY,
+        blog_id INTEGER NOT NULL,
+        viewer_hash TEXT NOT NULL,
+        window_start TIMESTAMP NOT NULL,
+        read_percent SMALLINT NOT NULL DEFAULT 0,
+        referrer_domain TEXT,
+        viewed_at TIMESTAMP NOT NULL DEFAULT NOW (),
+        FOREIGN KEY (blog_id) REFERENCES blogs (id) ON DELETE CASCADE,
+        UNIQUE (blog_id, viewer_hash, window_start)
+    );
+
+CREATE INDEX IF NOT EXISTS blog_view_events_viewed_at_idx ON blog_view_events (viewed_at);
+
+CREATE TABLE
+    IF NOT EXISTS blog_view_rollups (
+        blog_id INTEGER NOT NULL,
+        hour TIMESTAMP NOT NULL,
+        views INTEGER NOT NULL DEFAULT 0,
+        reads INTEGER NOT NULL DEFAULT 0,
+        read_percent_total BIGINT NOT NULL DEFAULT 0,
+        PRIMARY KEY (blog_id, hour),
+        FOREIGN KEY (blog_id) REFERENCES blogs (id) ON DELETE CASCADE
+    );
+
+CREATE TABLE
+    IF NOT EXISTS blog_referrer_rollups (
+        blog_id INTEGER NOT NULL,
+        hour TIMESTAMP NOT NULL,
+        referrer_domain TEXT NOT NULL,
+        views INTEGER NOT NULL DEFAULT 0,
+        PRIMARY KEY (blog_id, hour, referrer_domain),
+        FOREIGN KEY (blog_id) REFERENCES blogs (id) ON DELETE CASCADE
+    );
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS author_daily_follower_stats;
+
+DROP TABLE IF EXISTS blog_daily_stats;
+
+DROP TABLE IF EXISTS follow_events;
+
+DROP TYPE IF EXISTS follow_event_type;
@@ -0,0 +1,53 @@
+-- an unfollow deletes the follow, so follows and unfollows are also kept as events for
+-- the follower history of authors. existing follows are the first events
+DROP TYPE IF EXISTS follow_event_type;
+CREATE TYPE follow_event_type AS ENUM ('follow', 'unfollow');
+
+CREATE TABLE
+    IF NOT EXISTS follow_events (
+        id BIGSERIAL PRIMARY KEY,
+        follower_id INTEGER NOT NULL,
+        following_id INTEGER NOT NULL,
+        event_type follow_event_type NOT NULL,
+        created_at TIMESTAMP DEFAULT NOW (),
+        FOREIGN KEY (following_id) REFERENCES users (id) ON DELETE CASCADE
+    );
+
+CREATE INDEX IF NOT EXISTS follow_events_created_at_idx ON follow_events (created_at);
+
+INSERT INTO
+    follow_events (follower_id, following_id, event_type, created_at)
+SELECT
+    follower_id,
+    following_id,
+    'follow',
+    COALESCE(followed_at, NOW ())
+FROM
+    follows;
+
+-- daily activity on every blog and the followers of every author, rolled up from the hourly
+-- view rollups, likes, comments, bookmarks and follow events for the author dashboard
+CREATE TABLE
+    IF NOT EXISTS blog_daily_stats (
+        blog_id INTEGER NOT NULL,
+        day DATE NOT NULL,
+        views INTEGER NOT NULL DEFAULT 0,
+        reads INTEGER NOT NULL DEFAULT 0,
+        likes INTEGER NOT NULL DEFAULT 0,
+        comments INTEGER NOT NULL DEFAULT 0,
+        bookmarks INTEGER NOT NULL DEFAULT 0,
+        PRIMARY KEY (blog_id, day),
+        FOREIGN KEY (blog_id) REFERENCES blogs (id) ON DELETE CASCADE
+    );
+
+CREATE INDEX IF NOT EXISTS blog_daily_stats_day_idx ON blog_daily_stats (day);
+
+CREATE TABLE
+    IF NOT EXISTS author_daily_follower_stats (
+        author_id INTEGER NOT NULL,
+        day DATE NOT NULL,
+        gained INTEGER NOT NULL DEFAULT 0,
+        lost INTEGER NOT NULL DEFAULT 0,
+        PRIMARY KEY (author_id, day),
+        FOREIGN KEY (author_id) REFERENCES users (id) ON DELETE CASCADE
+    );
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS trending_snapshots;
+
+DROP TABLE IF EXISTS trending_topics;
+
+DROP TABLE IF EXISTS trending_blogs;
+
+DROP TYPE IF EXISTS trending_window;
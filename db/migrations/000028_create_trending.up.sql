@@ -0,0 +1,40 @@
+DROP TYPE IF EXISTS trending_window;
+CREATE TYPE trending_window AS ENUM ('1h', '24h', '7d');
+
+-- snapshots of the trending blogs and topics of every window, replaced whenever trending is refreshed.
+-- counts are of the window, score is its weighted activity per hour plus how much faster that is
+-- than in the window before
+CREATE TABLE
+    IF NOT EXISTS trending_blogs (
+        trending_window trending_window NOT NULL,
+        rank INTEGER NOT NULL,
+        blog_id INTEGER NOT NULL,
+        score DOUBLE PRECISION NOT NULL,
+        likes INTEGER NOT NULL DEFAULT 0,
+        comments INTEGER NOT NULL DEFAULT 0,
+        bookmarks INTEGER NOT NULL DEFAULT 0,
+        views INTEGER NOT NULL DEFAULT 0,
+        PRIMARY KEY (trending_window, rank),
+        FOREIGN KEY (blog_id) REFERENCES blogs (id) ON DELETE CASCADE
+    );
+
+CREATE TABLE
+    IF NOT EXISTS trending_topics (
+        trending_window trending_window NOT NULL,
+        rank INTEGER NOT NULL,
+        topic_id INTEGER NOT NULL,
+        score DOUBLE PRECISION NOT NULL,
+        blogs INTEGER NOT NULL DEFAULT 0,
+        likes INTEGER NOT NULL DEFAULT 0,
+        comments INTEGER NOT NULL DEFAULT 0,
+        bookmarks INTEGER NOT NULL DEFAULT 0,
+        views INTEGER NOT NULL DEFAULT 0,
+        PRIMARY KEY (trending_window, rank),
+        FOREIGN KEY (topic_id) REFERENCES topics (id) ON DELETE CASCADE
+    );
+
+CREATE TABLE
+    IF NOT EXISTS trending_snapshots (
+        trending_window trending_window PRIMARY KEY,
+        refreshed_at TIMESTAMP NOT NULL
+    );
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS related_blogs;
+
+DROP TABLE IF EXISTS blog_reads;
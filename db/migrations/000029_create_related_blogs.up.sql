@@ -0,0 +1,30 @@
+-- how far signed in users got through each blog they opened, kept apart from the anonymous view events
+-- so recommendations can leave out what a user already read
+CREATE TABLE
+    IF NOT EXISTS blog_reads (
+        user_id INTEGER NOT NULL,
+        blog_id INTEGER NOT NULL,
+        read_percent SMALLINT NOT NULL DEFAULT 0,
+        last_read_at TIMESTAMP NOT NULL DEFAULT NOW (),
+        PRIMARY KEY (user_id, blog_id),
+        FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+        FOREIGN KEY (blog_id) REFERENCES blogs (id) ON DELETE CASCADE
+    );
+
+-- the blogs most related to every blog, recomputed by the related blogs job. the score combines
+-- shared topics, similar text and readers that liked both
+CREATE TABLE
+    IF NOT EXISTS related_blogs (
+        blog_id INTEGER NOT NULL,
+        related_blog_id INTEGER NOT NULL,
+        score DOUBLE PRECISION NOT NULL,
+        topic_score DOUBLE PRECISION NOT NULL DEFAULT 0,
+        text_score DOUBLE PRECISION NOT NULL DEFAULT 0,
+        engagement_score DOUBLE PRECISION NOT NULL DEFAULT 0,
+        computed_at TIMESTAMP NOT NULL DEFAULT NOW (),
+        PRIMARY KEY (blog_id, related_blog_id),
+        FOREIGN KEY (blog_id) REFERENCES blogs (id) ON DELETE CASCADE,
+        FOREIGN KEY (related_blog_id) REFERENCES blogs (id) ON DELETE CASCADE
+    );
+
+CREATE INDEX IF NOT EXISTS related_blogs_blog_id_score_idx ON related_blogs (blog_id, score DESC);
@@ -0,0 +1,10 @@
+-- authors a user asked not to be suggested to follow again
+CREATE TABLE
+    IF NOT EXISTS dismissed_suggestions (
+        user_id INTEGER NOT NULL,
+        suggested_user_id INTEGER NOT NULL,
+        dismissed_at TIMESTAMP NOT NULL DEFAULT NOW (),
+        PRIMARY KEY (user_id, suggested_user_id),
+        FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+        FOREIGN KEY (suggested_user_id) REFERENCES users (id) ON DELETE CASCADE
+    );
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS bookmark_collection_items;
+
+DROP TABLE IF EXISTS bookmark_collections;
+
+DROP TYPE IF EXISTS collection_visibility;
+
+DROP INDEX IF EXISTS blog_bookmarks_bookmarked_by_id_bookmarked_at_idx;
+
+ALTER TABLE blog_bookmarks
+DROP COLUMN IF EXISTS note;
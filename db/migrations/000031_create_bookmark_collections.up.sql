@@ -0,0 +1,37 @@
+-- a private note the user keeps on a blog they bookmarked
+ALTER TABLE blog_bookmarks
+ADD COLUMN IF NOT EXISTS note TEXT;
+
+CREATE INDEX IF NOT EXISTS blog_bookmarks_bookmarked_by_id_bookmarked_at_idx ON blog_bookmarks (bookmarked_by_id, bookmarked_at DESC);
+
+DROP TYPE IF EXISTS collection_visibility;
+CREATE TYPE collection_visibility AS ENUM ('private', 'public');
+
+-- named reading lists of a user's bookmarks, public collections can be viewed by anyone
+CREATE TABLE
+    IF NOT EXISTS bookmark_collections (
+        id SERIAL PRIMARY KEY,
+        owner_id INTEGER NOT NULL,
+        name TEXT NOT NULL,
+        description TEXT,
+        visibility collection_visibility NOT NULL DEFAULT 'private',
+        created_at TIMESTAMP DEFAULT NOW (),
+        updated_at TIMESTAMP DEFAULT NOW (),
+        FOREIGN KEY (owner_id) REFERENCES users (id) ON DELETE CASCADE,
+        UNIQUE (owner_id, name)
+    );
+
+-- the bookmarks in a collection in the owner's order, removing a bookmark takes it out of every collection
+CREATE TABLE
+    IF NOT EXISTS bookmark_collection_items (
+        collection_id INTEGER NOT NULL,
+        user_id INTEGER NOT NULL,
+        blog_id INTEGER NOT NULL,
+        position INTEGER NOT NULL,
+        added_at TIMESTAMP DEFAULT NOW (),
+        PRIMARY KEY (collection_id, blog_id),
+        FOREIGN KEY (collection_id) REFERENCES bookmark_collections (id) ON DELETE CASCADE,
+        FOREIGN KEY (user_id, blog_id) REFERENCES blog_bookmarks (bookmarked_by_id, bookmarked_blog_id) ON DELETE CASCADE
+    );
+
+CREATE INDEX IF NOT EXISTS bookmark_collection_items_user_id_blog_id_idx ON bookmark_collection_items (user_id, blog_id);
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS series_follows;
+
+DROP TABLE IF EXISTS series_blogs;
+
+DROP TABLE IF EXISTS series;
+
+-- enum values can not be dropped, series_part notifications are removed so none are left unreadable
+DELETE FROM notification_preferences WHERE notification_type = 'series_part';
+
+DELETE FROM notifications WHERE notification_type = 'series_part';
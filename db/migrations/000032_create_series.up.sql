@@ -0,0 +1,38 @@
+ALTER TYPE notification_type ADD VALUE IF NOT EXISTS 'series_part';
+
+-- an author's blogs grouped into an ordered sequence, e.g. a multi-part tutorial
+CREATE TABLE
+    IF NOT EXISTS series (
+        id SERIAL PRIMARY KEY,
+        author_id INTEGER NOT NULL,
+        title TEXT NOT NULL,
+        description TEXT,
+        created_at TIMESTAMP DEFAULT NOW (),
+        updated_at TIMESTAMP DEFAULT NOW (),
+        FOREIGN KEY (author_id) REFERENCES users (id) ON DELETE CASCADE
+    );
+
+CREATE INDEX IF NOT EXISTS series_author_id_idx ON series (author_id);
+
+-- a blog is a part of at most one series
+CREATE TABLE
+    IF NOT EXISTS series_blogs (
+        series_id INTEGER NOT NULL,
+        blog_id INTEGER NOT NULL UNIQUE,
+        position INTEGER NOT NULL,
+        added_at TIMESTAMP DEFAULT NOW (),
+        PRIMARY KEY (series_id, blog_id),
+        FOREIGN KEY (series_id) REFERENCES series (id) ON DELETE CASCADE,
+        FOREIGN KEY (blog_id) REFERENCES blogs (id) ON DELETE CASCADE
+    );
+
+-- users notified when a new part is added to the series
+CREATE TABLE
+    IF NOT EXISTS series_follows (
+        series_id INTEGER NOT NULL,
+        user_id INTEGER NOT NULL,
+        followed_at TIMESTAMP DEFAULT NOW (),
+        PRIMARY KEY (series_id, user_id),
+        FOREIGN KEY (series_id) REFERENCES series (id) ON DELETE CASCADE,
+        FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+    );
@@ -0,0 +1,10 @@
+-- realtime events too large for a postgres NOTIFY payload, the notification only carries the id
+-- and every instance reads the event from here. rows are removed a few minutes after they are written
+CREATE TABLE
+    IF NOT EXISTS realtime_events (
+        id BIGSERIAL PRIMARY KEY,
+        payload TEXT NOT NULL,
+        created_at TIMESTAMP NOT NULL DEFAULT NOW ()
+    );
+
+CREATE INDEX IF NOT EXISTS realtime_events_created_at_idx ON realtime_events (created_at);
@@ -0,0 +1,39 @@
+-- the blogs and users that use a media, kept up to date when they are saved so the garbage
+-- collector does not have to search every blog for every media. a row references either a blog
+-- (its thumbnail or content) or a user (their image)
+CREATE TABLE
+    IF NOT EXISTS media_references (
+        media_id INTEGER NOT NULL,
+        blog_id INTEGER,
+        user_id INTEGER,
+        FOREIGN KEY (media_id) REFERENCES media (id) ON DELETE CASCADE,
+        FOREIGN KEY (blog_id) REFERENCES blogs (id) ON DELETE CASCADE,
+        FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+        CHECK ((blog_id IS NULL) <> (user_id IS NULL)),
+        UNIQUE (media_id, blog_id),
+        UNIQUE (media_id, user_id)
+    );
+
+CREATE INDEX IF NOT EXISTS media_references_blog_idx ON media_references (blog_id);
+
+CREATE INDEX IF NOT EXISTS media_references_user_idx ON media_references (user_id);
+
+-- references of everything saved before they were recorded
+INSERT INTO
+    media_references (media_id, blog_id)
+SELECT
+    m.id,
+    b.id
+FROM
+    media m
+    INNER JOIN blogs b ON position(m.storage_key in b.blog_thumbnail) > 0
+    OR position(m.storage_key in b.blog_content) > 0 ON CONFLICT DO NOTHING;
+
+INSERT INTO
+    media_references (media_id, user_id)
+SELECT
+    m.id,
+    u.id
+FROM
+    media m
+    INNER JOIN users u ON position(m.storage_key in u.image_url) > 0 ON CONFLICT DO NOTHING;
@@ -0,0 +1,18 @@
+DROP INDEX IF EXISTS upload_intents_due_idx;
+
+ALTER TABLE upload_intents
+DROP COLUMN IF EXISTS error;
+
+ALTER TABLE upload_intents
+DROP COLUMN IF EXISTS locked_until;
+
+ALTER TABLE upload_intents
+DROP COLUMN IF EXISTS next_attempt_at;
+
+ALTER TABLE upload_intents
+DROP COLUMN IF EXISTS attempts;
+
+ALTER TABLE upload_intents
+DROP COLUMN IF EXISTS status;
+
+DROP TYPE IF EXISTS upload_intent_status;
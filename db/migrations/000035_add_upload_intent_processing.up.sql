@@ -0,0 +1,25 @@
+-- completing an upload intent only checks the uploaded file and queues it, the media is
+-- created from it by a background worker. 'created' intents are waiting for their upload
+CREATE TYPE upload_intent_status AS ENUM ('created', 'queued', 'processing', 'completed', 'failed');
+
+ALTER TABLE upload_intents
+ADD COLUMN IF NOT EXISTS status upload_intent_status NOT NULL DEFAULT 'created';
+
+ALTER TABLE upload_intents
+ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE upload_intents
+ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMP;
+
+ALTER TABLE upload_intents
+ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP;
+
+-- why processing failed, shown to the client
+ALTER TABLE upload_intents
+ADD COLUMN IF NOT EXISTS error TEXT;
+
+UPDATE upload_intents SET status = 'completed' WHERE completed_at IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS upload_intents_due_idx ON upload_intents (next_attempt_at)
+WHERE
+    status IN ('queued', 'processing');
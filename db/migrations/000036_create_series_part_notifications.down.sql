@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS series_part_notifications;
+
+DROP TYPE IF EXISTS series_part_notification_status;
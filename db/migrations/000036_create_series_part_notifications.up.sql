@@ -0,0 +1,35 @@
+-- blogs added to a series whose followers still have to be notified, written in the same transaction
+-- as the series_blogs row and fanned out by a background worker. a blog is announced only the first
+-- time it is added to a series, removing it and adding it again does not notify the followers twice
+CREATE TYPE series_part_notification_status AS ENUM ('pending', 'sending', 'sent', 'dead');
+
+CREATE TABLE
+    IF NOT EXISTS series_part_notifications (
+        id BIGSERIAL PRIMARY KEY,
+        series_id INTEGER NOT NULL,
+        blog_id INTEGER NOT NULL,
+        status series_part_notification_status NOT NULL DEFAULT 'pending',
+        attempts INTEGER NOT NULL DEFAULT 0,
+        max_attempts INTEGER NOT NULL DEFAULT 8,
+        next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW (),
+        locked_until TIMESTAMP,
+        last_error TEXT,
+        created_at TIMESTAMP DEFAULT NOW (),
+        sent_at TIMESTAMP,
+        FOREIGN KEY (series_id) REFERENCES series (id) ON DELETE CASCADE,
+        FOREIGN KEY (blog_id) REFERENCES blogs (id) ON DELETE CASCADE,
+        UNIQUE (series_id, blog_id)
+    );
+
+CREATE INDEX IF NOT EXISTS series_part_notifications_due_idx ON series_part_notifications (status, next_attempt_at);
+
+-- parts added before this migration were already announced
+INSERT INTO
+    series_part_notifications (series_id, blog_id, status, sent_at)
+SELECT
+    series_id,
+    blog_id,
+    'sent',
+    added_at
+FROM
+    series_blogs ON CONFLICT (series_id, blog_id) DO NOTHING;
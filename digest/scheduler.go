@@ -0,0 +1,104 @@
+package digest
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dhruv15803/echo-blog-app/outbox"
+	"github.com/dhruv15803/echo-blog-app/storage"
+)
+
+const (
+	defaultInterval  = time.Minute * 5
+	defaultBatchSize = 100
+	digestBlogsLimit = 5
+)
+
+// Scheduler periodically queues daily and weekly digest mails for the users that are due one.
+// mails go through the email outbox, so delivery, retries and rendering are shared with every other mail.
+// several instances can run a scheduler, storage.RecordDigest makes sure each digest is queued once
+type Scheduler struct {
+	storage           *storage.Storage
+	unsubscribeSecret []byte
+	interval          time.Duration
+	batchSize         int
+}
+
+func NewScheduler(storage *storage.Storage, unsubscribeSecret []byte) *Scheduler {
+	return &Scheduler{
+		storage:           storage,
+		unsubscribeSecret: unsubscribeSecret,
+		interval:          defaultInterval,
+		batchSize:         defaultBatchSize,
+	}
+}
+
+// Run queues due digests every interval until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+
+	for {
+		s.queueDueDigests()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.interval):
+		}
+	}
+}
+
+func (s *Scheduler) queueDueDigests() {
+
+	recipients, err := s.storage.GetDueDigestRecipients(s.batchSize)
+	if err != nil {
+		log.Printf("failed to get due digest recipients :- %v\n", err.Error())
+		return
+	}
+
+	for _, recipient := range recipients {
+		if err := s.queueDigest(recipient); err != nil {
+			log.Printf("failed to queue digest for user %d :- %v\n", recipient.Id, err.Error())
+		}
+	}
+}
+
+func (s *Scheduler) queueDigest(recipient storage.DigestRecipient) error {
+
+	now := time.Now()
+	since := now.Add(-recipient.DigestFrequency.Period())
+
+	activity, err := s.storage.GetDigestActivity(recipient.Id, since)
+	if err != nil {
+		return err
+	}
+
+	blogs, err := s.storage.GetDigestBlogs(recipient.Id, since, digestBlogsLimit)
+	if err != nil {
+		return err
+	}
+
+	// nothing happened, the digest is recorded without sending an empty mail
+	var mail *storage.NewOutboxEmail
+
+	if len(activity) > 0 || len(blogs) > 0 {
+		mail = &storage.NewOutboxEmail{
+			IdempotencyKey: fmt.Sprintf("digest:%d:%s", recipient.Id, now.UTC().Format(time.DateOnly)),
+			Template:       storage.DigestEmailTemplate,
+			ToEmail:        recipient.Email,
+			Locale:         recipient.Language,
+			Payload: outbox.DigestMailPayload{
+				Frequency:        recipient.DigestFrequency,
+				Activity:         activity,
+				Blogs:            blogs,
+				UnsubscribeToken: SignUnsubscribeToken(s.unsubscribeSecret, recipient.Id),
+			},
+			// a digest that cannot be delivered soon is stale anyway
+			MaxAttempts: 3,
+		}
+	}
+
+	_, err = s.storage.RecordDigest(recipient.Id, mail)
+	return err
+}
@@ -0,0 +1,46 @@
+package digest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidUnsubscribeToken = errors.New("invalid unsubscribe token")
+
+// SignUnsubscribeToken returns a token of the form <userId>.<signature>. it does not expire
+// so that an unsubscribe link in an old digest keeps working
+func SignUnsubscribeToken(secret []byte, userId int) string {
+	return fmt.Sprintf("%d.%s", userId, unsubscribeSignature(secret, userId))
+}
+
+func VerifyUnsubscribeToken(secret []byte, token string) (int, error) {
+
+	userIdPart, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return 0, ErrInvalidUnsubscribeToken
+	}
+
+	userId, err := strconv.Atoi(userIdPart)
+	if err != nil {
+		return 0, ErrInvalidUnsubscribeToken
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(unsubscribeSignature(secret, userId))) {
+		return 0, ErrInvalidUnsubscribeToken
+	}
+
+	return userId, nil
+}
+
+func unsubscribeSignature(secret []byte, userId int) string {
+
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "unsubscribe:digest:%d", userId)
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,137 @@
+// Package document is the model of blog_content, the JSON document written by the editor.
+// a document is a tree of nodes like
+//
+//	{"type":"doc","content":[{"type":"paragraph","content":[{"type":"text","text":"hi","marks":[{"type":"bold"}]}]}]}
+//
+// block nodes hold other nodes in content, text nodes hold text and marks
+package document
+
+import (
+	"math"
+	"strings"
+)
+
+type NodeType string
+
+const (
+	DocNode            NodeType = "doc"
+	ParagraphNode      NodeType = "paragraph"
+	HeadingNode        NodeType = "heading"
+	BlockquoteNode     NodeType = "blockquote"
+	BulletListNode     NodeType = "bullet_list"
+	OrderedListNode    NodeType = "ordered_list"
+	ListItemNode       NodeType = "list_item"
+	TaskListNode       NodeType = "task_list"
+	TaskItemNode       NodeType = "task_item"
+	CodeBlockNode      NodeType = "code_block"
+	HorizontalRuleNode NodeType = "horizontal_rule"
+	ImageNode          NodeType = "image"
+	EmbedNode          NodeType = "embed"
+	TableNode          NodeType = "table"
+	TableRowNode       NodeType = "table_row"
+	TableHeaderNode    NodeType = "table_header"
+	TableCellNode      NodeType = "table_cell"
+	TextNode           NodeType = "text"
+	HardBreakNode      NodeType = "hard_break"
+)
+
+type MarkType string
+
+const (
+	BoldMark      MarkType = "bold"
+	ItalicMark    MarkType = "italic"
+	UnderlineMark MarkType = "underline"
+	StrikeMark    MarkType = "strike"
+	CodeMark      MarkType = "code"
+	LinkMark      MarkType = "link"
+)
+
+type Node struct {
+	Type    NodeType       `json:"type"`
+	Attrs   map[string]any `json:"attrs,omitempty"`
+	Content []Node         `json:"content,omitempty"`
+	Text    string         `json:"text,omitempty"`
+	Marks   []Mark         `json:"marks,omitempty"`
+}
+
+type Mark struct {
+	Type  MarkType       `json:"type"`
+	Attrs map[string]any `json:"attrs,omitempty"`
+}
+
+// StringAttr returns the attribute name if it is a string
+func (n Node) StringAttr(name string) string {
+	value, _ := n.Attrs[name].(string)
+	return value
+}
+
+// IntAttr returns the attribute name if it is a whole number, json numbers decode as float64
+func (n Node) IntAttr(name string) (int, bool) {
+
+	value, ok := n.Attrs[name].(float64)
+	if !ok || value != math.Trunc(value) || math.Abs(value) > math.MaxInt32 {
+		return 0, false
+	}
+
+	return int(value), true
+}
+
+// BoolAttr returns the attribute name if it is a boolean, false otherwise
+func (n Node) BoolAttr(name string) bool {
+	value, _ := n.Attrs[name].(bool)
+	return value
+}
+
+func (m Mark) StringAttr(name string) string {
+	value, _ := m.Attrs[name].(string)
+	return value
+}
+
+// Walk calls fn for n and every node below it, depth first in document order
+func (n Node) Walk(fn func(node Node)) {
+
+	fn(n)
+
+	for _, child := range n.Content {
+		child.Walk(fn)
+	}
+}
+
+// TextContent is the text of n and every node below it, without any separators
+func (n Node) TextContent() string {
+
+	var text []byte
+
+	n.Walk(func(node Node) {
+		text = append(text, node.Text...)
+	})
+
+	return string(text)
+}
+
+// FromText builds a document of plain paragraphs, one per block of text separated by a blank line.
+// it is used for blog content stored before documents were validated, which may be any text
+func FromText(text string) Node {
+
+	doc := Node{Type: DocNode}
+
+	for _, block := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+
+		paragraph := Node{Type: ParagraphNode}
+
+		for i, line := range strings.Split(strings.TrimSpace(block), "\n") {
+			if i > 0 {
+				paragraph.Content = append(paragraph.Content, Node{Type: HardBreakNode})
+			}
+			if line != "" {
+				paragraph.Content = append(paragraph.Content, Node{Type: TextNode, Text: line})
+			}
+		}
+
+		if len(paragraph.Content) > 0 {
+			doc.Content = append(doc.Content, paragraph)
+		}
+	}
+
+	return doc
+}
@@ -0,0 +1,443 @@
+package document
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// MaxSize is the largest encoded document accepted, in bytes
+	MaxSize = 512 << 10
+	// MaxDepth is how deep nodes may be nested, the doc node is at depth 1
+	MaxDepth = 16
+	// MaxNodes is the number of nodes a document may have in total
+	MaxNodes = 20000
+
+	maxUrlLength  = 2048
+	maxAttrLength = 500
+	// validation stops after this many errors, the document is rejected anyway
+	maxErrors = 50
+)
+
+// schemes allowed in the href of links and the src of images, links may also be relative
+var (
+	LinkSchemes  = []string{"http", "https", "mailto"}
+	ImageSchemes = []string{"http", "https"}
+)
+
+// EmbedHosts are the only hosts an embed can point to, embeds are always https
+var EmbedHosts = []string{
+	"www.youtube.com", "youtube.com", "youtu.be",
+	"vimeo.com", "player.vimeo.com",
+	"codepen.io", "gist.github.com",
+	"twitter.com", "x.com",
+}
+
+// TableAlignments are the values of the align attribute of table cells
+var TableAlignments = []string{"left", "center", "right"}
+
+var codeLanguagePattern = regexp.MustCompile(`^[a-zA-Z0-9_+#.-]{1,32}$`)
+
+type ValidationError struct {
+	// JSON pointer to the invalid node or value, e.g. /content/2/marks/0/attrs/href
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Path + " :- " + err.Message
+	}
+
+	return "invalid document :- " + strings.Join(messages, " , ")
+}
+
+var blockTypes = []NodeType{ParagraphNode, HeadingNode, BlockquoteNode, BulletListNode, OrderedListNode, TaskListNode, CodeBlockNode, HorizontalRuleNode, ImageNode, EmbedNode, TableNode}
+
+var inlineTypes = []NodeType{TextNode, HardBreakNode}
+
+type attrSpec struct {
+	required bool
+	check    func(value any) string
+}
+
+type nodeSpec struct {
+	// node types allowed in content, none if empty
+	children   []NodeType
+	minContent int
+	attrs      map[string]attrSpec
+	// text nodes inside this node cannot have marks
+	noMarks bool
+}
+
+var nodeSpecs = map[NodeType]nodeSpec{
+	DocNode:        {children: blockTypes, minContent: 1},
+	ParagraphNode:  {children: inlineTypes},
+	HeadingNode:    {children: inlineTypes, attrs: map[string]attrSpec{"level": {required: true, check: checkIntRange(1, 6)}}},
+	BlockquoteNode: {children: blockTypes, minContent: 1},
+	BulletListNode: {children: []NodeType{ListItemNode}, minContent: 1},
+	OrderedListNode: {children: []NodeType{ListItemNode}, minContent: 1, attrs: map[string]attrSpec{
+		"start": {check: checkIntRange(0, 1_000_000)},
+	}},
+	ListItemNode: {children: blockTypes, minContent: 1},
+	TaskListNode: {children: []NodeType{TaskItemNode}, minContent: 1},
+	TaskItemNode: {children: blockTypes, minContent: 1, attrs: map[string]attrSpec{
+		"checked": {required: true, check: checkBool},
+	}},
+	CodeBlockNode: {children: []NodeType{TextNode}, noMarks: true, attrs: map[string]attrSpec{
+		"language": {check: checkCodeLanguage},
+	}},
+	HorizontalRuleNode: {},
+	ImageNode: {attrs: map[string]attrSpec{
+		"src":   {required: true, check: checkUrl(ImageSchemes, false)},
+		"alt":   {check: checkString},
+		"title": {check: checkString},
+	}},
+	EmbedNode: {attrs: map[string]attrSpec{
+		"url": {required: true, check: checkEmbedUrl},
+	}},
+	// the first row of a table is its header, cells only hold inline content
+	TableNode:       {children: []NodeType{TableRowNode}, minContent: 1},
+	TableRowNode:    {children: []NodeType{TableHeaderNode, TableCellNode}, minContent: 1},
+	TableHeaderNode: {children: inlineTypes, attrs: map[string]attrSpec{"align": {check: checkOneOf(TableAlignments)}}},
+	TableCellNode:   {children: inlineTypes, attrs: map[string]attrSpec{"align": {check: checkOneOf(TableAlignments)}}},
+	TextNode:        {},
+	HardBreakNode:   {},
+}
+
+var markAttrs = map[MarkType]map[string]attrSpec{
+	BoldMark:      nil,
+	ItalicMark:    nil,
+	UnderlineMark: nil,
+	StrikeMark:    nil,
+	CodeMark:      nil,
+	LinkMark: {
+		"href":  {required: true, check: checkUrl(LinkSchemes, true)},
+		"title": {check: checkString},
+	},
+}
+
+// Parse decodes raw blog content and validates it, the error is ValidationErrors if the document is invalid
+func Parse(raw []byte) (*Node, error) {
+
+	if len(raw) > MaxSize {
+		return nil, ValidationErrors{{Path: "", Message: fmt.Sprintf("document is larger than %d bytes", MaxSize)}}
+	}
+
+	var doc Node
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, ValidationErrors{{Path: "", Message: "invalid json :- " + err.Error()}}
+	}
+
+	if decoder.More() {
+		return nil, ValidationErrors{{Path: "", Message: "unexpected data after the document"}}
+	}
+
+	if err := Validate(doc); err != nil {
+		return nil, err
+	}
+
+	return &doc, nil
+}
+
+// Validate checks doc against the schema, the error is ValidationErrors if it is invalid
+func Validate(doc Node) error {
+
+	v := validator{}
+
+	if doc.Type != DocNode {
+		v.fail("/type", fmt.Sprintf("document must be a %q node", DocNode))
+	} else {
+		v.validateNode(doc, "", 1, true)
+	}
+
+	if len(v.errors) > 0 {
+		return v.errors
+	}
+
+	return nil
+}
+
+type validator struct {
+	errors ValidationErrors
+	nodes  int
+}
+
+func (v *validator) fail(path string, message string) {
+	if len(v.errors) < maxErrors {
+		v.errors = append(v.errors, ValidationError{Path: path, Message: message})
+	}
+}
+
+func (v *validator) validateNode(node Node, path string, depth int, marksAllowed bool) {
+
+	v.nodes++
+	if v.nodes == MaxNodes+1 {
+		v.fail(path, fmt.Sprintf("document has more than %d nodes", MaxNodes))
+	}
+
+	spec, ok := nodeSpecs[node.Type]
+	if !ok {
+		v.fail(path+"/type", fmt.Sprintf("unknown node type %q", node.Type))
+		return
+	}
+
+	if depth > MaxDepth {
+		v.fail(path, fmt.Sprintf("nodes are nested deeper than %d", MaxDepth))
+		return
+	}
+
+	v.validateAttrs(node.Attrs, spec.attrs, path+"/attrs")
+
+	if node.Type == TextNode {
+		if node.Text == "" {
+			v.fail(path+"/text", "text nodes cannot be empty")
+		} else if !utf8.ValidString(node.Text) {
+			v.fail(path+"/text", "text is not valid utf-8")
+		}
+		if len(node.Content) > 0 {
+			v.fail(path+"/content", "text nodes cannot have content")
+		}
+		if len(node.Marks) > 0 && !marksAllowed {
+			v.fail(path+"/marks", "marks are not allowed here")
+			return
+		}
+		v.validateMarks(node.Marks, path+"/marks")
+		return
+	}
+
+	if node.Text != "" {
+		v.fail(path+"/text", fmt.Sprintf("%s nodes cannot have text", node.Type))
+	}
+
+	if len(node.Marks) > 0 {
+		v.fail(path+"/marks", fmt.Sprintf("%s nodes cannot have marks", node.Type))
+	}
+
+	if len(spec.children) == 0 && len(node.Content) > 0 {
+		v.fail(path+"/content", fmt.Sprintf("%s nodes cannot have content", node.Type))
+		return
+	}
+
+	if len(node.Content) < spec.minContent {
+		v.fail(path+"/content", fmt.Sprintf("%s nodes need at least %d child nodes", node.Type, spec.minContent))
+	}
+
+	for i, child := range node.Content {
+
+		childPath := path + "/content/" + strconv.Itoa(i)
+
+		if _, known := nodeSpecs[child.Type]; known && !slices.Contains(spec.children, child.Type) {
+			v.fail(childPath+"/type", fmt.Sprintf("%s nodes are not allowed in %s nodes", child.Type, node.Type))
+			continue
+		}
+
+		v.validateNode(child, childPath, depth+1, !spec.noMarks)
+	}
+}
+
+func (v *validator) validateMarks(marks []Mark, path string) {
+
+	seen := make(map[MarkType]bool, len(marks))
+
+	for i, mark := range marks {
+
+		markPath := path + "/" + strconv.Itoa(i)
+
+		attrs, ok := markAttrs[mark.Type]
+		if !ok {
+			v.fail(markPath+"/type", fmt.Sprintf("unknown mark type %q", mark.Type))
+			continue
+		}
+
+		if seen[mark.Type] {
+			v.fail(markPath+"/type", fmt.Sprintf("duplicate %s mark", mark.Type))
+			continue
+		}
+		seen[mark.Type] = true
+
+		v.validateAttrs(mark.Attrs, attrs, markPath+"/attrs")
+	}
+}
+
+func (v *validator) validateAttrs(attrs map[string]any, specs map[string]attrSpec, path string) {
+
+	// sorted so the errors come out in a stable order
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+
+		spec, ok := specs[name]
+		if !ok {
+			v.fail(path+"/"+name, fmt.Sprintf("unknown attribute %q", name))
+			continue
+		}
+
+		if message := spec.check(attrs[name]); message != "" {
+			v.fail(path+"/"+name, message)
+		}
+	}
+
+	required := make([]string, 0, len(specs))
+	for name, spec := range specs {
+		if _, ok := attrs[name]; spec.required && !ok {
+			required = append(required, name)
+		}
+	}
+	slices.Sort(required)
+
+	for _, name := range required {
+		v.fail(path+"/"+name, "attribute is required")
+	}
+}
+
+func checkString(value any) string {
+
+	s, ok := value.(string)
+	if !ok {
+		return "must be a string"
+	}
+
+	if len(s) > maxAttrLength {
+		return fmt.Sprintf("must be at most %d bytes", maxAttrLength)
+	}
+
+	return ""
+}
+
+func checkBool(value any) string {
+
+	if _, ok := value.(bool); !ok {
+		return "must be true or false"
+	}
+
+	return ""
+}
+
+func checkOneOf(values []string) func(value any) string {
+	return func(value any) string {
+
+		s, ok := value.(string)
+		if !ok || !slices.Contains(values, s) {
+			return fmt.Sprintf("must be one of %s", strings.Join(values, ", "))
+		}
+
+		return ""
+	}
+}
+
+func checkIntRange(min int, max int) func(value any) string {
+	return func(value any) string {
+
+		number, ok := Node{Attrs: map[string]any{"value": value}}.IntAttr("value")
+		if !ok || number < min || number > max {
+			return fmt.Sprintf("must be a whole number from %d to %d", min, max)
+		}
+
+		return ""
+	}
+}
+
+func checkCodeLanguage(value any) string {
+
+	language, ok := value.(string)
+	if !ok || !codeLanguagePattern.MatchString(language) {
+		return "must be a language name like go or c++"
+	}
+
+	return ""
+}
+
+// checkUrl allows absolute urls with one of schemes, and relative ones ("/path" or "#anchor") if allowRelative is set
+func checkUrl(schemes []string, allowRelative bool) func(value any) string {
+	return func(value any) string {
+
+		raw, ok := value.(string)
+		if !ok {
+			return "must be a string"
+		}
+
+		parsed, message := parseUrl(raw)
+		if message != "" {
+			return message
+		}
+
+		if parsed.Scheme == "" {
+			if allowRelative && ((strings.HasPrefix(raw, "/") && !strings.HasPrefix(raw, "//")) || strings.HasPrefix(raw, "#")) {
+				return ""
+			}
+			return "must be an absolute url"
+		}
+
+		if !slices.Contains(schemes, strings.ToLower(parsed.Scheme)) {
+			return fmt.Sprintf("url scheme must be one of %s", strings.Join(schemes, ", "))
+		}
+
+		if parsed.Scheme != "mailto" && parsed.Host == "" {
+			return "url must have a host"
+		}
+
+		return ""
+	}
+}
+
+func checkEmbedUrl(value any) string {
+
+	raw, ok := value.(string)
+	if !ok {
+		return "must be a string"
+	}
+
+	parsed, message := parseUrl(raw)
+	if message != "" {
+		return message
+	}
+
+	if parsed.Scheme != "https" {
+		return "embed urls must be https"
+	}
+
+	if !slices.Contains(EmbedHosts, strings.ToLower(parsed.Hostname())) {
+		return fmt.Sprintf("embeds are only allowed from %s", strings.Join(EmbedHosts, ", "))
+	}
+
+	return ""
+}
+
+func parseUrl(raw string) (*url.URL, string) {
+
+	if raw == "" || len(raw) > maxUrlLength {
+		return nil, fmt.Sprintf("url must be 1 to %d bytes", maxUrlLength)
+	}
+
+	// browsers ignore whitespace and control characters in urls, "java\tscript:" would slip past the scheme check
+	if strings.ContainsFunc(raw, func(r rune) bool { return r <= ' ' || r == 0x7f }) {
+		return nil, "url cannot contain whitespace or control characters"
+	}
+
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return nil, "invalid url"
+	}
+
+	return parsed, ""
+}
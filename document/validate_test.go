@@ -0,0 +1,189 @@
+package document
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// p wraps inline json in a document with a single paragraph
+func p(inline string) string {
+	return `{"type":"doc","content":[{"type":"paragraph","content":[` + inline + `]}]}`
+}
+
+// link is a paragraph with a single linked text node
+func link(href string) string {
+	return p(fmt.Sprintf(`{"type":"text","text":"x","marks":[{"type":"link","attrs":{"href":%q}}]}`, href))
+}
+
+func image(src string) string {
+	return fmt.Sprintf(`{"type":"doc","content":[{"type":"image","attrs":{"src":%q}}]}`, src)
+}
+
+func embed(url string) string {
+	return fmt.Sprintf(`{"type":"doc","content":[{"type":"embed","attrs":{"url":%q}}]}`, url)
+}
+
+// nested is a document with blockquotes nested depth deep around a paragraph
+func nested(depth int) string {
+	return `{"type":"doc","content":[` + strings.Repeat(`{"type":"blockquote","content":[`, depth) +
+		`{"type":"paragraph"}` + strings.Repeat(`]}`, depth) + `]}`
+}
+
+var parseTests = []struct {
+	name string
+	raw  string
+	want ValidationErrors
+}{
+	// valid documents
+	{name: "every block", raw: `{"type":"doc","content":[
+		{"type":"heading","attrs":{"level":2},"content":[{"type":"text","text":"Title"}]},
+		{"type":"paragraph"},
+		{"type":"blockquote","content":[{"type":"paragraph","content":[{"type":"text","text":"quote","marks":[{"type":"bold"},{"type":"italic"}]}]}]},
+		{"type":"bullet_list","content":[{"type":"list_item","content":[{"type":"paragraph","content":[{"type":"text","text":"a"}]}]}]},
+		{"type":"ordered_list","attrs":{"start":3},"content":[{"type":"list_item","content":[{"type":"paragraph"}]}]},
+		{"type":"task_list","content":[{"type":"task_item","attrs":{"checked":true},"content":[{"type":"paragraph"}]}]},
+		{"type":"code_block","attrs":{"language":"c++"},"content":[{"type":"text","text":"int main() {}"}]},
+		{"type":"horizontal_rule"},
+		{"type":"image","attrs":{"src":"https://cdn.example.com/a.png","alt":"a","title":"t"}},
+		{"type":"embed","attrs":{"url":"https://www.youtube.com/watch?v=abc"}},
+		{"type":"table","content":[{"type":"table_row","content":[
+			{"type":"table_header","attrs":{"align":"center"},"content":[{"type":"text","text":"h"}]},
+			{"type":"table_cell","content":[{"type":"text","text":"c"},{"type":"hard_break"},{"type":"text","text":"d"}]}
+		]}]}
+	]}`},
+	{name: "relative link", raw: link("/blogs/1")},
+	{name: "anchor link", raw: link("#section")},
+	{name: "mailto link", raw: link("mailto:someone@example.com")},
+	{name: "upper case scheme", raw: link("HTTPS://example.com")},
+	{name: "deepest nesting", raw: nested(MaxDepth - 2)},
+
+	// json
+	{name: "invalid json", raw: `{"type":`, want: ValidationErrors{{Path: "", Message: "invalid json :- unexpected EOF"}}},
+	{name: "unknown field", raw: `{"type":"doc","content":[{"type":"paragraph"}],"extra":1}`,
+		want: ValidationErrors{{Path: "", Message: `invalid json :- json: unknown field "extra"`}}},
+	{name: "trailing data", raw: p(``) + ` {}`, want: ValidationErrors{{Path: "", Message: "unexpected data after the document"}}},
+	{name: "too large", raw: p(fmt.Sprintf(`{"type":"text","text":%q}`, strings.Repeat("a", MaxSize))),
+		want: ValidationErrors{{Path: "", Message: fmt.Sprintf("document is larger than %d bytes", MaxSize)}}},
+
+	// structure
+	{name: "not a doc", raw: `{"type":"paragraph"}`, want: ValidationErrors{{Path: "/type", Message: `document must be a "doc" node`}}},
+	{name: "empty doc", raw: `{"type":"doc"}`, want: ValidationErrors{{Path: "/content", Message: "doc nodes need at least 1 child nodes"}}},
+	{name: "unknown node", raw: `{"type":"doc","content":[{"type":"marquee"}]}`,
+		want: ValidationErrors{{Path: "/content/0/type", Message: `unknown node type "marquee"`}}},
+	{name: "inline in doc", raw: `{"type":"doc","content":[{"type":"text","text":"loose"}]}`,
+		want: ValidationErrors{{Path: "/content/0/type", Message: "text nodes are not allowed in doc nodes"}}},
+	{name: "block in paragraph", raw: p(`{"type":"paragraph"}`),
+		want: ValidationErrors{{Path: "/content/0/content/0/type", Message: "paragraph nodes are not allowed in paragraph nodes"}}},
+	{name: "content in a leaf", raw: `{"type":"doc","content":[{"type":"horizontal_rule","content":[{"type":"paragraph"}]}]}`,
+		want: ValidationErrors{{Path: "/content/0/content", Message: "horizontal_rule nodes cannot have content"}}},
+	{name: "empty text", raw: p(`{"type":"text","text":""}`),
+		want: ValidationErrors{{Path: "/content/0/content/0/text", Message: "text nodes cannot be empty"}}},
+	{name: "text on a block", raw: `{"type":"doc","content":[{"type":"paragraph","text":"x"}]}`,
+		want: ValidationErrors{{Path: "/content/0/text", Message: "paragraph nodes cannot have text"}}},
+	{name: "too deep", raw: nested(MaxDepth - 1),
+		want: ValidationErrors{{Path: strings.Repeat("/content/0", MaxDepth), Message: fmt.Sprintf("nodes are nested deeper than %d", MaxDepth)}}},
+	{name: "too many nodes", raw: p(strings.TrimSuffix(strings.Repeat(`{"type":"hard_break"},`, MaxNodes), ",")),
+		want: ValidationErrors{{Path: fmt.Sprintf("/content/0/content/%d", MaxNodes-2), Message: fmt.Sprintf("document has more than %d nodes", MaxNodes)}}},
+
+	// marks
+	{name: "unknown mark", raw: p(`{"type":"text","text":"x","marks":[{"type":"blink"}]}`),
+		want: ValidationErrors{{Path: "/content/0/content/0/marks/0/type", Message: `unknown mark type "blink"`}}},
+	{name: "duplicate mark", raw: p(`{"type":"text","text":"x","marks":[{"type":"bold"},{"type":"bold"}]}`),
+		want: ValidationErrors{{Path: "/content/0/content/0/marks/1/type", Message: "duplicate bold mark"}}},
+	{name: "marks in code", raw: `{"type":"doc","content":[{"type":"code_block","content":[{"type":"text","text":"x","marks":[{"type":"bold"}]}]}]}`,
+		want: ValidationErrors{{Path: "/content/0/content/0/marks", Message: "marks are not allowed here"}}},
+	{name: "marks on a block", raw: `{"type":"doc","content":[{"type":"paragraph","marks":[{"type":"bold"}]}]}`,
+		want: ValidationErrors{{Path: "/content/0/marks", Message: "paragraph nodes cannot have marks"}}},
+
+	// attrs
+	{name: "missing attr", raw: `{"type":"doc","content":[{"type":"heading"}]}`,
+		want: ValidationErrors{{Path: "/content/0/attrs/level", Message: "attribute is required"}}},
+	{name: "unknown attr", raw: `{"type":"doc","content":[{"type":"paragraph","attrs":{"style":"color:red"}}]}`,
+		want: ValidationErrors{{Path: "/content/0/attrs/style", Message: `unknown attribute "style"`}}},
+	{name: "level out of range", raw: `{"type":"doc","content":[{"type":"heading","attrs":{"level":7}}]}`,
+		want: ValidationErrors{{Path: "/content/0/attrs/level", Message: "must be a whole number from 1 to 6"}}},
+	{name: "fractional level", raw: `{"type":"doc","content":[{"type":"heading","attrs":{"level":1.5}}]}`,
+		want: ValidationErrors{{Path: "/content/0/attrs/level", Message: "must be a whole number from 1 to 6"}}},
+	{name: "checked is not a bool", raw: `{"type":"doc","content":[{"type":"task_list","content":[{"type":"task_item","attrs":{"checked":"yes"},"content":[{"type":"paragraph"}]}]}]}`,
+		want: ValidationErrors{{Path: "/content/0/content/0/attrs/checked", Message: "must be true or false"}}},
+	{name: "code language", raw: `{"type":"doc","content":[{"type":"code_block","attrs":{"language":"x\"><script>"}}]}`,
+		want: ValidationErrors{{Path: "/content/0/attrs/language", Message: "must be a language name like go or c++"}}},
+	{name: "alignment", raw: `{"type":"doc","content":[{"type":"table","content":[{"type":"table_row","content":[{"type":"table_cell","attrs":{"align":"justify"}}]}]}]}`,
+		want: ValidationErrors{{Path: "/content/0/content/0/content/0/attrs/align", Message: "must be one of left, center, right"}}},
+	{name: "long alt", raw: fmt.Sprintf(`{"type":"doc","content":[{"type":"image","attrs":{"src":"https://cdn.example.com/a.png","alt":%q}}]}`, strings.Repeat("a", maxAttrLength+1)),
+		want: ValidationErrors{{Path: "/content/0/attrs/alt", Message: fmt.Sprintf("must be at most %d bytes", maxAttrLength)}}},
+	{name: "errors in a stable order", raw: `{"type":"doc","content":[{"type":"image","attrs":{"width":1,"alt":2}}]}`,
+		want: ValidationErrors{
+			{Path: "/content/0/attrs/alt", Message: "must be a string"},
+			{Path: "/content/0/attrs/width", Message: `unknown attribute "width"`},
+			{Path: "/content/0/attrs/src", Message: "attribute is required"},
+		}},
+
+	// urls
+	{name: "javascript link", raw: link("javascript:alert(1)"),
+		want: ValidationErrors{{Path: "/content/0/content/0/marks/0/attrs/href", Message: "url scheme must be one of http, https, mailto"}}},
+	{name: "obfuscated javascript link", raw: link("java\tscript:alert(1)"),
+		want: ValidationErrors{{Path: "/content/0/content/0/marks/0/attrs/href", Message: "url cannot contain whitespace or control characters"}}},
+	{name: "data link", raw: link("data:text/html,<script>alert(1)</script>"),
+		want: ValidationErrors{{Path: "/content/0/content/0/marks/0/attrs/href", Message: "url scheme must be one of http, https, mailto"}}},
+	{name: "protocol relative link", raw: link("//evil.example.com"),
+		want: ValidationErrors{{Path: "/content/0/content/0/marks/0/attrs/href", Message: "must be an absolute url"}}},
+	{name: "link without a host", raw: link("https:///path"),
+		want: ValidationErrors{{Path: "/content/0/content/0/marks/0/attrs/href", Message: "url must have a host"}}},
+	{name: "empty link", raw: link(""),
+		want: ValidationErrors{{Path: "/content/0/content/0/marks/0/attrs/href", Message: fmt.Sprintf("url must be 1 to %d bytes", maxUrlLength)}}},
+	{name: "relative image", raw: image("/a.png"),
+		want: ValidationErrors{{Path: "/content/0/attrs/src", Message: "must be an absolute url"}}},
+	{name: "mailto image", raw: image("mailto:someone@example.com"),
+		want: ValidationErrors{{Path: "/content/0/attrs/src", Message: "url scheme must be one of http, https"}}},
+	{name: "http embed", raw: embed("http://www.youtube.com/watch?v=abc"),
+		want: ValidationErrors{{Path: "/content/0/attrs/url", Message: "embed urls must be https"}}},
+	{name: "embed host", raw: embed("https://evil.example.com/watch?v=abc"),
+		want: ValidationErrors{{Path: "/content/0/attrs/url", Message: "embeds are only allowed from " + strings.Join(EmbedHosts, ", ")}}},
+}
+
+func TestParse(t *testing.T) {
+
+	for _, tt := range parseTests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			doc, err := Parse([]byte(tt.raw))
+
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Parse() error = %v", err)
+				}
+				if doc == nil || doc.Type != DocNode {
+					t.Fatalf("Parse() = %v, want a doc node", doc)
+				}
+				return
+			}
+
+			var validationErrors ValidationErrors
+			if !errors.As(err, &validationErrors) {
+				t.Fatalf("Parse() error = %v, want ValidationErrors", err)
+			}
+
+			if !reflect.DeepEqual(validationErrors, tt.want) {
+				t.Errorf("Parse() errors =\n%v\nwant\n%v", validationErrors, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateStopsAfterMaxErrors(t *testing.T) {
+
+	err := Validate(Node{Type: DocNode, Content: make([]Node, maxErrors*2)})
+
+	var validationErrors ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		t.Fatalf("Validate() error = %v, want ValidationErrors", err)
+	}
+
+	if len(validationErrors) != maxErrors {
+		t.Errorf("Validate() returned %d errors, want %d", len(validationErrors), maxErrors)
+	}
+}
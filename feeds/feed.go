@@ -0,0 +1,74 @@
+// Package feeds writes syndication feeds of blogs as RSS 2.0, Atom 1.0 or JSON Feed 1.1
+package feeds
+
+import (
+	"errors"
+	"time"
+)
+
+type Format string
+
+const (
+	RSSFormat  Format = "rss"
+	AtomFormat Format = "atom"
+	JSONFormat Format = "json"
+)
+
+var Formats = []Format{RSSFormat, AtomFormat, JSONFormat}
+
+var ErrUnknownFormat = errors.New("unknown feed format")
+
+type Feed struct {
+	Title       string
+	Description string
+	// url of the page the feed is about on the frontend
+	Link string
+	// url the feed itself is served at
+	FeedUrl string
+	// time of the most recent change to an item, zero for an empty feed
+	Updated time.Time
+	Items   []Item
+}
+
+type Item struct {
+	// stable id of the item, it does not change when the link does
+	Id          string
+	Link        string
+	Title       string
+	Summary     string
+	ContentHTML string
+	Author      string
+	Categories  []string
+	Published   time.Time
+	Updated     time.Time
+}
+
+// ContentType is the media type a feed of the format is served with
+func (f Format) ContentType() string {
+
+	switch f {
+	case RSSFormat:
+		return "application/rss+xml; charset=utf-8"
+	case AtomFormat:
+		return "application/atom+xml; charset=utf-8"
+	case JSONFormat:
+		return "application/feed+json; charset=utf-8"
+	}
+
+	return "application/octet-stream"
+}
+
+// Encode writes the feed in the given format
+func Encode(feed Feed, format Format) ([]byte, error) {
+
+	switch format {
+	case RSSFormat:
+		return RSS(feed)
+	case AtomFormat:
+		return Atom(feed)
+	case JSONFormat:
+		return JSON(feed)
+	}
+
+	return nil, ErrUnknownFormat
+}
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/dhruv15803/echo-blog-app/analytics"
+	"github.com/dhruv15803/echo-blog-app/storage"
+	"github.com/go-chi/chi/v5"
+)
+
+type RecordBlogViewPayload struct {
+	ReadPercent *int   `json:"read_percent"`
+	Referrer    string `json:"referrer"`
+}
+
+const (
+	defaultStatsDays = 30
+	maxStatsDays     = 365
+	topReferrersSize = 10
+)
+
+// RecordBlogViewHandler records a view of the blog, and how far the viewer read when read_percent is sent.
+// the frontend sends a view when the blog is opened and reads as the viewer scrolls, a viewer is counted
+// once per dedupe window. the referrer is the page the viewer came from, only its domain is kept
+func (h *Handler) RecordBlogViewHandler(w http.ResponseWriter, r *http.Request) {
+
+	blogId, err := strconv.Atoi(chi.URLParam(r, "blogId"))
+	if err != nil {
+		writeJSONError(w, "invalid request param blogId", http.StatusBadRequest)
+		return
+	}
+
+	var recordBlogViewPayload RecordBlogViewPayload
+
+	// the body is optional, a view without one has not been read yet
+	if err := json.NewDecoder(r.Body).Decode(&recordBlogViewPayload); err != nil && !errors.Is(err, io.EOF) {
+		writeJSONError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	readPercent := 0
+	if recordBlogViewPayload.ReadPercent != nil {
+		readPercent = *recordBlogViewPayload.ReadPercent
+	}
+
+	if readPercent < 0 || readPercent > 100 {
+		writeJSONError(w, "read_percent must be between 0 and 100", http.StatusBadRequest)
+		return
+	}
+
+	type Response struct {
+		Success bool `json:"success"`
+	}
+
+	// bots get the same response, they are just not counted
+	if analytics.IsBot(r.UserAgent()) {
+		if err := writeJSON(w, Response{Success: true}, http.StatusAccepted); err != nil {
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// views of blogs hidden by moderators are not recorded, like every other public read
+	if _, err := h.storage.GetVisibleBlogById(blogId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, "blog not found", http.StatusBadRequest)
+			return
+		} else {
+			log.Printf("failed to get blog :- %v\n", err.Error())
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// signed in viewers are the same viewer on every device, others are told apart by address and browser
+	viewer := "anonymous:" + clientIp(r) + "|" + r.UserAgent()
+	userId, signedIn := authTokenUserId(r)
+	if signedIn {
+		viewer = "user:" + strconv.Itoa(userId)
+	}
+
+	now := time.Now()
+	windowStart := now.Truncate(h.cfg.ViewDedupeWindow)
+
+	event := storage.BlogViewEvent{
+		BlogId:      blogId,
+		ViewerHash:  analytics.ViewerHash(h.cfg.ViewHashSecret, viewer, windowStart),
+		WindowStart: windowStart,
+		ReadPercent: readPercent,
+		ViewedAt:    now,
+	}
+
+	if signedIn {
+		event.UserId = &userId
+	}
+
+	clientHost := ""
+	if clientUrl, err := url.Parse(h.cfg.ClientUrl); err == nil {
+		clientHost = clientUrl.Hostname()
+	}
+
+	if domain := analytics.ReferrerDomain(recordBlogViewPayload.Referrer, clientHost); domain != "" {
+		event.ReferrerDomain = &domain
+	}
+
+	if !h.views.Record(event) {
+		log.Println("blog view buffer is full, dropping view")
+	}
+
+	if err := writeJSON(w, Response{Success: true}, http.StatusAccepted); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetBlogStatsHandler returns the views, reads and likes of the author's blog for every day of the last
+// days query param days, and the domains most of its readers came from
+func (h *Handler) GetBlogStatsHandler(w http.ResponseWriter, r *http.Request) {
+
+	userId, ok := r.Context().Value(AuthUserId).(int)
+	if !ok {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	blogId, err := strconv.Atoi(chi.URLParam(r, "blogId"))
+	if err != nil {
+		writeJSONError(w, "invalid request param blogId", http.StatusBadRequest)
+		return
+	}
+
+	days := defaultStatsDays
+	if r.URL.Query().Get("days") != "" {
+		days, err = strconv.Atoi(r.URL.Query().Get("days"))
+		if err != nil || days < 1 || days > maxStatsDays {
+			writeJSONError(w, "invalid query param days, must be between 1 and 365", http.StatusBadRequest)
+			return
+		}
+	}
+
+	blog, err := h.storage.GetBlogById(blogId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, "blog not found", http.StatusBadRequest)
+			return
+		} else {
+			log.Printf("failed to get blog :- %v\n", err.Error())
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if blog.BlogAuthorId != userId {
+		writeJSONError(w, "user not allowed to view blog stats", http.StatusUnauthorized)
+		return
+	}
+
+	since := time.Now().AddDate(0, 0, -(days - 1))
+
+	daily, err := h.storage.GetBlogStats(blog.Id, since)
+	if err != nil {
+		log.Printf("failed to get blog stats :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	topReferrers, err := h.storage.GetBlogTopReferrers(blog.Id, since, topReferrersSize)
+	if err != nil {
+		log.Printf("failed to get blog top referrers :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	type BlogStats struct {
+		Days               int                       `json:"days"`
+		Views              int                       `json:"views"`
+		Reads              int                       `json:"reads"`
+		ReadRatio          float64                   `json:"read_ratio"`
+		AverageReadPercent float64                   `json:"average_read_percent"`
+		Likes              int                       `json:"likes"`
+		Daily              []storage.BlogStatsBucket `json:"daily"`
+		TopReferrers       []storage.ReferrerViews   `json:"top_referrers"`
+	}
+
+	stats := BlogStats{Days: days, Daily: daily, TopReferrers: topReferrers}
+
+	var readPercentTotal int64
+	for _, bucket := range daily {
+		stats.Views += bucket.Views
+		stats.Reads += bucket.Reads
+		stats.Likes += bucket.Likes
+		readPercentTotal += bucket.ReadPercentTotal
+	}
+
+	if stats.Views > 0 {
+		stats.ReadRatio = float64(stats.Reads) / float64(stats.Views)
+		stats.AverageReadPercent = float64(readPercentTotal) / float64(stats.Views)
+	}
+
+	if stats.TopReferrers == nil {
+		stats.TopReferrers = []storage.ReferrerViews{}
+	}
+
+	type Response struct {
+		Success bool      `json:"success"`
+		Stats   BlogStats `json:"stats"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Stats: stats}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func clientIp(r *http.Request) string {
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dhruv15803/echo-blog-app/storage"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const (
+	TopicCreateAuditAction    = "topic.create"
+	TopicUpdateAuditAction    = "topic.update"
+	TopicDeleteAuditAction    = "topic.delete"
+	ReportsResolveAuditAction = "reports.resolve"
+	WebhookCreateAuditAction  = "webhook.create"
+	WebhookUpdateAuditAction  = "webhook.update"
+	WebhookDeleteAuditAction  = "webhook.delete"
+	TopicAuditTargetType      = "topic"
+	WebhookAuditTargetType    = "webhook"
+)
+
+// recordAuditLog appends a privileged action performed in this request to the audit log.
+// before and after are snapshots of the target and may be nil.
+// failures are logged and never fail the request, the action itself has already happened
+func (h *Handler) recordAuditLog(r *http.Request, action string, targetType string, targetId int, before any, after any) {
+
+	entry := storage.AuditLogEntry{
+		Action:     action,
+		TargetType: targetType,
+		TargetId:   &targetId,
+	}
+
+	if actorId, ok := r.Context().Value(AuthUserId).(int); ok {
+		entry.ActorId = &actorId
+	}
+
+	if before != nil {
+		beforeData, err := json.Marshal(before)
+		if err != nil {
+			log.Printf("failed to marshal audit log before data :- %v\n", err.Error())
+		}
+		entry.BeforeData = storage.AuditLogData(beforeData)
+	}
+
+	if after != nil {
+		afterData, err := json.Marshal(after)
+		if err != nil {
+			log.Printf("failed to marshal audit log after data :- %v\n", err.Error())
+		}
+		entry.AfterData = storage.AuditLogData(afterData)
+	}
+
+	ipAddress := clientIp(r)
+	entry.IpAddress = &ipAddress
+
+	if userAgent := r.UserAgent(); userAgent != "" {
+		entry.UserAgent = &userAgent
+	}
+
+	if requestId := middleware.GetReqID(r.Context()); requestId != "" {
+		entry.RequestId = &requestId
+	}
+
+	if _, err := h.storage.CreateAuditLogEntry(entry); err != nil {
+		log.Printf("failed to write audit log entry for action %s :- %v\n", action, err.Error())
+	}
+}
+
+func (h *Handler) GetAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+
+	query := r.URL.Query()
+
+	pageNum, err := strconv.Atoi(query.Get("page"))
+	if err != nil {
+		writeJSONError(w, "invalid query param page", http.StatusBadRequest)
+		return
+	}
+
+	limitNum, err := strconv.Atoi(query.Get("limit"))
+	if err != nil {
+		writeJSONError(w, "invalid query param limit", http.StatusBadRequest)
+		return
+	}
+
+	filter := storage.AuditLogFilter{
+		Action:     query.Get("action"),
+		TargetType: query.Get("target_type"),
+	}
+
+	if actorId := query.Get("actor_id"); actorId != "" {
+		if filter.ActorId, err = strconv.Atoi(actorId); err != nil {
+			writeJSONError(w, "invalid query param actor_id", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if targetId := query.Get("target_id"); targetId != "" {
+		if filter.TargetId, err = strconv.Atoi(targetId); err != nil {
+			writeJSONError(w, "invalid query param target_id", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if from := query.Get("from"); from != "" {
+		if filter.From, err = time.Parse(time.RFC3339, from); err != nil {
+			writeJSONError(w, "invalid query param from", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if to := query.Get("to"); to != "" {
+		if filter.To, err = time.Parse(time.RFC3339, to); err != nil {
+			writeJSONError(w, "invalid query param to", http.StatusBadRequest)
+			return
+		}
+	}
+
+	skip := pageNum*limitNum - limitNum
+
+	auditLogEntries, err := h.storage.GetAuditLogEntries(filter, skip, limitNum)
+	if err != nil {
+		log.Printf("failed to get audit log entries :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	totalEntriesCount, err := h.storage.GetAuditLogEntriesCount(filter)
+	if err != nil {
+		log.Printf("failed to get audit log entries count :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	noOfPages := int(math.Ceil(float64(totalEntriesCount) / float64(limitNum)))
+
+	type Response struct {
+		Success   bool                    `json:"success"`
+		Entries   []storage.AuditLogEntry `json:"entries"`
+		NoOfPages int                     `json:"no_of_pages"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Entries: auditLogEntries, NoOfPages: noOfPages}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+	}
+}
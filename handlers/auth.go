@@ -14,7 +14,7 @@ import (
 	"time"
 
 	"github.com/dhruv15803/echo-blog-app/helpers"
-	"github.com/dhruv15803/echo-blog-app/mailer"
+	"github.com/dhruv15803/echo-blog-app/outbox"
 	"github.com/dhruv15803/echo-blog-app/storage"
 	"github.com/go-chi/chi/v5"
 	"github.com/golang-jwt/jwt/v5"
@@ -24,6 +24,7 @@ import (
 type RegisterUserPayload struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	Language string `json:"language"`
 }
 
 type LoginUserPayload struct {
@@ -102,36 +103,21 @@ func (h *Handler) RegisterUserHandler(w http.ResponseWriter, r *http.Request) {
 	hashedToken := hex.EncodeToString(hashedTokenByteArray[:])
 	userInvitationExpirationTime := time.Now().Add(time.Minute * 30)
 
-	// create user and invitation //
-	user, err := h.storage.CreateUserAndInvitation(userEmail, string(hashedPasswordBytes), hashedToken, userInvitationExpirationTime)
-	if err != nil {
-		log.Printf("failed to create and invite user :- %v\n", err.Error())
-		writeJSONError(w, "internal server error", http.StatusInternalServerError)
-		return
-	}
-
-	// send mail (with retry mechanism if fails)
-
-	maxRetryCount := 3
-	currentCount := 0
-	hasInvitationMailSent := false
-
-	for currentCount < maxRetryCount {
-
-		if err := mailer.SendGoInvitationMail(os.Getenv("GOMAIL_FROM_EMAIL"), user.Email, "user activation - echo blog", "./templates/inviteEmail.html", plainTextToken); err != nil {
+	userLanguage := h.preferredLanguage(r, registerUserPayload.Language)
 
-			log.Printf("failed to send invitation mail , current count - %d , error :- %v\n", currentCount, err.Error())
-
-			currentCount = currentCount + 1
-			continue
-		}
-
-		hasInvitationMailSent = true
-		break
+	// the invitation mail is queued in the same transaction and delivered by the outbox workers
+	invitationMail := storage.NewOutboxEmail{
+		IdempotencyKey: "invite:" + hashedToken,
+		Template:       storage.InviteEmailTemplate,
+		ToEmail:        userEmail,
+		Locale:         userLanguage,
+		Payload:        outbox.TokenMailPayload{Token: plainTextToken},
 	}
 
-	if !hasInvitationMailSent {
-		log.Println("failed to send invitation email")
+	// create user and invitation //
+	user, err := h.storage.CreateUserAndInvitation(userEmail, string(hashedPasswordBytes), userLanguage, hashedToken, userInvitationExpirationTime, invitationMail)
+	if err != nil {
+		log.Printf("failed to create and invite user :- %v\n", err.Error())
 		writeJSONError(w, "internal server error", http.StatusInternalServerError)
 		return
 	}
@@ -193,6 +179,18 @@ func (h *Handler) LoginUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	isSuspended, err := h.storage.IsUserSuspended(user.Id)
+	if err != nil {
+		log.Printf("failed to check user suspension :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if isSuspended {
+		writeJSONError(w, "user is suspended", http.StatusForbidden)
+		return
+	}
+
 	claims := jwt.MapClaims{
 		"sub": user.Id,
 		"exp": time.Now().Add(time.Hour * 24 * 2).Unix(),
@@ -373,33 +371,17 @@ func (h *Handler) ForgotPasswordHandler(w http.ResponseWriter, r *http.Request)
 	hashedTokenStr := hex.EncodeToString(hashedTokenByteArr[:])
 	expirationTime := time.Now().Add(time.Minute * 15) // 15 minutes
 
-	_, err = h.storage.CreatePasswordReset(hashedTokenStr, user.Id, expirationTime)
-	if err != nil {
-		log.Printf("failed to create password reset :- %v\n", err.Error())
-		writeJSONError(w, "internal server error", http.StatusInternalServerError)
-		return
-	}
-
-	maxRetries := 3
-	currentCount := 0
-	isPasswordResetMailSent := false
-
-	for currentCount < maxRetries {
-
-		if err := mailer.SendGoPasswordResetMail(os.Getenv("GOMAIL_FROM_EMAIL"), user.Email, "Echo BLog Password Reset", "./templates/forgotPassword.html", plainTextToken); err != nil {
-			log.Printf("failed to send password reset mail , retry count - %v", currentCount+1)
-			currentCount++
-		}
-
-		// mail sent
-		isPasswordResetMailSent = true
-		if isPasswordResetMailSent {
-			break
-		}
+	passwordResetMail := storage.NewOutboxEmail{
+		IdempotencyKey: "password_reset:" + hashedTokenStr,
+		Template:       storage.PasswordResetEmailTemplate,
+		ToEmail:        user.Email,
+		Locale:         user.Language,
+		Payload:        outbox.TokenMailPayload{Token: plainTextToken},
 	}
 
-	if !isPasswordResetMailSent {
-		log.Printf("failed to sent password reset mail after %v retries", maxRetries)
+	_, err = h.storage.CreatePasswordReset(hashedTokenStr, user.Id, expirationTime, passwordResetMail)
+	if err != nil {
+		log.Printf("failed to create password reset :- %v\n", err.Error())
 		writeJSONError(w, "internal server error", http.StatusInternalServerError)
 		return
 	}
@@ -409,7 +391,7 @@ func (h *Handler) ForgotPasswordHandler(w http.ResponseWriter, r *http.Request)
 		Message string `json:"message"`
 	}
 
-	if err := writeJSON(w, Response{Success: true, Message: "password reset email sent successfully"}, http.StatusOK); err != nil {
+	if err := writeJSON(w, Response{Success: true, Message: "password reset email queued successfully"}, http.StatusOK); err != nil {
 		writeJSONError(w, "internal server error", http.StatusInternalServerError)
 	}
 }
@@ -8,10 +8,15 @@ import (
 	"log"
 	"math"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 
+	"github.com/dhruv15803/echo-blog-app/document"
 	"github.com/dhruv15803/echo-blog-app/helpers"
+	"github.com/dhruv15803/echo-blog-app/markdown"
+	"github.com/dhruv15803/echo-blog-app/notifications"
+	"github.com/dhruv15803/echo-blog-app/render"
 	"github.com/dhruv15803/echo-blog-app/storage"
 	"github.com/go-chi/chi/v5"
 )
@@ -20,6 +25,16 @@ type CreateBlogPayload struct {
 	BlogTitle       string `json:"blog_title"`
 	BlogDescription string `json:"blog_description"`
 	BlogContent     string `json:"blog_content"`
+	ContentFormat   string `json:"content_format"`
+	BlogThumbnail   string `json:"blog_thumbnail"`
+	BlogTopicIds    []int  `json:"blog_topic_ids"`
+}
+
+type UpdateBlogPayload struct {
+	BlogTitle       string `json:"blog_title"`
+	BlogDescription string `json:"blog_description"`
+	BlogContent     string `json:"blog_content"`
+	ContentFormat   string `json:"content_format"`
 	BlogThumbnail   string `json:"blog_thumbnail"`
 	BlogTopicIds    []int  `json:"blog_topic_ids"`
 }
@@ -62,49 +77,113 @@ func (h *Handler) CreateBlogHandler(w http.ResponseWriter, r *http.Request) {
 
 	blogTitle := strings.ToTitle(strings.TrimSpace(createBlogPayload.BlogTitle))
 	blogDescription := strings.TrimSpace(createBlogPayload.BlogDescription)
-	blogContent := createBlogPayload.BlogContent // this is supposed to be stringified JSON
+	blogContent := createBlogPayload.BlogContent // stringified JSON of the editor's document, or markdown
+	blogContentFormat := storage.BlogContentFormat(createBlogPayload.ContentFormat)
 	blogThumbnail := createBlogPayload.BlogThumbnail
 	blogTopicIds := createBlogPayload.BlogTopicIds
 
-	isBlogContentValidStringifiedJson := false
+	if blogTitle == "" || len(blogTopicIds) == 0 {
+		writeJSONError(w, "blog title, blog topics, and valid blog content is required", http.StatusBadRequest)
+		return
+	}
+
+	if blogContentFormat == "" {
+		blogContentFormat = storage.JSONBlogContentFormat
+	}
 
-	_, err = json.Marshal(blogContent)
+	blogContent, blogContentMarkdown, ok := parseBlogContent(w, blogContentFormat, blogContent)
+	if !ok {
+		return
+	}
+
+	if !h.validBlogTopics(w, blogTopicIds) {
+		return
+	}
+
+	newBlog, err := h.storage.CreateBlog(blogTitle, slugFor(blogTitle, "blog"), blogDescription, blogContent, blogContentFormat, blogContentMarkdown, blogThumbnail, user.Id, blogTopicIds)
 	if err != nil {
-		writeJSONError(w, "invalid blog content", http.StatusBadRequest)
+		log.Printf("failed to create blog :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	isBlogContentValidStringifiedJson = true
+	h.webhooks.Publish(storage.BlogCreatedWebhookEvent, user.Id, newBlog)
 
-	if blogTitle == "" || len(blogTopicIds) == 0 || !isBlogContentValidStringifiedJson {
-		writeJSONError(w, "blog title, blog topics, and valid blog content is required", http.StatusBadRequest)
+	type Response struct {
+		Success bool                   `json:"success"`
+		Message string                 `json:"message"`
+		Blog    storage.BlogWithTopics `json:"blog"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Message: "blog created sucessfully", Blog: *newBlog}, http.StatusCreated); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) UpdateBlogHandler(w http.ResponseWriter, r *http.Request) {
+
+	userId, ok := r.Context().Value(AuthUserId).(int)
+	if !ok {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	for _, topicId := range blogTopicIds {
-		_, err := h.storage.GetTopicById(topicId)
-		if err != nil {
-			if errors.Is(err, sql.ErrNoRows) {
-				writeJSONError(w, fmt.Sprintf("topic with id %v not found for blog", topicId), http.StatusBadRequest)
-				return
-			} else {
-				writeJSONError(w, "internal server error", http.StatusInternalServerError)
-				return
-			}
+	blogId, err := strconv.Atoi(chi.URLParam(r, "blogId"))
+	if err != nil {
+		writeJSONError(w, "invalid request param blogId", http.StatusBadRequest)
+		return
+	}
+
+	blog, err := h.storage.GetBlogById(blogId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, "blog not found", http.StatusBadRequest)
+			return
+		} else {
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
 		}
 	}
 
-	// all topics in blogTopicIds[] are valid topics
-	// there should be no duplicate topics in the slice(no duplicate value)
+	if userId != blog.BlogAuthorId {
+		writeJSONError(w, "user not allowed to update blog", http.StatusUnauthorized)
+		return
+	}
 
-	if helpers.HasDuplicates(blogTopicIds) {
-		writeJSONError(w, "blog cannot have multiple same topics", http.StatusBadRequest)
+	var updateBlogPayload UpdateBlogPayload
+
+	if err := json.NewDecoder(r.Body).Decode(&updateBlogPayload); err != nil {
+		writeJSONError(w, "invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	newBlog, err := h.storage.CreateBlog(blogTitle, blogDescription, blogContent, blogThumbnail, user.Id, blogTopicIds)
+	blogTitle := strings.ToTitle(strings.TrimSpace(updateBlogPayload.BlogTitle))
+	blogDescription := strings.TrimSpace(updateBlogPayload.BlogDescription)
+	blogContentFormat := storage.BlogContentFormat(updateBlogPayload.ContentFormat)
+	blogThumbnail := updateBlogPayload.BlogThumbnail
+	blogTopicIds := updateBlogPayload.BlogTopicIds
+
+	if blogTitle == "" || len(blogTopicIds) == 0 {
+		writeJSONError(w, "blog title, blog topics, and valid blog content is required", http.StatusBadRequest)
+		return
+	}
+
+	if blogContentFormat == "" {
+		blogContentFormat = storage.JSONBlogContentFormat
+	}
+
+	blogContent, blogContentMarkdown, ok := parseBlogContent(w, blogContentFormat, updateBlogPayload.BlogContent)
+	if !ok {
+		return
+	}
+
+	if !h.validBlogTopics(w, blogTopicIds) {
+		return
+	}
+
+	updatedBlog, err := h.storage.UpdateBlog(blog.Id, blogTitle, slugFor(blogTitle, "blog"), blogDescription, blogContent, blogContentFormat, blogContentMarkdown, blogThumbnail, blogTopicIds)
 	if err != nil {
-		log.Printf("failed to create blog :- %v\n", err.Error())
+		log.Printf("failed to update blog :- %v\n", err.Error())
 		writeJSONError(w, "internal server error", http.StatusInternalServerError)
 		return
 	}
@@ -115,9 +194,99 @@ func (h *Handler) CreateBlogHandler(w http.ResponseWriter, r *http.Request) {
 		Blog    storage.BlogWithTopics `json:"blog"`
 	}
 
-	if err := writeJSON(w, Response{Success: true, Message: "blog created sucessfully", Blog: *newBlog}, http.StatusCreated); err != nil {
+	if err := writeJSON(w, Response{Success: true, Message: "blog updated successfully", Blog: *updatedBlog}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+	}
+}
+
+// parseBlogContent validates blog content against the document model and returns it re-encoded, markdown
+// is converted to a document first and also returned as the source. an invalid document is answered
+// with a 422 listing the path of every invalid node
+func parseBlogContent(w http.ResponseWriter, contentFormat storage.BlogContentFormat, blogContent string) (string, *string, bool) {
+
+	var doc *document.Node
+	var markdownSource *string
+	var err error
+
+	switch contentFormat {
+	case storage.JSONBlogContentFormat:
+		doc, err = document.Parse([]byte(blogContent))
+	case storage.MarkdownBlogContentFormat:
+		if len(blogContent) > document.MaxSize {
+			err = document.ValidationErrors{{Path: "", Message: fmt.Sprintf("markdown is larger than %d bytes", document.MaxSize)}}
+			break
+		}
+		parsed := markdown.Parse(blogContent)
+		doc, markdownSource = &parsed, &blogContent
+		err = document.Validate(parsed)
+	default:
+		writeJSONError(w, "invalid content_format, must be json or markdown", http.StatusBadRequest)
+		return "", nil, false
+	}
+
+	if err != nil {
+		var validationErrors document.ValidationErrors
+		if !errors.As(err, &validationErrors) {
+			writeJSONError(w, "invalid blog content", http.StatusBadRequest)
+			return "", nil, false
+		}
+
+		writeBlogContentErrors(w, validationErrors)
+		return "", nil, false
+	}
+
+	normalizedContent, err := json.Marshal(doc)
+	if err != nil {
+		log.Printf("failed to encode blog content :- %v\n", err.Error())
 		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return "", nil, false
+	}
+
+	// markdown can convert to a document larger than the markdown itself
+	if len(normalizedContent) > document.MaxSize {
+		writeBlogContentErrors(w, document.ValidationErrors{{Path: "", Message: fmt.Sprintf("document is larger than %d bytes", document.MaxSize)}})
+		return "", nil, false
+	}
+
+	return string(normalizedContent), markdownSource, true
+}
+
+func writeBlogContentErrors(w http.ResponseWriter, validationErrors document.ValidationErrors) {
+
+	type Response struct {
+		Success bool                      `json:"success"`
+		Message string                    `json:"message"`
+		Errors  document.ValidationErrors `json:"errors"`
+	}
+
+	writeJSON(w, Response{Success: false, Message: "invalid blog content", Errors: validationErrors}, http.StatusUnprocessableEntity)
+}
+
+// validBlogTopics checks that every topic exists and none is repeated
+func (h *Handler) validBlogTopics(w http.ResponseWriter, blogTopicIds []int) bool {
+
+	for _, topicId := range blogTopicIds {
+		_, err := h.storage.GetTopicById(topicId)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				writeJSONError(w, fmt.Sprintf("topic with id %v not found for blog", topicId), http.StatusBadRequest)
+				return false
+			} else {
+				writeJSONError(w, "internal server error", http.StatusInternalServerError)
+				return false
+			}
+		}
 	}
+
+	// all topics in blogTopicIds[] are valid topics
+	// there should be no duplicate topics in the slice(no duplicate value)
+
+	if helpers.HasDuplicates(blogTopicIds) {
+		writeJSONError(w, "blog cannot have multiple same topics", http.StatusBadRequest)
+		return false
+	}
+
+	return true
 }
 
 func (h *Handler) DeleteBlogHandler(w http.ResponseWriter, r *http.Request) {
@@ -168,6 +337,8 @@ func (h *Handler) DeleteBlogHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.webhooks.Publish(storage.BlogDeletedWebhookEvent, user.Id, blog)
+
 	type Response struct {
 		Success bool   `json:"success"`
 		Message string `json:"message"`
@@ -236,6 +407,9 @@ func (h *Handler) LikeBlogHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		h.notifier.Emit(notifications.BlogLiked(user.Id, *blog))
+		h.publishBlogCounts(blog.Id)
+
 		type Response struct {
 			Success  bool             `json:"success"`
 			Message  string           `json:"message"`
@@ -254,6 +428,9 @@ func (h *Handler) LikeBlogHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		h.notifier.Retract(notifications.BlogLiked(user.Id, *blog))
+		h.publishBlogCounts(blog.Id)
+
 		type Response struct {
 			Success bool   `json:"success"`
 			Message string `json:"message"`
@@ -348,6 +525,8 @@ func (h *Handler) CreateBlogCommentHandler(w http.ResponseWriter, r *http.Reques
 			return
 		}
 
+		h.notifier.Emit(notifications.CommentReplied(user.Id, *parentComment))
+
 		log.Println(blogComment)
 	} else {
 
@@ -357,8 +536,14 @@ func (h *Handler) CreateBlogCommentHandler(w http.ResponseWriter, r *http.Reques
 			writeJSONError(w, "internal server error", http.StatusInternalServerError)
 			return
 		}
+
+		h.notifier.Emit(notifications.BlogCommented(user.Id, *blog))
+		h.publishBlogCounts(blog.Id)
 	}
 
+	h.publishNewComment(*blogComment)
+	h.webhooks.Publish(storage.CommentCreatedWebhookEvent, blog.BlogAuthorId, blogComment)
+
 	type Response struct {
 		Success     bool                `json:"success"`
 		Message     string              `json:"message"`
@@ -424,6 +609,8 @@ func (h *Handler) LikeBlogCommentHandler(w http.ResponseWriter, r *http.Request)
 			return
 		}
 
+		h.notifier.Emit(notifications.CommentLiked(user.Id, *blogComment))
+
 		responseMsg = "create blog comment like"
 
 	} else {
@@ -434,6 +621,8 @@ func (h *Handler) LikeBlogCommentHandler(w http.ResponseWriter, r *http.Request)
 			return
 		}
 
+		h.notifier.Retract(notifications.CommentLiked(user.Id, *blogComment))
+
 		responseMsg = "remove blog comment like"
 	}
 
@@ -515,6 +704,8 @@ func (h *Handler) BookmarkBlogHandler(w http.ResponseWriter, r *http.Request) {
 		responseMsg = "remove blog bookmark"
 	}
 
+	h.publishBlogCounts(blog.Id)
+
 	type Response struct {
 		Success bool   `json:"success"`
 		Message string `json:"message"`
@@ -526,6 +717,79 @@ func (h *Handler) BookmarkBlogHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (h *Handler) GetBlogHandler(w http.ResponseWriter, r *http.Request) {
+
+	blogId, err := strconv.Atoi(chi.URLParam(r, "blogId"))
+	if err != nil {
+		writeJSONError(w, "invalid request param blogId", http.StatusBadRequest)
+		return
+	}
+
+	h.writeBlog(w, r, blogId)
+}
+
+// writeBlog writes the blog that is not hidden with its metadata, and its content rendered in the format of the query param format if there is one
+func (h *Handler) writeBlog(w http.ResponseWriter, r *http.Request, blogId int) {
+
+	// without a format the blog is returned with its content document only
+	format := render.Format(r.URL.Query().Get("format"))
+	if format != "" && !slices.Contains(render.Formats, format) {
+		writeJSONError(w, "invalid query param format, must be html, md or text", http.StatusBadRequest)
+		return
+	}
+
+	blog, err := h.storage.GetBlogWithMetaDataById(blogId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, "blog not found", http.StatusBadRequest)
+			return
+		} else {
+			log.Printf("failed to get blog :- %v\n", err.Error())
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// blogs that are a part of a series link to the parts before and after them
+	seriesNav, err := h.storage.GetBlogSeriesNav(blog.Id)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("failed to get blog series nav :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	type Response struct {
+		Success         bool                     `json:"success"`
+		Blog            storage.BlogWithMetaData `json:"blog"`
+		Series          *storage.BlogSeriesNav   `json:"series,omitempty"`
+		Format          render.Format            `json:"format,omitempty"`
+		RenderedContent string                   `json:"rendered_content,omitempty"`
+	}
+
+	response := Response{Success: true, Blog: *blog, Series: seriesNav}
+
+	if format != "" {
+		response.Format = format
+		response.RenderedContent = render.Render(blogDocument(blog.BlogContent), format)
+	}
+
+	if err := writeJSON(w, response, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// blogDocument parses stored blog content, content written before it was validated is read as plain text
+func blogDocument(content string) document.Node {
+
+	doc, err := document.Parse([]byte(content))
+	if err != nil {
+		return document.FromText(content)
+	}
+
+	return *doc
+}
+
 func (h *Handler) GetBlogsByTopicHandler(w http.ResponseWriter, r *http.Request) {
 
 	topicId, err := strconv.Atoi(chi.URLParam(r, "topicId"))
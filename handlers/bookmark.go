@@ -0,0 +1,635 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dhruv15803/echo-blog-app/storage"
+	"github.com/go-chi/chi/v5"
+)
+
+type UpdateBookmarkNotePayload struct {
+	// an empty note clears it
+	Note string `json:"note"`
+}
+
+type CreateCollectionPayload struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// private by default
+	Visibility string `json:"visibility"`
+}
+
+type UpdateCollectionPayload struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	Visibility  *string `json:"visibility"`
+}
+
+type AddCollectionItemPayload struct {
+	BlogId int `json:"blog_id"`
+}
+
+type ReorderCollectionPayload struct {
+	// every blog in the collection in the new order
+	BlogIds []int `json:"blog_ids"`
+}
+
+const (
+	defaultBookmarksLimit        = 20
+	maxBookmarksLimit            = 100
+	maxBookmarkNoteLength        = 2000
+	maxCollectionNameLength      = 100
+	maxCollectionDescriptionSize = 500
+)
+
+// validateCollection returns the reason a collection's name, description or visibility is invalid, empty if they are valid
+func validateCollection(name string, description string, visibility string) string {
+
+	if name == "" {
+		return "collection name is required"
+	}
+
+	if utf8.RuneCountInString(name) > maxCollectionNameLength {
+		return "collection name must be at most 100 characters"
+	}
+
+	if utf8.RuneCountInString(description) > maxCollectionDescriptionSize {
+		return "collection description must be at most 500 characters"
+	}
+
+	if !slices.Contains(storage.CollectionVisibilities, storage.CollectionVisibility(visibility)) {
+		return "invalid visibility, must be private or public"
+	}
+
+	return ""
+}
+
+// ownedBookmarkCollection loads the collection in the url and checks that the auth user owns it,
+// writing the error response if not
+func (h *Handler) ownedBookmarkCollection(w http.ResponseWriter, r *http.Request) (*storage.BookmarkCollection, bool) {
+
+	userId, ok := r.Context().Value(AuthUserId).(int)
+	if !ok {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	collectionId, err := strconv.Atoi(chi.URLParam(r, "collectionId"))
+	if err != nil {
+		writeJSONError(w, "invalid request param collectionId", http.StatusBadRequest)
+		return nil, false
+	}
+
+	collection, err := h.storage.GetBookmarkCollectionById(collectionId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, "collection not found", http.StatusBadRequest)
+			return nil, false
+		} else {
+			log.Printf("failed to get bookmark collection :- %v\n", err.Error())
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return nil, false
+		}
+	}
+
+	if collection.OwnerId != userId {
+		writeJSONError(w, "collection not found", http.StatusBadRequest)
+		return nil, false
+	}
+
+	return collection, true
+}
+
+// collectionNameTaken reports whether another collection of the owner has the name, writing the error response if it does
+func (h *Handler) collectionNameTaken(w http.ResponseWriter, ownerId int, name string, collectionId int) bool {
+
+	existing, err := h.storage.GetBookmarkCollectionByName(ownerId, name)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("failed to get bookmark collection by name :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return true
+	}
+
+	if existing != nil && existing.Id != collectionId {
+		writeJSONError(w, "a collection with this name already exists", http.StatusBadRequest)
+		return true
+	}
+
+	return false
+}
+
+// GetMyBookmarksHandler returns the signed in user's bookmarks with their notes, most recent first
+func (h *Handler) GetMyBookmarksHandler(w http.ResponseWriter, r *http.Request) {
+
+	userId, ok := r.Context().Value(AuthUserId).(int)
+	if !ok {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	pageNum := 1
+	if r.URL.Query().Get("page") != "" {
+		page, err := strconv.Atoi(r.URL.Query().Get("page"))
+		if err != nil || page < 1 {
+			writeJSONError(w, "invalid query param page", http.StatusBadRequest)
+			return
+		}
+		pageNum = page
+	}
+
+	limitNum := defaultBookmarksLimit
+	if r.URL.Query().Get("limit") != "" {
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil || limit < 1 || limit > maxBookmarksLimit {
+			writeJSONError(w, "invalid query param limit, must be between 1 and 100", http.StatusBadRequest)
+			return
+		}
+		limitNum = limit
+	}
+
+	skip := pageNum*limitNum - limitNum
+
+	bookmarks, err := h.storage.GetUserBookmarks(userId, skip, limitNum)
+	if err != nil {
+		log.Printf("failed to get user bookmarks :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	totalBookmarksCount, err := h.storage.GetUserBookmarksCount(userId)
+	if err != nil {
+		log.Printf("failed to get user bookmarks count :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if bookmarks == nil {
+		bookmarks = []storage.BookmarkedBlog{}
+	}
+
+	noOfPages := int(math.Ceil(float64(totalBookmarksCount) / float64(limitNum)))
+
+	type Response struct {
+		Success   bool                     `json:"success"`
+		Bookmarks []storage.BookmarkedBlog `json:"bookmarks"`
+		NoOfPages int                      `json:"no_of_pages"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Bookmarks: bookmarks, NoOfPages: noOfPages}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// UpdateBookmarkNoteHandler sets the private note on the signed in user's bookmark of the blog
+func (h *Handler) UpdateBookmarkNoteHandler(w http.ResponseWriter, r *http.Request) {
+
+	userId, ok := r.Context().Value(AuthUserId).(int)
+	if !ok {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	blogId, err := strconv.Atoi(chi.URLParam(r, "blogId"))
+	if err != nil {
+		writeJSONError(w, "invalid request param blogId", http.StatusBadRequest)
+		return
+	}
+
+	var updateBookmarkNotePayload UpdateBookmarkNotePayload
+
+	if err := json.NewDecoder(r.Body).Decode(&updateBookmarkNotePayload); err != nil {
+		writeJSONError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	noteText := strings.TrimSpace(updateBookmarkNotePayload.Note)
+
+	if utf8.RuneCountInString(noteText) > maxBookmarkNoteLength {
+		writeJSONError(w, "note must be at most 2000 characters", http.StatusBadRequest)
+		return
+	}
+
+	var note *string
+	if noteText != "" {
+		note = &noteText
+	}
+
+	bookmark, err := h.storage.UpdateBlogBookmarkNote(userId, blogId, note)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, "bookmark not found", http.StatusBadRequest)
+			return
+		} else {
+			log.Printf("failed to update bookmark note :- %v\n", err.Error())
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	type Response struct {
+		Success  bool                 `json:"success"`
+		Message  string               `json:"message"`
+		Bookmark storage.BlogBookmark `json:"bookmark"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Message: "bookmark note updated", Bookmark: *bookmark}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) CreateCollectionHandler(w http.ResponseWriter, r *http.Request) {
+
+	userId, ok := r.Context().Value(AuthUserId).(int)
+	if !ok {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var createCollectionPayload CreateCollectionPayload
+
+	if err := json.NewDecoder(r.Body).Decode(&createCollectionPayload); err != nil {
+		writeJSONError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(createCollectionPayload.Name)
+	descriptionText := strings.TrimSpace(createCollectionPayload.Description)
+
+	visibility := string(storage.PrivateCollectionVisibility)
+	if createCollectionPayload.Visibility != "" {
+		visibility = createCollectionPayload.Visibility
+	}
+
+	if reason := validateCollection(name, descriptionText, visibility); reason != "" {
+		writeJSONError(w, reason, http.StatusBadRequest)
+		return
+	}
+
+	if h.collectionNameTaken(w, userId, name, 0) {
+		return
+	}
+
+	var description *string
+	if descriptionText != "" {
+		description = &descriptionText
+	}
+
+	collection, err := h.storage.CreateBookmarkCollection(userId, name, description, storage.CollectionVisibility(visibility))
+	if err != nil {
+		log.Printf("failed to create bookmark collection :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	type Response struct {
+		Success    bool                       `json:"success"`
+		Message    string                     `json:"message"`
+		Collection storage.BookmarkCollection `json:"collection"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Message: "collection created", Collection: *collection}, http.StatusCreated); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) GetCollectionsHandler(w http.ResponseWriter, r *http.Request) {
+
+	userId, ok := r.Context().Value(AuthUserId).(int)
+	if !ok {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	collections, err := h.storage.GetBookmarkCollectionsByOwner(userId)
+	if err != nil {
+		log.Printf("failed to get bookmark collections :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if collections == nil {
+		collections = []storage.BookmarkCollectionWithCount{}
+	}
+
+	type Response struct {
+		Success     bool                                  `json:"success"`
+		Collections []storage.BookmarkCollectionWithCount `json:"collections"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Collections: collections}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) UpdateCollectionHandler(w http.ResponseWriter, r *http.Request) {
+
+	collection, ok := h.ownedBookmarkCollection(w, r)
+	if !ok {
+		return
+	}
+
+	var updateCollectionPayload UpdateCollectionPayload
+
+	if err := json.NewDecoder(r.Body).Decode(&updateCollectionPayload); err != nil {
+		writeJSONError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	name := collection.Name
+	if updateCollectionPayload.Name != nil {
+		name = strings.TrimSpace(*updateCollectionPayload.Name)
+	}
+
+	descriptionText := ""
+	if collection.Description != nil {
+		descriptionText = *collection.Description
+	}
+	if updateCollectionPayload.Description != nil {
+		descriptionText = strings.TrimSpace(*updateCollectionPayload.Description)
+	}
+
+	visibility := string(collection.Visibility)
+	if updateCollectionPayload.Visibility != nil {
+		visibility = *updateCollectionPayload.Visibility
+	}
+
+	if reason := validateCollection(name, descriptionText, visibility); reason != "" {
+		writeJSONError(w, reason, http.StatusBadRequest)
+		return
+	}
+
+	if h.collectionNameTaken(w, collection.OwnerId, name, collection.Id) {
+		return
+	}
+
+	var description *string
+	if descriptionText != "" {
+		description = &descriptionText
+	}
+
+	updatedCollection, err := h.storage.UpdateBookmarkCollection(collection.Id, name, description, storage.CollectionVisibility(visibility))
+	if err != nil {
+		log.Printf("failed to update bookmark collection :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	type Response struct {
+		Success    bool                       `json:"success"`
+		Message    string                     `json:"message"`
+		Collection storage.BookmarkCollection `json:"collection"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Message: "collection updated", Collection: *updatedCollection}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// DeleteCollectionHandler deletes the collection, the blogs in it stay bookmarked
+func (h *Handler) DeleteCollectionHandler(w http.ResponseWriter, r *http.Request) {
+
+	collection, ok := h.ownedBookmarkCollection(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.storage.DeleteBookmarkCollection(collection.Id); err != nil {
+		log.Printf("failed to delete bookmark collection :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	type Response struct {
+		Success bool   `json:"success"`
+		Message string `json:"message"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Message: "collection deleted"}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// AddCollectionItemHandler puts the blog at the end of the collection, bookmarking it if the user has not
+func (h *Handler) AddCollectionItemHandler(w http.ResponseWriter, r *http.Request) {
+
+	collection, ok := h.ownedBookmarkCollection(w, r)
+	if !ok {
+		return
+	}
+
+	var addCollectionItemPayload AddCollectionItemPayload
+
+	if err := json.NewDecoder(r.Body).Decode(&addCollectionItemPayload); err != nil {
+		writeJSONError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	blog, err := h.storage.GetBlogById(addCollectionItemPayload.BlogId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, "blog not found", http.StatusBadRequest)
+			return
+		} else {
+			log.Printf("failed to get blog :- %v\n", err.Error())
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	bookmarked, err := h.storage.AddBookmarkCollectionItem(collection.Id, collection.OwnerId, blog.Id)
+	if err != nil {
+		log.Printf("failed to add bookmark collection item :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if bookmarked {
+		h.publishBlogCounts(blog.Id)
+	}
+
+	type Response struct {
+		Success bool   `json:"success"`
+		Message string `json:"message"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Message: "added blog to collection"}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// RemoveCollectionItemHandler takes the blog out of the collection, it stays bookmarked
+func (h *Handler) RemoveCollectionItemHandler(w http.ResponseWriter, r *http.Request) {
+
+	collection, ok := h.ownedBookmarkCollection(w, r)
+	if !ok {
+		return
+	}
+
+	blogId, err := strconv.Atoi(chi.URLParam(r, "blogId"))
+	if err != nil {
+		writeJSONError(w, "invalid request param blogId", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.storage.RemoveBookmarkCollectionItem(collection.Id, blogId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, "blog not in collection", http.StatusBadRequest)
+			return
+		} else {
+			log.Printf("failed to remove bookmark collection item :- %v\n", err.Error())
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	type Response struct {
+		Success bool   `json:"success"`
+		Message string `json:"message"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Message: "removed blog from collection"}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// ReorderCollectionHandler puts the blogs of the collection in the order of blog_ids
+func (h *Handler) ReorderCollectionHandler(w http.ResponseWriter, r *http.Request) {
+
+	collection, ok := h.ownedBookmarkCollection(w, r)
+	if !ok {
+		return
+	}
+
+	var reorderCollectionPayload ReorderCollectionPayload
+
+	if err := json.NewDecoder(r.Body).Decode(&reorderCollectionPayload); err != nil {
+		writeJSONError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.storage.ReorderBookmarkCollection(collection.Id, reorderCollectionPayload.BlogIds); err != nil {
+		if errors.Is(err, storage.ErrCollectionOrderMismatch) {
+			writeJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		} else {
+			log.Printf("failed to reorder bookmark collection :- %v\n", err.Error())
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	type Response struct {
+		Success bool   `json:"success"`
+		Message string `json:"message"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Message: "collection reordered"}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetCollectionHandler returns the collection and its blogs in order. anyone can view a public collection,
+// a private one only its owner. notes are only shown to the owner
+func (h *Handler) GetCollectionHandler(w http.ResponseWriter, r *http.Request) {
+
+	collectionId, err := strconv.Atoi(chi.URLParam(r, "collectionId"))
+	if err != nil {
+		writeJSONError(w, "invalid request param collectionId", http.StatusBadRequest)
+		return
+	}
+
+	collection, err := h.storage.GetBookmarkCollectionById(collectionId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, "collection not found", http.StatusBadRequest)
+			return
+		} else {
+			log.Printf("failed to get bookmark collection :- %v\n", err.Error())
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	userId, signedIn := authTokenUserId(r)
+	isOwner := signedIn && userId == collection.OwnerId
+
+	// private collections are not found for anyone else, so their existence is not given away
+	if collection.Visibility != storage.PublicCollectionVisibility && !isOwner {
+		writeJSONError(w, "collection not found", http.StatusBadRequest)
+		return
+	}
+
+	owner, err := h.storage.GetUserById(collection.OwnerId)
+	if err != nil {
+		log.Printf("failed to get user by id :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	items, err := h.storage.GetBookmarkCollectionItems(collection.Id)
+	if err != nil {
+		log.Printf("failed to get bookmark collection items :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if items == nil {
+		items = []storage.BookmarkCollectionItem{}
+	}
+
+	if !isOwner {
+		for i := range items {
+			items[i].Note = nil
+		}
+	}
+
+	type CollectionOwner struct {
+		Id       int     `json:"id"`
+		Name     *string `json:"name"`
+		ImageUrl *string `json:"image_url"`
+	}
+
+	type Response struct {
+		Success    bool                             `json:"success"`
+		Collection storage.BookmarkCollection       `json:"collection"`
+		Owner      CollectionOwner                  `json:"owner"`
+		Items      []storage.BookmarkCollectionItem `json:"items"`
+		// link to the collection's public page, only for public collections
+		ShareUrl *string `json:"share_url"`
+	}
+
+	resp := Response{
+		Success:    true,
+		Collection: *collection,
+		Owner:      CollectionOwner{Id: owner.Id, Name: owner.Name, ImageUrl: owner.ImageUrl},
+		Items:      items,
+	}
+
+	if collection.Visibility == storage.PublicCollectionVisibility {
+		shareUrl := collectionUrl(h.cfg.ClientUrl, collection.Id)
+		resp.ShareUrl = &shareUrl
+	}
+
+	if err := writeJSON(w, resp, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/dhruv15803/echo-blog-app/storage"
+)
+
+const (
+	dashboardDateLayout     = "2006-01-02"
+	defaultDashboardDays    = 30
+	maxDashboardDays        = 366 * 3
+	dashboardTopBlogsLength = 5
+)
+
+// GetDashboardHandler returns an overview of the signed in author's blogs between the dates of the query
+// params from and to, 30 days up to today by default. activity is grouped into periods of the query param
+// granularity, day, week or month. it reads the daily rollups, so today's activity shows up within an hour
+func (h *Handler) GetDashboardHandler(w http.ResponseWriter, r *http.Request) {
+
+	userId, ok := r.Context().Value(AuthUserId).(int)
+	if !ok {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	to := time.Now().UTC().Truncate(time.Hour * 24)
+	if r.URL.Query().Get("to") != "" {
+		date, err := time.Parse(dashboardDateLayout, r.URL.Query().Get("to"))
+		if err != nil {
+			writeJSONError(w, "invalid query param to, expected a date like 2024-01-31", http.StatusBadRequest)
+			return
+		}
+		to = date
+	}
+
+	from := to.AddDate(0, 0, -(defaultDashboardDays - 1))
+	if r.URL.Query().Get("from") != "" {
+		date, err := time.Parse(dashboardDateLayout, r.URL.Query().Get("from"))
+		if err != nil {
+			writeJSONError(w, "invalid query param from, expected a date like 2024-01-01", http.StatusBadRequest)
+			return
+		}
+		from = date
+	}
+
+	if from.After(to) || to.Sub(from) > time.Hour*24*maxDashboardDays {
+		writeJSONError(w, "invalid date range, from must be before to and at most 3 years apart", http.StatusBadRequest)
+		return
+	}
+
+	granularity := storage.DayDashboardGranularity
+	if r.URL.Query().Get("granularity") != "" {
+		granularity = storage.DashboardGranularity(r.URL.Query().Get("granularity"))
+		if !slices.Contains(storage.DashboardGranularities, granularity) {
+			writeJSONError(w, "invalid query param granularity, must be day, week or month", http.StatusBadRequest)
+			return
+		}
+	}
+
+	series, err := h.storage.GetAuthorDashboardSeries(userId, from, to, granularity)
+	if err != nil {
+		log.Printf("failed to get dashboard series :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	topBlogs, err := h.storage.GetAuthorTopBlogs(userId, from, to, dashboardTopBlogsLength, likesCountWt, commentsCountWt, bookmarksCountWt)
+	if err != nil {
+		log.Printf("failed to get dashboard top blogs :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	topics, err := h.storage.GetAuthorTopicBreakdown(userId, from, to)
+	if err != nil {
+		log.Printf("failed to get dashboard topics :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	counts, err := h.storage.GetAuthorCounts(userId)
+	if err != nil {
+		log.Printf("failed to get author counts :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	type DashboardTotals struct {
+		Views           int     `json:"views"`
+		Reads           int     `json:"reads"`
+		ReadRatio       float64 `json:"read_ratio"`
+		Likes           int     `json:"likes"`
+		Comments        int     `json:"comments"`
+		Bookmarks       int     `json:"bookmarks"`
+		FollowersGained int     `json:"followers_gained"`
+		FollowersLost   int     `json:"followers_lost"`
+		// followers and blogs the author has now, not only in the date range
+		Followers int `json:"followers"`
+		Blogs     int `json:"blogs"`
+	}
+
+	type Dashboard struct {
+		From        string                       `json:"from"`
+		To          string                       `json:"to"`
+		Granularity storage.DashboardGranularity `json:"granularity"`
+		Totals      DashboardTotals              `json:"totals"`
+		Series      []storage.DashboardBucket    `json:"series"`
+		TopBlogs    []storage.DashboardBlog      `json:"top_blogs"`
+		Topics      []storage.DashboardTopic     `json:"topics"`
+	}
+
+	totals := DashboardTotals{Followers: counts.Followers, Blogs: counts.Blogs}
+
+	for _, bucket := range series {
+		totals.Views += bucket.Views
+		totals.Reads += bucket.Reads
+		totals.Likes += bucket.Likes
+		totals.Comments += bucket.Comments
+		totals.Bookmarks += bucket.Bookmarks
+		totals.FollowersGained += bucket.FollowersGained
+		totals.FollowersLost += bucket.FollowersLost
+	}
+
+	if totals.Views > 0 {
+		totals.ReadRatio = float64(totals.Reads) / float64(totals.Views)
+	}
+
+	if topBlogs == nil {
+		topBlogs = []storage.DashboardBlog{}
+	}
+	if topics == nil {
+		topics = []storage.DashboardTopic{}
+	}
+
+	type Response struct {
+		Success   bool      `json:"success"`
+		Dashboard Dashboard `json:"dashboard"`
+	}
+
+	dashboard := Dashboard{
+		From:        from.Format(dashboardDateLayout),
+		To:          to.Format(dashboardDateLayout),
+		Granularity: granularity,
+		Totals:      totals,
+		Series:      series,
+		TopBlogs:    topBlogs,
+		Topics:      topics,
+	}
+
+	if err := writeJSON(w, Response{Success: true, Dashboard: dashboard}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dhruv15803/echo-blog-app/realtime"
+	"github.com/dhruv15803/echo-blog-app/storage"
+)
+
+const (
+	eventsHeartbeatInterval = 25 * time.Second
+	// events a connection may fall behind by before it is dropped
+	eventsBufferSize = 32
+)
+
+// connectionLimiter caps the number of open event streams per user
+type connectionLimiter struct {
+	mu          sync.Mutex
+	connections map[int]int
+	max         int
+}
+
+func newConnectionLimiter(max int) *connectionLimiter {
+	return &connectionLimiter{connections: make(map[int]int), max: max}
+}
+
+func (l *connectionLimiter) acquire(userId int) bool {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.connections[userId] >= l.max {
+		return false
+	}
+
+	l.connections[userId]++
+	return true
+}
+
+func (l *connectionLimiter) release(userId int) {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.connections[userId]--
+	if l.connections[userId] <= 0 {
+		delete(l.connections, userId)
+	}
+}
+
+// EventsHandler streams server sent events to the authenticated user, their notifications
+// always and the live counters and new comments of the blog passed as blog_id
+func (h *Handler) EventsHandler(w http.ResponseWriter, r *http.Request) {
+
+	userId, ok := r.Context().Value(AuthUserId).(int)
+	if !ok {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	topics := []string{realtime.UserTopic(userId)}
+
+	if r.URL.Query().Has("blog_id") {
+
+		blogId, err := strconv.Atoi(r.URL.Query().Get("blog_id"))
+		if err != nil {
+			writeJSONError(w, "invalid query param blog_id", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := h.storage.GetBlogById(blogId); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				writeJSONError(w, "blog not found", http.StatusBadRequest)
+				return
+			} else {
+				writeJSONError(w, "internal server error", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		topics = append(topics, realtime.BlogTopic(blogId))
+	}
+
+	if !h.connections.acquire(userId) {
+		writeJSONError(w, "too many open event streams", http.StatusTooManyRequests)
+		return
+	}
+	defer h.connections.release(userId)
+
+	// the stream outlives the server's write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("failed to clear write deadline for event stream :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	subscription := h.broker.Subscribe(eventsBufferSize, topics...)
+	defer subscription.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// clients reconnect after 3s if the stream drops
+	fmt.Fprint(w, "retry: 3000\n\n")
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(eventsHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-subscription.Dropped:
+			// the client fell too far behind, it reconnects and refetches
+			fmt.Fprint(w, "event: dropped\ndata: {}\n\n")
+			rc.Flush()
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case event := <-subscription.Events():
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, event.Data); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// publishBlogCounts pushes the blog's current counters to its viewers, best effort
+func (h *Handler) publishBlogCounts(blogId int) {
+
+	blogCounts, err := h.storage.GetBlogCounts(blogId)
+	if err != nil {
+		log.Printf("failed to get blog counts :- %v\n", err.Error())
+		return
+	}
+
+	h.publish(realtime.BlogTopic(blogId), realtime.BlogCountsEvent, blogCounts)
+}
+
+func (h *Handler) publishNewComment(blogComment storage.BlogComment) {
+	h.publish(realtime.BlogTopic(blogComment.BlogId), realtime.CommentCreatedEvent, blogComment)
+}
+
+func (h *Handler) publish(topic string, eventType string, data any) {
+
+	event, err := realtime.NewEvent(topic, eventType, data)
+	if err != nil {
+		log.Printf("failed to build %s event :- %v\n", eventType, err.Error())
+		return
+	}
+
+	if err := h.broker.Publish(event); err != nil {
+		log.Printf("failed to publish %s event :- %v\n", eventType, err.Error())
+	}
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dhruv15803/echo-blog-app/feeds"
+	"github.com/dhruv15803/echo-blog-app/render"
+	"github.com/dhruv15803/echo-blog-app/storage"
+	"github.com/go-chi/chi/v5"
+)
+
+const feedSiteTitle = "Echo Blog"
+
+func (h *Handler) LatestFeedHandler(w http.ResponseWriter, r *http.Request) {
+
+	format, ok := feedFormat(w, r)
+	if !ok {
+		return
+	}
+
+	h.writeFeed(w, r, format, feeds.Feed{
+		Title:       feedSiteTitle,
+		Description: "The latest blogs on " + feedSiteTitle,
+		Link:        h.cfg.ClientUrl,
+	}, nil, nil)
+}
+
+func (h *Handler) TopicFeedHandler(w http.ResponseWriter, r *http.Request) {
+
+	format, ok := feedFormat(w, r)
+	if !ok {
+		return
+	}
+
+	topicId, err := strconv.Atoi(chi.URLParam(r, "topicId"))
+	if err != nil {
+		writeJSONError(w, "invalid request param topicId", http.StatusBadRequest)
+		return
+	}
+
+	topic, err := h.storage.GetTopicById(topicId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, "topic not found", http.StatusBadRequest)
+			return
+		} else {
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	h.writeFeed(w, r, format, feeds.Feed{
+		Title:       topic.TopicTitle + " - " + feedSiteTitle,
+		Description: "The latest blogs about " + topic.TopicTitle,
+		Link:        topicUrl(h.cfg.ClientUrl, topic.TopicSlug),
+	}, &topic.Id, nil)
+}
+
+func (h *Handler) UserFeedHandler(w http.ResponseWriter, r *http.Request) {
+
+	format, ok := feedFormat(w, r)
+	if !ok {
+		return
+	}
+
+	userId, err := strconv.Atoi(chi.URLParam(r, "userId"))
+	if err != nil {
+		writeJSONError(w, "invalid request param userId", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.storage.GetUserById(userId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, "user not found", http.StatusBadRequest)
+			return
+		} else {
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	name := "user " + strconv.Itoa(user.Id)
+	if user.Name != nil && *user.Name != "" {
+		name = *user.Name
+	}
+
+	h.writeFeed(w, r, format, feeds.Feed{
+		Title:       name + " - " + feedSiteTitle,
+		Description: "The latest blogs by " + name,
+		Link:        profileUrl(h.cfg.ClientUrl, user.Id),
+	}, nil, &user.Id)
+}
+
+// feedFormat reads the format from the extension of the feed path, e.g. rss in /feeds/latest.rss
+func feedFormat(w http.ResponseWriter, r *http.Request) (feeds.Format, bool) {
+
+	format := feeds.Format(chi.URLParam(r, "format"))
+	if !slices.Contains(feeds.Formats, format) {
+		writeJSONError(w, "invalid feed format, expected one of rss, atom or json", http.StatusNotFound)
+		return "", false
+	}
+
+	return format, true
+}
+
+// writeFeed fills the feed with the latest published blogs matching the topic and author and writes it.
+// the etag is a hash of the encoded feed, so it also changes when a blog is hidden or deleted, which the
+// last modified time, the time of the newest change to a blog in the feed, does not reflect
+func (h *Handler) writeFeed(w http.ResponseWriter, r *http.Request, format feeds.Format, feed feeds.Feed, topicId *int, authorId *int) {
+
+	blogs, err := h.storage.GetFeedBlogs(topicId, authorId, h.cfg.FeedMaxItems)
+	if err != nil {
+		log.Printf("failed to get feed blogs :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	feed.FeedUrl = h.cfg.ApiUrl + r.URL.Path
+
+	for _, blog := range blogs {
+		feed.Items = append(feed.Items, feedItem(blog, h.cfg.ClientUrl, h.cfg.ApiUrl))
+		if blog.BlogUpdatedAt.After(feed.Updated) {
+			feed.Updated = blog.BlogUpdatedAt
+		}
+	}
+
+	body, err := feeds.Encode(feed, format)
+	if err != nil {
+		log.Printf("failed to encode feed :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	hash := sha256.Sum256(body)
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("ETag", `"`+hex.EncodeToString(hash[:16])+`"`)
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	// ServeContent answers If-None-Match and If-Modified-Since with 304 Not Modified
+	http.ServeContent(w, r, "", feed.Updated.Truncate(time.Second), bytes.NewReader(body))
+}
+
+// the id of an item is the api url of its blog, which unlike the slug stays the same when the title changes
+func feedItem(blog storage.FeedBlog, clientUrl string, apiUrl string) feeds.Item {
+
+	item := feeds.Item{
+		Id:          apiUrl + "/api/blog/" + strconv.Itoa(blog.Id),
+		Link:        blogUrl(clientUrl, blog.BlogSlug),
+		Title:       blog.BlogTitle,
+		ContentHTML: render.HTML(blogDocument(blog.BlogContent)),
+		Categories:  blog.BlogTopics,
+		Published:   blog.BlogCreatedAt,
+		Updated:     blog.BlogUpdatedAt,
+	}
+
+	if blog.BlogDescription != nil {
+		item.Summary = strings.TrimSpace(*blog.BlogDescription)
+	}
+	if blog.AuthorName != nil {
+		item.Author = *blog.AuthorName
+	}
+
+	return item
+}
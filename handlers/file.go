@@ -1,105 +1,355 @@
 package handlers
 
 import (
-	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"os"
+	"strconv"
+	"time"
 
-	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
+	"github.com/dhruv15803/echo-blog-app/blobstore"
+	"github.com/dhruv15803/echo-blog-app/imaging"
+	"github.com/dhruv15803/echo-blog-app/media"
+	"github.com/dhruv15803/echo-blog-app/storage"
+	"github.com/go-chi/chi/v5"
 )
 
 var (
 	FILE_KEY string = "imageFile"
 )
 
+// how long a client has to upload a file to the url of an upload intent
+const uploadIntentExpiry = time.Minute * 15
+
+// how much of an uploaded file is read to check its magic bytes
+const sniffLength = 512
+
+type CreateUploadIntentPayload struct {
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+}
+
 func (h *Handler) UploadFileHandler(w http.ResponseWriter, r *http.Request) {
 
-	// get file from request (Content-Type:"multipart/form-data")
-	// store file on server
-	// try upload to cloudinary
-	// send url response back to client
+	// get image from request (Content-Type:"multipart/form-data")
+	// return the user's existing media if they uploaded the same file before
+	// validate it by its magic bytes and re-encode it without metadata into its variants
+	// store every variant under one server generated key and record it in the media library
+
+	userId, ok := r.Context().Value(AuthUserId).(int)
+	if !ok {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
 
-	file, fileHeader, err := r.FormFile(FILE_KEY)
+	r.Body = http.MaxBytesReader(w, r.Body, h.cfg.MaxUploadBytes)
+
+	file, _, err := r.FormFile(FILE_KEY)
 	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeJSONError(w, fmt.Sprintf("file is larger than %d bytes", h.cfg.MaxUploadBytes), http.StatusRequestEntityTooLarge)
+			return
+		}
 		writeJSONError(w, fmt.Sprintf("failed to read multipart/form-data file with key %s", FILE_KEY), http.StatusBadRequest)
 		return
 	}
+	defer file.Close()
 
-	log.Printf("Filename :- %s", fileHeader.Filename)
-	log.Printf("File Size :- %v", fileHeader.Size)
+	data, err := io.ReadAll(file)
+	if err != nil {
+		writeJSONError(w, "failed to read file", http.StatusBadRequest)
+		return
+	}
 
-	// create file on server
-	serverImageFilePath := fmt.Sprintf("./uploads/%s", fileHeader.Filename)
+	media, ok := h.createMedia(w, r, userId, data)
+	if !ok {
+		return
+	}
 
-	dest, err := os.Create(serverImageFilePath)
+	writeUploadResponse(w, media)
+}
+
+// createMedia processes an uploaded image and records it in the user's media library, or returns the
+// media the user already has for the same file. on failure it writes the error response and returns false
+func (h *Handler) createMedia(w http.ResponseWriter, r *http.Request, userId int, data []byte) (*storage.Media, bool) {
+
+	created, err := media.Create(r.Context(), h.storage, h.blobs, userId, data, h.cfg.MediaQuotaBytes)
 	if err != nil {
-		log.Printf("failed to create dest file on server :- %v\n", err.Error())
+		switch {
+		case errors.Is(err, imaging.ErrUnsupportedFormat):
+			writeJSONError(w, "only jpeg , png , webp and gif images are allowed", http.StatusUnsupportedMediaType)
+		case errors.Is(err, imaging.ErrImageTooLarge):
+			writeJSONError(w, "image dimensions are too large", http.StatusRequestEntityTooLarge)
+		case errors.Is(err, media.ErrInvalidImage):
+			writeJSONError(w, "invalid image", http.StatusBadRequest)
+		case errors.Is(err, storage.ErrMediaQuotaExceeded):
+			writeJSONError(w, "storage quota exceeded", http.StatusForbidden)
+		default:
+			log.Printf("failed to create media :- %v\n", err.Error())
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		}
+		return nil, false
+	}
+
+	return created, true
+}
+
+func writeUploadResponse(w http.ResponseWriter, media *storage.Media) {
+
+	original := media.Variants[imaging.OriginalVariant]
+
+	type Response struct {
+		Success     bool                  `json:"success"`
+		MediaId     int                   `json:"media_id"`
+		Url         string                `json:"url"`
+		Key         string                `json:"key"`
+		ContentType string                `json:"content_type"`
+		Width       int                   `json:"width"`
+		Height      int                   `json:"height"`
+		Blurhash    *string               `json:"blurhash"`
+		Variants    storage.MediaVariants `json:"variants"`
+	}
+
+	if err := writeJSON(w, Response{
+		Success:     true,
+		MediaId:     media.Id,
+		Url:         original.Url,
+		Key:         original.Key,
+		ContentType: media.MimeType,
+		Width:       media.Width,
+		Height:      media.Height,
+		Blurhash:    media.Blurhash,
+		Variants:    media.Variants,
+	}, http.StatusOK); err != nil {
 		writeJSONError(w, "internal server error", http.StatusInternalServerError)
 		return
 	}
+}
 
-	_, err = io.Copy(dest, file)
-	if err != nil {
-		log.Println("failed to copy contents from multipart file to destination file")
+func (h *Handler) CreateUploadIntentHandler(w http.ResponseWriter, r *http.Request) {
+
+	// validate the type and size the client wants to upload
+	// presign an upload of exactly that to a server generated key of the blob store
+	// the client uploads the file there and then completes the intent
+
+	userId, ok := r.Context().Value(AuthUserId).(int)
+	if !ok {
 		writeJSONError(w, "internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	file.Close()
-	dest.Close()
+	var createUploadIntentPayload CreateUploadIntentPayload
+
+	if err := json.NewDecoder(r.Body).Decode(&createUploadIntentPayload); err != nil {
+		writeJSONError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	format, ok := imaging.ParseContentType(createUploadIntentPayload.ContentType)
+	if !ok {
+		writeJSONError(w, "only jpeg , png , webp and gif images are allowed", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	if createUploadIntentPayload.Size <= 0 {
+		writeJSONError(w, "invalid size", http.StatusBadRequest)
+		return
+	}
+
+	if createUploadIntentPayload.Size > h.cfg.MaxDirectUploadBytes {
+		writeJSONError(w, fmt.Sprintf("file is larger than %d bytes", h.cfg.MaxDirectUploadBytes), http.StatusRequestEntityTooLarge)
+		return
+	}
 
-	// dest file has the file contents
-	// open dest file and upload to cloudinary
+	presigner, ok := h.blobs.(blobstore.Presigner)
+	if !ok {
+		writeJSONError(w, "direct uploads are not supported", http.StatusNotImplemented)
+		return
+	}
 
-	uploadFile, err := os.Open(serverImageFilePath)
+	usage, err := h.storage.GetMediaUsage(userId)
 	if err != nil {
+		log.Printf("failed to get media usage :- %v\n", err.Error())
 		writeJSONError(w, "internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	maxRetries := 3
-	retryCount := 0
-	isImageFileUploaded := false
-	var result *uploader.UploadResult
+	if usage+createUploadIntentPayload.Size > h.cfg.MediaQuotaBytes {
+		writeJSONError(w, "storage quota exceeded", http.StatusForbidden)
+		return
+	}
 
-	for retryCount < maxRetries {
-		result, err = h.cld.Upload.Upload(context.TODO(), uploadFile, uploader.UploadParams{})
-		if err != nil {
-			log.Printf("failed to upload file to cloudinary, retry count = %v", retryCount+1)
-			retryCount++
-		}
+	key, err := blobstore.NewKey("incoming", format.Extension())
+	if err != nil {
+		log.Printf("failed to generate blob key :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
 
-		// successfully uploaded
-		isImageFileUploaded = true
-		if isImageFileUploaded {
-			break
-		}
+	target, err := presigner.PresignPut(key, format.ContentType(), createUploadIntentPayload.Size, uploadIntentExpiry)
+	if err != nil {
+		log.Printf("failed to presign upload :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
 	}
 
-	if !isImageFileUploaded {
-		log.Printf("failed to upload file to cloudinary :- %v\n", err.Error())
+	intent, err := h.storage.CreateUploadIntent(userId, key, format.ContentType(), createUploadIntentPayload.Size, uploadIntentExpiry)
+	if err != nil {
+		log.Printf("failed to create upload intent :- %v\n", err.Error())
 		writeJSONError(w, "internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	uploadFile.Close()
+	type Response struct {
+		Success      bool                    `json:"success"`
+		UploadIntent storage.UploadIntent    `json:"upload_intent"`
+		Upload       *blobstore.UploadTarget `json:"upload"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, UploadIntent: *intent, Upload: target}, http.StatusCreated); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) CompleteUploadIntentHandler(w http.ResponseWriter, r *http.Request) {
 
-	if err = os.Remove(serverImageFilePath); err != nil {
-		log.Printf("failed to remove file :- %v\n", err.Error())
+	// check the size and magic bytes of the uploaded file against the intent
+	// queue it to be processed into the media library by the media processor
+	// completing it again reports the progress , and the media once it is processed
+
+	userId, ok := r.Context().Value(AuthUserId).(int)
+	if !ok {
 		writeJSONError(w, "internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	type Response struct {
-		Success bool   `json:"success"`
-		Url     string `json:"url"`
+	intentId, err := strconv.Atoi(chi.URLParam(r, "intentId"))
+	if err != nil {
+		writeJSONError(w, "invalid request param intentId", http.StatusBadRequest)
+		return
 	}
 
-	if err := writeJSON(w, Response{Success: true, Url: result.SecureURL}, http.StatusOK); err != nil {
+	intent, err := h.storage.GetUploadIntentById(intentId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, "upload intent not found", http.StatusBadRequest)
+			return
+		}
+		log.Printf("failed to get upload intent :- %v\n", err.Error())
 		writeJSONError(w, "internal server error", http.StatusInternalServerError)
 		return
 	}
+
+	if intent.OwnerId != userId {
+		writeJSONError(w, "upload intent not found", http.StatusBadRequest)
+		return
+	}
+
+	if intent.Status == storage.CreatedUploadIntentStatus {
+
+		if intent.IsExpired {
+			writeJSONError(w, "upload intent expired", http.StatusGone)
+			return
+		}
+
+		if !h.checkUploadedFile(w, r, intent) {
+			return
+		}
+
+		queued, err := h.storage.QueueUploadIntent(intent.Id)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("failed to queue upload intent :- %v\n", err.Error())
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		// a concurrent completion queued it first
+		if errors.Is(err, sql.ErrNoRows) {
+			queued, err = h.storage.GetUploadIntentById(intent.Id)
+			if err != nil {
+				log.Printf("failed to get upload intent :- %v\n", err.Error())
+				writeJSONError(w, "internal server error", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		intent = queued
+	}
+
+	switch intent.Status {
+	case storage.CompletedUploadIntentStatus:
+		if intent.MediaId == nil {
+			writeJSONError(w, "media of upload intent was deleted", http.StatusGone)
+			return
+		}
+
+		media, err := h.storage.GetMediaById(*intent.MediaId)
+		if err != nil {
+			log.Printf("failed to get media :- %v\n", err.Error())
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		writeUploadResponse(w, media)
+	case storage.FailedUploadIntentStatus:
+		message := "failed to process image"
+		if intent.Error != nil {
+			message = *intent.Error
+		}
+		writeJSONError(w, message, http.StatusUnprocessableEntity)
+	default:
+		type Response struct {
+			Success      bool                 `json:"success"`
+			UploadIntent storage.UploadIntent `json:"upload_intent"`
+		}
+
+		if err := writeJSON(w, Response{Success: true, UploadIntent: *intent}, http.StatusAccepted); err != nil {
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		}
+	}
+}
+
+// checkUploadedFile checks the size and magic bytes of the file uploaded for an intent without
+// downloading all of it. on mismatch it writes the error response and returns false
+func (h *Handler) checkUploadedFile(w http.ResponseWriter, r *http.Request, intent *storage.UploadIntent) bool {
+
+	size, err := h.blobs.Size(r.Context(), intent.StorageKey)
+	if err != nil {
+		log.Printf("failed to get uploaded file size :- %v\n", err.Error())
+		writeJSONError(w, "file has not been uploaded", http.StatusBadRequest)
+		return false
+	}
+
+	if size != intent.Size {
+		writeJSONError(w, "uploaded file does not match the upload intent size", http.StatusBadRequest)
+		return false
+	}
+
+	object, err := h.blobs.Open(r.Context(), intent.StorageKey)
+	if err != nil {
+		log.Printf("failed to open uploaded file :- %v\n", err.Error())
+		writeJSONError(w, "file has not been uploaded", http.StatusBadRequest)
+		return false
+	}
+	defer object.Close()
+
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(object, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		log.Printf("failed to read uploaded file :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return false
+	}
+
+	if format, ok := imaging.Sniff(head[:n]); !ok || format.ContentType() != intent.ContentType {
+		writeJSONError(w, "uploaded file does not match the upload intent content type", http.StatusUnsupportedMediaType)
+		return false
+	}
+
+	return true
 }
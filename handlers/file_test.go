@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dhruv15803/echo-blog-app/blobstore"
+	"github.com/dhruv15803/echo-blog-app/storage"
+)
+
+func TestCheckUploadedFile(t *testing.T) {
+
+	blobs, err := blobstore.NewLocalStore(t.TempDir(), "http://localhost:8080/uploads", []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only the magic bytes are checked, the file is decoded by the media processor
+	pngHead := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 2048)...)
+	key := "incoming/2026/10/0123456789abcdef0123456789abcdef.png"
+
+	if _, err := blobs.Put(context.Background(), key, bytes.NewReader(pngHead), int64(len(pngHead)), "image/png"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		intent     storage.UploadIntent
+		wantStatus int
+	}{
+		{name: "matches", intent: storage.UploadIntent{StorageKey: key, ContentType: "image/png", Size: int64(len(pngHead))}},
+		{name: "other size", intent: storage.UploadIntent{StorageKey: key, ContentType: "image/png", Size: 10}, wantStatus: http.StatusBadRequest},
+		{name: "other type", intent: storage.UploadIntent{StorageKey: key, ContentType: "image/jpeg", Size: int64(len(pngHead))}, wantStatus: http.StatusUnsupportedMediaType},
+		{name: "not uploaded", intent: storage.UploadIntent{StorageKey: "incoming/2026/10/missing.png", ContentType: "image/png", Size: 10}, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			h := &Handler{blobs: blobs}
+			w := httptest.NewRecorder()
+
+			ok := h.checkUploadedFile(w, httptest.NewRequest(http.MethodPost, "/", nil), &tt.intent)
+
+			if ok != (tt.wantStatus == 0) {
+				t.Fatalf("checkUploadedFile() = %v, want %v", ok, tt.wantStatus == 0)
+			}
+
+			if tt.wantStatus != 0 && w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -1,18 +1,70 @@
 package handlers
 
 import (
-	"github.com/cloudinary/cloudinary-go/v2"
+	"time"
+
+	"github.com/dhruv15803/echo-blog-app/analytics"
+	"github.com/dhruv15803/echo-blog-app/blobstore"
+	"github.com/dhruv15803/echo-blog-app/mailer"
+	"github.com/dhruv15803/echo-blog-app/notifications"
+	"github.com/dhruv15803/echo-blog-app/realtime"
 	"github.com/dhruv15803/echo-blog-app/storage"
+	"github.com/dhruv15803/echo-blog-app/webhooks"
 )
 
+type HandlerConfig struct {
+	// number of pending reports after which a blog or comment is hidden until a moderator reviews it
+	ReportAutoHideThreshold int
+	// url of the frontend, used to build links in mails
+	ClientUrl string
+	// number of event streams a user may have open at once, e.g. one per tab
+	MaxEventStreamsPerUser int
+	// key that signs digest unsubscribe links
+	UnsubscribeSecret []byte
+	// largest request body accepted by the file upload
+	MaxUploadBytes int64
+	// largest file a client may upload straight to the blob store with an upload intent
+	MaxDirectUploadBytes int64
+	// total bytes of media a user may store
+	MediaQuotaBytes int64
+	// url of the api itself, used for the self links of feeds
+	ApiUrl string
+	// number of blogs in a feed
+	FeedMaxItems int
+	// a viewer is counted once per blog in each window of this length
+	ViewDedupeWindow time.Duration
+	// key of the hashes that tell viewers apart within a dedupe window
+	ViewHashSecret []byte
+	// webhook urls must use https
+	WebhookRequireHttps bool
+	// webhook urls may resolve to loopback and private addresses
+	WebhookAllowPrivateNetworks bool
+}
+
 type Handler struct {
-	storage *storage.Storage
-	cld     *cloudinary.Cloudinary
+	storage       *storage.Storage
+	blobs         blobstore.BlobStore
+	mailer        mailer.Mailer
+	mailTemplates *mailer.Templates
+	notifier      *notifications.Service
+	broker        realtime.Broker
+	webhooks      *webhooks.Publisher
+	views         *analytics.ViewWriter
+	connections   *connectionLimiter
+	cfg           HandlerConfig
 }
 
-func NewHandler(storage *storage.Storage, cld *cloudinary.Cloudinary) *Handler {
+func NewHandler(storage *storage.Storage, blobs blobstore.BlobStore, mailer mailer.Mailer, mailTemplates *mailer.Templates, notifier *notifications.Service, broker realtime.Broker, webhookPublisher *webhooks.Publisher, views *analytics.ViewWriter, cfg HandlerConfig) *Handler {
 	return &Handler{
-		storage: storage,
-		cld:     cld,
+		storage:       storage,
+		blobs:         blobs,
+		mailer:        mailer,
+		mailTemplates: mailTemplates,
+		notifier:      notifier,
+		broker:        broker,
+		webhooks:      webhookPublisher,
+		views:         views,
+		connections:   newConnectionLimiter(cfg.MaxEventStreamsPerUser),
+		cfg:           cfg,
 	}
 }
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/dhruv15803/echo-blog-app/mailer"
+	"github.com/dhruv15803/echo-blog-app/storage"
+	"github.com/go-chi/chi/v5"
+)
+
+type UpdateLanguagePayload struct {
+	Language string `json:"language"`
+}
+
+// preferredLanguage picks the mail language for a user :- an explicitly requested
+// language if we have templates for it, else the first supported Accept-Language tag, else the default
+func (h *Handler) preferredLanguage(r *http.Request, requested string) string {
+
+	requested = strings.ToLower(strings.TrimSpace(requested))
+	if h.mailTemplates.HasLocale(requested) {
+		return requested
+	}
+
+	for _, tag := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+
+		language, _, _ := strings.Cut(strings.TrimSpace(tag), ";")
+		language, _, _ = strings.Cut(language, "-")
+		language = strings.ToLower(language)
+
+		if h.mailTemplates.HasLocale(language) {
+			return language
+		}
+	}
+
+	return mailer.DefaultLocale
+}
+
+func (h *Handler) UpdateUserLanguageHandler(w http.ResponseWriter, r *http.Request) {
+
+	userId, ok := r.Context().Value(AuthUserId).(int)
+	if !ok {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var updateLanguagePayload UpdateLanguagePayload
+
+	if err := json.NewDecoder(r.Body).Decode(&updateLanguagePayload); err != nil {
+		writeJSONError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	language := strings.ToLower(strings.TrimSpace(updateLanguagePayload.Language))
+
+	if !h.mailTemplates.HasLocale(language) {
+		writeJSONError(w, "unsupported language", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.storage.UpdateUserLanguage(userId, language)
+	if err != nil {
+		log.Printf("failed to update user language :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	type Response struct {
+		Success bool         `json:"success"`
+		Message string       `json:"message"`
+		User    storage.User `json:"user"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Message: "language updated", User: *user}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+	}
+}
+
+// MailPreviewHandler renders a mail template with sample data, only mounted in development
+func (h *Handler) MailPreviewHandler(w http.ResponseWriter, r *http.Request) {
+
+	templateName := chi.URLParam(r, "template")
+
+	if !slices.Contains(h.mailTemplates.Names(), templateName) {
+		writeJSONError(w, "mail template not found", http.StatusNotFound)
+		return
+	}
+
+	locale := r.URL.Query().Get("locale")
+	if locale == "" {
+		locale = mailer.DefaultLocale
+	}
+
+	data := mailer.TemplateData{ClientUrl: h.cfg.ClientUrl, Data: mailer.SampleData[templateName]}
+
+	subject, htmlBody, textBody, err := h.mailTemplates.Render(templateName, locale, data)
+	if err != nil {
+		log.Printf("failed to render mail template preview :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("X-Mail-Subject", subject)
+
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(textBody))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(htmlBody))
+}
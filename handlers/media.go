@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/dhruv15803/echo-blog-app/storage"
+)
+
+func (h *Handler) GetMediaHandler(w http.ResponseWriter, r *http.Request) {
+
+	userId := r.Context().Value(AuthUserId).(int)
+
+	pageNum, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil {
+		writeJSONError(w, "invalid query param page", http.StatusBadRequest)
+		return
+	}
+
+	limitNum, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil {
+		writeJSONError(w, "invalid query param limit", http.StatusBadRequest)
+		return
+	}
+
+	skip := pageNum*limitNum - limitNum
+
+	media, err := h.storage.GetMediaByOwner(userId, skip, limitNum)
+	if err != nil {
+		log.Printf("failed to get media :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	totalMediaCount, err := h.storage.GetMediaCount(userId)
+	if err != nil {
+		log.Printf("failed to get media count :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	usage, err := h.storage.GetMediaUsage(userId)
+	if err != nil {
+		log.Printf("failed to get media usage :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	noOfPages := int(math.Ceil(float64(totalMediaCount) / float64(limitNum)))
+
+	type Response struct {
+		Success    bool            `json:"success"`
+		Media      []storage.Media `json:"media"`
+		NoOfPages  int             `json:"no_of_pages"`
+		UsedBytes  int64           `json:"used_bytes"`
+		QuotaBytes int64           `json:"quota_bytes"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Media: media, NoOfPages: noOfPages, UsedBytes: usage, QuotaBytes: h.cfg.MediaQuotaBytes}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+	}
+}
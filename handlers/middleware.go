@@ -16,54 +16,88 @@ var (
 	AuthUserId = "authUserId"
 )
 
+var errAuthCookieNotFound = errors.New("auth cookie not found")
+
+// authTokenUserIdFromRequest decodes the auth cookie with the jwt secret, checks that it has not
+// expired and returns the user it was issued to
+func authTokenUserIdFromRequest(r *http.Request) (int, error) {
+
+	cookie, err := r.Cookie("auth_token")
+	if err != nil {
+		return 0, errAuthCookieNotFound
+	}
+
+	token, err := jwt.Parse(cookie.Value, func(t *jwt.Token) (any, error) {
+		return []byte(JWT_SECRET), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return 0, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return 0, errors.New("invalid claims")
+	}
+
+	if !token.Valid {
+		return 0, errors.New("invalid token")
+	}
+
+	tokenExpirationTimeUnix, ok := claims["exp"].(float64)
+	if !ok || time.Now().Unix() > int64(tokenExpirationTimeUnix) {
+		return 0, errors.New("token has expired")
+	}
+
+	userId, ok := claims["sub"].(float64)
+	if !ok {
+		return 0, errors.New("invalid token subject")
+	}
+
+	return int(userId), nil
+}
+
+// authTokenUserId is the user of a valid auth cookie, for routes that anyone may call but that
+// treat signed in users differently
+func authTokenUserId(r *http.Request) (int, bool) {
+
+	userId, err := authTokenUserIdFromRequest(r)
+	if err != nil {
+		return 0, false
+	}
+
+	return userId, true
+}
+
 func (h *Handler) AuthMiddleware(next http.Handler) http.Handler {
 
-	// extract token from request cookie
-	// decode jwt token with secret and extract payload
-	// make sure that the expiration ("exp") is greather than time.Now().Unix()
+	// extract the user of the auth cookie, rejecting missing, invalid and expired tokens
 	// attatch payload (userId) to the request context
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-		cookie, err := r.Cookie("auth_token")
-		if err != nil {
-			writeJSONError(w, "auth cookie not found", http.StatusBadRequest)
-			return
-		}
-
-		tokenStr := cookie.Value
-
-		token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (any, error) {
-			return []byte(JWT_SECRET), nil
-		})
+		userId, err := authTokenUserIdFromRequest(r)
 		if err != nil {
+			if errors.Is(err, errAuthCookieNotFound) {
+				writeJSONError(w, "auth cookie not found", http.StatusBadRequest)
+				return
+			}
 			log.Printf("failed to parse jwt token :- %v\n", err.Error())
 			writeJSONError(w, "internal server error", http.StatusInternalServerError)
 			return
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			log.Println("invalid claims")
-			writeJSONError(w, "internal server error", http.StatusInternalServerError)
-			return
-		}
-
-		if !token.Valid {
-			log.Println("invalid token")
+		isSuspended, err := h.storage.IsUserSuspended(userId)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("failed to check user suspension :- %v\n", err.Error())
 			writeJSONError(w, "internal server error", http.StatusInternalServerError)
 			return
 		}
 
-		tokenExpirationTimeUnix := int64(claims["exp"].(float64))
-		if time.Now().Unix() > tokenExpirationTimeUnix {
-			log.Println("token has expired")
-			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		if isSuspended {
+			writeJSONError(w, "user is suspended", http.StatusForbidden)
 			return
 		}
 
-		userId := int(claims["sub"].(float64))
-
 		// attatch this payload to request context
 
 		ctx := context.WithValue(r.Context(), AuthUserId, userId)
@@ -103,3 +137,34 @@ func (h *Handler) AdminMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+func (h *Handler) ModeratorMiddleware(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		userId, ok := r.Context().Value(AuthUserId).(int)
+		if !ok {
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		user, err := h.storage.GetUserById(userId)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				writeJSONError(w, "user not found", http.StatusBadRequest)
+				return
+			} else {
+				log.Printf("failed to get user by id :- %v\n", err.Error())
+				writeJSONError(w, "internal server error", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if user.Role != storage.AdminRole && user.Role != storage.ModeratorRole {
+			writeJSONError(w, "user is not a moderator", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
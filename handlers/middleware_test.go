@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func signedAuthToken(t *testing.T, secret []byte, claims jwt.MapClaims) string {
+
+	t.Helper()
+
+	tokenStr, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tokenStr
+}
+
+func TestAuthTokenUserId(t *testing.T) {
+
+	secret := JWT_SECRET
+	JWT_SECRET = []byte("test-secret")
+	t.Cleanup(func() { JWT_SECRET = secret })
+
+	validToken := signedAuthToken(t, JWT_SECRET, jwt.MapClaims{"sub": 12, "exp": time.Now().Add(time.Hour).Unix()})
+	expiredToken := signedAuthToken(t, JWT_SECRET, jwt.MapClaims{"sub": 12, "exp": time.Now().Add(-time.Hour).Unix()})
+	otherSecretToken := signedAuthToken(t, []byte("other-secret"), jwt.MapClaims{"sub": 12, "exp": time.Now().Add(time.Hour).Unix()})
+	noSubjectToken := signedAuthToken(t, JWT_SECRET, jwt.MapClaims{"exp": time.Now().Add(time.Hour).Unix()})
+	noExpiryToken := signedAuthToken(t, JWT_SECRET, jwt.MapClaims{"sub": 12})
+
+	tests := []struct {
+		name       string
+		cookie     string
+		wantUserId int
+		wantOk     bool
+	}{
+		{name: "valid token", cookie: validToken, wantUserId: 12, wantOk: true},
+		{name: "no cookie"},
+		{name: "malformed token", cookie: "not-a-token"},
+		{name: "expired token", cookie: expiredToken},
+		{name: "signed with another secret", cookie: otherSecretToken},
+		{name: "no subject", cookie: noSubjectToken},
+		{name: "no expiry", cookie: noExpiryToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: "auth_token", Value: tt.cookie})
+			}
+
+			userId, ok := authTokenUserId(r)
+			if userId != tt.wantUserId || ok != tt.wantOk {
+				t.Errorf("authTokenUserId() = %d, %v, want %d, %v", userId, ok, tt.wantUserId, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareRejectsMissingCookie(t *testing.T) {
+
+	h := &Handler{}
+
+	called := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true })
+
+	w := httptest.NewRecorder()
+	h.AuthMiddleware(next).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if called {
+		t.Error("next handler was called without an auth cookie")
+	}
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
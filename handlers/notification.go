@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/dhruv15803/echo-blog-app/digest"
+	"github.com/dhruv15803/echo-blog-app/notifications"
+	"github.com/dhruv15803/echo-blog-app/storage"
+	"github.com/go-chi/chi/v5"
+)
+
+type UpdateNotificationPreferencesPayload struct {
+	DigestFrequency *storage.DigestFrequency                                 `json:"digest_frequency"`
+	Channels        map[storage.NotificationType]storage.NotificationChannel `json:"channels"`
+}
+
+type NotificationResponse struct {
+	storage.NotificationWithActors
+	Message string `json:"message"`
+}
+
+func (h *Handler) GetNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+
+	userId, ok := r.Context().Value(AuthUserId).(int)
+	if !ok {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	pageNum, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil {
+		writeJSONError(w, "invalid query param page", http.StatusBadRequest)
+		return
+	}
+
+	limitNum, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil {
+		writeJSONError(w, "invalid query param limit", http.StatusBadRequest)
+		return
+	}
+
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+
+	skip := pageNum*limitNum - limitNum
+
+	userNotifications, err := h.storage.GetNotifications(userId, unreadOnly, skip, limitNum)
+	if err != nil {
+		log.Printf("failed to get notifications :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	totalNotificationsCount, err := h.storage.GetNotificationsCount(userId, unreadOnly)
+	if err != nil {
+		log.Printf("failed to get notifications count :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	unreadCount, err := h.storage.GetNotificationsCount(userId, true)
+	if err != nil {
+		log.Printf("failed to get unread notifications count :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	notificationResponses := make([]NotificationResponse, 0, len(userNotifications))
+	for _, notification := range userNotifications {
+		notificationResponses = append(notificationResponses, NotificationResponse{NotificationWithActors: notification, Message: notifications.Summary(notification)})
+	}
+
+	noOfPages := int(math.Ceil(float64(totalNotificationsCount) / float64(limitNum)))
+
+	type Response struct {
+		Success       bool                   `json:"success"`
+		Notifications []NotificationResponse `json:"notifications"`
+		UnreadCount   int                    `json:"unread_count"`
+		NoOfPages     int                    `json:"no_of_pages"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Notifications: notificationResponses, UnreadCount: unreadCount, NoOfPages: noOfPages}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) MarkNotificationReadHandler(w http.ResponseWriter, r *http.Request) {
+
+	userId, ok := r.Context().Value(AuthUserId).(int)
+	if !ok {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	notificationId, err := strconv.Atoi(chi.URLParam(r, "notificationId"))
+	if err != nil {
+		writeJSONError(w, "invalid request param notificationId", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.storage.MarkNotificationRead(notificationId, userId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, "notification not found", http.StatusBadRequest)
+			return
+		} else {
+			log.Printf("failed to mark notification read :- %v\n", err.Error())
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	type Response struct {
+		Success bool   `json:"success"`
+		Message string `json:"message"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Message: "notification marked as read"}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) MarkAllNotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+
+	userId, ok := r.Context().Value(AuthUserId).(int)
+	if !ok {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	markedCount, err := h.storage.MarkAllNotificationsRead(userId)
+	if err != nil {
+		log.Printf("failed to mark all notifications read :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	type Response struct {
+		Success     bool   `json:"success"`
+		Message     string `json:"message"`
+		MarkedCount int64  `json:"marked_count"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Message: "all notifications marked as read", MarkedCount: markedCount}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) GetNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+
+	userId, ok := r.Context().Value(AuthUserId).(int)
+	if !ok {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	preferences, err := h.storage.GetNotificationPreferences(userId)
+	if err != nil {
+		log.Printf("failed to get notification preferences :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	type Response struct {
+		Success     bool                            `json:"success"`
+		Preferences storage.NotificationPreferences `json:"preferences"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Preferences: *preferences}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) UpdateNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+
+	userId, ok := r.Context().Value(AuthUserId).(int)
+	if !ok {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var payload UpdateNotificationPreferencesPayload
+
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeJSONError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if payload.DigestFrequency != nil && !slices.Contains(storage.DigestFrequencies, *payload.DigestFrequency) {
+		writeJSONError(w, "invalid digest frequency", http.StatusBadRequest)
+		return
+	}
+
+	for notificationType, channel := range payload.Channels {
+		if !slices.Contains(storage.NotificationTypes, notificationType) {
+			writeJSONError(w, "invalid notification type "+string(notificationType), http.StatusBadRequest)
+			return
+		}
+		if !slices.Contains(storage.NotificationChannels, channel) {
+			writeJSONError(w, "invalid notification channel "+string(channel), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := h.storage.UpdateNotificationPreferences(userId, payload.DigestFrequency, payload.Channels); err != nil {
+		log.Printf("failed to update notification preferences :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	preferences, err := h.storage.GetNotificationPreferences(userId)
+	if err != nil {
+		log.Printf("failed to get notification preferences :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	type Response struct {
+		Success     bool                            `json:"success"`
+		Message     string                          `json:"message"`
+		Preferences storage.NotificationPreferences `json:"preferences"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Message: "notification preferences updated", Preferences: *preferences}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+	}
+}
+
+// UnsubscribeDigestHandler turns digests off for the user the signed token belongs to.
+// it needs no login, it is called by the client's unsubscribe page and by mail clients
+// supporting one-click List-Unsubscribe
+func (h *Handler) UnsubscribeDigestHandler(w http.ResponseWriter, r *http.Request) {
+
+	userId, err := digest.VerifyUnsubscribeToken(h.cfg.UnsubscribeSecret, r.URL.Query().Get("token"))
+	if err != nil {
+		writeJSONError(w, "invalid unsubscribe token", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.storage.UpdateDigestFrequency(userId, storage.OffDigestFrequency); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, "user not found", http.StatusBadRequest)
+			return
+		} else {
+			log.Printf("failed to unsubscribe user from digests :- %v\n", err.Error())
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	type Response struct {
+		Success bool   `json:"success"`
+		Message string `json:"message"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Message: "unsubscribed from digest emails"}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/dhruv15803/echo-blog-app/analytics"
+	"github.com/dhruv15803/echo-blog-app/storage"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultRelatedLimit = 5
+	maxRelatedLimit     = 20
+)
+
+// GetRelatedBlogsHandler returns the blogs most related to the blog by shared topics, similar text and
+// readers that liked both, as last computed by the related blogs job. a signed in viewer is not shown
+// their own blogs or blogs they already read
+func (h *Handler) GetRelatedBlogsHandler(w http.ResponseWriter, r *http.Request) {
+
+	blogId, err := strconv.Atoi(chi.URLParam(r, "blogId"))
+	if err != nil {
+		writeJSONError(w, "invalid request param blogId", http.StatusBadRequest)
+		return
+	}
+
+	limitNum := defaultRelatedLimit
+	if r.URL.Query().Get("limit") != "" {
+		limitNum, err = strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil || limitNum < 1 || limitNum > maxRelatedLimit {
+			writeJSONError(w, "invalid query param limit, must be between 1 and 20", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if _, err := h.storage.GetBlogById(blogId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, "blog not found", http.StatusBadRequest)
+			return
+		} else {
+			log.Printf("failed to get blog :- %v\n", err.Error())
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	var viewerId *int
+	if userId, ok := authTokenUserId(r); ok {
+		viewerId = &userId
+	}
+
+	blogs, err := h.storage.GetRelatedBlogs(blogId, viewerId, analytics.ReadPercent, limitNum)
+	if err != nil {
+		log.Printf("failed to get related blogs :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if blogs == nil {
+		blogs = []storage.RelatedBlog{}
+	}
+
+	type Response struct {
+		Success bool                  `json:"success"`
+		Blogs   []storage.RelatedBlog `json:"blogs"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Blogs: blogs}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dhruv15803/echo-blog-app/storage"
+	"github.com/go-chi/chi/v5"
+	"github.com/lib/pq"
+)
+
+type CreateReportPayload struct {
+	TargetType string `json:"target_type"`
+	TargetId   int    `json:"target_id"`
+	Reason     string `json:"reason"`
+	Details    string `json:"details"`
+}
+
+type ResolveReportsPayload struct {
+	Action      string `json:"action"`
+	Note        string `json:"note"`
+	SuspendDays int    `json:"suspend_days"`
+}
+
+const (
+	maxReportDetailsLength = 1000
+	defaultSuspendDays     = 7
+	// ten years, far below the days at which the suspension would overflow time.Duration
+	maxSuspendDays = 3650
+)
+
+func (h *Handler) CreateReportHandler(w http.ResponseWriter, r *http.Request) {
+
+	userId, ok := r.Context().Value(AuthUserId).(int)
+	if !ok {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var createReportPayload CreateReportPayload
+
+	if err := json.NewDecoder(r.Body).Decode(&createReportPayload); err != nil {
+		writeJSONError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	targetType := storage.ReportTargetType(strings.ToLower(strings.TrimSpace(createReportPayload.TargetType)))
+	reason := storage.ReportReason(strings.ToLower(strings.TrimSpace(createReportPayload.Reason)))
+	details := strings.TrimSpace(createReportPayload.Details)
+
+	if !slices.Contains(storage.ReportTargetTypes, targetType) {
+		writeJSONError(w, "invalid report target type", http.StatusBadRequest)
+		return
+	}
+
+	if !slices.Contains(storage.ReportReasons, reason) {
+		writeJSONError(w, "invalid report reason", http.StatusBadRequest)
+		return
+	}
+
+	if len(details) > maxReportDetailsLength {
+		writeJSONError(w, "report details too long", http.StatusBadRequest)
+		return
+	}
+
+	targetOwnerId, err := h.storage.GetReportTargetOwnerId(targetType, createReportPayload.TargetId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, "report target not found", http.StatusBadRequest)
+			return
+		} else {
+			log.Printf("failed to get report target owner :- %v\n", err.Error())
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if targetOwnerId == userId {
+		writeJSONError(w, "cannot report your own content", http.StatusBadRequest)
+		return
+	}
+
+	// one report per reporter per target
+	_, err = h.storage.GetReportByReporter(userId, targetType, createReportPayload.TargetId)
+	if err == nil {
+		writeJSONError(w, "already reported", http.StatusConflict)
+		return
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("failed to get report by reporter :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	report, err := h.storage.CreateReport(userId, targetType, createReportPayload.TargetId, reason, details)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			writeJSONError(w, "already reported", http.StatusConflict)
+			return
+		}
+		log.Printf("failed to create report :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// hide content that crosses the report threshold until a moderator looks at it
+	if targetType != storage.UserReportTarget && h.cfg.ReportAutoHideThreshold > 0 {
+
+		pendingReportsCount, err := h.storage.GetPendingReportsCountByTarget(targetType, report.TargetId)
+		if err != nil {
+			log.Printf("failed to get pending reports count :- %v\n", err.Error())
+		} else if pendingReportsCount >= h.cfg.ReportAutoHideThreshold {
+			if err := h.storage.HideReportTarget(targetType, report.TargetId); err != nil {
+				log.Printf("failed to auto hide report target :- %v\n", err.Error())
+			}
+		}
+	}
+
+	type Response struct {
+		Success bool           `json:"success"`
+		Message string         `json:"message"`
+		Report  storage.Report `json:"report"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Message: "report submitted", Report: *report}, http.StatusCreated); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+	}
+}
+
+// lets reporters follow up on the outcome of their reports
+func (h *Handler) GetMyReportsHandler(w http.ResponseWriter, r *http.Request) {
+
+	userId, ok := r.Context().Value(AuthUserId).(int)
+	if !ok {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	pageNum, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil {
+		writeJSONError(w, "invalid query param page", http.StatusBadRequest)
+		return
+	}
+
+	limitNum, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil {
+		writeJSONError(w, "invalid query param limit", http.StatusBadRequest)
+		return
+	}
+
+	skip := pageNum*limitNum - limitNum
+
+	reports, err := h.storage.GetReportsByReporter(userId, skip, limitNum)
+	if err != nil {
+		log.Printf("failed to get reports by reporter :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	totalReportsCount, err := h.storage.GetReportsCountByReporter(userId)
+	if err != nil {
+		log.Printf("failed to get reports count by reporter :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	noOfPages := int(math.Ceil(float64(totalReportsCount) / float64(limitNum)))
+
+	type Response struct {
+		Success   bool             `json:"success"`
+		Reports   []storage.Report `json:"reports"`
+		NoOfPages int              `json:"no_of_pages"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Reports: reports, NoOfPages: noOfPages}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) GetModerationQueueHandler(w http.ResponseWriter, r *http.Request) {
+
+	status := storage.ReportStatus(r.URL.Query().Get("status"))
+	if status == "" {
+		status = storage.PendingReportStatus
+	}
+
+	if status != storage.PendingReportStatus && status != storage.DismissedReportStatus && status != storage.ActionedReportStatus {
+		writeJSONError(w, "invalid query param status", http.StatusBadRequest)
+		return
+	}
+
+	pageNum, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil {
+		writeJSONError(w, "invalid query param page", http.StatusBadRequest)
+		return
+	}
+
+	limitNum, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil {
+		writeJSONError(w, "invalid query param limit", http.StatusBadRequest)
+		return
+	}
+
+	skip := pageNum*limitNum - limitNum
+
+	reportedTargets, err := h.storage.GetReportedTargets(status, skip, limitNum)
+	if err != nil {
+		log.Printf("failed to get reported targets :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	totalTargetsCount, err := h.storage.GetReportedTargetsCount(status)
+	if err != nil {
+		log.Printf("failed to get reported targets count :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	noOfPages := int(math.Ceil(float64(totalTargetsCount) / float64(limitNum)))
+
+	type Response struct {
+		Success         bool                     `json:"success"`
+		ReportedTargets []storage.ReportedTarget `json:"reported_targets"`
+		NoOfPages       int                      `json:"no_of_pages"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, ReportedTargets: reportedTargets, NoOfPages: noOfPages}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) GetTargetReportsHandler(w http.ResponseWriter, r *http.Request) {
+
+	targetType := storage.ReportTargetType(chi.URLParam(r, "targetType"))
+	if !slices.Contains(storage.ReportTargetTypes, targetType) {
+		writeJSONError(w, "invalid request param targetType", http.StatusBadRequest)
+		return
+	}
+
+	targetId, err := strconv.Atoi(chi.URLParam(r, "targetId"))
+	if err != nil {
+		writeJSONError(w, "invalid request param targetId", http.StatusBadRequest)
+		return
+	}
+
+	reports, err := h.storage.GetReportsByTarget(targetType, targetId)
+	if err != nil {
+		log.Printf("failed to get reports by target :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	type Response struct {
+		Success bool             `json:"success"`
+		Reports []storage.Report `json:"reports"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Reports: reports}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) ResolveReportsHandler(w http.ResponseWriter, r *http.Request) {
+
+	moderatorId, ok := r.Context().Value(AuthUserId).(int)
+	if !ok {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	targetType := storage.ReportTargetType(chi.URLParam(r, "targetType"))
+	if !slices.Contains(storage.ReportTargetTypes, targetType) {
+		writeJSONError(w, "invalid request param targetType", http.StatusBadRequest)
+		return
+	}
+
+	targetId, err := strconv.Atoi(chi.URLParam(r, "targetId"))
+	if err != nil {
+		writeJSONError(w, "invalid request param targetId", http.StatusBadRequest)
+		return
+	}
+
+	var resolveReportsPayload ResolveReportsPayload
+
+	if err := json.NewDecoder(r.Body).Decode(&resolveReportsPayload); err != nil {
+		writeJSONError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	action := storage.ModerationAction(strings.ToLower(strings.TrimSpace(resolveReportsPayload.Action)))
+	note := strings.TrimSpace(resolveReportsPayload.Note)
+
+	if !slices.Contains(storage.ModerationActions, action) {
+		writeJSONError(w, "invalid moderation action", http.StatusBadRequest)
+		return
+	}
+
+	if targetType == storage.UserReportTarget && (action == storage.HideAction || action == storage.DeleteAction) {
+		writeJSONError(w, "user reports can only be dismissed, warned or suspended", http.StatusBadRequest)
+		return
+	}
+
+	suspendDays := resolveReportsPayload.SuspendDays
+	if suspendDays <= 0 {
+		suspendDays = defaultSuspendDays
+	}
+
+	if suspendDays > maxSuspendDays {
+		writeJSONError(w, fmt.Sprintf("suspend_days must be at most %d", maxSuspendDays), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.storage.ApplyModerationAction(targetType, targetId, action, note, time.Hour*24*time.Duration(suspendDays), moderatorId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, "no pending reports found for target", http.StatusBadRequest)
+			return
+		} else {
+			log.Printf("failed to apply moderation action :- %v\n", err.Error())
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	h.recordAuditLog(r, ReportsResolveAuditAction, string(targetType), targetId, nil, result)
+
+	type Response struct {
+		Success bool                     `json:"success"`
+		Message string                   `json:"message"`
+		Result  storage.ModerationResult `json:"result"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Message: "reports resolved", Result: *result}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestResolveReportsHandlerRejectsLongSuspension(t *testing.T) {
+
+	routeContext := chi.NewRouteContext()
+	routeContext.URLParams.Add("targetType", "user")
+	routeContext.URLParams.Add("targetId", "5")
+
+	// a large number meant as permanent would overflow time.Duration and expire at once
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"action":"suspend","suspend_days":200000}`))
+	ctx := context.WithValue(r.Context(), chi.RouteCtxKey, routeContext)
+	r = r.WithContext(context.WithValue(ctx, AuthUserId, 1))
+
+	w := httptest.NewRecorder()
+
+	// the request is rejected before any storage call
+	(&Handler{}).ResolveReportsHandler(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
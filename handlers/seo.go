@@ -0,0 +1,404 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/dhruv15803/echo-blog-app/render"
+	"github.com/dhruv15803/echo-blog-app/sitemap"
+	"github.com/dhruv15803/echo-blog-app/storage"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	maxSlugLength        = 80
+	maxDescriptionLength = 160
+	// urls in one sitemap of blogs, topics or profiles
+	sitemapPageSize = 5000
+)
+
+type MetaTag struct {
+	Property string `json:"property,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Content  string `json:"content"`
+}
+
+type BlogMeta struct {
+	Title        string    `json:"title"`
+	Description  string    `json:"description"`
+	CanonicalUrl string    `json:"canonical_url"`
+	OpenGraph    []MetaTag `json:"open_graph"`
+	Twitter      []MetaTag `json:"twitter"`
+	JSONLD       BlogLD    `json:"json_ld"`
+}
+
+// BlogLD is the schema.org BlogPosting of a blog, written to the page as application/ld+json
+type BlogLD struct {
+	Context          string   `json:"@context"`
+	Type             string   `json:"@type"`
+	Headline         string   `json:"headline"`
+	Description      string   `json:"description,omitempty"`
+	Image            []string `json:"image,omitempty"`
+	DatePublished    string   `json:"datePublished"`
+	DateModified     string   `json:"dateModified"`
+	Author           PersonLD `json:"author"`
+	MainEntityOfPage string   `json:"mainEntityOfPage"`
+	Url              string   `json:"url"`
+	Keywords         string   `json:"keywords,omitempty"`
+}
+
+type PersonLD struct {
+	Type string `json:"@type"`
+	Name string `json:"name"`
+	Url  string `json:"url"`
+}
+
+// slugFor is the slug of a title cut to maxSlugLength, or the fallback for a title without letters or digits
+func slugFor(title string, fallback string) string {
+
+	slug := []rune(render.Slugify(title))
+	if len(slug) > maxSlugLength {
+		slug = slug[:maxSlugLength]
+	}
+
+	if trimmed := strings.Trim(string(slug), "-"); trimmed != "" {
+		return trimmed
+	}
+
+	return fallback
+}
+
+// GetBlogBySlugHandler responds like GetBlogHandler. a slug the blog had before its title changed
+// is answered with a 301 to the blog's current slug
+func (h *Handler) GetBlogBySlugHandler(w http.ResponseWriter, r *http.Request) {
+
+	slug := chi.URLParam(r, "slug")
+
+	blogId, err := h.storage.GetBlogIdBySlug(slug)
+	if err == nil {
+		h.writeBlog(w, r, blogId)
+		return
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("failed to get blog by slug :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	currentSlug, err := h.storage.GetRedirectedBlogSlug(slug)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, "blog not found", http.StatusNotFound)
+			return
+		} else {
+			log.Printf("failed to get redirected blog slug :- %v\n", err.Error())
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	location := "/api/blog/by-slug/" + url.PathEscape(currentSlug)
+	if r.URL.RawQuery != "" {
+		location += "?" + r.URL.RawQuery
+	}
+
+	w.Header().Set("Location", location)
+
+	type Response struct {
+		Success bool   `json:"success"`
+		Message string `json:"message"`
+		Slug    string `json:"slug"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Message: "blog moved", Slug: currentSlug}, http.StatusMovedPermanently); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) GetTopicBySlugHandler(w http.ResponseWriter, r *http.Request) {
+
+	topic, err := h.storage.GetTopicBySlug(chi.URLParam(r, "slug"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, "topic not found", http.StatusNotFound)
+			return
+		} else {
+			log.Printf("failed to get topic by slug :- %v\n", err.Error())
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	type Response struct {
+		Success bool          `json:"success"`
+		Topic   storage.Topic `json:"topic"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Topic: *topic}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetBlogMetaHandler returns what the frontend puts in the head of a blog's page, the canonical url,
+// OpenGraph and Twitter card tags and the JSON-LD BlogPosting
+func (h *Handler) GetBlogMetaHandler(w http.ResponseWriter, r *http.Request) {
+
+	blogId, err := strconv.Atoi(chi.URLParam(r, "blogId"))
+	if err != nil {
+		writeJSONError(w, "invalid request param blogId", http.StatusBadRequest)
+		return
+	}
+
+	blog, err := h.storage.GetBlogWithMetaDataById(blogId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, "blog not found", http.StatusBadRequest)
+			return
+		} else {
+			log.Printf("failed to get blog :- %v\n", err.Error())
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	type Response struct {
+		Success bool     `json:"success"`
+		Meta    BlogMeta `json:"meta"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Meta: blogMeta(*blog, h.cfg.ClientUrl)}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func blogMeta(blog storage.BlogWithMetaData, clientUrl string) BlogMeta {
+
+	canonicalUrl := blogUrl(clientUrl, blog.BlogSlug)
+	authorUrl := profileUrl(clientUrl, blog.BlogAuthor.Id)
+
+	description := ""
+	if blog.BlogDescription != nil {
+		description = truncateText(*blog.BlogDescription, maxDescriptionLength)
+	}
+	if description == "" {
+		description = truncateText(render.Text(blogDocument(blog.BlogContent)), maxDescriptionLength)
+	}
+
+	authorName := "user " + strconv.Itoa(blog.BlogAuthor.Id)
+	if blog.BlogAuthor.Name != nil && *blog.BlogAuthor.Name != "" {
+		authorName = *blog.BlogAuthor.Name
+	}
+
+	modifiedAt := blog.BlogCreatedAt
+	if blog.BlogUpdatedAt != nil {
+		modifiedAt = *blog.BlogUpdatedAt
+	}
+
+	image := ""
+	if blog.BlogThumbnail != nil {
+		image = *blog.BlogThumbnail
+	}
+
+	topics := make([]string, 0, len(blog.BlogTopics))
+	for _, topic := range blog.BlogTopics {
+		topics = append(topics, topic.TopicTitle)
+	}
+
+	openGraph := []MetaTag{
+		{Property: "og:type", Content: "article"},
+		{Property: "og:site_name", Content: feedSiteTitle},
+		{Property: "og:title", Content: blog.BlogTitle},
+		{Property: "og:description", Content: description},
+		{Property: "og:url", Content: canonicalUrl},
+		{Property: "article:published_time", Content: blog.BlogCreatedAt},
+		{Property: "article:modified_time", Content: modifiedAt},
+		{Property: "article:author", Content: authorUrl},
+	}
+	for _, topic := range topics {
+		openGraph = append(openGraph, MetaTag{Property: "article:tag", Content: topic})
+	}
+
+	twitterCard := "summary"
+	if image != "" {
+		twitterCard = "summary_large_image"
+		openGraph = append(openGraph, MetaTag{Property: "og:image", Content: image}, MetaTag{Property: "og:image:alt", Content: blog.BlogTitle})
+	}
+
+	twitter := []MetaTag{
+		{Name: "twitter:card", Content: twitterCard},
+		{Name: "twitter:title", Content: blog.BlogTitle},
+		{Name: "twitter:description", Content: description},
+	}
+	if image != "" {
+		twitter = append(twitter, MetaTag{Name: "twitter:image", Content: image})
+	}
+
+	jsonLD := BlogLD{
+		Context:          "https://schema.org",
+		Type:             "BlogPosting",
+		Headline:         blog.BlogTitle,
+		Description:      description,
+		DatePublished:    blog.BlogCreatedAt,
+		DateModified:     modifiedAt,
+		Author:           PersonLD{Type: "Person", Name: authorName, Url: authorUrl},
+		MainEntityOfPage: canonicalUrl,
+		Url:              canonicalUrl,
+		Keywords:         strings.Join(topics, ", "),
+	}
+	if image != "" {
+		jsonLD.Image = []string{image}
+	}
+
+	return BlogMeta{
+		Title:        blog.BlogTitle,
+		Description:  description,
+		CanonicalUrl: canonicalUrl,
+		OpenGraph:    openGraph,
+		Twitter:      twitter,
+		JSONLD:       jsonLD,
+	}
+}
+
+// truncateText collapses whitespace and cuts text to at most max runes at a word boundary, adding an ellipsis when it was cut
+func truncateText(text string, max int) string {
+
+	text = strings.Join(strings.Fields(text), " ")
+
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+
+	cut := string(runes[:max-1])
+	if i := strings.LastIndexByte(cut, ' '); i > 0 {
+		cut = cut[:i]
+	}
+
+	return strings.TrimRight(cut, " ,.;:") + "…"
+}
+
+func blogUrl(clientUrl string, slug string) string {
+	return clientUrl + "/blog/" + url.PathEscape(slug)
+}
+
+func topicUrl(clientUrl string, slug string) string {
+	return clientUrl + "/topic/" + url.PathEscape(slug)
+}
+
+func profileUrl(clientUrl string, userId int) string {
+	return clientUrl + "/user/" + strconv.Itoa(userId)
+}
+
+func collectionUrl(clientUrl string, collectionId int) string {
+	return clientUrl + "/collections/" + strconv.Itoa(collectionId)
+}
+
+// SitemapIndexHandler lists a sitemap for every page of blogs, topics and profiles
+func (h *Handler) SitemapIndexHandler(w http.ResponseWriter, r *http.Request) {
+
+	var sitemaps []sitemap.URL
+
+	for _, kind := range storage.SitemapKinds {
+
+		count, err := h.storage.GetSitemapEntriesCount(kind)
+		if err != nil {
+			log.Printf("failed to get sitemap entries count :- %v\n", err.Error())
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		noOfPages := int(math.Ceil(float64(count) / float64(sitemapPageSize)))
+
+		for page := 1; page <= noOfPages; page++ {
+			sitemaps = append(sitemaps, sitemap.URL{Loc: h.cfg.ApiUrl + "/sitemaps/" + string(kind) + "-" + strconv.Itoa(page) + ".xml"})
+		}
+	}
+
+	body, err := sitemap.Index(sitemaps)
+	if err != nil {
+		log.Printf("failed to encode sitemap index :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeSitemap(w, body)
+}
+
+// SitemapHandler writes a page of the blogs, topics or profiles sitemap, e.g. /sitemaps/blogs-1.xml
+func (h *Handler) SitemapHandler(w http.ResponseWriter, r *http.Request) {
+
+	kind := storage.SitemapKind(chi.URLParam(r, "kind"))
+	if !slices.Contains(storage.SitemapKinds, kind) {
+		writeJSONError(w, "sitemap not found", http.StatusNotFound)
+		return
+	}
+
+	pageNum, err := strconv.Atoi(chi.URLParam(r, "page"))
+	if err != nil || pageNum < 1 {
+		writeJSONError(w, "sitemap not found", http.StatusNotFound)
+		return
+	}
+
+	skip := pageNum*sitemapPageSize - sitemapPageSize
+
+	entries, err := h.storage.GetSitemapEntries(kind, skip, sitemapPageSize)
+	if err != nil {
+		log.Printf("failed to get sitemap entries :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if len(entries) == 0 && pageNum > 1 {
+		writeJSONError(w, "sitemap not found", http.StatusNotFound)
+		return
+	}
+
+	urls := make([]sitemap.URL, 0, len(entries))
+
+	for _, entry := range entries {
+
+		var loc string
+
+		switch kind {
+		case storage.BlogSitemapKind:
+			loc = blogUrl(h.cfg.ClientUrl, entry.Key)
+		case storage.TopicSitemapKind:
+			loc = topicUrl(h.cfg.ClientUrl, entry.Key)
+		case storage.ProfileSitemapKind:
+			loc = h.cfg.ClientUrl + "/user/" + url.PathEscape(entry.Key)
+		}
+
+		urls = append(urls, sitemap.URL{Loc: loc, LastModified: entry.LastModified})
+	}
+
+	body, err := sitemap.URLSet(urls)
+	if err != nil {
+		log.Printf("failed to encode sitemap :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeSitemap(w, body)
+}
+
+func writeSitemap(w http.ResponseWriter, body []byte) {
+
+	w.Header().Set("Content-Type", sitemap.ContentType)
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := w.Write(body); err != nil {
+		log.Printf("failed to write sitemap :- %v\n", err.Error())
+	}
+}
@@ -0,0 +1,515 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dhruv15803/echo-blog-app/storage"
+	"github.com/go-chi/chi/v5"
+)
+
+type CreateSeriesPayload struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+type UpdateSeriesPayload struct {
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+}
+
+type AddSeriesBlogPayload struct {
+	BlogId int `json:"blog_id"`
+}
+
+type ReorderSeriesPayload struct {
+	// every blog in the series in the new order
+	BlogIds []int `json:"blog_ids"`
+}
+
+const (
+	maxSeriesTitleLength       = 150
+	maxSeriesDescriptionLength = 1000
+)
+
+// validateSeries returns the reason a series title or description is invalid, empty if they are valid
+func validateSeries(title string, description string) string {
+
+	if title == "" {
+		return "series title is required"
+	}
+
+	if utf8.RuneCountInString(title) > maxSeriesTitleLength {
+		return "series title must be at most 150 characters"
+	}
+
+	if utf8.RuneCountInString(description) > maxSeriesDescriptionLength {
+		return "series description must be at most 1000 characters"
+	}
+
+	return ""
+}
+
+// ownedSeries loads the series in the url and checks that the auth user is its author,
+// writing the error response if not
+func (h *Handler) ownedSeries(w http.ResponseWriter, r *http.Request) (*storage.Series, bool) {
+
+	userId, ok := r.Context().Value(AuthUserId).(int)
+	if !ok {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	seriesId, err := strconv.Atoi(chi.URLParam(r, "seriesId"))
+	if err != nil {
+		writeJSONError(w, "invalid request param seriesId", http.StatusBadRequest)
+		return nil, false
+	}
+
+	series, err := h.storage.GetSeriesById(seriesId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, "series not found", http.StatusBadRequest)
+			return nil, false
+		} else {
+			log.Printf("failed to get series :- %v\n", err.Error())
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return nil, false
+		}
+	}
+
+	if series.AuthorId != userId {
+		writeJSONError(w, "user not allowed to update series", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	return series, true
+}
+
+func (h *Handler) CreateSeriesHandler(w http.ResponseWriter, r *http.Request) {
+
+	userId, ok := r.Context().Value(AuthUserId).(int)
+	if !ok {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var createSeriesPayload CreateSeriesPayload
+
+	if err := json.NewDecoder(r.Body).Decode(&createSeriesPayload); err != nil {
+		writeJSONError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	title := strings.TrimSpace(createSeriesPayload.Title)
+	descriptionText := strings.TrimSpace(createSeriesPayload.Description)
+
+	if reason := validateSeries(title, descriptionText); reason != "" {
+		writeJSONError(w, reason, http.StatusBadRequest)
+		return
+	}
+
+	var description *string
+	if descriptionText != "" {
+		description = &descriptionText
+	}
+
+	series, err := h.storage.CreateSeries(userId, title, description)
+	if err != nil {
+		log.Printf("failed to create series :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	type Response struct {
+		Success bool           `json:"success"`
+		Message string         `json:"message"`
+		Series  storage.Series `json:"series"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Message: "series created", Series: *series}, http.StatusCreated); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetSeriesHandler returns the series with its parts in order, its author and how many follow it.
+// is_following tells a signed in viewer whether they follow the series
+func (h *Handler) GetSeriesHandler(w http.ResponseWriter, r *http.Request) {
+
+	seriesId, err := strconv.Atoi(chi.URLParam(r, "seriesId"))
+	if err != nil {
+		writeJSONError(w, "invalid request param seriesId", http.StatusBadRequest)
+		return
+	}
+
+	series, err := h.storage.GetSeriesById(seriesId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, "series not found", http.StatusBadRequest)
+			return
+		} else {
+			log.Printf("failed to get series :- %v\n", err.Error())
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	author, err := h.storage.GetUserById(series.AuthorId)
+	if err != nil {
+		log.Printf("failed to get user by id :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	parts, err := h.storage.GetSeriesParts(series.Id)
+	if err != nil {
+		log.Printf("failed to get series parts :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	followersCount, err := h.storage.GetSeriesFollowersCount(series.Id)
+	if err != nil {
+		log.Printf("failed to get series followers count :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	isFollowing := false
+	if userId, ok := authTokenUserId(r); ok {
+		isFollowing, err = h.storage.IsFollowingSeries(series.Id, userId)
+		if err != nil {
+			log.Printf("failed to get series follow :- %v\n", err.Error())
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if parts == nil {
+		parts = []storage.SeriesPart{}
+	}
+
+	type SeriesAuthor struct {
+		Id       int     `json:"id"`
+		Name     *string `json:"name"`
+		ImageUrl *string `json:"image_url"`
+	}
+
+	type Response struct {
+		Success        bool                 `json:"success"`
+		Series         storage.Series       `json:"series"`
+		Author         SeriesAuthor         `json:"author"`
+		Parts          []storage.SeriesPart `json:"parts"`
+		FollowersCount int                  `json:"followers_count"`
+		IsFollowing    bool                 `json:"is_following"`
+	}
+
+	resp := Response{
+		Success:        true,
+		Series:         *series,
+		Author:         SeriesAuthor{Id: author.Id, Name: author.Name, ImageUrl: author.ImageUrl},
+		Parts:          parts,
+		FollowersCount: followersCount,
+		IsFollowing:    isFollowing,
+	}
+
+	if err := writeJSON(w, resp, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) UpdateSeriesHandler(w http.ResponseWriter, r *http.Request) {
+
+	series, ok := h.ownedSeries(w, r)
+	if !ok {
+		return
+	}
+
+	var updateSeriesPayload UpdateSeriesPayload
+
+	if err := json.NewDecoder(r.Body).Decode(&updateSeriesPayload); err != nil {
+		writeJSONError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	title := series.Title
+	if updateSeriesPayload.Title != nil {
+		title = strings.TrimSpace(*updateSeriesPayload.Title)
+	}
+
+	descriptionText := ""
+	if series.Description != nil {
+		descriptionText = *series.Description
+	}
+	if updateSeriesPayload.Description != nil {
+		descriptionText = strings.TrimSpace(*updateSeriesPayload.Description)
+	}
+
+	if reason := validateSeries(title, descriptionText); reason != "" {
+		writeJSONError(w, reason, http.StatusBadRequest)
+		return
+	}
+
+	var description *string
+	if descriptionText != "" {
+		description = &descriptionText
+	}
+
+	updatedSeries, err := h.storage.UpdateSeries(series.Id, title, description)
+	if err != nil {
+		log.Printf("failed to update series :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	type Response struct {
+		Success bool           `json:"success"`
+		Message string         `json:"message"`
+		Series  storage.Series `json:"series"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Message: "series updated", Series: *updatedSeries}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// DeleteSeriesHandler deletes the series, its blogs are kept
+func (h *Handler) DeleteSeriesHandler(w http.ResponseWriter, r *http.Request) {
+
+	series, ok := h.ownedSeries(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.storage.DeleteSeries(series.Id); err != nil {
+		log.Printf("failed to delete series :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	type Response struct {
+		Success bool   `json:"success"`
+		Message string `json:"message"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Message: "series deleted"}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// AddSeriesBlogHandler adds one of the author's blogs as the next part of the series, the followers of the
+// series are notified in the background. a blog can be a part of only one series
+func (h *Handler) AddSeriesBlogHandler(w http.ResponseWriter, r *http.Request) {
+
+	series, ok := h.ownedSeries(w, r)
+	if !ok {
+		return
+	}
+
+	var addSeriesBlogPayload AddSeriesBlogPayload
+
+	if err := json.NewDecoder(r.Body).Decode(&addSeriesBlogPayload); err != nil {
+		writeJSONError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	blog, err := h.storage.GetBlogById(addSeriesBlogPayload.BlogId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, "blog not found", http.StatusBadRequest)
+			return
+		} else {
+			log.Printf("failed to get blog :- %v\n", err.Error())
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if blog.BlogAuthorId != series.AuthorId {
+		writeJSONError(w, "user not allowed to add this blog to series", http.StatusUnauthorized)
+		return
+	}
+
+	blogSeriesId, err := h.storage.GetSeriesIdByBlogId(blog.Id)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("failed to get series of blog :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err == nil {
+		if blogSeriesId == series.Id {
+			writeJSONError(w, "blog is already in this series", http.StatusBadRequest)
+		} else {
+			writeJSONError(w, "blog is already in another series", http.StatusBadRequest)
+		}
+		return
+	}
+
+	if err := h.storage.AddSeriesBlog(series.Id, blog.Id); err != nil {
+		log.Printf("failed to add series blog :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	type Response struct {
+		Success bool   `json:"success"`
+		Message string `json:"message"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Message: "added blog to series"}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// RemoveSeriesBlogHandler takes the blog out of the series, the blog itself is kept
+func (h *Handler) RemoveSeriesBlogHandler(w http.ResponseWriter, r *http.Request) {
+
+	series, ok := h.ownedSeries(w, r)
+	if !ok {
+		return
+	}
+
+	blogId, err := strconv.Atoi(chi.URLParam(r, "blogId"))
+	if err != nil {
+		writeJSONError(w, "invalid request param blogId", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.storage.RemoveSeriesBlog(series.Id, blogId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, "blog not in series", http.StatusBadRequest)
+			return
+		} else {
+			log.Printf("failed to remove series blog :- %v\n", err.Error())
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	type Response struct {
+		Success bool   `json:"success"`
+		Message string `json:"message"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Message: "removed blog from series"}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// ReorderSeriesHandler puts the parts of the series in the order of blog_ids
+func (h *Handler) ReorderSeriesHandler(w http.ResponseWriter, r *http.Request) {
+
+	series, ok := h.ownedSeries(w, r)
+	if !ok {
+		return
+	}
+
+	var reorderSeriesPayload ReorderSeriesPayload
+
+	if err := json.NewDecoder(r.Body).Decode(&reorderSeriesPayload); err != nil {
+		writeJSONError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.storage.ReorderSeries(series.Id, reorderSeriesPayload.BlogIds); err != nil {
+		if errors.Is(err, storage.ErrSeriesOrderMismatch) {
+			writeJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		} else {
+			log.Printf("failed to reorder series :- %v\n", err.Error())
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	type Response struct {
+		Success bool   `json:"success"`
+		Message string `json:"message"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Message: "series reordered"}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// FollowSeriesHandler follows the series, or unfollows it when the user already follows it
+func (h *Handler) FollowSeriesHandler(w http.ResponseWriter, r *http.Request) {
+
+	userId, ok := r.Context().Value(AuthUserId).(int)
+	if !ok {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	seriesId, err := strconv.Atoi(chi.URLParam(r, "seriesId"))
+	if err != nil {
+		writeJSONError(w, "invalid request param seriesId", http.StatusBadRequest)
+		return
+	}
+
+	series, err := h.storage.GetSeriesById(seriesId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, "series not found", http.StatusBadRequest)
+			return
+		} else {
+			log.Printf("failed to get series :- %v\n", err.Error())
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if series.AuthorId == userId {
+		writeJSONError(w, "cannot follow your own series", http.StatusBadRequest)
+		return
+	}
+
+	following, err := h.storage.IsFollowingSeries(series.Id, userId)
+	if err != nil {
+		log.Printf("failed to get series follow :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var responseMsg string
+
+	if !following {
+		if err := h.storage.CreateSeriesFollow(series.Id, userId); err != nil {
+			log.Printf("failed to create series follow :- %v\n", err.Error())
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		responseMsg = "followed series"
+	} else {
+		if err := h.storage.RemoveSeriesFollow(series.Id, userId); err != nil {
+			log.Printf("failed to remove series follow :- %v\n", err.Error())
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		responseMsg = "removed series follow"
+	}
+
+	type Response struct {
+		Success bool   `json:"success"`
+		Message string `json:"message"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Message: responseMsg}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+}
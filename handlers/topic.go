@@ -44,12 +44,14 @@ func (h *Handler) CreateTopicHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	topic, err := h.storage.CreateTopic(topicTitle)
+	topic, err := h.storage.CreateTopic(topicTitle, slugFor(topicTitle, "topic"))
 	if err != nil {
 		writeJSONError(w, "internal server error", http.StatusInternalServerError)
 		return
 	}
 
+	h.recordAuditLog(r, TopicCreateAuditAction, TopicAuditTargetType, topic.Id, nil, topic)
+
 	type Response struct {
 		Success bool          `json:"success"`
 		Topic   storage.Topic `json:"topic"`
@@ -88,6 +90,8 @@ func (h *Handler) DeleteTopicHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.recordAuditLog(r, TopicDeleteAuditAction, TopicAuditTargetType, topic.Id, topic, nil)
+
 	type Response struct {
 		Success bool   `json:"success"`
 		Message string `json:"message"`
@@ -168,12 +172,14 @@ func (h *Handler) UpdateTopicHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	} else {
 
-		updatedTopic, err := h.storage.UpdateTopicById(topic.Id, newTopicTitle)
+		updatedTopic, err := h.storage.UpdateTopicById(topic.Id, newTopicTitle, slugFor(newTopicTitle, "topic"))
 		if err != nil {
 			writeJSONError(w, "internal server error", http.StatusInternalServerError)
 			return
 		}
 
+		h.recordAuditLog(r, TopicUpdateAuditAction, TopicAuditTargetType, topic.Id, topic, updatedTopic)
+
 		type Response struct {
 			Success      bool          `json:"success"`
 			Message      string        `json:"message"`
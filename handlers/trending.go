@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/dhruv15803/echo-blog-app/storage"
+)
+
+const (
+	defaultTrendingLimit       = 20
+	maxTrendingLimit           = 100
+	defaultTrendingTopicsLimit = 10
+)
+
+// trendingWindow is the window of the query param window, 24h when it is not set
+func trendingWindow(r *http.Request) (storage.TrendingWindow, bool) {
+
+	if r.URL.Query().Get("window") == "" {
+		return storage.DayTrendingWindow, true
+	}
+
+	window := storage.TrendingWindow(r.URL.Query().Get("window"))
+
+	return window, slices.Contains(storage.TrendingWindows, window)
+}
+
+// trendingLimit is the query param limit, or the default when it is not set
+func trendingLimit(r *http.Request, defaultLimit int) (int, bool) {
+
+	if r.URL.Query().Get("limit") == "" {
+		return defaultLimit, true
+	}
+
+	limitNum, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limitNum < 1 || limitNum > maxTrendingLimit {
+		return 0, false
+	}
+
+	return limitNum, true
+}
+
+// GetTrendingBlogsHandler returns the blogs whose likes, comments, bookmarks and views are growing fastest in
+// the window of the query param window, 1h, 24h or 7d. it reads the snapshot the trending refresher keeps,
+// refreshed_at is when that snapshot was taken
+func (h *Handler) GetTrendingBlogsHandler(w http.ResponseWriter, r *http.Request) {
+
+	window, ok := trendingWindow(r)
+	if !ok {
+		writeJSONError(w, "invalid query param window, must be 1h, 24h or 7d", http.StatusBadRequest)
+		return
+	}
+
+	pageNum := 1
+	if r.URL.Query().Get("page") != "" {
+		page, err := strconv.Atoi(r.URL.Query().Get("page"))
+		if err != nil || page < 1 {
+			writeJSONError(w, "invalid query param page", http.StatusBadRequest)
+			return
+		}
+		pageNum = page
+	}
+
+	limitNum, ok := trendingLimit(r, defaultTrendingLimit)
+	if !ok {
+		writeJSONError(w, "invalid query param limit, must be between 1 and 100", http.StatusBadRequest)
+		return
+	}
+
+	skip := pageNum*limitNum - limitNum
+
+	blogs, err := h.storage.GetTrendingBlogs(window, skip, limitNum)
+	if err != nil {
+		log.Printf("failed to get trending blogs :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	totalBlogsCount, err := h.storage.GetTrendingBlogsCount(window)
+	if err != nil {
+		log.Printf("failed to get trending blogs count :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	refreshedAt, err := h.storage.GetTrendingRefreshedAt(window)
+	if err != nil {
+		log.Printf("failed to get trending refreshed at :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if blogs == nil {
+		blogs = []storage.TrendingBlog{}
+	}
+
+	noOfPages := int(math.Ceil(float64(totalBlogsCount) / float64(limitNum)))
+
+	type Response struct {
+		Success     bool                   `json:"success"`
+		Window      storage.TrendingWindow `json:"window"`
+		RefreshedAt *time.Time             `json:"refreshed_at"`
+		Blogs       []storage.TrendingBlog `json:"blogs"`
+		NoOfPages   int                    `json:"no_of_pages"`
+	}
+
+	resp := Response{Success: true, Window: window, Blogs: blogs, NoOfPages: noOfPages}
+	if !refreshedAt.IsZero() {
+		resp.RefreshedAt = &refreshedAt
+	}
+
+	if err := writeJSON(w, resp, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetTrendingTopicsHandler returns the topics whose blogs are getting more activity fastest in the window of
+// the query param window, 1h, 24h or 7d, with the number of their blogs that had any
+func (h *Handler) GetTrendingTopicsHandler(w http.ResponseWriter, r *http.Request) {
+
+	window, ok := trendingWindow(r)
+	if !ok {
+		writeJSONError(w, "invalid query param window, must be 1h, 24h or 7d", http.StatusBadRequest)
+		return
+	}
+
+	limitNum, ok := trendingLimit(r, defaultTrendingTopicsLimit)
+	if !ok {
+		writeJSONError(w, "invalid query param limit, must be between 1 and 100", http.StatusBadRequest)
+		return
+	}
+
+	topics, err := h.storage.GetTrendingTopics(window, limitNum)
+	if err != nil {
+		log.Printf("failed to get trending topics :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	refreshedAt, err := h.storage.GetTrendingRefreshedAt(window)
+	if err != nil {
+		log.Printf("failed to get trending refreshed at :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if topics == nil {
+		topics = []storage.TrendingTopic{}
+	}
+
+	type Response struct {
+		Success     bool                    `json:"success"`
+		Window      storage.TrendingWindow  `json:"window"`
+		RefreshedAt *time.Time              `json:"refreshed_at"`
+		Topics      []storage.TrendingTopic `json:"topics"`
+	}
+
+	resp := Response{Success: true, Window: window, Topics: topics}
+	if !refreshedAt.IsZero() {
+		resp.RefreshedAt = &refreshedAt
+	}
+
+	if err := writeJSON(w, resp, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+}
@@ -3,13 +3,31 @@ package handlers
 import (
 	"database/sql"
 	"errors"
+	"github.com/dhruv15803/echo-blog-app/notifications"
 	"github.com/dhruv15803/echo-blog-app/storage"
 	"github.com/go-chi/chi/v5"
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 )
 
+const (
+	defaultSuggestionsLimit = 10
+	maxSuggestionsLimit     = 50
+	// blogs published this recently count towards an author's recent activity
+	suggestionRecentPeriod = time.Hour * 24 * 30
+	// authors without a blog in this long are not suggested
+	suggestionActivePeriod = time.Hour * 24 * 180
+)
+
+// shared topics say the most about whether an author is worth following, recent blogs the least
+var suggestionWeights = storage.SuggestionWeights{
+	MutualFollows: 0.4,
+	SharedTopics:  0.5,
+	RecentBlogs:   0.2,
+}
+
 func (h *Handler) FollowUserHandler(w http.ResponseWriter, r *http.Request) {
 
 	authUserId, ok := r.Context().Value(AuthUserId).(int)
@@ -70,6 +88,9 @@ func (h *Handler) FollowUserHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		h.notifier.Emit(notifications.UserFollowed(authUser.Id, user.Id))
+		h.webhooks.Publish(storage.UserFollowedWebhookEvent, user.Id, newFollow)
+
 		type Response struct {
 			Success bool           `json:"success"`
 			Message string         `json:"message"`
@@ -87,6 +108,8 @@ func (h *Handler) FollowUserHandler(w http.ResponseWriter, r *http.Request) {
 			writeJSONError(w, "internal server error", http.StatusInternalServerError)
 			return
 		}
+
+		h.notifier.Retract(notifications.UserFollowed(authUser.Id, user.Id))
 		type Response struct {
 			Success bool   `json:"success"`
 			Message string `json:"message"`
@@ -97,3 +120,95 @@ func (h *Handler) FollowUserHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 }
+
+// GetUserSuggestionsHandler returns authors the signed in user may want to follow, ranked by the users they
+// follow that follow the author, the topics they both care about and how much the author published lately
+func (h *Handler) GetUserSuggestionsHandler(w http.ResponseWriter, r *http.Request) {
+
+	userId, ok := r.Context().Value(AuthUserId).(int)
+	if !ok {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	limitNum := defaultSuggestionsLimit
+	if r.URL.Query().Get("limit") != "" {
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil || limit < 1 || limit > maxSuggestionsLimit {
+			writeJSONError(w, "invalid query param limit, must be between 1 and 50", http.StatusBadRequest)
+			return
+		}
+		limitNum = limit
+	}
+
+	now := time.Now()
+
+	suggestions, err := h.storage.GetAuthorSuggestions(userId, now.Add(-suggestionRecentPeriod), now.Add(-suggestionActivePeriod), suggestionWeights, limitNum)
+	if err != nil {
+		log.Printf("failed to get author suggestions :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if suggestions == nil {
+		suggestions = []storage.AuthorSuggestion{}
+	}
+
+	type Response struct {
+		Success     bool                       `json:"success"`
+		Suggestions []storage.AuthorSuggestion `json:"suggestions"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Suggestions: suggestions}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// DismissUserSuggestionHandler stops the user of the request param userId from being suggested again
+func (h *Handler) DismissUserSuggestionHandler(w http.ResponseWriter, r *http.Request) {
+
+	authUserId, ok := r.Context().Value(AuthUserId).(int)
+	if !ok {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	userId, err := strconv.Atoi(chi.URLParam(r, "userId"))
+	if err != nil {
+		writeJSONError(w, "invalid request param userId", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.storage.GetUserById(userId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, "user not found", http.StatusBadRequest)
+			return
+		} else {
+			log.Printf("failed to get user by id :- %v\n", err.Error())
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if authUserId == userId {
+		writeJSONError(w, "cannot dismiss this user", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.storage.DismissSuggestion(authUserId, userId); err != nil {
+		log.Printf("failed to dismiss suggestion :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	type Response struct {
+		Success bool   `json:"success"`
+		Message string `json:"message"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Message: "dismissed suggestion"}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+}
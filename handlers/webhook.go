@@ -0,0 +1,418 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/dhruv15803/echo-blog-app/storage"
+	"github.com/dhruv15803/echo-blog-app/webhooks"
+	"github.com/go-chi/chi/v5"
+)
+
+type CreateWebhookPayload struct {
+	Url        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	// global endpoints receive every event, only admins can register them
+	IsGlobal bool `json:"is_global"`
+}
+
+type UpdateWebhookPayload struct {
+	Url        *string  `json:"url"`
+	EventTypes []string `json:"event_types"`
+	IsActive   *bool    `json:"is_active"`
+}
+
+// validateWebhook returns the reason a webhook url or its event types are invalid, empty if they are valid
+func (h *Handler) validateWebhook(ctx context.Context, webhookUrl string, eventTypes []string) string {
+
+	parsedUrl, err := url.Parse(webhookUrl)
+	if err != nil || parsedUrl.Hostname() == "" || (parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https") {
+		return "invalid webhook url"
+	}
+
+	if h.cfg.WebhookRequireHttps && parsedUrl.Scheme != "https" {
+		return "webhook url must use https"
+	}
+
+	if !h.cfg.WebhookAllowPrivateNetworks {
+		if err := webhooks.ValidateURLHost(ctx, parsedUrl); err != nil {
+			if errors.Is(err, webhooks.ErrDisallowedAddress) {
+				return err.Error()
+			}
+			return "webhook url host could not be resolved"
+		}
+	}
+
+	if len(eventTypes) == 0 {
+		return "at least one event type is required"
+	}
+
+	for _, eventType := range eventTypes {
+		if !slices.Contains(storage.WebhookEventTypes, storage.WebhookEventType(eventType)) {
+			return "invalid event type " + eventType
+		}
+	}
+
+	sortedEventTypes := slices.Clone(eventTypes)
+	slices.Sort(sortedEventTypes)
+	if len(slices.Compact(sortedEventTypes)) != len(eventTypes) {
+		return "duplicate event types"
+	}
+
+	return ""
+}
+
+// ownedWebhookEndpoint loads the webhook in the url and checks that the auth user owns it,
+// writing the error response if not
+func (h *Handler) ownedWebhookEndpoint(w http.ResponseWriter, r *http.Request) (*storage.WebhookEndpoint, bool) {
+
+	userId, ok := r.Context().Value(AuthUserId).(int)
+	if !ok {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	webhookId, err := strconv.Atoi(chi.URLParam(r, "webhookId"))
+	if err != nil {
+		writeJSONError(w, "invalid request param webhookId", http.StatusBadRequest)
+		return nil, false
+	}
+
+	endpoint, err := h.storage.GetWebhookEndpointById(webhookId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, "webhook not found", http.StatusBadRequest)
+			return nil, false
+		} else {
+			log.Printf("failed to get webhook endpoint :- %v\n", err.Error())
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return nil, false
+		}
+	}
+
+	if endpoint.OwnerId != userId {
+		writeJSONError(w, "webhook not found", http.StatusBadRequest)
+		return nil, false
+	}
+
+	return endpoint, true
+}
+
+func (h *Handler) CreateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+
+	userId, ok := r.Context().Value(AuthUserId).(int)
+	if !ok {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	user, err := h.storage.GetUserById(userId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, "user not found", http.StatusBadRequest)
+			return
+		} else {
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	var createWebhookPayload CreateWebhookPayload
+
+	if err := json.NewDecoder(r.Body).Decode(&createWebhookPayload); err != nil {
+		writeJSONError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	webhookUrl := strings.TrimSpace(createWebhookPayload.Url)
+
+	if reason := h.validateWebhook(r.Context(), webhookUrl, createWebhookPayload.EventTypes); reason != "" {
+		writeJSONError(w, reason, http.StatusBadRequest)
+		return
+	}
+
+	if createWebhookPayload.IsGlobal && user.Role != storage.AdminRole {
+		writeJSONError(w, "only admins can register global webhooks", http.StatusUnauthorized)
+		return
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		log.Printf("failed to generate webhook secret :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	endpoint, err := h.storage.CreateWebhookEndpoint(user.Id, webhookUrl, secret, createWebhookPayload.EventTypes, createWebhookPayload.IsGlobal)
+	if err != nil {
+		log.Printf("failed to create webhook endpoint :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if endpoint.IsGlobal {
+		h.recordAuditLog(r, WebhookCreateAuditAction, WebhookAuditTargetType, endpoint.Id, nil, endpoint)
+	}
+
+	// the secret is only ever returned here, the owner needs it to verify signatures
+	type Response struct {
+		Success bool                    `json:"success"`
+		Message string                  `json:"message"`
+		Webhook storage.WebhookEndpoint `json:"webhook"`
+		Secret  string                  `json:"secret"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Message: "webhook created", Webhook: *endpoint, Secret: secret}, http.StatusCreated); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) GetWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+
+	userId, ok := r.Context().Value(AuthUserId).(int)
+	if !ok {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	endpoints, err := h.storage.GetWebhookEndpointsByOwner(userId)
+	if err != nil {
+		log.Printf("failed to get webhook endpoints :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	type Response struct {
+		Success  bool                      `json:"success"`
+		Webhooks []storage.WebhookEndpoint `json:"webhooks"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Webhooks: endpoints}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) UpdateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+
+	endpoint, ok := h.ownedWebhookEndpoint(w, r)
+	if !ok {
+		return
+	}
+
+	var updateWebhookPayload UpdateWebhookPayload
+
+	if err := json.NewDecoder(r.Body).Decode(&updateWebhookPayload); err != nil {
+		writeJSONError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	webhookUrl := endpoint.Url
+	if updateWebhookPayload.Url != nil {
+		webhookUrl = strings.TrimSpace(*updateWebhookPayload.Url)
+	}
+
+	eventTypes := []string(endpoint.EventTypes)
+	if updateWebhookPayload.EventTypes != nil {
+		eventTypes = updateWebhookPayload.EventTypes
+	}
+
+	isActive := endpoint.IsActive
+	if updateWebhookPayload.IsActive != nil {
+		isActive = *updateWebhookPayload.IsActive
+	}
+
+	if reason := h.validateWebhook(r.Context(), webhookUrl, eventTypes); reason != "" {
+		writeJSONError(w, reason, http.StatusBadRequest)
+		return
+	}
+
+	updatedEndpoint, err := h.storage.UpdateWebhookEndpoint(endpoint.Id, webhookUrl, eventTypes, isActive)
+	if err != nil {
+		log.Printf("failed to update webhook endpoint :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if updatedEndpoint.IsGlobal {
+		h.recordAuditLog(r, WebhookUpdateAuditAction, WebhookAuditTargetType, endpoint.Id, endpoint, updatedEndpoint)
+	}
+
+	type Response struct {
+		Success bool                    `json:"success"`
+		Message string                  `json:"message"`
+		Webhook storage.WebhookEndpoint `json:"webhook"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Message: "webhook updated", Webhook: *updatedEndpoint}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) DeleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+
+	endpoint, ok := h.ownedWebhookEndpoint(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.storage.DeleteWebhookEndpoint(endpoint.Id); err != nil {
+		log.Printf("failed to delete webhook endpoint :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if endpoint.IsGlobal {
+		h.recordAuditLog(r, WebhookDeleteAuditAction, WebhookAuditTargetType, endpoint.Id, endpoint, nil)
+	}
+
+	type Response struct {
+		Success bool   `json:"success"`
+		Message string `json:"message"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Message: "webhook deleted"}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) GetWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+
+	endpoint, ok := h.ownedWebhookEndpoint(w, r)
+	if !ok {
+		return
+	}
+
+	pageNum, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil {
+		writeJSONError(w, "invalid query param page", http.StatusBadRequest)
+		return
+	}
+
+	limitNum, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil {
+		writeJSONError(w, "invalid query param limit", http.StatusBadRequest)
+		return
+	}
+
+	skip := pageNum*limitNum - limitNum
+
+	deliveries, err := h.storage.GetWebhookDeliveries(endpoint.Id, skip, limitNum)
+	if err != nil {
+		log.Printf("failed to get webhook deliveries :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	totalDeliveriesCount, err := h.storage.GetWebhookDeliveriesCount(endpoint.Id)
+	if err != nil {
+		log.Printf("failed to get webhook deliveries count :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	noOfPages := int(math.Ceil(float64(totalDeliveriesCount) / float64(limitNum)))
+
+	type Response struct {
+		Success    bool                      `json:"success"`
+		Deliveries []storage.WebhookDelivery `json:"deliveries"`
+		NoOfPages  int                       `json:"no_of_pages"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Deliveries: deliveries, NoOfPages: noOfPages}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) GetWebhookDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+
+	endpoint, ok := h.ownedWebhookEndpoint(w, r)
+	if !ok {
+		return
+	}
+
+	deliveryId, err := strconv.ParseInt(chi.URLParam(r, "deliveryId"), 10, 64)
+	if err != nil {
+		writeJSONError(w, "invalid request param deliveryId", http.StatusBadRequest)
+		return
+	}
+
+	delivery, err := h.storage.GetWebhookDeliveryById(endpoint.Id, deliveryId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, "webhook delivery not found", http.StatusBadRequest)
+			return
+		} else {
+			log.Printf("failed to get webhook delivery :- %v\n", err.Error())
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	attempts, err := h.storage.GetWebhookDeliveryAttempts(delivery.Id)
+	if err != nil {
+		log.Printf("failed to get webhook delivery attempts :- %v\n", err.Error())
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	type Response struct {
+		Success  bool                             `json:"success"`
+		Delivery storage.WebhookDelivery          `json:"delivery"`
+		Attempts []storage.WebhookDeliveryAttempt `json:"attempts"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Delivery: *delivery, Attempts: attempts}, http.StatusOK); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+	}
+}
+
+// ReplayWebhookDeliveryHandler sends a past delivery again as a new delivery, e.g. after fixing the receiver
+func (h *Handler) ReplayWebhookDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+
+	endpoint, ok := h.ownedWebhookEndpoint(w, r)
+	if !ok {
+		return
+	}
+
+	deliveryId, err := strconv.ParseInt(chi.URLParam(r, "deliveryId"), 10, 64)
+	if err != nil {
+		writeJSONError(w, "invalid request param deliveryId", http.StatusBadRequest)
+		return
+	}
+
+	delivery, err := h.storage.ReplayWebhookDelivery(endpoint.Id, deliveryId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, "webhook delivery not found", http.StatusBadRequest)
+			return
+		} else {
+			log.Printf("failed to replay webhook delivery :- %v\n", err.Error())
+			writeJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	message := "webhook delivery queued"
+	if !endpoint.IsActive {
+		message = "webhook delivery queued, it is sent once the webhook is re-enabled"
+	}
+
+	type Response struct {
+		Success  bool                    `json:"success"`
+		Message  string                  `json:"message"`
+		Delivery storage.WebhookDelivery `json:"delivery"`
+	}
+
+	if err := writeJSON(w, Response{Success: true, Message: message, Delivery: *delivery}, http.StatusCreated); err != nil {
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+	}
+}
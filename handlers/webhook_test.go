@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+)
+
+func TestValidateWebhook(t *testing.T) {
+
+	eventTypes := []string{"blog.created"}
+
+	tests := []struct {
+		name       string
+		cfg        HandlerConfig
+		url        string
+		eventTypes []string
+		want       string
+	}{
+		{name: "public https", url: "https://93.184.216.34/hook", eventTypes: eventTypes},
+		{name: "public http", url: "http://93.184.216.34/hook", eventTypes: eventTypes},
+		{name: "http when https is required", cfg: HandlerConfig{WebhookRequireHttps: true}, url: "http://93.184.216.34/hook", eventTypes: eventTypes, want: "webhook url must use https"},
+		{name: "not http", url: "ftp://93.184.216.34/hook", eventTypes: eventTypes, want: "invalid webhook url"},
+		{name: "no host", url: "https:///hook", eventTypes: eventTypes, want: "invalid webhook url"},
+		{name: "metadata address", url: "http://169.254.169.254/latest/meta-data", eventTypes: eventTypes, want: "webhook url must resolve to a public address"},
+		{name: "loopback", url: "http://127.0.0.1:8080/hook", eventTypes: eventTypes, want: "webhook url must resolve to a public address"},
+		{name: "private", url: "http://192.168.0.10/hook", eventTypes: eventTypes, want: "webhook url must resolve to a public address"},
+		{name: "loopback when private networks are allowed", cfg: HandlerConfig{WebhookAllowPrivateNetworks: true}, url: "http://127.0.0.1:8080/hook", eventTypes: eventTypes},
+		{name: "no event types", url: "https://93.184.216.34/hook", want: "at least one event type is required"},
+		{name: "unknown event type", url: "https://93.184.216.34/hook", eventTypes: []string{"blog.liked"}, want: "invalid event type blog.liked"},
+		{name: "duplicate event types", url: "https://93.184.216.34/hook", eventTypes: []string{"blog.created", "blog.created"}, want: "duplicate event types"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			h := &Handler{cfg: tt.cfg}
+
+			if got := h.validateWebhook(context.Background(), tt.url, tt.eventTypes); got != tt.want {
+				t.Errorf("validateWebhook() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,317 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrImageTooLarge     = errors.New("image dimensions are too large")
+)
+
+const (
+	// MaxPixels bounds width x height (x frames for gifs) before anything is decoded,
+	// a small file can otherwise declare a huge canvas and exhaust memory
+	MaxPixels = 50_000_000
+
+	// the original variant is still downscaled to this size
+	OriginalMaxSize = 2560
+
+	jpegQuality = 85
+
+	blurhashXComponents = 4
+	blurhashYComponents = 3
+)
+
+const OriginalVariant = "original"
+
+// VariantSpec is a resized copy generated for every upload, it fits in MaxWidth x MaxHeight
+type VariantSpec struct {
+	Name      string
+	MaxWidth  int
+	MaxHeight int
+}
+
+var VariantSpecs = []VariantSpec{
+	{Name: "thumbnail", MaxWidth: 320, MaxHeight: 320},
+	{Name: "medium", MaxWidth: 1280, MaxHeight: 1280},
+}
+
+type Variant struct {
+	Name        string
+	Width       int
+	Height      int
+	ContentType string
+	Extension   string
+	Data        []byte
+}
+
+// Result is a processed upload. variants that would not be smaller than the original are
+// not generated, Variant returns the original in their place
+type Result struct {
+	Format   Format
+	Width    int
+	Height   int
+	Blurhash string
+	Variants []Variant
+}
+
+func (r *Result) Variant(name string) Variant {
+
+	for _, v := range r.Variants {
+		if v.Name == name {
+			return v
+		}
+	}
+
+	return r.Variants[0]
+}
+
+// Process validates an uploaded image by its magic bytes and re-encodes it, which drops EXIF
+// (GPS included) and any other metadata. the EXIF orientation is applied to the pixels first
+func Process(data []byte) (*Result, error) {
+
+	format, ok := Sniff(data)
+	if !ok {
+		return nil, ErrUnsupportedFormat
+	}
+
+	switch format {
+	case JPEG:
+		return processJPEG(data)
+	case PNG:
+		return processPNG(data)
+	case GIF:
+		return processGIF(data)
+	case WebP:
+		return processWebP(data)
+	}
+
+	return nil, ErrUnsupportedFormat
+}
+
+func checkPixels(w, h, frames int) error {
+
+	if w <= 0 || h <= 0 {
+		return ErrUnsupportedFormat
+	}
+
+	if w*h > MaxPixels || w*h*frames > MaxPixels {
+		return ErrImageTooLarge
+	}
+
+	return nil
+}
+
+func processJPEG(data []byte) (*Result, error) {
+
+	config, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkPixels(config.Width, config.Height, 1); err != nil {
+		return nil, err
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	rgba := applyOrientation(toRGBA(img), jpegOrientation(data))
+
+	return buildResult(JPEG, rgba, nil, encodeJPEG)
+}
+
+func processPNG(data []byte) (*Result, error) {
+
+	config, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkPixels(config.Width, config.Height, 1); err != nil {
+		return nil, err
+	}
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	return buildResult(PNG, toRGBA(img), nil, encodePNG)
+}
+
+// processGIF keeps the original as a gif so animations survive, gif.EncodeAll only writes
+// the frames and the loop count back. the resized variants are still pngs of the first frame
+func processGIF(data []byte) (*Result, error) {
+
+	config, err := gif.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkPixels(config.Width, config.Height, 1); err != nil {
+		return nil, err
+	}
+
+	anim, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkPixels(config.Width, config.Height, len(anim.Image)); err != nil {
+		return nil, err
+	}
+
+	var original bytes.Buffer
+	err = gif.EncodeAll(&original, &gif.GIF{
+		Image:           anim.Image,
+		Delay:           anim.Delay,
+		Disposal:        anim.Disposal,
+		LoopCount:       anim.LoopCount,
+		Config:          anim.Config,
+		BackgroundIndex: anim.BackgroundIndex,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the first frame can be smaller than the canvas
+	first := image.NewRGBA(image.Rect(0, 0, config.Width, config.Height))
+	frame := toRGBA(anim.Image[0])
+	offset := anim.Image[0].Bounds().Min
+	for y := 0; y < frame.Bounds().Dy() && offset.Y+y < config.Height; y++ {
+		if offset.Y+y < 0 {
+			continue
+		}
+		for x := 0; x < frame.Bounds().Dx() && offset.X+x < config.Width; x++ {
+			if offset.X+x < 0 {
+				continue
+			}
+			si := y*frame.Stride + x*4
+			di := (offset.Y+y)*first.Stride + (offset.X+x)*4
+			copy(first.Pix[di:di+4], frame.Pix[si:si+4])
+		}
+	}
+
+	originalVariant := &Variant{
+		Name:        OriginalVariant,
+		Width:       config.Width,
+		Height:      config.Height,
+		ContentType: GIF.ContentType(),
+		Extension:   GIF.Extension(),
+		Data:        original.Bytes(),
+	}
+
+	return buildResult(GIF, first, originalVariant, encodePNG)
+}
+
+// processWebP keeps the original as a webp without its metadata chunks so animations survive,
+// the resized variants are jpegs, or pngs when the image has transparency, of the first frame
+func processWebP(data []byte) (*Result, error) {
+
+	chunks, err := readWebPChunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	w, h, err := webpDimensions(chunks)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkPixels(w, h, 1); err != nil {
+		return nil, err
+	}
+
+	first, err := decodeWebPFirstFrame(data, chunks, w, h)
+	if err != nil {
+		return nil, err
+	}
+
+	originalVariant := &Variant{
+		Name:        OriginalVariant,
+		Width:       w,
+		Height:      h,
+		ContentType: WebP.ContentType(),
+		Extension:   WebP.Extension(),
+		Data:        stripWebPMetadata(chunks),
+	}
+
+	encode := encodeJPEG
+	if !first.Opaque() {
+		encode = encodePNG
+	}
+
+	return buildResult(WebP, first, originalVariant, encode)
+}
+
+type encodeFunc func(img *image.RGBA) (Variant, error)
+
+func encodeJPEG(img *image.RGBA) (Variant, error) {
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return Variant{}, err
+	}
+
+	return Variant{ContentType: JPEG.ContentType(), Extension: JPEG.Extension(), Data: buf.Bytes()}, nil
+}
+
+func encodePNG(img *image.RGBA) (Variant, error) {
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return Variant{}, err
+	}
+
+	return Variant{ContentType: PNG.ContentType(), Extension: PNG.Extension(), Data: buf.Bytes()}, nil
+}
+
+// buildResult encodes the original (unless it is already given) and every variant smaller than it
+func buildResult(format Format, img *image.RGBA, original *Variant, encode encodeFunc) (*Result, error) {
+
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+
+	result := &Result{
+		Format:   format,
+		Blurhash: Blurhash(img, blurhashXComponents, blurhashYComponents),
+	}
+
+	if original == nil {
+		ow, oh := fit(w, h, OriginalMaxSize, OriginalMaxSize)
+		variant, err := encode(resize(img, ow, oh))
+		if err != nil {
+			return nil, err
+		}
+		variant.Name, variant.Width, variant.Height = OriginalVariant, ow, oh
+		original = &variant
+	}
+
+	result.Width, result.Height = original.Width, original.Height
+	result.Variants = append(result.Variants, *original)
+
+	for _, spec := range VariantSpecs {
+
+		vw, vh := fit(w, h, spec.MaxWidth, spec.MaxHeight)
+		if vw >= original.Width && vh >= original.Height {
+			continue
+		}
+
+		variant, err := encode(resize(img, vw, vh))
+		if err != nil {
+			return nil, err
+		}
+		variant.Name, variant.Width, variant.Height = spec.Name, vw, vh
+		result.Variants = append(result.Variants, variant)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,508 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+// gpsMarker stands in for location data in the metadata of test images, it must not survive processing
+const gpsMarker = "GPS 51.5007N 0.1246W"
+
+func gradient(w, h int) *image.RGBA {
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 255 / w), G: uint8(y * 255 / h), B: 128, A: 255})
+		}
+	}
+
+	return img
+}
+
+// exifSegment is an APP1 segment with the orientation tag, followed by the gps marker
+func exifSegment(orientation int) []byte {
+
+	tiff := []byte("II*\x00")
+	tiff = binary.LittleEndian.AppendUint32(tiff, 8)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 3)
+	tiff = binary.LittleEndian.AppendUint32(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, uint16(orientation))
+	tiff = binary.LittleEndian.AppendUint16(tiff, 0)
+	tiff = binary.LittleEndian.AppendUint32(tiff, 0)
+	tiff = append(tiff, gpsMarker...)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+
+	segment := []byte{0xFF, 0xE1}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+
+	return append(segment, payload...)
+}
+
+func jpegWithExif(t *testing.T, w, h, orientation int) []byte {
+
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, gradient(w, h), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+
+	// right after the start of image marker
+	out := append([]byte{}, data[:2]...)
+	out = append(out, exifSegment(orientation)...)
+
+	return append(out, data[2:]...)
+}
+
+// pngWithText adds a tEXt chunk holding the gps marker before the image data
+func pngWithText(t *testing.T, img image.Image) []byte {
+
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	// the 8 byte signature and the 25 byte IHDR chunk come first
+	headerEnd := 8 + 25
+
+	chunkData := append([]byte("tEXt"), "Location\x00"+gpsMarker...)
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(chunkData)-4))
+	chunk = append(chunk, chunkData...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunkData))
+
+	out := append([]byte{}, data[:headerEnd]...)
+	out = append(out, chunk...)
+
+	return append(out, data[headerEnd:]...)
+}
+
+// gifWithComment is a two frame animation with a comment extension holding the gps marker
+func gifWithComment(t *testing.T, w, h int) []byte {
+
+	t.Helper()
+
+	frames := make([]*image.Paletted, 2)
+	for i := range frames {
+		frames[i] = image.NewPaletted(image.Rect(0, 0, w, h), palette.Plan9)
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				frames[i].SetColorIndex(x, y, uint8((x+y+i*40)%256))
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, &gif.GIF{Image: frames, Delay: []int{10, 10}}); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	// header, logical screen descriptor and global color table
+	headerEnd := 13
+	if flags := data[10]; flags&0x80 != 0 {
+		headerEnd += 3 << ((flags & 0x07) + 1)
+	}
+
+	comment := []byte{0x21, 0xFE, byte(len(gpsMarker))}
+	comment = append(comment, gpsMarker...)
+	comment = append(comment, 0x00)
+
+	out := append([]byte{}, data[:headerEnd]...)
+	out = append(out, comment...)
+
+	return append(out, data[headerEnd:]...)
+}
+
+func webpChunkBytes(fourCC string, payload []byte) []byte {
+
+	chunk := []byte(fourCC)
+	chunk = binary.LittleEndian.AppendUint32(chunk, uint32(len(payload)))
+	chunk = append(chunk, payload...)
+	if len(payload)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+
+	return chunk
+}
+
+func readTestWebP(t *testing.T, name string) []webpChunk {
+
+	t.Helper()
+
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunks, err := readWebPChunks(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return chunks
+}
+
+// webpWithExif puts the image chunks of a testdata webp in an extended webp with an EXIF chunk
+func webpWithExif(t *testing.T, name string) []byte {
+
+	t.Helper()
+
+	chunks := readTestWebP(t, name)
+
+	w, h, err := webpDimensions(chunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body []byte
+	vp8x := make([]byte, 10)
+	vp8x[0] = vp8xExifFlag
+	for _, chunk := range chunks {
+		switch chunk.fourCC {
+		case "VP8X":
+			vp8x[0] |= chunk.payload[0]
+		case "ALPH":
+			vp8x[0] |= vp8xAlphaFlag
+			fallthrough
+		default:
+			body = append(body, webpChunkBytes(chunk.fourCC, chunk.payload)...)
+		}
+	}
+	putUint24(vp8x[4:7], w-1)
+	putUint24(vp8x[7:10], h-1)
+
+	out := webpChunkBytes("VP8X", vp8x)
+	out = append(out, body...)
+	out = append(out, webpChunkBytes("EXIF", exifSegment(1)[4:])...)
+
+	return riffWebP(out)
+}
+
+// animatedWebP is a two frame animation on a w x h canvas of the testdata webp, the second frame
+// drawn at the offset
+func animatedWebP(t *testing.T, name string, w, h, offsetX, offsetY int) []byte {
+
+	t.Helper()
+
+	chunks := readTestWebP(t, name)
+
+	fw, fh, err := webpDimensions(chunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var frameData []byte
+	for _, chunk := range chunks {
+		if chunk.fourCC != "VP8X" {
+			frameData = append(frameData, webpChunkBytes(chunk.fourCC, chunk.payload)...)
+		}
+	}
+
+	frame := func(x, y int) []byte {
+		header := make([]byte, 16)
+		putUint24(header[0:3], x/2)
+		putUint24(header[3:6], y/2)
+		putUint24(header[6:9], fw-1)
+		putUint24(header[9:12], fh-1)
+		putUint24(header[12:15], 100)
+		return webpChunkBytes("ANMF", append(header, frameData...))
+	}
+
+	vp8x := make([]byte, 10)
+	vp8x[0] = 0x02 | vp8xAlphaFlag | vp8xXmpFlag
+	putUint24(vp8x[4:7], w-1)
+	putUint24(vp8x[7:10], h-1)
+
+	out := webpChunkBytes("VP8X", vp8x)
+	out = append(out, webpChunkBytes("ANIM", make([]byte, 6))...)
+	out = append(out, frame(0, 0)...)
+	out = append(out, frame(offsetX, offsetY)...)
+	out = append(out, webpChunkBytes("XMP ", []byte(gpsMarker))...)
+
+	return riffWebP(out)
+}
+
+func putUint24(b []byte, v int) {
+	b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+}
+
+type wantVariant struct {
+	name        string
+	width       int
+	height      int
+	contentType string
+}
+
+func TestProcess(t *testing.T) {
+
+	tests := []struct {
+		name         string
+		data         []byte
+		format       Format
+		width        int
+		height       int
+		wantVariants []wantVariant
+	}{
+		{
+			name:   "jpeg",
+			data:   jpegWithExif(t, 1600, 900, 1),
+			format: JPEG, width: 1600, height: 900,
+			wantVariants: []wantVariant{
+				{OriginalVariant, 1600, 900, "image/jpeg"},
+				{"thumbnail", 320, 180, "image/jpeg"},
+				{"medium", 1280, 720, "image/jpeg"},
+			},
+		},
+		{
+			name:   "jpeg rotated by its exif orientation",
+			data:   jpegWithExif(t, 1600, 900, 6),
+			format: JPEG, width: 900, height: 1600,
+			wantVariants: []wantVariant{
+				{OriginalVariant, 900, 1600, "image/jpeg"},
+				{"thumbnail", 180, 320, "image/jpeg"},
+				{"medium", 720, 1280, "image/jpeg"},
+			},
+		},
+		{
+			name:   "jpeg larger than the original max size",
+			data:   jpegWithExif(t, 3000, 1500, 1),
+			format: JPEG, width: 2560, height: 1280,
+			wantVariants: []wantVariant{
+				{OriginalVariant, 2560, 1280, "image/jpeg"},
+				{"thumbnail", 320, 160, "image/jpeg"},
+				{"medium", 1280, 640, "image/jpeg"},
+			},
+		},
+		{
+			name:   "png",
+			data:   pngWithText(t, gradient(640, 480)),
+			format: PNG, width: 640, height: 480,
+			wantVariants: []wantVariant{
+				{OriginalVariant, 640, 480, "image/png"},
+				{"thumbnail", 320, 240, "image/png"},
+			},
+		},
+		{
+			name:   "gif",
+			data:   gifWithComment(t, 400, 200),
+			format: GIF, width: 400, height: 200,
+			wantVariants: []wantVariant{
+				{OriginalVariant, 400, 200, "image/gif"},
+				{"thumbnail", 320, 160, "image/png"},
+			},
+		},
+		{
+			name:   "lossy webp",
+			data:   webpWithExif(t, "lossy.webp"),
+			format: WebP, width: 150, height: 100,
+			wantVariants: []wantVariant{
+				{OriginalVariant, 150, 100, "image/webp"},
+			},
+		},
+		{
+			name:   "lossless webp",
+			data:   webpWithExif(t, "lossless.webp"),
+			format: WebP, width: 75, height: 100,
+			wantVariants: []wantVariant{
+				{OriginalVariant, 75, 100, "image/webp"},
+			},
+		},
+		{
+			name:   "lossy webp with alpha",
+			data:   webpWithExif(t, "lossy-with-alpha.webp"),
+			format: WebP, width: 400, height: 301,
+			wantVariants: []wantVariant{
+				{OriginalVariant, 400, 301, "image/webp"},
+				{"thumbnail", 320, 240, "image/png"},
+			},
+		},
+		{
+			name:   "animated webp",
+			data:   animatedWebP(t, "lossy.webp", 400, 300, 100, 100),
+			format: WebP, width: 400, height: 300,
+			wantVariants: []wantVariant{
+				{OriginalVariant, 400, 300, "image/webp"},
+				{"thumbnail", 320, 240, "image/png"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			result, err := Process(tt.data)
+			if err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+
+			if result.Format != tt.format || result.Width != tt.width || result.Height != tt.height {
+				t.Errorf("Process() = %s %dx%d, want %s %dx%d", result.Format, result.Width, result.Height, tt.format, tt.width, tt.height)
+			}
+
+			if len(result.Blurhash) != 4+2*blurhashXComponents*blurhashYComponents {
+				t.Errorf("Blurhash = %q, want a %dx%d components blurhash", result.Blurhash, blurhashXComponents, blurhashYComponents)
+			}
+
+			if len(result.Variants) != len(tt.wantVariants) {
+				t.Fatalf("got %d variants, want %d", len(result.Variants), len(tt.wantVariants))
+			}
+
+			for i, want := range tt.wantVariants {
+
+				variant := result.Variants[i]
+				if variant.Name != want.name || variant.Width != want.width || variant.Height != want.height || variant.ContentType != want.contentType {
+					t.Errorf("variant %d = %s %dx%d %s, want %s %dx%d %s", i, variant.Name, variant.Width, variant.Height, variant.ContentType,
+						want.name, want.width, want.height, want.contentType)
+				}
+
+				if bytes.Contains(variant.Data, []byte(gpsMarker)) || bytes.Contains(variant.Data, []byte("Exif\x00\x00")) {
+					t.Errorf("variant %s still has the metadata", variant.Name)
+				}
+
+				config, format, err := decodeVariantConfig(variant)
+				if err != nil {
+					t.Fatalf("variant %s does not decode: %v", variant.Name, err)
+				}
+
+				if config.Width != want.width || config.Height != want.height {
+					t.Errorf("variant %s decodes as %s %dx%d, want %dx%d", variant.Name, format, config.Width, config.Height, want.width, want.height)
+				}
+			}
+		})
+	}
+}
+
+func decodeVariantConfig(variant Variant) (image.Config, string, error) {
+
+	if variant.ContentType == WebP.ContentType() {
+		chunks, err := readWebPChunks(variant.Data)
+		if err != nil {
+			return image.Config{}, "", err
+		}
+		w, h, err := webpDimensions(chunks)
+		if err != nil {
+			return image.Config{}, "", err
+		}
+		for _, chunk := range chunks {
+			if chunk.fourCC == "EXIF" || chunk.fourCC == "XMP " {
+				return image.Config{}, "", ErrInvalidWebP
+			}
+		}
+		if chunks[0].fourCC == "VP8X" && chunks[0].payload[0]&(vp8xExifFlag|vp8xXmpFlag) != 0 {
+			return image.Config{}, "", ErrInvalidWebP
+		}
+		// animated webps are not read by the decoder
+		if chunks[0].fourCC != "VP8X" || chunks[0].payload[0]&0x02 == 0 {
+			if _, err := webp.Decode(bytes.NewReader(variant.Data)); err != nil {
+				return image.Config{}, "", err
+			}
+		}
+		return image.Config{Width: w, Height: h}, "webp", nil
+	}
+
+	return image.DecodeConfig(bytes.NewReader(variant.Data))
+}
+
+func TestProcessAnimatedGIFKeepsFrames(t *testing.T) {
+
+	result, err := Process(gifWithComment(t, 400, 200))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	anim, err := gif.DecodeAll(bytes.NewReader(result.Variant(OriginalVariant).Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(anim.Image) != 2 {
+		t.Errorf("original has %d frames, want 2", len(anim.Image))
+	}
+}
+
+func TestProcessAnimatedWebPDrawsFirstFrame(t *testing.T) {
+
+	result, err := Process(animatedWebP(t, "lossy.webp", 400, 300, 100, 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunks, err := readWebPChunks(result.Variant(OriginalVariant).Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frames := 0
+	for _, chunk := range chunks {
+		if chunk.fourCC == "ANMF" {
+			frames++
+		}
+	}
+	if frames != 2 {
+		t.Errorf("original has %d frames, want 2", frames)
+	}
+
+	thumbnail, err := png.Decode(bytes.NewReader(result.Variant("thumbnail").Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first frame covers the top left of the canvas, the rest is transparent
+	if _, _, _, a := thumbnail.At(10, 10).RGBA(); a == 0 {
+		t.Error("the first frame is not drawn at the top left")
+	}
+	if _, _, _, a := thumbnail.At(300, 220).RGBA(); a != 0 {
+		t.Error("the canvas outside the first frame is not transparent")
+	}
+}
+
+func TestProcessRejects(t *testing.T) {
+
+	// a png header declaring a canvas larger than MaxPixels
+	var huge bytes.Buffer
+	if err := png.Encode(&huge, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	hugeData := huge.Bytes()
+	binary.BigEndian.PutUint32(hugeData[16:20], 20000)
+	binary.BigEndian.PutUint32(hugeData[20:24], 20000)
+	binary.BigEndian.PutUint32(hugeData[29:33], crc32.ChecksumIEEE(hugeData[12:29]))
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{name: "not an image", data: []byte("<svg xmlns='http://www.w3.org/2000/svg'></svg>"), want: ErrUnsupportedFormat},
+		{name: "too many pixels", data: hugeData, want: ErrImageTooLarge},
+		{name: "truncated webp", data: []byte("RIFF\xff\x00\x00\x00WEBPVP8 "), want: ErrInvalidWebP},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Process(tt.data); err != tt.want {
+				t.Errorf("Process() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
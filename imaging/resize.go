@@ -0,0 +1,87 @@
+package imaging
+
+import (
+	"image"
+	"image/draw"
+)
+
+// toRGBA copies img into a premultiplied RGBA image with its origin at 0,0
+func toRGBA(img image.Image) *image.RGBA {
+
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+
+	return rgba
+}
+
+// fit returns the largest size with the aspect ratio of w x h that fits in maxW x maxH, never upscaling
+func fit(w, h, maxW, maxH int) (int, int) {
+
+	if w <= maxW && h <= maxH {
+		return w, h
+	}
+
+	if w*maxH > h*maxW {
+		return maxW, max(1, h*maxW/w)
+	}
+
+	return max(1, w*maxH/h), maxH
+}
+
+// resize downscales src to w x h by averaging the source pixels each destination pixel covers.
+// it is separable, a horizontal pass into a float buffer followed by a vertical pass
+func resize(src *image.RGBA, w, h int) *image.RGBA {
+
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	if sw == w && sh == h {
+		return src
+	}
+
+	// horizontal pass, sh rows of w pixels
+	tmp := make([]float64, sh*w*4)
+	for x := 0; x < w; x++ {
+		forEachCoverage(x, w, sw, func(sx int, weight float64) {
+			for y := 0; y < sh; y++ {
+				si := y*src.Stride + sx*4
+				ti := (y*w + x) * 4
+				for c := 0; c < 4; c++ {
+					tmp[ti+c] += float64(src.Pix[si+c]) * weight
+				}
+			}
+		})
+	}
+
+	// vertical pass
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	acc := make([]float64, w*4)
+	for y := 0; y < h; y++ {
+		clear(acc)
+		forEachCoverage(y, h, sh, func(sy int, weight float64) {
+			for i := range acc {
+				acc[i] += tmp[sy*w*4+i] * weight
+			}
+		})
+		for i, v := range acc {
+			dst.Pix[y*dst.Stride+i] = uint8(min(255, v+0.5))
+		}
+	}
+
+	return dst
+}
+
+// forEachCoverage calls fn with every source index that destination index d covers
+// and the normalized share of it, when dn destination pixels span sn source pixels
+func forEachCoverage(d, dn, sn int, fn func(s int, weight float64)) {
+
+	scale := float64(sn) / float64(dn)
+	start := float64(d) * scale
+	end := start + scale
+
+	for s := int(start); s < sn && float64(s) < end; s++ {
+		coverage := min(end, float64(s+1)) - max(start, float64(s))
+		if coverage > 0 {
+			fn(s, coverage/scale)
+		}
+	}
+}
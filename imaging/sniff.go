@@ -0,0 +1,53 @@
+package imaging
+
+import "bytes"
+
+type Format string
+
+const (
+	JPEG Format = "jpeg"
+	PNG  Format = "png"
+	GIF  Format = "gif"
+	WebP Format = "webp"
+)
+
+func (f Format) ContentType() string {
+	return "image/" + string(f)
+}
+
+func (f Format) Extension() string {
+	if f == JPEG {
+		return ".jpg"
+	}
+	return "." + string(f)
+}
+
+// Sniff detects the image format from its magic bytes, the file name and
+// content type sent by the client are never trusted
+func Sniff(head []byte) (Format, bool) {
+
+	switch {
+	case bytes.HasPrefix(head, []byte{0xFF, 0xD8, 0xFF}):
+		return JPEG, true
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		return PNG, true
+	case bytes.HasPrefix(head, []byte("GIF87a")), bytes.HasPrefix(head, []byte("GIF89a")):
+		return GIF, true
+	case len(head) >= 12 && bytes.Equal(head[:4], []byte("RIFF")) && bytes.Equal(head[8:12], []byte("WEBP")):
+		return WebP, true
+	default:
+		return "", false
+	}
+}
+
+// ParseContentType returns the format of an accepted image content type
+func ParseContentType(contentType string) (Format, bool) {
+
+	for _, format := range []Format{JPEG, PNG, GIF, WebP} {
+		if format.ContentType() == contentType {
+			return format, true
+		}
+	}
+
+	return "", false
+}
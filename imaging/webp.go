@@ -0,0 +1,204 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+
+	"golang.org/x/image/webp"
+)
+
+var ErrInvalidWebP = errors.New("invalid webp image")
+
+// vp8x flags of the metadata chunks stripWebPMetadata drops
+const (
+	vp8xExifFlag  = 0x08
+	vp8xXmpFlag   = 0x04
+	vp8xAlphaFlag = 0x10
+)
+
+type webpChunk struct {
+	fourCC  string
+	payload []byte
+}
+
+func readWebPChunks(data []byte) ([]webpChunk, error) {
+
+	if len(data) < 12 {
+		return nil, ErrInvalidWebP
+	}
+
+	riffSize := int(binary.LittleEndian.Uint32(data[4:8]))
+	if riffSize+8 > len(data) || riffSize < 4 {
+		return nil, ErrInvalidWebP
+	}
+
+	var chunks []webpChunk
+	body := data[12 : 8+riffSize]
+	for len(body) > 0 {
+
+		if len(body) < 8 {
+			return nil, ErrInvalidWebP
+		}
+
+		size := int(binary.LittleEndian.Uint32(body[4:8]))
+		if size > len(body)-8 {
+			return nil, ErrInvalidWebP
+		}
+
+		chunks = append(chunks, webpChunk{fourCC: string(body[:4]), payload: body[8 : 8+size]})
+
+		// chunks are padded to an even size
+		next := 8 + size + size%2
+		if next > len(body) {
+			next = len(body)
+		}
+		body = body[next:]
+	}
+
+	if len(chunks) == 0 {
+		return nil, ErrInvalidWebP
+	}
+
+	return chunks, nil
+}
+
+// webpDimensions reads the canvas size from the VP8X, VP8 or VP8L header
+func webpDimensions(chunks []webpChunk) (int, int, error) {
+
+	first := chunks[0]
+	p := first.payload
+
+	switch first.fourCC {
+	case "VP8X":
+		if len(p) < 10 {
+			return 0, 0, ErrInvalidWebP
+		}
+		w := int(p[4]) | int(p[5])<<8 | int(p[6])<<16
+		h := int(p[7]) | int(p[8])<<8 | int(p[9])<<16
+		return w + 1, h + 1, nil
+
+	case "VP8 ":
+		if len(p) < 10 || !bytes.Equal(p[3:6], []byte{0x9D, 0x01, 0x2A}) {
+			return 0, 0, ErrInvalidWebP
+		}
+		w := int(binary.LittleEndian.Uint16(p[6:8]) & 0x3FFF)
+		h := int(binary.LittleEndian.Uint16(p[8:10]) & 0x3FFF)
+		return w, h, nil
+
+	case "VP8L":
+		if len(p) < 5 || p[0] != 0x2F {
+			return 0, 0, ErrInvalidWebP
+		}
+		bits := binary.LittleEndian.Uint32(p[1:5])
+		return int(bits&0x3FFF) + 1, int(bits>>14&0x3FFF) + 1, nil
+	}
+
+	return 0, 0, ErrInvalidWebP
+}
+
+// stripWebPMetadata rewrites the RIFF container without its EXIF and XMP chunks.
+// the image data itself is copied untouched so that it is not encoded again
+func stripWebPMetadata(chunks []webpChunk) []byte {
+
+	var body bytes.Buffer
+	body.WriteString("WEBP")
+
+	for _, chunk := range chunks {
+
+		if chunk.fourCC == "EXIF" || chunk.fourCC == "XMP " {
+			continue
+		}
+
+		payload := chunk.payload
+		if chunk.fourCC == "VP8X" && len(payload) > 0 {
+			payload = bytes.Clone(payload)
+			payload[0] &^= vp8xExifFlag | vp8xXmpFlag
+		}
+
+		body.WriteString(chunk.fourCC)
+		binary.Write(&body, binary.LittleEndian, uint32(len(payload)))
+		body.Write(payload)
+		if len(payload)%2 == 1 {
+			body.WriteByte(0)
+		}
+	}
+
+	out := make([]byte, 0, body.Len()+8)
+	out = append(out, "RIFF"...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(body.Len()))
+
+	return append(out, body.Bytes()...)
+}
+
+// decodeWebPFirstFrame decodes a still webp, or the first frame of an animated one drawn on its
+// w x h canvas. the webp decoder does not read animations, so the frame is put in a still webp of its own
+func decodeWebPFirstFrame(data []byte, chunks []webpChunk, w, h int) (*image.RGBA, error) {
+
+	var frame []byte
+	for _, chunk := range chunks {
+		if chunk.fourCC == "ANMF" {
+			frame = chunk.payload
+			break
+		}
+	}
+
+	if frame == nil {
+		img, err := webp.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return toRGBA(img), nil
+	}
+
+	// the frame header is its offset halved, its size minus one, its duration and flags
+	if len(frame) < 16 {
+		return nil, ErrInvalidWebP
+	}
+
+	offsetX := 2 * (int(frame[0]) | int(frame[1])<<8 | int(frame[2])<<16)
+	offsetY := 2 * (int(frame[3]) | int(frame[4])<<8 | int(frame[5])<<16)
+	frameData := frame[16:]
+
+	vp8x := make([]byte, 10)
+	copy(vp8x[4:10], frame[6:12])
+
+	frameChunks, err := readWebPChunks(riffWebP(frameData))
+	if err != nil {
+		return nil, err
+	}
+	for _, chunk := range frameChunks {
+		if chunk.fourCC == "ALPH" {
+			vp8x[0] |= vp8xAlphaFlag
+		}
+	}
+
+	var still bytes.Buffer
+	still.WriteString("VP8X")
+	binary.Write(&still, binary.LittleEndian, uint32(len(vp8x)))
+	still.Write(vp8x)
+	still.Write(frameData)
+
+	img, err := webp.Decode(bytes.NewReader(riffWebP(still.Bytes())))
+	if err != nil {
+		return nil, err
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(canvas, img.Bounds().Add(image.Pt(offsetX, offsetY)), img, img.Bounds().Min, draw.Src)
+
+	return canvas, nil
+}
+
+// riffWebP wraps chunks in a RIFF WEBP container
+func riffWebP(chunks []byte) []byte {
+
+	out := make([]byte, 0, len(chunks)+12)
+	out = append(out, "RIFF"...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(chunks)+4))
+	out = append(out, "WEBP"...)
+
+	return append(out, chunks...)
+}
@@ -0,0 +1,73 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileMailer writes every mail as an .eml file into a directory
+type FileMailer struct {
+	dir string
+	mu  sync.Mutex
+	seq int
+}
+
+func NewFileMailer(dir string) (*FileMailer, error) {
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &FileMailer{dir: dir}, nil
+}
+
+func (m *FileMailer) Send(msg Message) error {
+
+	m.mu.Lock()
+	m.seq++
+	fileName := fmt.Sprintf("%d-%04d.eml", time.Now().UnixNano(), m.seq)
+	m.mu.Unlock()
+
+	file, err := os.Create(filepath.Join(m.dir, fileName))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := toGoMailMessage(msg).WriteTo(file); err != nil {
+		return err
+	}
+
+	return file.Close()
+}
+
+// MemoryMailer keeps sent mails in memory so tests can assert on them
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (m *MemoryMailer) Send(msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Messages returns a copy of every mail sent so far
+func (m *MemoryMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	messages := make([]Message, len(m.messages))
+	copy(messages, m.messages)
+	return messages
+}
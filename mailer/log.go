@@ -0,0 +1,22 @@
+package mailer
+
+import "log"
+
+// LogMailer prints mails instead of sending them, for local development
+type LogMailer struct {
+	logger *log.Logger
+}
+
+func NewLogMailer(logger *log.Logger) *LogMailer {
+
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	return &LogMailer{logger: logger}
+}
+
+func (m *LogMailer) Send(msg Message) error {
+	m.logger.Printf("mail from %s to %s , subject %q :-\n%s\n", msg.From, msg.To, msg.Subject, msg.HTMLBody)
+	return nil
+}
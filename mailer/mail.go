@@ -1,85 +1,46 @@
 package mailer
 
 import (
-	"bytes"
-	"fmt"
-	"html/template"
-	"os"
-
 	"gopkg.in/gomail.v2"
 )
 
-type InviteMailData struct {
-	Subject       string
-	ActivationUrl string
-}
-
-type PasswordResetMailData struct {
-	Subject           string
-	PasswordResetLink string
+type Message struct {
+	From     string
+	To       string
+	Subject  string
+	HTMLBody string
+	TextBody string
+	// extra headers, e.g. List-Unsubscribe
+	Headers map[string]string
 }
 
-type GoMailConfig struct {
-	GoMailUsername string
-	GoMailPassword string
-	GoMailPort     int
-}
-
-func NewGoMailConfig(goMailUsername string, goMailPassword string, goMailPort int) *GoMailConfig {
-	return &GoMailConfig{
-		GoMailUsername: goMailUsername,
-		GoMailPassword: goMailPassword,
-		GoMailPort:     goMailPort,
-	}
+// Mailer delivers a fully rendered message
+type Mailer interface {
+	Send(msg Message) error
 }
 
-func SendGoInvitationMail(fromEmail string, toEmail string, subject string, templatePath string, plainTextToken string) error {
-	goMailCfg := NewGoMailConfig(os.Getenv("GOMAIL_USERNAME"), os.Getenv("GOMAIL_PASSWORD"), 587)
-
-	clientUrl := os.Getenv("CLIENT_URL")
-	activationUrl := fmt.Sprintf("%s/activate-account/%s", clientUrl, plainTextToken)
-
-	// parse template
-	tmpl := template.Must(template.ParseFiles(templatePath))
-
-	var body bytes.Buffer
-
-	tmpl.Execute(&body, InviteMailData{Subject: subject, ActivationUrl: activationUrl})
+// mails with both bodies are sent as multipart/alternative, plain text first so clients prefer the html part
+func toGoMailMessage(msg Message) *gomail.Message {
 
 	message := gomail.NewMessage()
 
-	message.SetHeader("From", fromEmail)
-	message.SetHeader("To", toEmail)
-	message.SetHeader("Subject", subject)
-	message.SetBody("text/html", body.String())
-
-	dialer := gomail.NewDialer("smtp.gmail.com", 587, goMailCfg.GoMailUsername, goMailCfg.GoMailPassword)
+	message.SetHeader("From", msg.From)
+	message.SetHeader("To", msg.To)
+	message.SetHeader("Subject", msg.Subject)
 
-	return dialer.DialAndSend(message)
-}
-
-func SendGoPasswordResetMail(fromEmail string, toEmail string, subject string, templatePath string, plainTextToken string) error {
-	goMailCfg := NewGoMailConfig(os.Getenv("GOMAIL_USERNAME"), os.Getenv("GOMAIL_PASSWORD"), 587)
-	clientUrl := os.Getenv("CLIENT_URL")
-
-	passwordResetLink := fmt.Sprintf("%s/password-reset/%s", clientUrl, plainTextToken)
-
-	tmpl := template.Must(template.ParseFiles(templatePath))
-
-	var body bytes.Buffer
-
-	if err := tmpl.Execute(&body, PasswordResetMailData{Subject: "Password reset mail", PasswordResetLink: passwordResetLink}); err != nil {
-		return err
+	for name, value := range msg.Headers {
+		message.SetHeader(name, value)
 	}
 
-	message := gomail.NewMessage()
-
-	message.SetHeader("From", fromEmail)
-	message.SetHeader("To", toEmail)
-	message.SetHeader("Subject", subject)
-	message.SetBody("text/html", body.String())
-
-	dialer := gomail.NewDialer("smtp.gmail.com", goMailCfg.GoMailPort, goMailCfg.GoMailUsername, goMailCfg.GoMailPassword)
+	switch {
+	case msg.TextBody != "" && msg.HTMLBody != "":
+		message.SetBody("text/plain", msg.TextBody)
+		message.AddAlternative("text/html", msg.HTMLBody)
+	case msg.HTMLBody != "":
+		message.SetBody("text/html", msg.HTMLBody)
+	default:
+		message.SetBody("text/plain", msg.TextBody)
+	}
 
-	return dialer.DialAndSend(message)
+	return message
 }
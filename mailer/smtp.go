@@ -0,0 +1,57 @@
+package mailer
+
+import (
+	"crypto/tls"
+	"fmt"
+
+	"gopkg.in/gomail.v2"
+)
+
+type smtpTLSMode string
+
+const (
+	// upgrade a plain connection with STARTTLS when the server offers it
+	StartTLSMode smtpTLSMode = "starttls"
+	// implicit TLS from the first byte, usually port 465
+	ImplicitTLSMode smtpTLSMode = "tls"
+)
+
+type SMTPConfig struct {
+	Host               string
+	Port               int
+	Username           string
+	Password           string
+	TLSMode            string
+	InsecureSkipVerify bool
+}
+
+type SMTPMailer struct {
+	dialer *gomail.Dialer
+}
+
+func NewSMTPMailer(cfg SMTPConfig) (*SMTPMailer, error) {
+
+	if cfg.Host == "" || cfg.Port == 0 {
+		return nil, fmt.Errorf("smtp host and port are required")
+	}
+
+	// gomail only authenticates when a username is set
+	dialer := gomail.NewDialer(cfg.Host, cfg.Port, cfg.Username, cfg.Password)
+
+	switch smtpTLSMode(cfg.TLSMode) {
+	case "", StartTLSMode:
+		dialer.SSL = false
+	case ImplicitTLSMode:
+		dialer.SSL = true
+	default:
+		return nil, fmt.Errorf("invalid smtp tls mode %q", cfg.TLSMode)
+	}
+
+	dialer.TLSConfig = &tls.Config{ServerName: cfg.Host, InsecureSkipVerify: cfg.InsecureSkipVerify}
+
+	return &SMTPMailer{dialer: dialer}, nil
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	return m.dialer.DialAndSend(toGoMailMessage(msg))
+}
@@ -0,0 +1,218 @@
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	texttemplate "text/template"
+)
+
+const (
+	DefaultLocale = "en"
+	AppName       = "EchoBlog"
+)
+
+const (
+	InviteTemplate        = "invite"
+	PasswordResetTemplate = "password_reset"
+	DigestTemplate        = "digest"
+)
+
+// TemplateData is what every mail template is rendered with.
+// template specific values live in Data, e.g. {{ .Data.ActivationUrl }}
+type TemplateData struct {
+	AppName   string
+	ClientUrl string
+	Locale    string
+	Subject   string
+	Data      map[string]any
+}
+
+type templateKey struct {
+	locale string
+	name   string
+}
+
+// Templates is the registry of mail templates, parsed once at startup.
+//
+// every mail has an html and a plain text variant per locale, laid out as
+// locales/<locale>/<name>.html and locales/<locale>/<name>.txt. the html variant is
+// wrapped in layouts/base.html and the text variant in layouts/base.txt.
+// the text variant also defines the "subject" block
+type Templates struct {
+	html    map[templateKey]*htmltemplate.Template
+	text    map[templateKey]*texttemplate.Template
+	names   []string
+	locales []string
+}
+
+func ParseTemplates(fsys fs.FS) (*Templates, error) {
+
+	t := &Templates{
+		html: make(map[templateKey]*htmltemplate.Template),
+		text: make(map[templateKey]*texttemplate.Template),
+	}
+
+	localeDirs, err := fs.ReadDir(fsys, "locales")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, localeDir := range localeDirs {
+
+		if !localeDir.IsDir() {
+			continue
+		}
+
+		locale := localeDir.Name()
+		t.locales = append(t.locales, locale)
+
+		htmlFiles, err := fs.Glob(fsys, path.Join("locales", locale, "*.html"))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, htmlFile := range htmlFiles {
+
+			name := strings.TrimSuffix(path.Base(htmlFile), ".html")
+			if name == "common" {
+				continue
+			}
+
+			commonHtmlFile := path.Join("locales", locale, "common.html")
+			commonTextFile := path.Join("locales", locale, "common.txt")
+			textFile := path.Join("locales", locale, name+".txt")
+
+			htmlTmpl, err := htmltemplate.ParseFS(fsys, "layouts/base.html", commonHtmlFile, htmlFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse mail template %s :- %w", htmlFile, err)
+			}
+
+			textTmpl, err := texttemplate.ParseFS(fsys, "layouts/base.txt", commonTextFile, textFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse mail template %s :- %w", textFile, err)
+			}
+
+			if textTmpl.Lookup("subject") == nil {
+				return nil, fmt.Errorf("mail template %s does not define a subject", textFile)
+			}
+
+			key := templateKey{locale: locale, name: name}
+			t.html[key] = htmlTmpl
+			t.text[key] = textTmpl
+
+			if !slices.Contains(t.names, name) {
+				t.names = append(t.names, name)
+			}
+		}
+	}
+
+	if !slices.Contains(t.locales, DefaultLocale) {
+		return nil, fmt.Errorf("mail templates for default locale %s not found", DefaultLocale)
+	}
+
+	// every template must exist in the default locale so it can always fall back
+	for _, name := range t.names {
+		if _, ok := t.html[templateKey{locale: DefaultLocale, name: name}]; !ok {
+			return nil, fmt.Errorf("mail template %s missing for default locale %s", name, DefaultLocale)
+		}
+	}
+
+	slices.Sort(t.names)
+	slices.Sort(t.locales)
+
+	return t, nil
+}
+
+func (t *Templates) Names() []string {
+	return t.names
+}
+
+func (t *Templates) Locales() []string {
+	return t.locales
+}
+
+func (t *Templates) HasLocale(locale string) bool {
+	return slices.Contains(t.locales, locale)
+}
+
+// Render renders the subject, html and plain text bodies of a mail.
+// unknown locales fall back to DefaultLocale
+func (t *Templates) Render(name string, locale string, data TemplateData) (subject string, htmlBody string, textBody string, err error) {
+
+	key := templateKey{locale: locale, name: name}
+	if _, ok := t.html[key]; !ok {
+		key.locale = DefaultLocale
+	}
+
+	htmlTmpl, ok := t.html[key]
+	if !ok {
+		return "", "", "", fmt.Errorf("mail template %s not found", name)
+	}
+	textTmpl := t.text[key]
+
+	if data.AppName == "" {
+		data.AppName = AppName
+	}
+	data.Locale = key.locale
+
+	var buf bytes.Buffer
+
+	if err := textTmpl.ExecuteTemplate(&buf, "subject", data); err != nil {
+		return "", "", "", err
+	}
+	subject = strings.TrimSpace(buf.String())
+	data.Subject = subject
+
+	buf.Reset()
+	if err := htmlTmpl.ExecuteTemplate(&buf, "base.html", data); err != nil {
+		return "", "", "", err
+	}
+	htmlBody = buf.String()
+
+	buf.Reset()
+	if err := textTmpl.ExecuteTemplate(&buf, "base.txt", data); err != nil {
+		return "", "", "", err
+	}
+	textBody = strings.TrimSpace(buf.String()) + "\n"
+
+	return subject, htmlBody, textBody, nil
+}
+
+// Compose renders a mail into a message ready to be sent
+func (t *Templates) Compose(name string, locale string, fromEmail string, toEmail string, data TemplateData) (Message, error) {
+
+	subject, htmlBody, textBody, err := t.Render(name, locale, data)
+	if err != nil {
+		return Message{}, err
+	}
+
+	return Message{From: fromEmail, To: toEmail, Subject: subject, HTMLBody: htmlBody, TextBody: textBody}, nil
+}
+
+// SampleData is used to preview templates during development
+var SampleData = map[string]map[string]any{
+	InviteTemplate: {
+		"ActivationUrl": "http://localhost:5173/activate-account/sample-token",
+	},
+	PasswordResetTemplate: {
+		"PasswordResetLink": "http://localhost:5173/password-reset/sample-token",
+	},
+	DigestTemplate: {
+		"Frequency": "weekly",
+		"Activity": []map[string]any{
+			{"Type": "blog_like", "Count": 12},
+			{"Type": "blog_comment", "Count": 3},
+			{"Type": "follow", "Count": 2},
+		},
+		"Blogs": []map[string]any{
+			{"Title": "Understanding Go interfaces", "AuthorName": "Jane", "Url": "http://localhost:5173/blog/1"},
+			{"Title": "Postgres indexing in practice", "AuthorName": "Sam", "Url": "http://localhost:5173/blog/2"},
+		},
+		"UnsubscribeUrl": "http://localhost:5173/unsubscribe?token=sample-token",
+	},
+}
@@ -1,24 +1,73 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
+	"github.com/dhruv15803/echo-blog-app/analytics"
+	"github.com/dhruv15803/echo-blog-app/blobstore"
 	"github.com/dhruv15803/echo-blog-app/cloudinary"
 	"github.com/dhruv15803/echo-blog-app/db"
+	"github.com/dhruv15803/echo-blog-app/digest"
 	"github.com/dhruv15803/echo-blog-app/handlers"
+	"github.com/dhruv15803/echo-blog-app/mailer"
+	"github.com/dhruv15803/echo-blog-app/media"
+	"github.com/dhruv15803/echo-blog-app/notifications"
+	"github.com/dhruv15803/echo-blog-app/outbox"
+	"github.com/dhruv15803/echo-blog-app/realtime"
+	"github.com/dhruv15803/echo-blog-app/related"
 	"github.com/dhruv15803/echo-blog-app/storage"
+	"github.com/dhruv15803/echo-blog-app/templates"
+	"github.com/dhruv15803/echo-blog-app/trending"
+	"github.com/dhruv15803/echo-blog-app/webhooks"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/jmoiron/sqlx"
 	"github.com/joho/godotenv"
 )
 
 type ServerConfig struct {
-	Addr          string
-	DbConnStr     string
-	CloudinaryUrl string
+	Addr                    string
+	DbConnStr               string
+	CloudinaryUrl           string
+	ReportAutoHideThreshold int
+	MailFromEmail           string
+	EmailWorkers            int
+	ClientUrl               string
+	MailBackend             string
+	MailFileDir             string
+	SMTP                    mailer.SMTPConfig
+	RealtimeBroker          string
+	MaxEventStreamsPerUser  int
+	ApiUrl                  string
+	WebhookWorkers          int
+	WebhookDisableAfter     int
+	WebhookRequireHttps     bool
+	// lets webhooks reach loopback and private addresses, for receivers running next to the api in development
+	WebhookAllowPrivateNetworks bool
+	UnsubscribeSecret           string
+	BlobStore                   string
+	LocalUploadDir              string
+	LocalUploadUrl              string
+	S3                          blobstore.S3Config
+	MaxUploadBytes              int64
+	MaxDirectUploadBytes        int64
+	LocalUploadSecret           string
+	MediaQuotaBytes             int64
+	MediaGCGracePeriod          time.Duration
+	MediaProcessingWorkers      int
+	FeedMaxItems                int
+	ViewDedupeWindow            time.Duration
+	ViewHashSecret              string
 }
 
 func loadServerConfig() (*ServerConfig, error) {
@@ -31,18 +80,257 @@ func loadServerConfig() (*ServerConfig, error) {
 	dbConnStr := os.Getenv("DB_CONN")
 	cloudinaryUrl := os.Getenv("CLOUDINARY_URL")
 
+	reportAutoHideThreshold, err := strconv.Atoi(os.Getenv("REPORT_AUTO_HIDE_THRESHOLD"))
+	if err != nil {
+		reportAutoHideThreshold = 5
+	}
+
+	emailWorkers, err := strconv.Atoi(os.Getenv("EMAIL_WORKERS"))
+	if err != nil {
+		emailWorkers = 2
+	}
+
+	smtpPort, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
+	if err != nil {
+		smtpPort = 587
+	}
+
+	smtpHost := os.Getenv("SMTP_HOST")
+	if smtpHost == "" {
+		smtpHost = "smtp.gmail.com"
+	}
+
+	smtpUsername := os.Getenv("SMTP_USERNAME")
+	if smtpUsername == "" {
+		smtpUsername = os.Getenv("GOMAIL_USERNAME")
+	}
+
+	smtpPassword := os.Getenv("SMTP_PASSWORD")
+	if smtpPassword == "" {
+		smtpPassword = os.Getenv("GOMAIL_PASSWORD")
+	}
+
+	mailBackend := os.Getenv("MAIL_BACKEND")
+	if mailBackend == "" {
+		mailBackend = "smtp"
+	}
+
+	mailFileDir := os.Getenv("MAIL_FILE_DIR")
+	if mailFileDir == "" {
+		mailFileDir = "./mail"
+	}
+
+	realtimeBroker := os.Getenv("REALTIME_BROKER")
+	if realtimeBroker == "" {
+		realtimeBroker = "memory"
+	}
+
+	maxEventStreamsPerUser, err := strconv.Atoi(os.Getenv("MAX_EVENT_STREAMS_PER_USER"))
+	if err != nil {
+		maxEventStreamsPerUser = 5
+	}
+
+	webhookWorkers, err := strconv.Atoi(os.Getenv("WEBHOOK_WORKERS"))
+	if err != nil {
+		webhookWorkers = 2
+	}
+
+	webhookDisableAfter, err := strconv.Atoi(os.Getenv("WEBHOOK_DISABLE_AFTER_FAILURES"))
+	if err != nil {
+		webhookDisableAfter = 20
+	}
+
+	blobStore := os.Getenv("BLOB_STORE")
+	if blobStore == "" {
+		blobStore = "cloudinary"
+	}
+
+	localUploadDir := os.Getenv("LOCAL_UPLOAD_DIR")
+	if localUploadDir == "" {
+		localUploadDir = "./uploads"
+	}
+
+	localUploadUrl := os.Getenv("LOCAL_UPLOAD_URL")
+	if localUploadUrl == "" {
+		localUploadUrl = os.Getenv("API_URL") + "/uploads"
+	}
+
+	maxUploadBytes, err := strconv.ParseInt(os.Getenv("MAX_UPLOAD_BYTES"), 10, 64)
+	if err != nil {
+		maxUploadBytes = 10 << 20
+	}
+
+	maxDirectUploadBytes, err := strconv.ParseInt(os.Getenv("MAX_DIRECT_UPLOAD_BYTES"), 10, 64)
+	if err != nil {
+		maxDirectUploadBytes = 50 << 20
+	}
+
+	// signs the upload urls of the local blob store, it is only needed when that store is used
+	var localUploadSecret string
+	if blobStore == "local" {
+		if localUploadSecret, err = dedicatedSecret("LOCAL_UPLOAD_SECRET"); err != nil {
+			return nil, err
+		}
+	}
+
+	mediaQuotaBytes, err := strconv.ParseInt(os.Getenv("MEDIA_QUOTA_BYTES"), 10, 64)
+	if err != nil {
+		mediaQuotaBytes = 500 << 20
+	}
+
+	mediaGCGracePeriod, err := time.ParseDuration(os.Getenv("MEDIA_GC_GRACE_PERIOD"))
+	if err != nil {
+		mediaGCGracePeriod = time.Hour * 24 * 7
+	}
+
+	// every worker holds a whole uploaded file and its decoded image in memory
+	mediaProcessingWorkers, err := strconv.Atoi(os.Getenv("MEDIA_PROCESSING_WORKERS"))
+	if err != nil {
+		mediaProcessingWorkers = 2
+	}
+
+	feedMaxItems, err := strconv.Atoi(os.Getenv("FEED_MAX_ITEMS"))
+	if err != nil || feedMaxItems <= 0 {
+		feedMaxItems = 20
+	}
+
+	viewDedupeWindow, err := time.ParseDuration(os.Getenv("VIEW_DEDUPE_WINDOW"))
+	if err != nil || viewDedupeWindow <= 0 {
+		viewDedupeWindow = time.Minute * 30
+	}
+
+	viewHashSecret, err := dedicatedSecret("VIEW_HASH_SECRET")
+	if err != nil {
+		return nil, err
+	}
+
+	// unsubscribe links are public, they are signed with their own key so that it never signs auth tokens
+	unsubscribeSecret, err := dedicatedSecret("UNSUBSCRIBE_SECRET")
+	if err != nil {
+		return nil, err
+	}
+
 	return &ServerConfig{
-		Addr:          addr,
-		DbConnStr:     dbConnStr,
-		CloudinaryUrl: cloudinaryUrl,
+		Addr:                    addr,
+		DbConnStr:               dbConnStr,
+		CloudinaryUrl:           cloudinaryUrl,
+		ReportAutoHideThreshold: reportAutoHideThreshold,
+		MailFromEmail:           os.Getenv("GOMAIL_FROM_EMAIL"),
+		EmailWorkers:            emailWorkers,
+		ClientUrl:               os.Getenv("CLIENT_URL"),
+		MailBackend:             mailBackend,
+		MailFileDir:             mailFileDir,
+		SMTP: mailer.SMTPConfig{
+			Host:               smtpHost,
+			Port:               smtpPort,
+			Username:           smtpUsername,
+			Password:           smtpPassword,
+			TLSMode:            os.Getenv("SMTP_TLS"),
+			InsecureSkipVerify: os.Getenv("SMTP_INSECURE_SKIP_VERIFY") == "true",
+		},
+		RealtimeBroker:              realtimeBroker,
+		MaxEventStreamsPerUser:      maxEventStreamsPerUser,
+		ApiUrl:                      os.Getenv("API_URL"),
+		WebhookWorkers:              webhookWorkers,
+		WebhookDisableAfter:         webhookDisableAfter,
+		WebhookRequireHttps:         os.Getenv("GO_ENV") == "production",
+		WebhookAllowPrivateNetworks: os.Getenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS") == "true",
+		UnsubscribeSecret:           unsubscribeSecret,
+		BlobStore:                   blobStore,
+		LocalUploadDir:              localUploadDir,
+		LocalUploadUrl:              localUploadUrl,
+		MaxUploadBytes:              maxUploadBytes,
+		MaxDirectUploadBytes:        maxDirectUploadBytes,
+		LocalUploadSecret:           localUploadSecret,
+		MediaQuotaBytes:             mediaQuotaBytes,
+		MediaGCGracePeriod:          mediaGCGracePeriod,
+		MediaProcessingWorkers:      mediaProcessingWorkers,
+		FeedMaxItems:                feedMaxItems,
+		ViewDedupeWindow:            viewDedupeWindow,
+		ViewHashSecret:              viewHashSecret,
+		S3: blobstore.S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyId:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			UsePathStyle:    os.Getenv("S3_USE_PATH_STYLE") == "true",
+			PublicUrl:       os.Getenv("S3_PUBLIC_URL"),
+		},
 	}, nil
 }
 
+// dedicatedSecret reads a signing secret that must be set and must not be the auth token secret
+func dedicatedSecret(name string) (string, error) {
+
+	secret := os.Getenv(name)
+	if secret == "" {
+		return "", fmt.Errorf("%s is not set", name)
+	}
+
+	if secret == os.Getenv("JWT_SECRET") {
+		return "", fmt.Errorf("%s must not be the same as JWT_SECRET", name)
+	}
+
+	return secret, nil
+}
+
+// MAIL_BACKEND picks how mail leaves the server :- smtp , log (print to stdout) or file (write .eml files)
+func newMailer(cfg *ServerConfig) (mailer.Mailer, error) {
+
+	switch cfg.MailBackend {
+	case "smtp":
+		return mailer.NewSMTPMailer(cfg.SMTP)
+	case "log":
+		return mailer.NewLogMailer(log.Default()), nil
+	case "file":
+		return mailer.NewFileMailer(cfg.MailFileDir)
+	default:
+		return nil, fmt.Errorf("unknown mail backend %q", cfg.MailBackend)
+	}
+}
+
+// newBlobStore picks where uploaded files are stored
+func newBlobStore(cfg *ServerConfig) (blobstore.BlobStore, error) {
+	switch cfg.BlobStore {
+	case "cloudinary":
+		cld, err := cloudinary.NewCloudinaryInstance()
+		if err != nil {
+			return nil, err
+		}
+		return blobstore.NewCloudinaryStore(cld), nil
+	case "local":
+		return blobstore.NewLocalStore(cfg.LocalUploadDir, cfg.LocalUploadUrl, []byte(cfg.LocalUploadSecret))
+	case "s3":
+		return blobstore.NewS3Store(cfg.S3, nil)
+	default:
+		return nil, fmt.Errorf("unknown blob store %q", cfg.BlobStore)
+	}
+}
+
+// newBroker picks the pub/sub backend for live events. the in-memory hub is enough for a
+// single instance, postgres LISTEN/NOTIFY fans events out to every instance
+func newBroker(ctx context.Context, cfg *ServerConfig, dbConn *sqlx.DB) (realtime.Broker, error) {
+	switch cfg.RealtimeBroker {
+	case "memory":
+		return realtime.NewHub(), nil
+	case "postgres":
+		broker, err := realtime.NewPostgresBroker(dbConn, cfg.DbConnStr)
+		if err != nil {
+			return nil, err
+		}
+		go broker.Run(ctx)
+		return broker, nil
+	default:
+		return nil, fmt.Errorf("unknown realtime broker %q", cfg.RealtimeBroker)
+	}
+}
+
 func main() {
 
 	cfg, err := loadServerConfig()
 	if err != nil {
-		log.Fatalln("failed to load server config")
+		log.Fatalf("failed to load server config :- %v\n", err.Error())
 	}
 
 	dbConn, err := db.ConnectToPostgres(cfg.DbConnStr)
@@ -53,17 +341,118 @@ func main() {
 	defer dbConn.Close()
 	log.Println("connected to database!")
 
-	cld, err := cloudinary.NewCloudinaryInstance()
+	blobs, err := newBlobStore(cfg)
 	if err != nil {
-		log.Fatalf("failed to load cloudinary instance :- %v\n", err.Error())
+		log.Fatalf("failed to create blob store :- %v\n", err.Error())
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	mailTemplates, err := mailer.ParseTemplates(templates.FS)
+	if err != nil {
+		log.Fatalf("failed to parse mail templates :- %v\n", err.Error())
+	}
+
+	mail, err := newMailer(cfg)
+	if err != nil {
+		log.Fatalf("failed to create mailer :- %v\n", err.Error())
 	}
 
 	store := storage.NewStorage(dbConn)
-	handler := handlers.NewHandler(store, cld)
+
+	emailDispatcher := outbox.NewDispatcher(store, mail, mailTemplates, cfg.MailFromEmail, cfg.ClientUrl, cfg.ApiUrl, cfg.EmailWorkers)
+	emailDispatcherDone := make(chan struct{})
+	go func() {
+		emailDispatcher.Run(ctx)
+		close(emailDispatcherDone)
+	}()
+
+	webhookDispatcher := webhooks.NewDispatcher(store, webhooks.NewClient(cfg.WebhookAllowPrivateNetworks), cfg.WebhookWorkers, cfg.WebhookDisableAfter)
+	webhookDispatcherDone := make(chan struct{})
+	go func() {
+		webhookDispatcher.Run(ctx)
+		close(webhookDispatcherDone)
+	}()
+
+	digestScheduler := digest.NewScheduler(store, []byte(cfg.UnsubscribeSecret))
+	go digestScheduler.Run(ctx)
+
+	mediaCollector := media.NewCollector(store, blobs, cfg.MediaGCGracePeriod)
+	go mediaCollector.Run(ctx)
+
+	mediaProcessor := media.NewProcessor(store, blobs, cfg.MediaQuotaBytes, cfg.MediaProcessingWorkers)
+	mediaProcessorDone := make(chan struct{})
+	go func() {
+		mediaProcessor.Run(ctx)
+		close(mediaProcessorDone)
+	}()
+
+	viewWriter := analytics.NewViewWriter(store)
+	viewWriterDone := make(chan struct{})
+	go func() {
+		viewWriter.Run(ctx)
+		close(viewWriterDone)
+	}()
+
+	viewRoller := analytics.NewRoller(store, cfg.ViewDedupeWindow)
+	go viewRoller.Run(ctx)
+
+	trendingRefresher := trending.NewRefresher(store)
+	go trendingRefresher.Run(ctx)
+
+	relatedRecommender := related.NewRecommender(store)
+	go relatedRecommender.Run(ctx)
+
+	broker, err := newBroker(ctx, cfg, dbConn)
+	if err != nil {
+		log.Fatalf("failed to create realtime broker :- %v\n", err.Error())
+	}
+
+	notifier := notifications.NewService(store, broker)
+
+	seriesPartDispatcher := notifications.NewSeriesPartDispatcher(store, notifier)
+	seriesPartDispatcherDone := make(chan struct{})
+	go func() {
+		seriesPartDispatcher.Run(ctx)
+		close(seriesPartDispatcherDone)
+	}()
+
+	handler := handlers.NewHandler(store, blobs, mail, mailTemplates, notifier, broker, webhooks.NewPublisher(store), viewWriter, handlers.HandlerConfig{
+		ReportAutoHideThreshold:     cfg.ReportAutoHideThreshold,
+		ClientUrl:                   cfg.ClientUrl,
+		MaxEventStreamsPerUser:      cfg.MaxEventStreamsPerUser,
+		UnsubscribeSecret:           []byte(cfg.UnsubscribeSecret),
+		MaxUploadBytes:              cfg.MaxUploadBytes,
+		MaxDirectUploadBytes:        cfg.MaxDirectUploadBytes,
+		MediaQuotaBytes:             cfg.MediaQuotaBytes,
+		ApiUrl:                      cfg.ApiUrl,
+		FeedMaxItems:                cfg.FeedMaxItems,
+		ViewDedupeWindow:            cfg.ViewDedupeWindow,
+		ViewHashSecret:              []byte(cfg.ViewHashSecret),
+		WebhookRequireHttps:         cfg.WebhookRequireHttps,
+		WebhookAllowPrivateNetworks: cfg.WebhookAllowPrivateNetworks,
+	})
 
 	r := chi.NewRouter()
 
+	// files of the local blob store are served, and directly uploaded to, by the api itself
+	if localStore, ok := blobs.(*blobstore.LocalStore); ok {
+		r.Handle("/uploads/*", http.StripPrefix("/uploads", localStore))
+	}
+
+	r.With(middleware.Logger).Get("/sitemap.xml", handler.SitemapIndexHandler)
+	r.With(middleware.Logger).Get("/sitemaps/{kind}-{page}.xml", handler.SitemapHandler)
+
+	r.Route("/feeds", func(r chi.Router) {
+		r.Use(middleware.Logger)
+		r.Get("/latest.{format}", handler.LatestFeedHandler)
+		r.Get("/topic/{topicId}.{format}", handler.TopicFeedHandler)
+		r.Get("/user/{userId}.{format}", handler.UserFeedHandler)
+	})
+
 	r.Route("/api", func(r chi.Router) {
+		r.Use(middleware.RequestID)
 		r.Use(middleware.Logger)
 		r.Get("/health", handler.HealthCheckHandler)
 
@@ -81,30 +470,128 @@ func main() {
 			r.With(handler.AuthMiddleware).With(handler.AdminMiddleware).Delete("/{topicId}", handler.DeleteTopicHandler)
 			r.With(handler.AuthMiddleware).With(handler.AdminMiddleware).Put("/{topicId}", handler.UpdateTopicHandler)
 			r.With(handler.AuthMiddleware).Get("/topics", handler.GetTopicsHandler)
+			r.Get("/by-slug/{slug}", handler.GetTopicBySlugHandler)
+			r.Get("/trending", handler.GetTrendingTopicsHandler)
 		})
 
 		r.Route("/blog", func(r chi.Router) {
 
 			r.Get("/{topicId}/blogs", handler.GetBlogsByTopicHandler)
+			r.Get("/trending", handler.GetTrendingBlogsHandler)
+			r.Get("/by-slug/{slug}", handler.GetBlogBySlugHandler)
+			r.Get("/{blogId}", handler.GetBlogHandler)
+			r.Get("/{blogId}/meta", handler.GetBlogMetaHandler)
+			r.Get("/{blogId}/related", handler.GetRelatedBlogsHandler)
+			r.Post("/{blogId}/view", handler.RecordBlogViewHandler)
 			r.With(handler.AuthMiddleware).Get("/following/blogs", handler.GetBlogsByUserFollowingsHandler)
 			r.Group(func(r chi.Router) {
 				r.Use(handler.AuthMiddleware)
 				r.Post("/", handler.CreateBlogHandler)
+				r.Put("/{blogId}", handler.UpdateBlogHandler)
 				r.Delete("/{blogId}", handler.DeleteBlogHandler)
 				r.Post("/{blogId}/like", handler.LikeBlogHandler)
 				r.Post("/{blogId}/comment", handler.CreateBlogCommentHandler)
 				r.Post("/{blogId}/bookmark", handler.BookmarkBlogHandler)
 				r.Post("/blog-comment/{blogCommentId}/like", handler.LikeBlogCommentHandler)
+				r.Get("/{blogId}/stats", handler.GetBlogStatsHandler)
 			})
 		})
 
 		r.Route("/user", func(r chi.Router) {
 			r.Use(handler.AuthMiddleware)
 			r.Post("/{userId}/follow", handler.FollowUserHandler)
+			r.Get("/suggestions", handler.GetUserSuggestionsHandler)
+			r.Post("/suggestions/{userId}/dismiss", handler.DismissUserSuggestionHandler)
+			r.Put("/language", handler.UpdateUserLanguageHandler)
+			r.Get("/notification-preferences", handler.GetNotificationPreferencesHandler)
+			r.Put("/notification-preferences", handler.UpdateNotificationPreferencesHandler)
 		})
 
+		r.Route("/me", func(r chi.Router) {
+			r.Use(handler.AuthMiddleware)
+			r.Get("/dashboard", handler.GetDashboardHandler)
+			r.Get("/bookmarks", handler.GetMyBookmarksHandler)
+			r.Put("/bookmarks/{blogId}/note", handler.UpdateBookmarkNoteHandler)
+			r.Get("/collections", handler.GetCollectionsHandler)
+			r.Post("/collections", handler.CreateCollectionHandler)
+			r.Put("/collections/{collectionId}", handler.UpdateCollectionHandler)
+			r.Delete("/collections/{collectionId}", handler.DeleteCollectionHandler)
+			r.Post("/collections/{collectionId}/items", handler.AddCollectionItemHandler)
+			r.Delete("/collections/{collectionId}/items/{blogId}", handler.RemoveCollectionItemHandler)
+			r.Put("/collections/{collectionId}/order", handler.ReorderCollectionHandler)
+		})
+
+		r.Get("/collections/{collectionId}", handler.GetCollectionHandler)
+
+		r.Route("/series", func(r chi.Router) {
+			r.Get("/{seriesId}", handler.GetSeriesHandler)
+			r.Group(func(r chi.Router) {
+				r.Use(handler.AuthMiddleware)
+				r.Post("/", handler.CreateSeriesHandler)
+				r.Put("/{seriesId}", handler.UpdateSeriesHandler)
+				r.Delete("/{seriesId}", handler.DeleteSeriesHandler)
+				r.Post("/{seriesId}/blogs", handler.AddSeriesBlogHandler)
+				r.Delete("/{seriesId}/blogs/{blogId}", handler.RemoveSeriesBlogHandler)
+				r.Put("/{seriesId}/order", handler.ReorderSeriesHandler)
+				r.Post("/{seriesId}/follow", handler.FollowSeriesHandler)
+			})
+		})
+
+		r.Route("/notifications", func(r chi.Router) {
+			r.Use(handler.AuthMiddleware)
+			r.Get("/", handler.GetNotificationsHandler)
+			r.Put("/read-all", handler.MarkAllNotificationsReadHandler)
+			r.Put("/{notificationId}/read", handler.MarkNotificationReadHandler)
+		})
+
+		r.With(handler.AuthMiddleware).Get("/events", handler.EventsHandler)
+		r.Post("/unsubscribe", handler.UnsubscribeDigestHandler)
+
+		r.Route("/webhooks", func(r chi.Router) {
+			r.Use(handler.AuthMiddleware)
+			r.Post("/", handler.CreateWebhookHandler)
+			r.Get("/", handler.GetWebhooksHandler)
+			r.Put("/{webhookId}", handler.UpdateWebhookHandler)
+			r.Delete("/{webhookId}", handler.DeleteWebhookHandler)
+			r.Get("/{webhookId}/deliveries", handler.GetWebhookDeliveriesHandler)
+			r.Get("/{webhookId}/deliveries/{deliveryId}", handler.GetWebhookDeliveryHandler)
+			r.Post("/{webhookId}/deliveries/{deliveryId}/replay", handler.ReplayWebhookDeliveryHandler)
+		})
+
+		r.Route("/report", func(r chi.Router) {
+			r.Use(handler.AuthMiddleware)
+			r.Post("/", handler.CreateReportHandler)
+			r.Get("/mine", handler.GetMyReportsHandler)
+		})
+
+		r.Route("/moderation", func(r chi.Router) {
+			r.Use(handler.AuthMiddleware)
+			r.Use(handler.ModeratorMiddleware)
+			r.Get("/reports", handler.GetModerationQueueHandler)
+			r.Get("/reports/{targetType}/{targetId}", handler.GetTargetReportsHandler)
+			r.Put("/reports/{targetType}/{targetId}/resolve", handler.ResolveReportsHandler)
+		})
+
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(handler.AuthMiddleware)
+			r.Use(handler.AdminMiddleware)
+			r.Get("/audit-log", handler.GetAuditLogHandler)
+		})
+
+		if os.Getenv("GO_ENV") == "development" {
+			r.Route("/dev", func(r chi.Router) {
+				r.Get("/mail-preview/{template}", handler.MailPreviewHandler)
+			})
+		}
+
 		r.Route("/file", func(r chi.Router) {
-			r.Post("/upload", handler.UploadFileHandler)
+			r.With(handler.AuthMiddleware).Post("/upload", handler.UploadFileHandler)
+			r.With(handler.AuthMiddleware).Post("/upload-intent", handler.CreateUploadIntentHandler)
+			r.With(handler.AuthMiddleware).Post("/upload-intent/{intentId}/complete", handler.CompleteUploadIntentHandler)
+		})
+
+		r.Route("/media", func(r chi.Router) {
+			r.With(handler.AuthMiddleware).Get("/", handler.GetMediaHandler)
 		})
 	})
 
@@ -114,13 +601,36 @@ func main() {
 		ReadTimeout:  time.Second * 15,
 		WriteTimeout: time.Second * 15,
 		IdleTimeout:  time.Second * 30,
+		// request contexts end on shutdown so open event streams do not hold it up
+		BaseContext: func(net.Listener) context.Context {
+			return ctx
+		},
 	}
 
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+		defer cancel()
+
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("failed to shutdown server gracefully :- %v\n", err.Error())
+		}
+	}()
+
 	log.Printf("Starting server on port %v\n", cfg.Addr)
 
-	if err = server.ListenAndServe(); err != nil {
+	if err = server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatalf("failed to start server on port %v\n", cfg.Addr)
 	}
+
+	// let in flight mails, webhooks, uploads and series notifications finish, and buffered views be written, before exiting
+	<-emailDispatcherDone
+	<-webhookDispatcherDone
+	<-mediaProcessorDone
+	<-seriesPartDispatcherDone
+	<-viewWriterDone
+	log.Println("server stopped")
 }
 
 /*
@@ -0,0 +1,750 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+)
+
+type blockKind int
+
+const (
+	documentBlock blockKind = iota
+	blockquoteBlock
+	listBlock
+	itemBlock
+	paragraphBlock
+	headingBlock
+	thematicBreakBlock
+	codeBlock
+	tableBlock
+)
+
+type listData struct {
+	ordered      bool
+	bulletChar   byte
+	delimiter    byte
+	start        int
+	padding      int
+	markerOffset int
+}
+
+type block struct {
+	kind     blockKind
+	parent   *block
+	children []*block
+	open     bool
+
+	// lines of paragraphs, code blocks and tables while they are open
+	lines []string
+	// inline content of paragraphs and headings once they are closed
+	content string
+
+	level int
+
+	fenced      bool
+	fenceChar   byte
+	fenceLength int
+	fenceOffset int
+	info        string
+	code        string
+
+	list listData
+}
+
+func (b *block) lastChild() *block {
+
+	if len(b.children) == 0 {
+		return nil
+	}
+
+	return b.children[len(b.children)-1]
+}
+
+func canContain(parent blockKind, child blockKind) bool {
+
+	switch parent {
+	case documentBlock, blockquoteBlock, itemBlock:
+		return child != itemBlock
+	case listBlock:
+		return child == itemBlock
+	}
+
+	return false
+}
+
+func acceptsLines(kind blockKind) bool {
+	return kind == paragraphBlock || kind == codeBlock || kind == tableBlock
+}
+
+// blockParser builds the block structure of a document line by line, following the
+// parsing strategy of the CommonMark spec: every line first continues the open blocks it
+// matches, then may start new ones, and what is left is added to the innermost open block
+type blockParser struct {
+	doc  *block
+	tip  *block
+	refs map[string]linkReference
+
+	line string
+
+	offset               int
+	column               int
+	nextNonspace         int
+	nextNonspaceColumn   int
+	indent               int
+	indented             bool
+	blank                bool
+	partiallyConsumedTab bool
+
+	oldTip               *block
+	allClosed            bool
+	lastMatchedContainer *block
+}
+
+func newBlockParser() *blockParser {
+
+	doc := &block{kind: documentBlock, open: true}
+
+	return &blockParser{doc: doc, tip: doc, refs: map[string]linkReference{}}
+}
+
+func (p *blockParser) parse(source string) *block {
+
+	source = strings.ReplaceAll(source, "\r\n", "\n")
+	source = strings.ReplaceAll(source, "\r", "\n")
+	source = strings.ReplaceAll(source, "\x00", "�")
+
+	lines := strings.Split(source, "\n")
+	if strings.HasSuffix(source, "\n") {
+		lines = lines[:len(lines)-1]
+	}
+
+	for _, line := range lines {
+		p.incorporateLine(line)
+	}
+
+	for p.tip != nil {
+		p.finalize(p.tip)
+	}
+
+	return p.doc
+}
+
+func (p *blockParser) incorporateLine(line string) {
+
+	p.line = line
+	p.offset = 0
+	p.column = 0
+	p.blank = false
+	p.partiallyConsumedTab = false
+
+	container := p.doc
+	p.oldTip = p.tip
+
+	// continue the open blocks the line matches
+	for {
+		last := container.lastChild()
+		if last == nil || !last.open {
+			break
+		}
+
+		p.findNextNonspace()
+
+		result := p.continueBlock(last)
+		if result == 2 {
+			// the line ended a fenced code block
+			return
+		}
+		if result == 1 {
+			break
+		}
+
+		container = last
+	}
+
+	p.allClosed = container == p.oldTip
+	p.lastMatchedContainer = container
+
+	// tables end where another block starts, so new blocks are looked for below them too
+	matchedLeaf := container.kind != paragraphBlock && container.kind != tableBlock && acceptsLines(container.kind)
+
+	for !matchedLeaf {
+
+		p.findNextNonspace()
+
+		if !p.indented && !maybeSpecialPattern.MatchString(p.line[p.nextNonspace:]) {
+			p.advanceNextNonspace()
+			break
+		}
+
+		result := 0
+		for _, start := range blockStarts {
+			if result = start(p, container); result != 0 {
+				break
+			}
+		}
+
+		if result == 0 {
+			p.advanceNextNonspace()
+			break
+		}
+
+		container = p.tip
+		matchedLeaf = result == 2
+	}
+
+	// a line that matched none of the open blocks still continues an open paragraph
+	if !p.allClosed && !p.blank && p.tip.kind == paragraphBlock {
+		p.addLine()
+		return
+	}
+
+	p.closeUnmatchedBlocks()
+
+	if acceptsLines(container.kind) {
+		p.addLine()
+	} else if p.offset < len(p.line) && !p.blank {
+		p.addChild(paragraphBlock)
+		p.advanceNextNonspace()
+		p.addLine()
+	}
+}
+
+// continueBlock returns 0 if the line continues b, 1 if it does not and 2 if it continued and completed b
+func (p *blockParser) continueBlock(b *block) int {
+
+	switch b.kind {
+	case blockquoteBlock:
+		if p.indented || p.nextNonspace >= len(p.line) || p.line[p.nextNonspace] != '>' {
+			return 1
+		}
+		p.advanceNextNonspace()
+		p.advanceOffset(1, false)
+		if p.offset < len(p.line) && isSpaceOrTab(p.line[p.offset]) {
+			p.advanceOffset(1, true)
+		}
+
+	case itemBlock:
+		if p.blank {
+			// an item can start with at most one blank line
+			if len(b.children) == 0 {
+				return 1
+			}
+			p.advanceNextNonspace()
+		} else if p.indent >= b.list.markerOffset+b.list.padding {
+			p.advanceOffset(b.list.markerOffset+b.list.padding, true)
+		} else {
+			return 1
+		}
+
+	case headingBlock, thematicBreakBlock:
+		return 1
+
+	case codeBlock:
+		if b.fenced {
+			if !p.indented && closingFence(p.line[p.nextNonspace:], b.fenceChar) >= b.fenceLength {
+				p.finalize(b)
+				return 2
+			}
+			// the indentation of the opening fence is removed from every line
+			for i := b.fenceOffset; i > 0 && p.offset < len(p.line) && isSpaceOrTab(p.line[p.offset]); i-- {
+				p.advanceOffset(1, true)
+			}
+		} else if p.indent >= 4 {
+			p.advanceOffset(4, true)
+		} else if p.blank {
+			p.advanceNextNonspace()
+		} else {
+			return 1
+		}
+
+	case paragraphBlock, tableBlock:
+		if p.blank {
+			return 1
+		}
+	}
+
+	return 0
+}
+
+var (
+	maybeSpecialPattern  = regexp.MustCompile("^[#`~*+_=<>0-9|:-]")
+	atxHeadingPattern    = regexp.MustCompile(`^#{1,6}(?:[ \t]+|$)`)
+	openingFencePattern  = regexp.MustCompile("^(?:`{3,}|~{3,})")
+	setextHeadingPattern = regexp.MustCompile(`^(?:=+|-+)[ \t]*$`)
+	thematicBreakPattern = regexp.MustCompile(`^(?:(?:\*[ \t]*){3,}|(?:_[ \t]*){3,}|(?:-[ \t]*){3,})$`)
+	bulletMarkerPattern  = regexp.MustCompile(`^[*+-]`)
+	orderedMarkerPattern = regexp.MustCompile(`^(\d{1,9})([.)])`)
+)
+
+// blockStarts are tried in order, each returns 0 if no block starts, 1 if a container block
+// started and 2 if a leaf block started
+var blockStarts = []func(p *blockParser, container *block) int{
+	startBlockquote,
+	startAtxHeading,
+	startFencedCode,
+	startTable,
+	startSetextHeading,
+	startThematicBreak,
+	startListItem,
+	startIndentedCode,
+}
+
+func startBlockquote(p *blockParser, container *block) int {
+
+	if p.indented || p.line[p.nextNonspace] != '>' {
+		return 0
+	}
+
+	p.advanceNextNonspace()
+	p.advanceOffset(1, false)
+	if p.offset < len(p.line) && isSpaceOrTab(p.line[p.offset]) {
+		p.advanceOffset(1, true)
+	}
+
+	p.closeUnmatchedBlocks()
+	p.addChild(blockquoteBlock)
+
+	return 1
+}
+
+func startAtxHeading(p *blockParser, container *block) int {
+
+	if p.indented {
+		return 0
+	}
+
+	match := atxHeadingPattern.FindString(p.line[p.nextNonspace:])
+	if match == "" {
+		return 0
+	}
+
+	p.advanceNextNonspace()
+	p.advanceOffset(len(match), false)
+	p.closeUnmatchedBlocks()
+
+	heading := p.addChild(headingBlock)
+	heading.level = strings.Count(match, "#")
+	heading.content = atxHeadingContent(p.line[p.offset:])
+
+	p.advanceOffset(len(p.line)-p.offset, false)
+
+	return 2
+}
+
+// atxHeadingContent removes the optional closing sequence of #s
+func atxHeadingContent(content string) string {
+
+	content = strings.TrimRight(content, " \t")
+
+	trimmed := strings.TrimRight(content, "#")
+	if trimmed == "" {
+		return ""
+	}
+	if len(trimmed) < len(content) && isSpaceOrTab(trimmed[len(trimmed)-1]) {
+		content = trimmed
+	}
+
+	return strings.TrimSpace(content)
+}
+
+func startFencedCode(p *blockParser, container *block) int {
+
+	if p.indented {
+		return 0
+	}
+
+	rest := p.line[p.nextNonspace:]
+
+	fence := openingFencePattern.FindString(rest)
+	if fence == "" || (fence[0] == '`' && strings.Contains(rest[len(fence):], "`")) {
+		return 0
+	}
+
+	p.closeUnmatchedBlocks()
+
+	code := p.addChild(codeBlock)
+	code.fenced = true
+	code.fenceChar = fence[0]
+	code.fenceLength = len(fence)
+	code.fenceOffset = p.indent
+
+	// the rest of the line is the info string, it is the first line of the block
+	p.advanceNextNonspace()
+	p.advanceOffset(len(fence), false)
+
+	return 2
+}
+
+// closingFence returns the length of the fence line if it closes a block fenced with c, 0 otherwise
+func closingFence(line string, c byte) int {
+
+	n := 0
+	for n < len(line) && line[n] == c {
+		n++
+	}
+
+	if n < 3 || strings.TrimRight(line[n:], " \t") != "" {
+		return 0
+	}
+
+	return n
+}
+
+// startTable turns the last line of a paragraph into the header of a gfm table when it is followed
+// by a delimiter row with the same number of cells
+func startTable(p *blockParser, container *block) int {
+
+	if p.indented || container.kind != paragraphBlock || len(container.lines) == 0 {
+		return 0
+	}
+
+	delimiterRow := p.line[p.nextNonspace:]
+	if !strings.Contains(delimiterRow, "|") {
+		return 0
+	}
+
+	alignments, ok := tableAlignments(delimiterRow)
+	if !ok {
+		return 0
+	}
+
+	header := container.lines[len(container.lines)-1]
+	if len(splitTableRow(header)) != len(alignments) {
+		return 0
+	}
+
+	p.closeUnmatchedBlocks()
+
+	// lines of the paragraph before the header stay a paragraph
+	container.lines = container.lines[:len(container.lines)-1]
+	if len(container.lines) == 0 {
+		parent := container.parent
+		parent.children = parent.children[:len(parent.children)-1]
+		p.tip = parent
+	} else {
+		p.finalize(container)
+	}
+
+	// the header is the first line of the table, the delimiter row is added as the second
+	table := p.addChild(tableBlock)
+	table.lines = []string{header}
+
+	p.advanceNextNonspace()
+
+	return 2
+}
+
+func startSetextHeading(p *blockParser, container *block) int {
+
+	if p.indented || container.kind != paragraphBlock {
+		return 0
+	}
+
+	match := setextHeadingPattern.FindString(p.line[p.nextNonspace:])
+	if match == "" {
+		return 0
+	}
+
+	p.closeUnmatchedBlocks()
+
+	// link reference definitions at the start of the paragraph are not part of the heading
+	content := p.removeReferenceDefinitions(strings.Join(container.lines, "\n"))
+	if content == "" {
+		return 0
+	}
+
+	heading := &block{kind: headingBlock, parent: container.parent, open: true, content: content, level: 2}
+	if match[0] == '=' {
+		heading.level = 1
+	}
+
+	siblings := container.parent.children
+	siblings[len(siblings)-1] = heading
+	p.tip = heading
+
+	p.advanceOffset(len(p.line)-p.offset, false)
+
+	return 2
+}
+
+func startThematicBreak(p *blockParser, container *block) int {
+
+	if p.indented || !thematicBreakPattern.MatchString(p.line[p.nextNonspace:]) {
+		return 0
+	}
+
+	p.closeUnmatchedBlocks()
+	p.addChild(thematicBreakBlock)
+	p.advanceOffset(len(p.line)-p.offset, false)
+
+	return 2
+}
+
+func startListItem(p *blockParser, container *block) int {
+
+	if p.indented && container.kind != listBlock {
+		return 0
+	}
+
+	data, ok := p.parseListMarker(container)
+	if !ok {
+		return 0
+	}
+
+	p.closeUnmatchedBlocks()
+
+	if p.tip.kind != listBlock || !listsMatch(p.tip.list, data) {
+		list := p.addChild(listBlock)
+		list.list = data
+	}
+
+	item := p.addChild(itemBlock)
+	item.list = data
+
+	return 1
+}
+
+func (p *blockParser) parseListMarker(container *block) (listData, bool) {
+
+	if p.indent >= 4 {
+		return listData{}, false
+	}
+
+	rest := p.line[p.nextNonspace:]
+	data := listData{markerOffset: p.indent}
+	markerLength := 0
+
+	if match := bulletMarkerPattern.FindString(rest); match != "" {
+		data.bulletChar = match[0]
+		markerLength = 1
+	} else if match := orderedMarkerPattern.FindStringSubmatch(rest); match != nil {
+		// only lists starting at 1 can interrupt a paragraph
+		start := atoi(match[1])
+		if container.kind == paragraphBlock && start != 1 {
+			return listData{}, false
+		}
+		data.ordered = true
+		data.start = start
+		data.delimiter = match[2][0]
+		markerLength = len(match[0])
+	} else {
+		return listData{}, false
+	}
+
+	// the marker has to be followed by whitespace, and an empty item cannot interrupt a paragraph
+	if markerLength < len(rest) && !isSpaceOrTab(rest[markerLength]) {
+		return listData{}, false
+	}
+	if container.kind == paragraphBlock && strings.TrimSpace(rest[markerLength:]) == "" {
+		return listData{}, false
+	}
+
+	p.advanceNextNonspace()
+	p.advanceOffset(markerLength, true)
+
+	spacesStartColumn := p.column
+	spacesStartOffset := p.offset
+
+	for {
+		p.advanceOffset(1, true)
+		if p.column-spacesStartColumn >= 5 || p.offset >= len(p.line) || !isSpaceOrTab(p.line[p.offset]) {
+			break
+		}
+	}
+
+	blankItem := p.offset >= len(p.line)
+	spacesAfterMarker := p.column - spacesStartColumn
+
+	// with 5 or more spaces the content is indented code, which starts one space after the marker
+	if spacesAfterMarker >= 5 || spacesAfterMarker < 1 || blankItem {
+		data.padding = markerLength + 1
+		p.column = spacesStartColumn
+		p.offset = spacesStartOffset
+		if p.offset < len(p.line) && isSpaceOrTab(p.line[p.offset]) {
+			p.advanceOffset(1, true)
+		}
+	} else {
+		data.padding = markerLength + spacesAfterMarker
+	}
+
+	return data, true
+}
+
+func listsMatch(list listData, item listData) bool {
+	return list.ordered == item.ordered && list.bulletChar == item.bulletChar && list.delimiter == item.delimiter
+}
+
+func startIndentedCode(p *blockParser, container *block) int {
+
+	if !p.indented || p.tip.kind == paragraphBlock || p.blank {
+		return 0
+	}
+
+	p.advanceOffset(4, true)
+	p.closeUnmatchedBlocks()
+	p.addChild(codeBlock)
+
+	return 2
+}
+
+func (p *blockParser) findNextNonspace() {
+
+	i := p.offset
+	column := p.column
+
+	for i < len(p.line) {
+		if p.line[i] == ' ' {
+			column++
+		} else if p.line[i] == '\t' {
+			column += 4 - column%4
+		} else {
+			break
+		}
+		i++
+	}
+
+	p.blank = i >= len(p.line)
+	p.nextNonspace = i
+	p.nextNonspaceColumn = column
+	p.indent = column - p.column
+	p.indented = p.indent >= 4
+}
+
+func (p *blockParser) advanceNextNonspace() {
+	p.offset = p.nextNonspace
+	p.column = p.nextNonspaceColumn
+	p.partiallyConsumedTab = false
+}
+
+// advanceOffset moves past count bytes, or count columns if columns is set. a tab that is only
+// partly consumed is remembered, the rest of it is added as spaces to the block's line
+func (p *blockParser) advanceOffset(count int, columns bool) {
+
+	for count > 0 && p.offset < len(p.line) {
+
+		if p.line[p.offset] != '\t' {
+			p.partiallyConsumedTab = false
+			p.offset++
+			p.column++
+			count--
+			continue
+		}
+
+		charsToTab := 4 - p.column%4
+
+		if columns {
+			p.partiallyConsumedTab = charsToTab > count
+			charsToAdvance := min(charsToTab, count)
+			p.column += charsToAdvance
+			if !p.partiallyConsumedTab {
+				p.offset++
+			}
+			count -= charsToAdvance
+		} else {
+			p.partiallyConsumedTab = false
+			p.column += charsToTab
+			p.offset++
+			count--
+		}
+	}
+}
+
+func (p *blockParser) addLine() {
+
+	if p.partiallyConsumedTab {
+		p.offset++
+		charsToTab := 4 - p.column%4
+		p.tip.lines = append(p.tip.lines, strings.Repeat(" ", charsToTab)+p.line[p.offset:])
+		return
+	}
+
+	p.tip.lines = append(p.tip.lines, p.line[p.offset:])
+}
+
+func (p *blockParser) addChild(kind blockKind) *block {
+
+	for !canContain(p.tip.kind, kind) {
+		p.finalize(p.tip)
+	}
+
+	child := &block{kind: kind, parent: p.tip, open: true}
+	p.tip.children = append(p.tip.children, child)
+	p.tip = child
+
+	return child
+}
+
+func (p *blockParser) closeUnmatchedBlocks() {
+
+	if p.allClosed {
+		return
+	}
+
+	for p.oldTip != p.lastMatchedContainer {
+		parent := p.oldTip.parent
+		p.finalize(p.oldTip)
+		p.oldTip = parent
+	}
+
+	p.allClosed = true
+}
+
+func (p *blockParser) finalize(b *block) {
+
+	b.open = false
+
+	switch b.kind {
+	case paragraphBlock:
+		b.content = p.removeReferenceDefinitions(strings.Join(b.lines, "\n"))
+		b.lines = nil
+		// a paragraph of only link reference definitions is not a paragraph
+		if b.content == "" {
+			siblings := b.parent.children
+			b.parent.children = siblings[:len(siblings)-1]
+		}
+
+	case codeBlock:
+		if b.fenced {
+			b.info = unescapeString(strings.TrimSpace(b.lines[0]))
+			b.code = strings.Join(b.lines[1:], "\n")
+		} else {
+			lines := b.lines
+			for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+				lines = lines[:len(lines)-1]
+			}
+			b.code = strings.Join(lines, "\n")
+		}
+		b.lines = nil
+	}
+
+	p.tip = b.parent
+}
+
+// removeReferenceDefinitions stores the link reference definitions at the start of a
+// paragraph's content and returns what is left
+func (p *blockParser) removeReferenceDefinitions(content string) string {
+
+	for strings.HasPrefix(content, "[") {
+		n := parseReferenceDefinition(content, p.refs)
+		if n == 0 {
+			break
+		}
+		content = content[n:]
+	}
+
+	return strings.TrimSpace(content)
+}
+
+func isSpaceOrTab(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+func atoi(digits string) int {
+
+	n := 0
+	for _, d := range digits {
+		n = n*10 + int(d-'0')
+	}
+
+	return n
+}
@@ -0,0 +1,849 @@
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type inlineKind int
+
+const (
+	textInline inlineKind = iota
+	codeInline
+	softBreakInline
+	hardBreakInline
+	emphasisInline
+	strongInline
+	strikeInline
+	underlineInline
+	linkInline
+	imageInline
+)
+
+type inlineNode struct {
+	kind        inlineKind
+	text        string
+	destination string
+	title       string
+	children    []*inlineNode
+	prev, next  *inlineNode
+}
+
+type linkReference struct {
+	destination string
+	title       string
+}
+
+// delimiter is a run of *, _ or ~ (or an <u> tag) that may open or close emphasis
+type delimiter struct {
+	node           *inlineNode
+	char           byte
+	length         int
+	originalLength int
+	canOpen        bool
+	canClose       bool
+	prev, next     *delimiter
+}
+
+// bracket is a [ or ![ that may start a link or an image
+type bracket struct {
+	node          *inlineNode
+	image         bool
+	active        bool
+	position      int
+	prevDelimiter *delimiter
+	prev          *bracket
+}
+
+// inlineParser parses the content of a paragraph, heading or table cell. nodes are kept in a
+// linked list so emphasis and links can wrap the nodes between their delimiters, following the
+// delimiter run algorithm of the CommonMark spec
+type inlineParser struct {
+	src  string
+	pos  int
+	refs map[string]linkReference
+
+	head, tail *inlineNode
+	delimiters *delimiter
+	brackets   *bracket
+
+	// backtick run lengths with no closing run after some position, so they are not searched for again
+	missingBackticks map[int]bool
+}
+
+func parseInlines(src string, refs map[string]linkReference) []*inlineNode {
+
+	p := &inlineParser{src: strings.TrimRight(src, " \t\n"), refs: refs}
+
+	for p.pos < len(p.src) {
+		p.parseInline()
+	}
+
+	p.processEmphasis(nil)
+
+	return p.detach(p.head, nil)
+}
+
+const inlineSpecialChars = "\n\\`*_~[]!<&"
+
+func (p *inlineParser) parseInline() {
+
+	switch c := p.src[p.pos]; c {
+	case '\n':
+		p.newline()
+	case '\\':
+		p.backslash()
+	case '`':
+		p.backticks()
+	case '*', '_', '~':
+		p.delimiterRun(c)
+	case '[':
+		p.pos++
+		p.pushBracket(p.appendText("["), false)
+	case '!':
+		p.pos++
+		if p.pos < len(p.src) && p.src[p.pos] == '[' {
+			p.pos++
+			p.pushBracket(p.appendText("!["), true)
+		} else {
+			p.appendText("!")
+		}
+	case ']':
+		p.closeBracket()
+	case '<':
+		p.angleBracket()
+	case '&':
+		p.entity()
+	default:
+		end := strings.IndexAny(p.src[p.pos:], inlineSpecialChars)
+		if end == -1 {
+			end = len(p.src) - p.pos
+		}
+		p.appendText(p.src[p.pos : p.pos+end])
+		p.pos += end
+	}
+}
+
+func (p *inlineParser) append(node *inlineNode) *inlineNode {
+
+	node.prev = p.tail
+	if p.tail != nil {
+		p.tail.next = node
+	} else {
+		p.head = node
+	}
+	p.tail = node
+
+	return node
+}
+
+func (p *inlineParser) appendText(text string) *inlineNode {
+	return p.append(&inlineNode{kind: textInline, text: text})
+}
+
+func (p *inlineParser) remove(node *inlineNode) {
+
+	if node.prev != nil {
+		node.prev.next = node.next
+	} else {
+		p.head = node.next
+	}
+
+	if node.next != nil {
+		node.next.prev = node.prev
+	} else {
+		p.tail = node.prev
+	}
+
+	node.prev, node.next = nil, nil
+}
+
+// detach unlinks the nodes from first up to but not including end and returns them
+func (p *inlineParser) detach(first *inlineNode, end *inlineNode) []*inlineNode {
+
+	var nodes []*inlineNode
+
+	for node := first; node != end; {
+		next := node.next
+		node.prev, node.next = nil, nil
+		nodes = append(nodes, node)
+		node = next
+	}
+
+	return nodes
+}
+
+// newline is a soft break, or a hard break if the line ended with two or more spaces
+func (p *inlineParser) newline() {
+
+	p.pos++
+
+	kind := softBreakInline
+
+	if p.tail != nil && p.tail.kind == textInline {
+		trimmed := strings.TrimRight(p.tail.text, " ")
+		if len(p.tail.text)-len(trimmed) >= 2 {
+			kind = hardBreakInline
+		}
+		p.tail.text = trimmed
+	}
+
+	p.append(&inlineNode{kind: kind})
+	p.skipSpaces()
+}
+
+func (p *inlineParser) backslash() {
+
+	p.pos++
+
+	if p.pos < len(p.src) && p.src[p.pos] == '\n' {
+		p.pos++
+		p.append(&inlineNode{kind: hardBreakInline})
+		p.skipSpaces()
+		return
+	}
+
+	if p.pos < len(p.src) && isASCIIPunctuation(p.src[p.pos]) {
+		p.appendText(p.src[p.pos : p.pos+1])
+		p.pos++
+		return
+	}
+
+	p.appendText("\\")
+}
+
+func (p *inlineParser) backticks() {
+
+	start := p.pos
+	for p.pos < len(p.src) && p.src[p.pos] == '`' {
+		p.pos++
+	}
+	length := p.pos - start
+
+	if !p.missingBackticks[length] {
+		for i := p.pos; i < len(p.src); {
+			if p.src[i] != '`' {
+				i++
+				continue
+			}
+
+			runStart := i
+			for i < len(p.src) && p.src[i] == '`' {
+				i++
+			}
+
+			if i-runStart == length {
+				p.append(&inlineNode{kind: codeInline, text: codeSpanContent(p.src[p.pos:runStart])})
+				p.pos = i
+				return
+			}
+		}
+
+		if p.missingBackticks == nil {
+			p.missingBackticks = map[int]bool{}
+		}
+		p.missingBackticks[length] = true
+	}
+
+	p.appendText(p.src[start:p.pos])
+}
+
+// codeSpanContent turns line endings into spaces and strips one space from both sides,
+// which lets code spans start or end with a backtick
+func codeSpanContent(content string) string {
+
+	content = strings.ReplaceAll(content, "\n", " ")
+
+	if len(content) >= 2 && content[0] == ' ' && content[len(content)-1] == ' ' && strings.Trim(content, " ") != "" {
+		return content[1 : len(content)-1]
+	}
+
+	return content
+}
+
+func (p *inlineParser) delimiterRun(c byte) {
+
+	start := p.pos
+	for p.pos < len(p.src) && p.src[p.pos] == c {
+		p.pos++
+	}
+	length := p.pos - start
+
+	before, after := '\n', '\n'
+	if start > 0 {
+		before, _ = utf8.DecodeLastRuneInString(p.src[:start])
+	}
+	if p.pos < len(p.src) {
+		after, _ = utf8.DecodeRuneInString(p.src[p.pos:])
+	}
+
+	leftFlanking := !unicode.IsSpace(after) && (!isPunctuation(after) || unicode.IsSpace(before) || isPunctuation(before))
+	rightFlanking := !unicode.IsSpace(before) && (!isPunctuation(before) || unicode.IsSpace(after) || isPunctuation(after))
+
+	canOpen, canClose := leftFlanking, rightFlanking
+	// an underscore inside a word does not open or close emphasis, snake_case_names stay as they are
+	if c == '_' {
+		canOpen = leftFlanking && (!rightFlanking || isPunctuation(before))
+		canClose = rightFlanking && (!leftFlanking || isPunctuation(after))
+	}
+
+	node := p.appendText(p.src[start:p.pos])
+
+	// gfm strikethrough is one or two tildes
+	if c == '~' && length > 2 {
+		return
+	}
+
+	if canOpen || canClose {
+		p.pushDelimiter(&delimiter{node: node, char: c, length: length, originalLength: length, canOpen: canOpen, canClose: canClose})
+	}
+}
+
+func (p *inlineParser) pushDelimiter(d *delimiter) {
+
+	d.prev = p.delimiters
+	if p.delimiters != nil {
+		p.delimiters.next = d
+	}
+	p.delimiters = d
+}
+
+func (p *inlineParser) removeDelimiter(d *delimiter) {
+
+	if d.prev != nil {
+		d.prev.next = d.next
+	}
+
+	if d.next != nil {
+		d.next.prev = d.prev
+	} else {
+		p.delimiters = d.prev
+	}
+}
+
+type openersBottomKey struct {
+	char     byte
+	canOpen  bool
+	lengthBy int
+}
+
+// processEmphasis matches the delimiters above stackBottom into emphasis, strong emphasis,
+// strikethrough and underline, wrapping the nodes between every matched pair
+func (p *inlineParser) processEmphasis(stackBottom *delimiter) {
+
+	if p.delimiters == stackBottom {
+		return
+	}
+
+	openersBottom := map[openersBottomKey]*delimiter{}
+
+	closer := p.delimiters
+	for closer.prev != stackBottom {
+		closer = closer.prev
+	}
+
+	for closer != nil {
+
+		if !closer.canClose {
+			closer = closer.next
+			continue
+		}
+
+		key := openersBottomKey{char: closer.char, canOpen: closer.canOpen, lengthBy: closer.originalLength % 3}
+		if closer.char == '~' || closer.char == 'u' {
+			key = openersBottomKey{char: closer.char, lengthBy: closer.length}
+		}
+
+		var opener *delimiter
+		for candidate := closer.prev; candidate != nil && candidate != stackBottom && candidate != openersBottom[key]; candidate = candidate.prev {
+			if candidate.char == closer.char && candidate.canOpen && delimitersMatch(candidate, closer) {
+				opener = candidate
+				break
+			}
+		}
+
+		if opener == nil {
+			openersBottom[key] = closer.prev
+			next := closer.next
+			if !closer.canOpen {
+				p.removeDelimiter(closer)
+			}
+			closer = next
+			continue
+		}
+
+		var kind inlineKind
+		used := 1
+
+		switch closer.char {
+		case '*', '_':
+			kind = emphasisInline
+			if opener.length >= 2 && closer.length >= 2 {
+				kind = strongInline
+				used = 2
+			}
+		case '~':
+			kind = strikeInline
+			used = closer.length
+		case 'u':
+			kind = underlineInline
+		}
+
+		opener.length -= used
+		closer.length -= used
+
+		if closer.char == 'u' {
+			opener.node.text, closer.node.text = "", ""
+		} else {
+			opener.node.text = opener.node.text[:len(opener.node.text)-used]
+			closer.node.text = closer.node.text[used:]
+		}
+
+		wrapper := &inlineNode{kind: kind, children: p.detach(opener.node.next, closer.node)}
+		opener.node.next, wrapper.prev = wrapper, opener.node
+		wrapper.next, closer.node.prev = closer.node, wrapper
+
+		// delimiters between the pair can no longer match
+		opener.next, closer.prev = closer, opener
+
+		if opener.length == 0 {
+			p.remove(opener.node)
+			p.removeDelimiter(opener)
+		}
+
+		if closer.length == 0 {
+			next := closer.next
+			p.remove(closer.node)
+			p.removeDelimiter(closer)
+			closer = next
+		}
+	}
+
+	for p.delimiters != nil && p.delimiters != stackBottom {
+		p.removeDelimiter(p.delimiters)
+	}
+}
+
+// delimitersMatch applies the rule of 3 to * and _, strikethrough and underline need runs of the same length
+func delimitersMatch(opener *delimiter, closer *delimiter) bool {
+
+	if closer.char == '~' || closer.char == 'u' {
+		return opener.length == closer.length
+	}
+
+	if (opener.canClose || closer.canOpen) && (opener.originalLength+closer.originalLength)%3 == 0 {
+		return opener.originalLength%3 == 0 && closer.originalLength%3 == 0
+	}
+
+	return true
+}
+
+func (p *inlineParser) pushBracket(node *inlineNode, image bool) {
+	p.brackets = &bracket{node: node, image: image, active: true, position: p.pos, prevDelimiter: p.delimiters, prev: p.brackets}
+}
+
+// closeBracket makes a link or image of the nodes since the last bracket when it is followed by a
+// destination or matches a link reference, and leaves a literal ] otherwise
+func (p *inlineParser) closeBracket() {
+
+	p.pos++
+
+	opener := p.brackets
+	if opener == nil {
+		p.appendText("]")
+		return
+	}
+
+	p.brackets = opener.prev
+
+	if !opener.active {
+		p.appendText("]")
+		return
+	}
+
+	labelEnd := p.pos - 1
+
+	destination, title, matched := p.inlineLinkDestination()
+
+	if !matched {
+		beforeLabel := p.pos
+
+		label := ""
+		if n := parseLinkLabel(p.src[p.pos:]); n > 2 {
+			label = p.src[p.pos+1 : p.pos+n-1]
+			p.pos += n
+		} else {
+			// collapsed [] and shortcut references use the link text as the label
+			label = p.src[opener.position:labelEnd]
+			if n == 2 {
+				p.pos += n
+			}
+		}
+
+		if ref, ok := p.refs[normalizeLabel(label)]; ok {
+			destination, title, matched = ref.destination, ref.title, true
+		} else {
+			p.pos = beforeLabel
+		}
+	}
+
+	if !matched {
+		p.appendText("]")
+		return
+	}
+
+	kind := linkInline
+	if opener.image {
+		kind = imageInline
+	}
+
+	p.processEmphasis(opener.prevDelimiter)
+
+	node := &inlineNode{kind: kind, destination: destination, title: title, children: p.detach(opener.node.next, nil)}
+
+	p.tail = opener.node
+	p.tail.next = nil
+	p.remove(opener.node)
+	p.append(node)
+
+	// links cannot contain other links
+	if !opener.image {
+		for b := p.brackets; b != nil; b = b.prev {
+			if !b.image {
+				b.active = false
+			}
+		}
+	}
+}
+
+// inlineLinkDestination parses (destination "title") after the ] of a link
+func (p *inlineParser) inlineLinkDestination() (string, string, bool) {
+
+	if p.pos >= len(p.src) || p.src[p.pos] != '(' {
+		return "", "", false
+	}
+
+	start := p.pos
+	p.pos++
+	p.skipWhitespace()
+
+	destination, ok := p.parseLinkDestination()
+	if !ok {
+		p.pos = start
+		return "", "", false
+	}
+
+	title := ""
+	beforeTitle := p.pos
+	p.skipWhitespace()
+
+	// the title has to be separated from the destination by whitespace
+	if p.pos > beforeTitle {
+		if parsed, ok := p.parseLinkTitle(); ok {
+			title = parsed
+		}
+	}
+
+	p.skipWhitespace()
+
+	if p.pos >= len(p.src) || p.src[p.pos] != ')' {
+		p.pos = start
+		return "", "", false
+	}
+	p.pos++
+
+	return destination, title, true
+}
+
+func (p *inlineParser) parseLinkDestination() (string, bool) {
+
+	if p.pos < len(p.src) && p.src[p.pos] == '<' {
+		for i := p.pos + 1; i < len(p.src); i++ {
+			switch p.src[i] {
+			case '\\':
+				if i+1 < len(p.src) && isASCIIPunctuation(p.src[i+1]) {
+					i++
+				}
+			case '>':
+				destination := unescapeString(p.src[p.pos+1 : i])
+				p.pos = i + 1
+				return destination, true
+			case '<', '\n':
+				return "", false
+			}
+		}
+		return "", false
+	}
+
+	i := p.pos
+	openParens := 0
+
+loop:
+	for i < len(p.src) {
+		switch c := p.src[i]; {
+		case c == '\\' && i+1 < len(p.src) && isASCIIPunctuation(p.src[i+1]):
+			i += 2
+			continue
+		case c == '(':
+			openParens++
+			if openParens > 32 {
+				return "", false
+			}
+		case c == ')':
+			if openParens == 0 {
+				break loop
+			}
+			openParens--
+		case c <= ' ' || c == 0x7f:
+			break loop
+		}
+		i++
+	}
+
+	if openParens != 0 || (i == p.pos && (i >= len(p.src) || p.src[i] != ')')) {
+		return "", false
+	}
+
+	destination := unescapeString(p.src[p.pos:i])
+	p.pos = i
+
+	return destination, true
+}
+
+func (p *inlineParser) parseLinkTitle() (string, bool) {
+
+	if p.pos >= len(p.src) {
+		return "", false
+	}
+
+	closing := p.src[p.pos]
+	switch closing {
+	case '"', '\'':
+	case '(':
+		closing = ')'
+	default:
+		return "", false
+	}
+
+	for i := p.pos + 1; i < len(p.src); i++ {
+		switch c := p.src[i]; {
+		case c == '\\' && i+1 < len(p.src) && isASCIIPunctuation(p.src[i+1]):
+			i++
+		case c == closing:
+			title := unescapeString(p.src[p.pos+1 : i])
+			p.pos = i + 1
+			return title, true
+		case c == '(' && closing == ')':
+			return "", false
+		}
+	}
+
+	return "", false
+}
+
+// parseLinkLabel returns the length of the [label] at the start of s including the brackets, 0 if there is none
+func parseLinkLabel(s string) int {
+
+	if !strings.HasPrefix(s, "[") {
+		return 0
+	}
+
+	for i := 1; i < len(s) && i <= 1000; i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			return 0
+		case ']':
+			return i + 1
+		}
+	}
+
+	return 0
+}
+
+// normalizeLabel makes labels that only differ in case and whitespace match
+func normalizeLabel(label string) string {
+	return strings.ToLower(strings.ToUpper(strings.Join(strings.Fields(label), " ")))
+}
+
+var (
+	uriAutolinkPattern   = regexp.MustCompile(`^<([a-zA-Z][a-zA-Z0-9.+-]{1,31}:[^<>\x00-\x20]*)>`)
+	emailAutolinkPattern = regexp.MustCompile("^<([a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*)>")
+	lineBreakTagPattern  = regexp.MustCompile(`^<br\s*/?>`)
+	entityPattern        = regexp.MustCompile(`^&(?:#[xX][0-9a-fA-F]{1,6}|#[0-9]{1,7}|[a-zA-Z][a-zA-Z0-9]{1,31});`)
+)
+
+// angleBracket parses autolinks, and the <u> and <br> tags which are the only html kept, other
+// html is written as text
+func (p *inlineParser) angleBracket() {
+
+	rest := p.src[p.pos:]
+
+	if match := uriAutolinkPattern.FindStringSubmatch(rest); match != nil {
+		p.pos += len(match[0])
+		p.append(&inlineNode{kind: linkInline, destination: match[1], children: []*inlineNode{{kind: textInline, text: match[1]}}})
+		return
+	}
+
+	if match := emailAutolinkPattern.FindStringSubmatch(rest); match != nil {
+		p.pos += len(match[0])
+		p.append(&inlineNode{kind: linkInline, destination: "mailto:" + match[1], children: []*inlineNode{{kind: textInline, text: match[1]}}})
+		return
+	}
+
+	if match := lineBreakTagPattern.FindString(rest); match != "" {
+		p.pos += len(match)
+		p.append(&inlineNode{kind: hardBreakInline})
+		return
+	}
+
+	if strings.HasPrefix(rest, "<u>") || strings.HasPrefix(rest, "</u>") {
+		opening := strings.HasPrefix(rest, "<u>")
+		tag := rest[:strings.IndexByte(rest, '>')+1]
+		p.pos += len(tag)
+		p.pushDelimiter(&delimiter{node: p.appendText(tag), char: 'u', length: 1, originalLength: 1, canOpen: opening, canClose: !opening})
+		return
+	}
+
+	p.pos++
+	p.appendText("<")
+}
+
+func (p *inlineParser) entity() {
+
+	match := entityPattern.FindString(p.src[p.pos:])
+	if match == "" {
+		p.pos++
+		p.appendText("&")
+		return
+	}
+
+	p.pos += len(match)
+	p.appendText(html.UnescapeString(match))
+}
+
+func (p *inlineParser) skipSpaces() {
+	for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// skipWhitespace skips spaces, tabs and at most one line ending
+func (p *inlineParser) skipWhitespace() {
+
+	newline := false
+
+	for p.pos < len(p.src) {
+		switch p.src[p.pos] {
+		case ' ', '\t':
+		case '\n':
+			if newline {
+				return
+			}
+			newline = true
+		default:
+			return
+		}
+		p.pos++
+	}
+}
+
+// parseReferenceDefinition stores the [label]: destination "title" at the start of s and returns its
+// length, 0 if s does not start with one
+func parseReferenceDefinition(s string, refs map[string]linkReference) int {
+
+	n := parseLinkLabel(s)
+	if n == 0 || n >= len(s) || s[n] != ':' {
+		return 0
+	}
+
+	label := normalizeLabel(s[1 : n-1])
+	if label == "" {
+		return 0
+	}
+
+	p := &inlineParser{src: s, pos: n + 1}
+	p.skipWhitespace()
+
+	if p.pos >= len(s) || s[p.pos] == '\n' {
+		return 0
+	}
+
+	destination, ok := p.parseLinkDestination()
+	if !ok {
+		return 0
+	}
+
+	beforeTitle := p.pos
+	p.skipWhitespace()
+
+	title, titleMatched := "", false
+	if p.pos > beforeTitle {
+		title, titleMatched = p.parseLinkTitle()
+	}
+	if !titleMatched {
+		p.pos = beforeTitle
+	}
+
+	// nothing but spaces may follow on the line, a title that is followed by more text is not a title
+	if !p.atLineEnd() {
+		if !titleMatched {
+			return 0
+		}
+		title = ""
+		p.pos = beforeTitle
+		if !p.atLineEnd() {
+			return 0
+		}
+	}
+
+	if p.pos < len(s) {
+		p.pos++
+	}
+
+	// the first definition of a label wins
+	if _, exists := refs[label]; !exists {
+		refs[label] = linkReference{destination: destination, title: title}
+	}
+
+	return p.pos
+}
+
+// atLineEnd skips spaces and tabs and reports whether the line ends there
+func (p *inlineParser) atLineEnd() bool {
+
+	for p.pos < len(p.src) && isSpaceOrTab(p.src[p.pos]) {
+		p.pos++
+	}
+
+	return p.pos >= len(p.src) || p.src[p.pos] == '\n'
+}
+
+var unescapePattern = regexp.MustCompile(`\\[!-/:-@\[-` + "`" + `{-~]|&(?:#[xX][0-9a-fA-F]{1,6}|#[0-9]{1,7}|[a-zA-Z][a-zA-Z0-9]{1,31});`)
+
+// unescapeString resolves backslash escapes and entities in link destinations, titles and info strings
+func unescapeString(s string) string {
+
+	if !strings.ContainsAny(s, "\\&") {
+		return s
+	}
+
+	return unescapePattern.ReplaceAllStringFunc(s, func(match string) string {
+		if match[0] == '\\' {
+			return match[1:]
+		}
+		return html.UnescapeString(match)
+	})
+}
+
+func isASCIIPunctuation(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+func isPunctuation(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
@@ -0,0 +1,418 @@
+// Package markdown parses CommonMark, with the gfm tables, task lists and strikethrough
+// extensions, into the blog content document model. raw html is not kept, except for <u>
+// and <br> which the document model has an equivalent for, other tags are read as text.
+// the document is not validated here, links and images may still have urls that are not allowed
+package markdown
+
+import (
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/dhruv15803/echo-blog-app/document"
+)
+
+// Parse converts markdown source to a document
+func Parse(source string) document.Node {
+
+	p := newBlockParser()
+	root := p.parse(source)
+
+	c := converter{refs: p.refs}
+
+	return document.Node{Type: document.DocNode, Content: c.blocks(root.children, 1)}
+}
+
+type converter struct {
+	refs map[string]linkReference
+}
+
+var taskMarkerPattern = regexp.MustCompile(`^\[([ xX])\](?:[ \t]+|$)`)
+
+func (c *converter) blocks(blocks []*block, depth int) []document.Node {
+
+	// nodes nested deeper than a document may be are not converted, validation rejects the document anyway
+	if depth > document.MaxDepth {
+		return nil
+	}
+
+	var nodes []document.Node
+
+	for _, b := range blocks {
+		nodes = append(nodes, c.block(b, depth)...)
+	}
+
+	return nodes
+}
+
+func (c *converter) block(b *block, depth int) []document.Node {
+
+	switch b.kind {
+	case paragraphBlock:
+		return c.paragraph(b.content)
+
+	case headingBlock:
+		return []document.Node{{
+			Type:    document.HeadingNode,
+			Attrs:   map[string]any{"level": float64(b.level)},
+			Content: c.inline(b.content),
+		}}
+
+	case blockquoteBlock:
+		content := c.blocks(b.children, depth+1)
+		if len(content) == 0 {
+			return nil
+		}
+		return []document.Node{{Type: document.BlockquoteNode, Content: content}}
+
+	case listBlock:
+		return []document.Node{c.list(b, depth)}
+
+	case codeBlock:
+		code := document.Node{Type: document.CodeBlockNode}
+		if language, _, _ := strings.Cut(b.info, " "); language != "" {
+			code.Attrs = map[string]any{"language": language}
+		}
+		if b.code != "" {
+			code.Content = []document.Node{{Type: document.TextNode, Text: b.code}}
+		}
+		return []document.Node{code}
+
+	case thematicBreakBlock:
+		return []document.Node{{Type: document.HorizontalRuleNode}}
+
+	case tableBlock:
+		return []document.Node{c.table(b)}
+	}
+
+	return nil
+}
+
+// list converts a bullet list whose every item starts with [ ] or [x] to a task list
+func (c *converter) list(b *block, depth int) document.Node {
+
+	tasks := !b.list.ordered
+	for _, item := range b.children {
+		if len(item.children) == 0 || item.children[0].kind != paragraphBlock || !taskMarkerPattern.MatchString(item.children[0].content) {
+			tasks = false
+			break
+		}
+	}
+
+	list := document.Node{Type: document.BulletListNode}
+	itemType := document.ListItemNode
+
+	switch {
+	case tasks:
+		list.Type = document.TaskListNode
+		itemType = document.TaskItemNode
+	case b.list.ordered:
+		list.Type = document.OrderedListNode
+		if b.list.start != 1 {
+			list.Attrs = map[string]any{"start": float64(b.list.start)}
+		}
+	}
+
+	for _, item := range b.children {
+
+		node := document.Node{Type: itemType}
+
+		if tasks {
+			paragraph := item.children[0]
+			marker := taskMarkerPattern.FindStringSubmatch(paragraph.content)
+			paragraph.content = strings.TrimSpace(paragraph.content[len(marker[0]):])
+			node.Attrs = map[string]any{"checked": marker[1] != " "}
+		}
+
+		node.Content = c.blocks(item.children, depth+2)
+
+		// items need a block, an empty item has an empty paragraph
+		if len(node.Content) == 0 {
+			node.Content = []document.Node{{Type: document.ParagraphNode}}
+		}
+
+		list.Content = append(list.Content, node)
+	}
+
+	return list
+}
+
+// paragraph converts inline content to a paragraph. images are blocks in the document model, so
+// a paragraph is split around its images, and a paragraph of only an autolink to an embed host is an embed
+func (c *converter) paragraph(content string) []document.Node {
+
+	inlines := parseInlines(content, c.refs)
+
+	if len(inlines) == 1 && isEmbedLink(inlines[0]) {
+		return []document.Node{{Type: document.EmbedNode, Attrs: map[string]any{"url": inlines[0].destination}}}
+	}
+
+	var nodes []document.Node
+	var current []document.Node
+
+	flush := func() {
+		if paragraph := trimInline(mergeText(current)); len(paragraph) > 0 {
+			nodes = append(nodes, document.Node{Type: document.ParagraphNode, Content: paragraph})
+		}
+		current = nil
+	}
+
+	for _, node := range flattenInlines(inlines, nil, true) {
+		if node.Type == document.ImageNode {
+			flush()
+			nodes = append(nodes, node)
+			continue
+		}
+		current = append(current, node)
+	}
+	flush()
+
+	return nodes
+}
+
+// inline converts the content of headings and table cells, images are written as their alt text
+func (c *converter) inline(content string) []document.Node {
+	return trimInline(mergeText(flattenInlines(parseInlines(content, c.refs), nil, false)))
+}
+
+func isEmbedLink(node *inlineNode) bool {
+
+	if node.kind != linkInline || len(node.children) != 1 || node.children[0].text != node.destination {
+		return false
+	}
+
+	scheme, rest, _ := strings.Cut(node.destination, "://")
+	host, _, _ := strings.Cut(rest, "/")
+
+	return scheme == "https" && slices.Contains(document.EmbedHosts, strings.ToLower(host))
+}
+
+// flattenInlines turns the inline tree into text nodes with the marks of every node above them
+func flattenInlines(inlines []*inlineNode, marks []document.Mark, images bool) []document.Node {
+
+	var nodes []document.Node
+
+	for _, inline := range inlines {
+		switch inline.kind {
+		case textInline:
+			if inline.text != "" {
+				nodes = append(nodes, document.Node{Type: document.TextNode, Text: inline.text, Marks: marks})
+			}
+		case codeInline:
+			if inline.text != "" {
+				nodes = append(nodes, document.Node{Type: document.TextNode, Text: inline.text, Marks: withMark(marks, document.Mark{Type: document.CodeMark})})
+			}
+		case softBreakInline:
+			nodes = append(nodes, document.Node{Type: document.TextNode, Text: " ", Marks: marks})
+		case hardBreakInline:
+			nodes = append(nodes, document.Node{Type: document.HardBreakNode})
+		case emphasisInline:
+			nodes = append(nodes, flattenInlines(inline.children, withMark(marks, document.Mark{Type: document.ItalicMark}), images)...)
+		case strongInline:
+			nodes = append(nodes, flattenInlines(inline.children, withMark(marks, document.Mark{Type: document.BoldMark}), images)...)
+		case strikeInline:
+			nodes = append(nodes, flattenInlines(inline.children, withMark(marks, document.Mark{Type: document.StrikeMark}), images)...)
+		case underlineInline:
+			nodes = append(nodes, flattenInlines(inline.children, withMark(marks, document.Mark{Type: document.UnderlineMark}), images)...)
+		case linkInline:
+			link := document.Mark{Type: document.LinkMark, Attrs: map[string]any{"href": inline.destination}}
+			if inline.title != "" {
+				link.Attrs["title"] = inline.title
+			}
+			nodes = append(nodes, flattenInlines(inline.children, withMark(marks, link), images)...)
+		case imageInline:
+			alt := plainText(inline.children)
+			if !images {
+				if alt != "" {
+					nodes = append(nodes, document.Node{Type: document.TextNode, Text: alt, Marks: marks})
+				}
+				continue
+			}
+			image := document.Node{Type: document.ImageNode, Attrs: map[string]any{"src": inline.destination}}
+			if alt != "" {
+				image.Attrs["alt"] = alt
+			}
+			if inline.title != "" {
+				image.Attrs["title"] = inline.title
+			}
+			nodes = append(nodes, image)
+		}
+	}
+
+	return nodes
+}
+
+// withMark returns marks with mark added, unless a mark of its type is already there
+func withMark(marks []document.Mark, mark document.Mark) []document.Mark {
+
+	for _, existing := range marks {
+		if existing.Type == mark.Type {
+			return marks
+		}
+	}
+
+	return append(slices.Clip(marks), mark)
+}
+
+// plainText is the text of inline nodes without any formatting, used for the alt text of images
+func plainText(inlines []*inlineNode) string {
+
+	var text strings.Builder
+
+	for _, inline := range inlines {
+		switch inline.kind {
+		case textInline, codeInline:
+			text.WriteString(inline.text)
+		case softBreakInline, hardBreakInline:
+			text.WriteString(" ")
+		default:
+			text.WriteString(plainText(inline.children))
+		}
+	}
+
+	return text.String()
+}
+
+// mergeText joins neighbouring text nodes that have the same marks
+func mergeText(nodes []document.Node) []document.Node {
+
+	var merged []document.Node
+
+	for _, node := range nodes {
+		if last := len(merged) - 1; last >= 0 && node.Type == document.TextNode && merged[last].Type == document.TextNode && sameMarks(merged[last].Marks, node.Marks) {
+			merged[last].Text += node.Text
+			continue
+		}
+		merged = append(merged, node)
+	}
+
+	return merged
+}
+
+func sameMarks(a []document.Mark, b []document.Mark) bool {
+	return slices.EqualFunc(a, b, func(x document.Mark, y document.Mark) bool {
+		return x.Type == y.Type && maps.Equal(x.Attrs, y.Attrs)
+	})
+}
+
+// trimInline removes whitespace and hard breaks from both ends of inline content
+func trimInline(nodes []document.Node) []document.Node {
+
+	for len(nodes) > 0 {
+		first := &nodes[0]
+		if first.Type == document.TextNode {
+			first.Text = strings.TrimLeft(first.Text, " \t")
+			if first.Text != "" {
+				break
+			}
+		}
+		nodes = nodes[1:]
+	}
+
+	for len(nodes) > 0 {
+		last := &nodes[len(nodes)-1]
+		if last.Type == document.TextNode {
+			last.Text = strings.TrimRight(last.Text, " \t")
+			if last.Text != "" {
+				break
+			}
+		}
+		nodes = nodes[:len(nodes)-1]
+	}
+
+	return nodes
+}
+
+// table converts a gfm table, the first line is the header and the second the delimiter row.
+// rows with fewer cells than the header are filled up, extra cells are dropped
+func (c *converter) table(b *block) document.Node {
+
+	alignments, _ := tableAlignments(b.lines[1])
+	rows := append([]string{b.lines[0]}, b.lines[2:]...)
+
+	table := document.Node{Type: document.TableNode}
+
+	for i, line := range rows {
+
+		cells := splitTableRow(line)
+		row := document.Node{Type: document.TableRowNode}
+
+		for j, alignment := range alignments {
+
+			cell := document.Node{Type: document.TableCellNode}
+			if i == 0 {
+				cell.Type = document.TableHeaderNode
+			}
+			if alignment != "" {
+				cell.Attrs = map[string]any{"align": alignment}
+			}
+			if j < len(cells) {
+				cell.Content = c.inline(cells[j])
+			}
+
+			row.Content = append(row.Content, cell)
+		}
+
+		table.Content = append(table.Content, row)
+	}
+
+	return table
+}
+
+var tableDelimiterPattern = regexp.MustCompile(`^:?-+:?$`)
+
+// tableAlignments parses a delimiter row like | :--- | :---: | ---: | ---, the alignment of a column is empty if it has none
+func tableAlignments(line string) ([]string, bool) {
+
+	cells := splitTableRow(line)
+	alignments := make([]string, len(cells))
+
+	for i, cell := range cells {
+
+		if !tableDelimiterPattern.MatchString(cell) {
+			return nil, false
+		}
+
+		left, right := strings.HasPrefix(cell, ":"), strings.HasSuffix(cell, ":")
+		switch {
+		case left && right:
+			alignments[i] = "center"
+		case left:
+			alignments[i] = "left"
+		case right:
+			alignments[i] = "right"
+		}
+	}
+
+	return alignments, true
+}
+
+// splitTableRow splits a row at pipes that are not escaped, the pipes at the start and end of the row are optional
+func splitTableRow(line string) []string {
+
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, "\\|") {
+		line = line[:len(line)-1]
+	}
+
+	var cells []string
+	var cell strings.Builder
+
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			// an escaped pipe is part of the cell, also inside code spans
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+
+	return append(cells, strings.TrimSpace(cell.String()))
+}
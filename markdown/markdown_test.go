@@ -0,0 +1,195 @@
+package markdown
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dhruv15803/echo-blog-app/document"
+	"github.com/dhruv15803/echo-blog-app/render"
+)
+
+func marshal(t *testing.T, doc document.Node) string {
+
+	t.Helper()
+
+	var b strings.Builder
+
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+
+	if err := encoder.Encode(doc); err != nil {
+		t.Fatal(err)
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// like the examples of the CommonMark spec, every case is markdown and the document it parses to
+var parseTests = []struct {
+	name     string
+	markdown string
+	want     string
+}{
+	// headings
+	{
+		name:     "atx headings",
+		markdown: "# One\n## Two ##\n###### Six\n####### seven",
+		want:     `{"type":"doc","content":[{"type":"heading","attrs":{"level":1},"content":[{"type":"text","text":"One"}]},{"type":"heading","attrs":{"level":2},"content":[{"type":"text","text":"Two"}]},{"type":"heading","attrs":{"level":6},"content":[{"type":"text","text":"Six"}]},{"type":"paragraph","content":[{"type":"text","text":"####### seven"}]}]}`,
+	},
+	{
+		name:     "setext headings",
+		markdown: "Setext\n===\n\nTwo\n---",
+		want:     `{"type":"doc","content":[{"type":"heading","attrs":{"level":1},"content":[{"type":"text","text":"Setext"}]},{"type":"heading","attrs":{"level":2},"content":[{"type":"text","text":"Two"}]}]}`,
+	},
+	{
+		name:     "no space after hashes",
+		markdown: "#hashtag",
+		want:     `{"type":"doc","content":[{"type":"paragraph","content":[{"type":"text","text":"#hashtag"}]}]}`,
+	},
+	// lists
+	{
+		name:     "bullet and ordered lists",
+		markdown: "- a\n- b\n\n1. x\n2. y",
+		want:     `{"type":"doc","content":[{"type":"bullet_list","content":[{"type":"list_item","content":[{"type":"paragraph","content":[{"type":"text","text":"a"}]}]},{"type":"list_item","content":[{"type":"paragraph","content":[{"type":"text","text":"b"}]}]}]},{"type":"ordered_list","content":[{"type":"list_item","content":[{"type":"paragraph","content":[{"type":"text","text":"x"}]}]},{"type":"list_item","content":[{"type":"paragraph","content":[{"type":"text","text":"y"}]}]}]}]}`,
+	},
+	{
+		name:     "ordered list start",
+		markdown: "3) three\n4) four",
+		want:     `{"type":"doc","content":[{"type":"ordered_list","attrs":{"start":3},"content":[{"type":"list_item","content":[{"type":"paragraph","content":[{"type":"text","text":"three"}]}]},{"type":"list_item","content":[{"type":"paragraph","content":[{"type":"text","text":"four"}]}]}]}]}`,
+	},
+	// nesting
+	{
+		name:     "nested lists",
+		markdown: "- a\n  - b\n    - c\n- d",
+		want:     `{"type":"doc","content":[{"type":"bullet_list","content":[{"type":"list_item","content":[{"type":"paragraph","content":[{"type":"text","text":"a"}]},{"type":"bullet_list","content":[{"type":"list_item","content":[{"type":"paragraph","content":[{"type":"text","text":"b"}]},{"type":"bullet_list","content":[{"type":"list_item","content":[{"type":"paragraph","content":[{"type":"text","text":"c"}]}]}]}]}]}]},{"type":"list_item","content":[{"type":"paragraph","content":[{"type":"text","text":"d"}]}]}]}]}`,
+	},
+	{
+		name:     "blockquote with a list and a nested quote",
+		markdown: "> quote\n> - item\n>\n> > nested",
+		want:     `{"type":"doc","content":[{"type":"blockquote","content":[{"type":"paragraph","content":[{"type":"text","text":"quote"}]},{"type":"bullet_list","content":[{"type":"list_item","content":[{"type":"paragraph","content":[{"type":"text","text":"item"}]}]}]},{"type":"blockquote","content":[{"type":"paragraph","content":[{"type":"text","text":"nested"}]}]}]}]}`,
+	},
+	// task lists
+	{
+		name:     "task list",
+		markdown: "- [ ] todo\n- [x] done\n- [X] also",
+		want:     `{"type":"doc","content":[{"type":"task_list","content":[{"type":"task_item","attrs":{"checked":false},"content":[{"type":"paragraph","content":[{"type":"text","text":"todo"}]}]},{"type":"task_item","attrs":{"checked":true},"content":[{"type":"paragraph","content":[{"type":"text","text":"done"}]}]},{"type":"task_item","attrs":{"checked":true},"content":[{"type":"paragraph","content":[{"type":"text","text":"also"}]}]}]}]}`,
+	},
+	{
+		name:     "list with only some task items",
+		markdown: "- [ ] todo\n- not a task",
+		want:     `{"type":"doc","content":[{"type":"bullet_list","content":[{"type":"list_item","content":[{"type":"paragraph","content":[{"type":"text","text":"[ ] todo"}]}]},{"type":"list_item","content":[{"type":"paragraph","content":[{"type":"text","text":"not a task"}]}]}]}]}`,
+	},
+	// tables
+	{
+		name:     "table with alignment, escaped pipe and a short row",
+		markdown: "| a | b |\n| :-- | --: |\n| 1 | `x\\|y` |\n| 2 |",
+		want:     `{"type":"doc","content":[{"type":"table","content":[{"type":"table_row","content":[{"type":"table_header","attrs":{"align":"left"},"content":[{"type":"text","text":"a"}]},{"type":"table_header","attrs":{"align":"right"},"content":[{"type":"text","text":"b"}]}]},{"type":"table_row","content":[{"type":"table_cell","attrs":{"align":"left"},"content":[{"type":"text","text":"1"}]},{"type":"table_cell","attrs":{"align":"right"},"content":[{"type":"text","text":"x|y","marks":[{"type":"code"}]}]}]},{"type":"table_row","content":[{"type":"table_cell","attrs":{"align":"left"},"content":[{"type":"text","text":"2"}]},{"type":"table_cell","attrs":{"align":"right"}}]}]}]}`,
+	},
+	// code
+	{
+		name:     "fenced code with a language",
+		markdown: "```go\nfmt.Println(1)\n```",
+		want:     `{"type":"doc","content":[{"type":"code_block","attrs":{"language":"go"},"content":[{"type":"text","text":"fmt.Println(1)"}]}]}`,
+	},
+	{
+		name:     "tilde fence closed by a longer fence",
+		markdown: "~~~\nno lang\n~~~~",
+		want:     `{"type":"doc","content":[{"type":"code_block","content":[{"type":"text","text":"no lang"}]}]}`,
+	},
+	{
+		name:     "unclosed fence runs to the end",
+		markdown: "```\nunclosed",
+		want:     `{"type":"doc","content":[{"type":"code_block","content":[{"type":"text","text":"unclosed"}]}]}`,
+	},
+	{
+		name:     "indented code",
+		markdown: "    indented\n    code",
+		want:     `{"type":"doc","content":[{"type":"code_block","content":[{"type":"text","text":"indented\ncode"}]}]}`,
+	},
+	// links
+	{
+		name:     "inline, auto and reference links",
+		markdown: "[text](https://example.com \"title\") <https://auto.example.com> [ref][r]\n\n[r]: /relative 'Ref Title'",
+		want:     `{"type":"doc","content":[{"type":"paragraph","content":[{"type":"text","text":"text","marks":[{"type":"link","attrs":{"href":"https://example.com","title":"title"}}]},{"type":"text","text":" "},{"type":"text","text":"https://auto.example.com","marks":[{"type":"link","attrs":{"href":"https://auto.example.com"}}]},{"type":"text","text":" "},{"type":"text","text":"ref","marks":[{"type":"link","attrs":{"href":"/relative","title":"Ref Title"}}]}]}]}`,
+	},
+	{
+		name:     "image alt is plain text",
+		markdown: "![alt *text*](/img.png)",
+		want:     `{"type":"doc","content":[{"type":"image","attrs":{"alt":"alt text","src":"/img.png"}}]}`,
+	},
+	// inlines
+	{
+		name:     "emphasis, strikethrough, code, underline and br",
+		markdown: "**bold** *it* ~~del~~ `code` <u>under</u> a<br>b",
+		want:     `{"type":"doc","content":[{"type":"paragraph","content":[{"type":"text","text":"bold","marks":[{"type":"bold"}]},{"type":"text","text":" "},{"type":"text","text":"it","marks":[{"type":"italic"}]},{"type":"text","text":" "},{"type":"text","text":"del","marks":[{"type":"strike"}]},{"type":"text","text":" "},{"type":"text","text":"code","marks":[{"type":"code"}]},{"type":"text","text":" "},{"type":"text","text":"under","marks":[{"type":"underline"}]},{"type":"text","text":" a"},{"type":"hard_break"},{"type":"text","text":"b"}]}]}`,
+	},
+	{
+		name:     "hard breaks",
+		markdown: "line\\\nbreak  \nspaces",
+		want:     `{"type":"doc","content":[{"type":"paragraph","content":[{"type":"text","text":"line"},{"type":"hard_break"},{"type":"text","text":"break"},{"type":"hard_break"},{"type":"text","text":"spaces"}]}]}`,
+	},
+	{
+		name:     "entities and escapes",
+		markdown: "&amp; &copy; \\*lit\\*",
+		want:     `{"type":"doc","content":[{"type":"paragraph","content":[{"type":"text","text":"& © *lit*"}]}]}`,
+	},
+	{
+		name:     "thematic breaks",
+		markdown: "***\n\n---",
+		want:     `{"type":"doc","content":[{"type":"horizontal_rule"},{"type":"horizontal_rule"}]}`,
+	},
+	{
+		name:     "other html is text",
+		markdown: "<div>html</div>",
+		want:     `{"type":"doc","content":[{"type":"paragraph","content":[{"type":"text","text":"<div>html</div>"}]}]}`,
+	},
+}
+
+func TestParse(t *testing.T) {
+
+	for _, tt := range parseTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := marshal(t, Parse(tt.markdown)); got != tt.want {
+				t.Errorf("Parse(%q) =\n%s\nwant\n%s", tt.markdown, got, tt.want)
+			}
+		})
+	}
+}
+
+// a document written as markdown and parsed again is the same document, and its markdown does not change
+func TestRoundTrip(t *testing.T) {
+
+	sources := []string{
+		"# Title\n\nSome **bold**, *italic*, ~~struck~~, <u>underlined</u> and `code` text with a [link](https://example.com \"title\").",
+		"## Lists\n\n- one\n- two\n  - nested\n\n3. three\n4. four\n\n- [x] done\n- [ ] todo",
+		"> quoted\n>\n> - item\n>\n> > deeper",
+		"```go\nfunc main() {\n\tfmt.Println(\"```\")\n}\n```\n\n---\n\n![alt](https://cdn.example.com/a(1).png \"title\")",
+		"| name | value |\n| :--- | ---: |\n| a \\| b | `x\\|y` |\n| two<br>lines | 3 |",
+		"\\# not a heading\n\n\\- not a list\n\n1\\. not ordered\n\n\\*not emphasis\\* \\<not html\\> \\&amp;",
+		"line\\\nbreak",
+		"**bold *and italic* over nodes** and [***~~<u>`every mark`</u>~~***](/blogs/1)",
+		"<https://www.youtube.com/watch?v=abc>",
+	}
+
+	for _, source := range sources {
+
+		doc := Parse(source)
+		if err := document.Validate(doc); err != nil {
+			t.Errorf("Parse(%q) is not a valid document: %v", source, err)
+			continue
+		}
+
+		written := render.Markdown(doc)
+		reparsed := Parse(written)
+
+		if got, want := marshal(t, reparsed), marshal(t, doc); got != want {
+			t.Errorf("document of %q changed after a round trip through\n%s\ngot\n%s\nwant\n%s", source, written, got, want)
+			continue
+		}
+
+		if rewritten := render.Markdown(reparsed); rewritten != written {
+			t.Errorf("markdown of %q is not stable\nfirst:\n%s\nsecond:\n%s", source, written, rewritten)
+		}
+	}
+}
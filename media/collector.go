@@ -0,0 +1,110 @@
+package media
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/dhruv15803/echo-blog-app/blobstore"
+	"github.com/dhruv15803/echo-blog-app/storage"
+)
+
+const (
+	defaultInterval  = time.Hour
+	defaultBatchSize = 100
+)
+
+// Collector periodically deletes media that no blog or user references anymore,
+// and the files of direct uploads that were never completed.
+// a media is only deleted once it has been unreferenced for the whole grace period, so an
+// image uploaded for a blog that is still being written, or briefly removed from one, survives
+type Collector struct {
+	storage     *storage.Storage
+	blobs       blobstore.BlobStore
+	gracePeriod time.Duration
+	interval    time.Duration
+	batchSize   int
+}
+
+func NewCollector(storage *storage.Storage, blobs blobstore.BlobStore, gracePeriod time.Duration) *Collector {
+	return &Collector{
+		storage:     storage,
+		blobs:       blobs,
+		gracePeriod: gracePeriod,
+		interval:    defaultInterval,
+		batchSize:   defaultBatchSize,
+	}
+}
+
+// Run collects unreferenced media every interval until ctx is cancelled
+func (c *Collector) Run(ctx context.Context) {
+
+	for {
+		c.collect(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(c.interval):
+		}
+	}
+}
+
+func (c *Collector) collect(ctx context.Context) {
+
+	c.collectUploadIntents(ctx)
+
+	if err := c.storage.MarkUnreferencedMedia(); err != nil {
+		log.Printf("failed to mark unreferenced media :- %v\n", err.Error())
+		return
+	}
+
+	for ctx.Err() == nil {
+
+		deleted, err := c.storage.DeleteUnreferencedMedia(c.gracePeriod, c.batchSize)
+		if err != nil {
+			log.Printf("failed to delete unreferenced media :- %v\n", err.Error())
+			return
+		}
+
+		// the rows are gone, a file that fails to delete is only logged and left behind
+		for _, media := range deleted {
+			for _, variant := range media.Variants {
+				if err := c.blobs.Delete(ctx, variant.Key); err != nil {
+					log.Printf("failed to delete blob %s of media %d :- %v\n", variant.Key, media.Id, err.Error())
+				}
+			}
+		}
+
+		if len(deleted) < c.batchSize {
+			return
+		}
+	}
+}
+
+// collectUploadIntents deletes the files of direct uploads that were never completed
+func (c *Collector) collectUploadIntents(ctx context.Context) {
+
+	for ctx.Err() == nil {
+
+		deleted, err := c.storage.DeleteStaleUploadIntents(c.batchSize)
+		if err != nil {
+			log.Printf("failed to delete stale upload intents :- %v\n", err.Error())
+			return
+		}
+
+		for _, intent := range deleted {
+			// completed intents' files were deleted on completion
+			if intent.CompletedAt != nil {
+				continue
+			}
+			if err := c.blobs.Delete(ctx, intent.StorageKey); err != nil {
+				log.Printf("failed to delete blob %s of upload intent %d :- %v\n", intent.StorageKey, intent.Id, err.Error())
+			}
+		}
+
+		if len(deleted) < c.batchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dhruv15803/echo-blog-app/blobstore"
+	"github.com/dhruv15803/echo-blog-app/imaging"
+	"github.com/dhruv15803/echo-blog-app/storage"
+)
+
+const maxUploadAttempts = 3
+
+// ErrInvalidImage is returned for files that look like a supported image but cannot be decoded
+var ErrInvalidImage = errors.New("invalid image")
+
+// Create processes an image and records it in the user's media library, or returns the media the
+// user already has for the same file. images that are not supported or too large return the imaging
+// errors, ErrInvalidImage if they cannot be decoded and storage.ErrMediaQuotaExceeded if they do not fit
+func Create(ctx context.Context, store *storage.Storage, blobs blobstore.BlobStore, ownerId int, data []byte, quota int64) (*storage.Media, error) {
+
+	hash := sha256.Sum256(data)
+	contentHash := hex.EncodeToString(hash[:])
+
+	existingMedia, err := store.ReuseMediaByHash(ownerId, contentHash)
+	if err == nil {
+		return existingMedia, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to get media by hash: %w", err)
+	}
+
+	result, err := imaging.Process(data)
+	if err != nil {
+		if errors.Is(err, imaging.ErrUnsupportedFormat) || errors.Is(err, imaging.ErrImageTooLarge) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	var size int64
+	for _, variant := range result.Variants {
+		size += int64(len(variant.Data))
+	}
+
+	// checked before uploading anything , CreateMedia checks again under a lock
+	usage, err := store.GetMediaUsage(ownerId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get media usage: %w", err)
+	}
+
+	if usage+size > quota {
+		return nil, storage.ErrMediaQuotaExceeded
+	}
+
+	// every variant is stored under the same random base key , e.g media/2026/10/<random>/thumbnail.jpg
+	baseKey, err := blobstore.NewKey("media", "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate blob key: %w", err)
+	}
+
+	uploaded := make(storage.MediaVariants, len(result.Variants))
+
+	for _, variant := range result.Variants {
+
+		blob, err := putWithRetry(ctx, blobs, baseKey+"/"+variant.Name+variant.Extension, variant.Data, variant.ContentType)
+		if err != nil {
+			deleteUploadedVariants(blobs, uploaded)
+			return nil, fmt.Errorf("failed to upload %s variant: %w", variant.Name, err)
+		}
+
+		uploaded[variant.Name] = storage.MediaVariant{Url: blob.Url, Key: blob.Key, Width: variant.Width, Height: variant.Height}
+	}
+
+	// variants that were not generated because the image is already small enough point to the original
+	variants := make(storage.MediaVariants, len(imaging.VariantSpecs)+1)
+	variants[imaging.OriginalVariant] = uploaded[imaging.OriginalVariant]
+	for _, spec := range imaging.VariantSpecs {
+		variants[spec.Name] = uploaded[result.Variant(spec.Name).Name]
+	}
+
+	var blurhash *string
+	if result.Blurhash != "" {
+		blurhash = &result.Blurhash
+	}
+
+	media, created, err := store.CreateMedia(storage.NewMedia{
+		OwnerId:     ownerId,
+		StorageKey:  baseKey,
+		Url:         variants[imaging.OriginalVariant].Url,
+		ContentHash: contentHash,
+		MimeType:    result.Variant(imaging.OriginalVariant).ContentType,
+		Size:        size,
+		Width:       result.Width,
+		Height:      result.Height,
+		Blurhash:    blurhash,
+		Variants:    variants,
+	}, quota)
+	if err != nil {
+		deleteUploadedVariants(blobs, uploaded)
+		if errors.Is(err, storage.ErrMediaQuotaExceeded) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to create media: %w", err)
+	}
+
+	// the same file was uploaded concurrently , the other upload's files are kept
+	if !created {
+		deleteUploadedVariants(blobs, uploaded)
+	}
+
+	return media, nil
+}
+
+// putWithRetry stores data under key , retrying a failed upload from the start
+func putWithRetry(ctx context.Context, blobs blobstore.BlobStore, key string, data []byte, contentType string) (*blobstore.Blob, error) {
+
+	var blob *blobstore.Blob
+	var err error
+
+	for attempt := 1; attempt <= maxUploadAttempts; attempt++ {
+
+		blob, err = blobs.Put(ctx, key, bytes.NewReader(data), int64(len(data)), contentType)
+		if err == nil {
+			return blob, nil
+		}
+
+		log.Printf("failed to upload file , attempt %d of %d :- %v\n", attempt, maxUploadAttempts, err.Error())
+
+		if attempt < maxUploadAttempts {
+			time.Sleep(time.Duration(attempt) * 500 * time.Millisecond)
+		}
+	}
+
+	return nil, err
+}
+
+// deleteUploadedVariants removes variants that will not be recorded as media , best effort
+func deleteUploadedVariants(blobs blobstore.BlobStore, uploaded storage.MediaVariants) {
+
+	for _, variant := range uploaded {
+		if err := blobs.Delete(context.Background(), variant.Key); err != nil {
+			log.Printf("failed to delete blob %s :- %v\n", variant.Key, err.Error())
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package media
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/dhruv15803/echo-blog-app/blobstore"
+	"github.com/dhruv15803/echo-blog-app/imaging"
+	"github.com/dhruv15803/echo-blog-app/outbox"
+	"github.com/dhruv15803/echo-blog-app/storage"
+)
+
+const (
+	defaultPollInterval    = time.Second * 2
+	defaultProcessingBatch = 4
+	processingLease        = time.Minute * 5
+	maxProcessingAttempts  = 5
+)
+
+// errUploadChanged is returned when the uploaded file no longer matches the intent it was checked against
+var errUploadChanged = errors.New("uploaded file does not match the upload intent")
+
+// Processor creates media from the files of completed upload intents using a pool of workers.
+// processing large images takes longer than a request may, so completing an intent only queues it.
+// intents failing for a transient reason are retried with the same backoff as mails
+type Processor struct {
+	storage      *storage.Storage
+	blobs        blobstore.BlobStore
+	quota        int64
+	workers      int
+	pollInterval time.Duration
+	batchSize    int
+}
+
+func NewProcessor(storage *storage.Storage, blobs blobstore.BlobStore, quota int64, workers int) *Processor {
+
+	if workers <= 0 {
+		workers = 1
+	}
+
+	return &Processor{
+		storage:      storage,
+		blobs:        blobs,
+		quota:        quota,
+		workers:      workers,
+		pollInterval: defaultPollInterval,
+		batchSize:    defaultProcessingBatch,
+	}
+}
+
+// Run starts the workers and blocks until ctx is cancelled and every worker has stopped
+func (p *Processor) Run(ctx context.Context) {
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < p.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.work(ctx)
+		}()
+	}
+
+	wg.Wait()
+}
+
+func (p *Processor) work(ctx context.Context) {
+
+	for {
+		intents, err := p.storage.ClaimUploadIntents(p.batchSize, time.Now().Add(processingLease))
+		if err != nil {
+			log.Printf("failed to claim upload intents :- %v\n", err.Error())
+		}
+
+		for _, intent := range intents {
+			p.process(intent)
+		}
+
+		if len(intents) == p.batchSize && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(p.pollInterval):
+		}
+	}
+}
+
+// process runs to the end even once the processor is stopping, so a claimed intent is not left half done
+func (p *Processor) process(intent storage.UploadIntent) {
+
+	ctx := context.Background()
+
+	media, err := p.create(ctx, intent)
+	if err == nil {
+		// a concurrent processing of the same intent got the same media , as it has the same content
+		if err := p.storage.CompleteUploadIntent(intent.Id, media.Id); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("failed to complete upload intent %d :- %v\n", intent.Id, err.Error())
+			return
+		}
+
+		// only the processed variants are kept
+		if err := p.blobs.Delete(ctx, intent.StorageKey); err != nil {
+			log.Printf("failed to delete blob %s :- %v\n", intent.StorageKey, err.Error())
+		}
+		return
+	}
+
+	message, retry := failureMessage(err)
+
+	if retry && intent.Attempts < maxProcessingAttempts {
+		log.Printf("failed to process upload intent %d , attempt %d of %d :- %v\n", intent.Id, intent.Attempts, maxProcessingAttempts, err.Error())
+
+		if err := p.storage.RetryUploadIntent(intent.Id, time.Now().Add(outbox.Backoff(intent.Attempts)), message); err != nil {
+			log.Printf("failed to retry upload intent %d :- %v\n", intent.Id, err.Error())
+		}
+		return
+	}
+
+	if err := p.storage.FailUploadIntent(intent.Id, message); err != nil {
+		log.Printf("failed to record upload intent %d failure :- %v\n", intent.Id, err.Error())
+	}
+}
+
+// create reads the uploaded file, checks it again as it may have been replaced since the intent
+// was completed, and creates media from it
+func (p *Processor) create(ctx context.Context, intent storage.UploadIntent) (*storage.Media, error) {
+
+	object, err := p.blobs.Open(ctx, intent.StorageKey)
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+
+	// one byte more than expected is read, to notice a larger file
+	data, err := io.ReadAll(io.LimitReader(object, intent.Size+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) != intent.Size {
+		return nil, errUploadChanged
+	}
+
+	if format, ok := imaging.Sniff(data); !ok || format.ContentType() != intent.ContentType {
+		return nil, errUploadChanged
+	}
+
+	return Create(ctx, p.storage, p.blobs, intent.OwnerId, data, p.quota)
+}
+
+// failureMessage is the error shown to the owner of an intent that failed with err,
+// and whether processing it again may succeed
+func failureMessage(err error) (string, bool) {
+
+	switch {
+	case errors.Is(err, imaging.ErrUnsupportedFormat):
+		return "only jpeg , png , webp and gif images are allowed", false
+	case errors.Is(err, imaging.ErrImageTooLarge):
+		return "image dimensions are too large", false
+	case errors.Is(err, ErrInvalidImage):
+		return "invalid image", false
+	case errors.Is(err, storage.ErrMediaQuotaExceeded):
+		return "storage quota exceeded", false
+	case errors.Is(err, errUploadChanged):
+		return errUploadChanged.Error(), false
+	}
+
+	return "failed to process image", true
+}
@@ -0,0 +1,190 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dhruv15803/echo-blog-app/blobstore"
+	"github.com/dhruv15803/echo-blog-app/storage"
+	"github.com/jmoiron/sqlx"
+)
+
+const testIntentKey = "incoming/2026/10/0123456789abcdef0123456789abcdef.png"
+
+func newTestProcessor(t *testing.T) (*Processor, sqlmock.Sqlmock, string) {
+
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sql mock: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	dir := t.TempDir()
+
+	blobs, err := blobstore.NewLocalStore(dir, "http://localhost:8080/uploads", []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewProcessor(storage.NewStorage(sqlx.NewDb(db, "postgres")), blobs, 1<<20, 1), mock, dir
+}
+
+func testPNG(t *testing.T) []byte {
+
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, 40, 30))
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 40; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 6), G: uint8(y * 8), B: 120, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// uploadIntent stores data as the file uploaded for a queued intent
+func uploadIntent(t *testing.T, p *Processor, data []byte, attempts int) storage.UploadIntent {
+
+	t.Helper()
+
+	if _, err := p.blobs.Put(context.Background(), testIntentKey, bytes.NewReader(data), int64(len(data)), "image/png"); err != nil {
+		t.Fatal(err)
+	}
+
+	return storage.UploadIntent{
+		Id:          7,
+		OwnerId:     2,
+		StorageKey:  testIntentKey,
+		ContentType: "image/png",
+		Size:        int64(len(data)),
+		Status:      storage.ProcessingUploadIntentStatus,
+		Attempts:    attempts,
+	}
+}
+
+func mediaRow() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "owner_id", "storage_key", "url", "content_hash", "mime_type", "size", "width", "height",
+		"blurhash", "variants", "unreferenced_since", "created_at"}).
+		AddRow(5, 2, "media/2026/10/fedcba9876543210fedcba9876543210", "http://localhost:8080/uploads/media/original.png", "hash",
+			"image/png", 1024, 40, 30, nil, `{}`, nil, "2026-10-18T00:00:00Z")
+}
+
+func TestProcessorCompletesIntent(t *testing.T) {
+
+	p, mock, dir := newTestProcessor(t)
+	intent := uploadIntent(t, p, testPNG(t), 1)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE media SET unreferenced_since=NULL WHERE owner_id=$1 AND content_hash=$2`)).
+		WithArgs(2, sqlmock.AnyArg()).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE(SUM(size),0) FROM media WHERE owner_id=$1`)).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"usage"}).AddRow(0))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT id FROM users WHERE id=$1 FOR UPDATE`)).WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE media SET unreferenced_since=NULL WHERE owner_id=$1 AND content_hash=$2`)).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE(SUM(size),0) FROM media WHERE owner_id=$1`)).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"usage"}).AddRow(0))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO media`)).
+		WillReturnRows(mediaRow())
+	mock.ExpectCommit()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE upload_intents SET status='completed',media_id=$1`)).WithArgs(5, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	p.process(intent)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	// only the processed variants are kept
+	if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(testIntentKey))); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("uploaded file was not deleted, stat error = %v", err)
+	}
+}
+
+func TestProcessorFailsChangedUpload(t *testing.T) {
+
+	p, mock, _ := newTestProcessor(t)
+	intent := uploadIntent(t, p, testPNG(t), 1)
+
+	// the file was replaced by one of another type after the intent was completed
+	if _, err := p.blobs.Put(context.Background(), testIntentKey, bytes.NewReader(bytes.Repeat([]byte("a"), int(intent.Size))), intent.Size, "image/png"); err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE upload_intents SET status='failed'`)).
+		WithArgs(7, "uploaded file does not match the upload intent").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	p.process(intent)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProcessorRetriesTransientFailure(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		attempts int
+		expect   func(mock sqlmock.Sqlmock)
+	}{
+		{
+			name:     "retried",
+			attempts: 1,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE upload_intents SET status='queued'`)).
+					WithArgs(7, sqlmock.AnyArg(), "failed to process image").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name:     "out of attempts",
+			attempts: maxProcessingAttempts,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE upload_intents SET status='failed'`)).
+					WithArgs(7, "failed to process image").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			p, mock, _ := newTestProcessor(t)
+			intent := uploadIntent(t, p, testPNG(t), tt.attempts)
+
+			mock.ExpectQuery(regexp.QuoteMeta(`UPDATE media SET unreferenced_since=NULL`)).
+				WillReturnError(errors.New("connection reset by peer"))
+			tt.expect(mock)
+
+			p.process(intent)
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
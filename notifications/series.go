@@ -0,0 +1,118 @@
+package notifications
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dhruv15803/echo-blog-app/outbox"
+	"github.com/dhruv15803/echo-blog-app/storage"
+)
+
+const (
+	defaultPollInterval = time.Second * 2
+	defaultBatchSize    = 10
+	fanOutLease         = time.Minute * 5
+)
+
+// SeriesPartDispatcher tells the followers of a series about its new parts, queued in the
+// series_part_notifications table when the blog is added. a series may have many followers
+// so the fan out happens here rather than in the request
+type SeriesPartDispatcher struct {
+	storage      *storage.Storage
+	service      *Service
+	pollInterval time.Duration
+	batchSize    int
+}
+
+func NewSeriesPartDispatcher(storage *storage.Storage, service *Service) *SeriesPartDispatcher {
+	return &SeriesPartDispatcher{
+		storage:      storage,
+		service:      service,
+		pollInterval: defaultPollInterval,
+		batchSize:    defaultBatchSize,
+	}
+}
+
+// Run polls for queued parts until ctx is cancelled
+func (d *SeriesPartDispatcher) Run(ctx context.Context) {
+
+	for {
+		parts, err := d.storage.ClaimOutboxSeriesParts(d.batchSize, time.Now().Add(fanOutLease))
+		if err != nil {
+			log.Printf("failed to claim series parts :- %v\n", err.Error())
+		}
+
+		for _, part := range parts {
+			d.process(part)
+		}
+
+		// keep draining while there is a backlog
+		if len(parts) == d.batchSize && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(d.pollInterval):
+		}
+	}
+}
+
+func (d *SeriesPartDispatcher) process(part storage.OutboxSeriesPart) {
+
+	fanOutErr := d.fanOut(part)
+	if fanOutErr == nil {
+		if err := d.storage.MarkOutboxSeriesPartSent(part.Id); err != nil {
+			log.Printf("failed to mark series part %d as sent :- %v\n", part.Id, err.Error())
+		}
+		return
+	}
+
+	log.Printf("failed to notify followers of series part %d , attempt %d of %d :- %v\n", part.Id, part.Attempts, part.MaxAttempts, fanOutErr.Error())
+
+	if part.Attempts >= part.MaxAttempts {
+		if err := d.storage.MarkOutboxSeriesPartDead(part.Id, fanOutErr.Error()); err != nil {
+			log.Printf("failed to mark series part %d as dead :- %v\n", part.Id, err.Error())
+		}
+		return
+	}
+
+	nextAttemptAt := time.Now().Add(outbox.Backoff(part.Attempts))
+	if err := d.storage.MarkOutboxSeriesPartFailed(part.Id, fanOutErr.Error(), nextAttemptAt); err != nil {
+		log.Printf("failed to reschedule series part %d :- %v\n", part.Id, err.Error())
+	}
+}
+
+// fanOut delivers a notification to every follower that does not have it yet. the remaining
+// followers are still notified when one fails, the part is then retried for the failed ones
+func (d *SeriesPartDispatcher) fanOut(part storage.OutboxSeriesPart) error {
+
+	blog, err := d.storage.GetBlogById(part.BlogId)
+	if err != nil {
+		// the blog was deleted since, the row is cascaded away with it
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	followerIds, err := d.storage.GetSeriesFollowerIdsToNotify(part.SeriesId, SeriesPartAdded(*blog, 0).groupKey(), blog.BlogAuthorId)
+	if err != nil {
+		return err
+	}
+
+	var deliverErrs []error
+
+	for _, followerId := range followerIds {
+		if err := d.service.Deliver(SeriesPartAdded(*blog, followerId)); err != nil {
+			deliverErrs = append(deliverErrs, fmt.Errorf("follower %d :- %w", followerId, err))
+		}
+	}
+
+	return errors.Join(deliverErrs...)
+}
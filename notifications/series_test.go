@@ -0,0 +1,142 @@
+package notifications
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dhruv15803/echo-blog-app/realtime"
+	"github.com/dhruv15803/echo-blog-app/storage"
+	"github.com/jmoiron/sqlx"
+)
+
+func newTestSeriesPartDispatcher(t *testing.T) (*SeriesPartDispatcher, sqlmock.Sqlmock) {
+
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sql mock: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	store := storage.NewStorage(sqlx.NewDb(db, "postgres"))
+
+	return NewSeriesPartDispatcher(store, NewService(store, realtime.NewHub())), mock
+}
+
+func blogRow() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "blog_title", "blog_slug", "blog_description", "blog_content", "blog_content_format",
+		"blog_content_markdown", "blog_thumbnail", "blog_author_id", "blog_created_at", "blog_updated_at"}).
+		AddRow(9, "Part two", "part-two", nil, "{}", "json", nil, nil, 2, "2026-10-18T00:00:00Z", nil)
+}
+
+func TestSeriesPartDispatcherNotifiesFollowers(t *testing.T) {
+
+	d, mock := newTestSeriesPartDispatcher(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM blogs WHERE id=$1`)).WithArgs(9).WillReturnRows(blogRow())
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT sf.user_id FROM series_follows AS sf`)).WithArgs(3, "series_part:9", 2).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(2).AddRow(5))
+	// the author follows their own series and is skipped, the other follower turned these notifications off
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE((SELECT channel FROM notification_preferences`)).
+		WithArgs(5, storage.SeriesPartNotification).
+		WillReturnRows(sqlmock.NewRows([]string{"channel"}).AddRow("off"))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE series_part_notifications SET status='sent'`)).WithArgs(int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	d.process(storage.OutboxSeriesPart{Id: 1, SeriesId: 3, BlogId: 9, Attempts: 1, MaxAttempts: 8})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSeriesPartDispatcherRetriesFailedDelivery(t *testing.T) {
+
+	d, mock := newTestSeriesPartDispatcher(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM blogs WHERE id=$1`)).WithArgs(9).WillReturnRows(blogRow())
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT sf.user_id FROM series_follows AS sf`)).WithArgs(3, "series_part:9", 2).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(5).AddRow(6))
+	// storing the notification of the first follower fails, the second is still delivered to
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE((SELECT channel FROM notification_preferences`)).
+		WithArgs(5, storage.SeriesPartNotification).
+		WillReturnRows(sqlmock.NewRows([]string{"channel"}).AddRow("in_app"))
+	mock.ExpectBegin().WillReturnError(errors.New("connection reset by peer"))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE((SELECT channel FROM notification_preferences`)).
+		WithArgs(6, storage.SeriesPartNotification).
+		WillReturnRows(sqlmock.NewRows([]string{"channel"}).AddRow("off"))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE series_part_notifications SET status='pending'`)).
+		WithArgs(int64(1), "follower 5 :- connection reset by peer", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	d.process(storage.OutboxSeriesPart{Id: 1, SeriesId: 3, BlogId: 9, Attempts: 1, MaxAttempts: 8})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSeriesPartDispatcherSkipsDeletedBlog(t *testing.T) {
+
+	d, mock := newTestSeriesPartDispatcher(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM blogs WHERE id=$1`)).WithArgs(9).WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE series_part_notifications SET status='sent'`)).WithArgs(int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	d.process(storage.OutboxSeriesPart{Id: 1, SeriesId: 3, BlogId: 9, Attempts: 1, MaxAttempts: 8})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSeriesPartDispatcherRetriesFailure(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		attempts int
+		expect   func(mock sqlmock.Sqlmock)
+	}{
+		{
+			name:     "retried",
+			attempts: 1,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE series_part_notifications SET status='pending'`)).
+					WithArgs(int64(1), "connection reset by peer", sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name:     "out of attempts",
+			attempts: 8,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE series_part_notifications SET status='dead'`)).
+					WithArgs(int64(1), "connection reset by peer").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			d, mock := newTestSeriesPartDispatcher(t)
+
+			mock.ExpectQuery(regexp.QuoteMeta(`FROM blogs WHERE id=$1`)).WithArgs(9).WillReturnRows(blogRow())
+			mock.ExpectQuery(regexp.QuoteMeta(`SELECT sf.user_id FROM series_follows AS sf`)).WithArgs(3, "series_part:9", 2).
+				WillReturnError(errors.New("connection reset by peer"))
+			tt.expect(mock)
+
+			d.process(storage.OutboxSeriesPart{Id: 1, SeriesId: 3, BlogId: 9, Attempts: tt.attempts, MaxAttempts: 8})
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package notifications
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/dhruv15803/echo-blog-app/realtime"
+	"github.com/dhruv15803/echo-blog-app/storage"
+)
+
+// Event is something a user did that another user should hear about
+type Event struct {
+	Type        storage.NotificationType
+	ActorId     int
+	RecipientId int
+	BlogId      *int
+	CommentId   *int
+}
+
+func BlogLiked(actorId int, blog storage.Blog) Event {
+	return Event{Type: storage.BlogLikeNotification, ActorId: actorId, RecipientId: blog.BlogAuthorId, BlogId: &blog.Id}
+}
+
+func BlogCommented(actorId int, blog storage.Blog) Event {
+	return Event{Type: storage.BlogCommentNotification, ActorId: actorId, RecipientId: blog.BlogAuthorId, BlogId: &blog.Id}
+}
+
+func CommentReplied(actorId int, parentComment storage.BlogComment) Event {
+	return Event{Type: storage.CommentReplyNotification, ActorId: actorId, RecipientId: parentComment.CommentAuthorId, BlogId: &parentComment.BlogId, CommentId: &parentComment.Id}
+}
+
+func CommentLiked(actorId int, comment storage.BlogComment) Event {
+	return Event{Type: storage.CommentLikeNotification, ActorId: actorId, RecipientId: comment.CommentAuthorId, BlogId: &comment.BlogId, CommentId: &comment.Id}
+}
+
+func UserFollowed(actorId int, followedUserId int) Event {
+	return Event{Type: storage.FollowNotification, ActorId: actorId, RecipientId: followedUserId}
+}
+
+// SeriesPartAdded tells a follower of a series that its author added the blog to it
+func SeriesPartAdded(blog storage.Blog, followerId int) Event {
+	return Event{Type: storage.SeriesPartNotification, ActorId: blog.BlogAuthorId, RecipientId: followerId, BlogId: &blog.Id}
+}
+
+// groupKey decides which events aggregate into one notification, e.g. all likes on one blog
+func (e Event) groupKey() string {
+	switch e.Type {
+	case storage.BlogLikeNotification, storage.BlogCommentNotification, storage.SeriesPartNotification:
+		return fmt.Sprintf("%s:%d", e.Type, *e.BlogId)
+	case storage.CommentReplyNotification, storage.CommentLikeNotification:
+		return fmt.Sprintf("%s:%d", e.Type, *e.CommentId)
+	default:
+		return string(e.Type)
+	}
+}
+
+// Service turns engagement events into aggregated in-app notifications and pushes them
+// to the recipient's live connections. notifications are best effort, failures are logged
+// and never fail the action that caused them
+type Service struct {
+	storage *storage.Storage
+	broker  realtime.Broker
+}
+
+func NewService(storage *storage.Storage, broker realtime.Broker) *Service {
+	return &Service{storage: storage, broker: broker}
+}
+
+// Emit records the event on the recipient's notification for it, unless the recipient turned this type off
+func (s *Service) Emit(event Event) {
+	if err := s.Deliver(event); err != nil {
+		log.Printf("failed to emit %s notification :- %v\n", event.Type, err.Error())
+	}
+}
+
+// Deliver is Emit for callers that retry, e.g. an outbox, the error is returned instead of logged.
+// pushing to live connections stays best effort as the notification is stored by then
+func (s *Service) Deliver(event Event) error {
+
+	// nobody needs to be told about their own actions
+	if event.ActorId == event.RecipientId {
+		return nil
+	}
+
+	channel, err := s.storage.GetNotificationChannel(event.RecipientId, event.Type)
+	if err != nil {
+		return err
+	}
+
+	if channel == storage.OffNotificationChannel {
+		return nil
+	}
+
+	notification, err := s.storage.UpsertNotification(event.RecipientId, event.Type, event.groupKey(), event.BlogId, event.CommentId, event.ActorId)
+	if err != nil {
+		return err
+	}
+
+	s.push(*notification)
+
+	return nil
+}
+
+// push tells the recipient's open connections about the notification, clients fetch
+// the aggregated notification itself from the notifications endpoint
+func (s *Service) push(notification storage.Notification) {
+
+	unreadCount, err := s.storage.GetNotificationsCount(notification.RecipientId, true)
+	if err != nil {
+		log.Printf("failed to get unread notifications count :- %v\n", err.Error())
+		return
+	}
+
+	type Data struct {
+		Notification storage.Notification `json:"notification"`
+		UnreadCount  int                  `json:"unread_count"`
+	}
+
+	realtimeEvent, err := realtime.NewEvent(realtime.UserTopic(notification.RecipientId), realtime.NotificationEvent, Data{Notification: notification, UnreadCount: unreadCount})
+	if err != nil {
+		log.Printf("failed to build notification event :- %v\n", err.Error())
+		return
+	}
+
+	if err := s.broker.Publish(realtimeEvent); err != nil {
+		log.Printf("failed to publish notification event :- %v\n", err.Error())
+	}
+}
+
+// Retract undoes Emit when the underlying action is undone, e.g. a like is toggled off
+func (s *Service) Retract(event Event) {
+
+	if event.ActorId == event.RecipientId {
+		return
+	}
+
+	if err := s.storage.RemoveNotificationActor(event.RecipientId, event.groupKey(), event.ActorId); err != nil {
+		log.Printf("failed to retract %s notification :- %v\n", event.Type, err.Error())
+	}
+}
+
+// Summary is the human readable text of a notification, e.g. "X and 12 others liked your post"
+func Summary(notification storage.NotificationWithActors) string {
+
+	actors := "Someone"
+	if len(notification.LatestActors) > 0 && notification.LatestActors[0].Name != nil && *notification.LatestActors[0].Name != "" {
+		actors = *notification.LatestActors[0].Name
+	}
+
+	switch others := notification.ActorsCount - 1; {
+	case others == 1:
+		actors += " and 1 other"
+	case others > 1:
+		actors += fmt.Sprintf(" and %d others", others)
+	}
+
+	switch notification.NotificationType {
+	case storage.BlogLikeNotification:
+		return actors + " liked your post"
+	case storage.BlogCommentNotification:
+		return actors + " commented on your post"
+	case storage.CommentReplyNotification:
+		return actors + " replied to your comment"
+	case storage.CommentLikeNotification:
+		return actors + " liked your comment"
+	case storage.FollowNotification:
+		return actors + " followed you"
+	case storage.SeriesPartNotification:
+		return actors + " published a new part of a series you follow"
+	default:
+		return actors + " interacted with you"
+	}
+}
@@ -0,0 +1,254 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/dhruv15803/echo-blog-app/mailer"
+	"github.com/dhruv15803/echo-blog-app/storage"
+)
+
+const (
+	defaultPollInterval = time.Second * 2
+	defaultBatchSize    = 10
+	sendLease           = time.Minute * 2
+	baseBackoff         = time.Second * 5
+	maxBackoff          = time.Minute * 10
+)
+
+// TokenMailPayload is the outbox payload of mails carrying a single use token link
+type TokenMailPayload struct {
+	Token string `json:"token"`
+}
+
+// DigestMailPayload is the outbox payload of digest mails, rendered when the mail is sent
+type DigestMailPayload struct {
+	Frequency        storage.DigestFrequency  `json:"frequency"`
+	Activity         []storage.DigestActivity `json:"activity"`
+	Blogs            []storage.DigestBlog     `json:"blogs"`
+	UnsubscribeToken string                   `json:"unsubscribe_token"`
+}
+
+// Dispatcher delivers queued mails from the email_outbox table using a pool of workers
+type Dispatcher struct {
+	storage      *storage.Storage
+	mailer       mailer.Mailer
+	templates    *mailer.Templates
+	fromEmail    string
+	clientUrl    string
+	apiUrl       string
+	workers      int
+	pollInterval time.Duration
+	batchSize    int
+}
+
+func NewDispatcher(storage *storage.Storage, mailer mailer.Mailer, templates *mailer.Templates, fromEmail string, clientUrl string, apiUrl string, workers int) *Dispatcher {
+
+	if workers <= 0 {
+		workers = 1
+	}
+
+	return &Dispatcher{
+		storage:      storage,
+		mailer:       mailer,
+		templates:    templates,
+		fromEmail:    fromEmail,
+		clientUrl:    clientUrl,
+		apiUrl:       apiUrl,
+		workers:      workers,
+		pollInterval: defaultPollInterval,
+		batchSize:    defaultBatchSize,
+	}
+}
+
+// Run starts the workers and blocks until ctx is cancelled and every worker has stopped
+func (d *Dispatcher) Run(ctx context.Context) {
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < d.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.work(ctx)
+		}()
+	}
+
+	wg.Wait()
+}
+
+func (d *Dispatcher) work(ctx context.Context) {
+
+	for {
+		emails, err := d.storage.ClaimOutboxEmails(d.batchSize, time.Now().Add(sendLease))
+		if err != nil {
+			log.Printf("failed to claim outbox emails :- %v\n", err.Error())
+		}
+
+		for _, email := range emails {
+			d.process(email)
+		}
+
+		// keep draining while there is a backlog
+		if len(emails) == d.batchSize && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(d.pollInterval):
+		}
+	}
+}
+
+func (d *Dispatcher) process(email storage.OutboxEmail) {
+
+	sendErr := d.deliver(email)
+	if sendErr == nil {
+		if err := d.storage.MarkOutboxEmailSent(email.Id); err != nil {
+			log.Printf("failed to mark outbox email %d as sent :- %v\n", email.Id, err.Error())
+		}
+		return
+	}
+
+	log.Printf("failed to send outbox email %d , attempt %d of %d :- %v\n", email.Id, email.Attempts, email.MaxAttempts, sendErr.Error())
+
+	if email.Attempts >= email.MaxAttempts {
+		if err := d.storage.MarkOutboxEmailDead(email.Id, sendErr.Error()); err != nil {
+			log.Printf("failed to mark outbox email %d as dead :- %v\n", email.Id, err.Error())
+		}
+		return
+	}
+
+	nextAttemptAt := time.Now().Add(Backoff(email.Attempts))
+	if err := d.storage.MarkOutboxEmailFailed(email.Id, sendErr.Error(), nextAttemptAt); err != nil {
+		log.Printf("failed to reschedule outbox email %d :- %v\n", email.Id, err.Error())
+	}
+}
+
+func (d *Dispatcher) deliver(email storage.OutboxEmail) (err error) {
+
+	// a misbehaving mailer must not take the whole worker pool down with it
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic while sending mail :- %v", r)
+		}
+	}()
+
+	if len(email.Payload) == 0 {
+		return errors.New("outbox email has no payload")
+	}
+
+	var data map[string]any
+	var headers map[string]string
+
+	switch email.Template {
+	case storage.InviteEmailTemplate:
+
+		var payload TokenMailPayload
+
+		if err := json.Unmarshal(email.Payload, &payload); err != nil {
+			return err
+		}
+
+		data = map[string]any{"ActivationUrl": fmt.Sprintf("%s/activate-account/%s", d.clientUrl, payload.Token)}
+	case storage.PasswordResetEmailTemplate:
+
+		var payload TokenMailPayload
+
+		if err := json.Unmarshal(email.Payload, &payload); err != nil {
+			return err
+		}
+
+		data = map[string]any{"PasswordResetLink": fmt.Sprintf("%s/password-reset/%s", d.clientUrl, payload.Token)}
+	case storage.DigestEmailTemplate:
+
+		var payload DigestMailPayload
+
+		if err := json.Unmarshal(email.Payload, &payload); err != nil {
+			return err
+		}
+
+		data, headers = d.digestMailData(payload)
+	default:
+		return fmt.Errorf("unknown outbox email template %q", email.Template)
+	}
+
+	message, err := d.templates.Compose(email.Template, email.Locale, d.fromEmail, email.ToEmail, mailer.TemplateData{ClientUrl: d.clientUrl, Data: data})
+	if err != nil {
+		return err
+	}
+
+	message.Headers = headers
+
+	if email.Subject != nil {
+		message.Subject = *email.Subject
+	}
+
+	return d.mailer.Send(message)
+}
+
+// digestMailData builds the template data of a digest and its one-click unsubscribe headers (RFC 8058).
+// the link in the mail opens the client which confirms, mail clients post to the api directly
+func (d *Dispatcher) digestMailData(payload DigestMailPayload) (map[string]any, map[string]string) {
+
+	activity := make([]map[string]any, 0, len(payload.Activity))
+	for _, a := range payload.Activity {
+		activity = append(activity, map[string]any{"Type": string(a.NotificationType), "Count": a.Count})
+	}
+
+	blogs := make([]map[string]any, 0, len(payload.Blogs))
+	for _, blog := range payload.Blogs {
+
+		authorName := ""
+		if blog.AuthorName != nil {
+			authorName = *blog.AuthorName
+		}
+
+		blogs = append(blogs, map[string]any{"Title": blog.BlogTitle, "AuthorName": authorName, "Url": fmt.Sprintf("%s/blog/%d", d.clientUrl, blog.Id)})
+	}
+
+	token := url.QueryEscape(payload.UnsubscribeToken)
+	unsubscribeUrl := fmt.Sprintf("%s/unsubscribe?token=%s", d.clientUrl, token)
+
+	headers := map[string]string{"List-Unsubscribe": fmt.Sprintf("<%s>", unsubscribeUrl)}
+	if d.apiUrl != "" {
+		headers["List-Unsubscribe"] = fmt.Sprintf("<%s/api/unsubscribe?token=%s>", d.apiUrl, token)
+		headers["List-Unsubscribe-Post"] = "List-Unsubscribe=One-Click"
+	}
+
+	data := map[string]any{
+		"Frequency":      string(payload.Frequency),
+		"Activity":       activity,
+		"Blogs":          blogs,
+		"UnsubscribeUrl": unsubscribeUrl,
+	}
+
+	return data, headers
+}
+
+// Backoff is the delay before retrying after the given number of failed attempts,
+// doubling from baseBackoff up to maxBackoff with up to 20% jitter
+func Backoff(attempts int) time.Duration {
+
+	backoff := baseBackoff
+	for i := 1; i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+
+	jitter := time.Duration(rand.Int64N(int64(backoff) / 5))
+
+	return backoff + jitter
+}
@@ -0,0 +1,134 @@
+package realtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+const (
+	NotificationEvent   = "notification"
+	BlogCountsEvent     = "blog.counts"
+	CommentCreatedEvent = "comment.created"
+)
+
+// Event is a message pushed to every subscriber of its topic
+type Event struct {
+	Topic string          `json:"topic"`
+	Type  string          `json:"type"`
+	Data  json.RawMessage `json:"data"`
+}
+
+func UserTopic(userId int) string {
+	return fmt.Sprintf("user:%d", userId)
+}
+
+func BlogTopic(blogId int) string {
+	return fmt.Sprintf("blog:%d", blogId)
+}
+
+func NewEvent(topic string, eventType string, data any) (Event, error) {
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+
+	return Event{Topic: topic, Type: eventType, Data: payload}, nil
+}
+
+// Broker fans events out to subscribers. the in-process Hub only reaches
+// subscribers on this instance, PostgresBroker reaches every instance
+type Broker interface {
+	Publish(event Event) error
+	Subscribe(bufferSize int, topics ...string) *Subscription
+}
+
+// Subscription receives the events of its topics until it is closed.
+// a subscriber that falls bufferSize events behind is dropped, Dropped is closed
+// when that happens so the consumer can tell the client to reconnect
+type Subscription struct {
+	hub     *Hub
+	topics  []string
+	events  chan Event
+	Dropped chan struct{}
+	once    sync.Once
+}
+
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+func (s *Subscription) Close() {
+	s.hub.unsubscribe(s)
+}
+
+// Hub is an in-process pub/sub hub
+type Hub struct {
+	mu          sync.RWMutex
+	subscribers map[string]map[*Subscription]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{subscribers: make(map[string]map[*Subscription]struct{})}
+}
+
+func (h *Hub) Subscribe(bufferSize int, topics ...string) *Subscription {
+
+	subscription := &Subscription{
+		hub:     h,
+		topics:  topics,
+		events:  make(chan Event, bufferSize),
+		Dropped: make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, topic := range topics {
+		if h.subscribers[topic] == nil {
+			h.subscribers[topic] = make(map[*Subscription]struct{})
+		}
+		h.subscribers[topic][subscription] = struct{}{}
+	}
+
+	return subscription
+}
+
+func (h *Hub) unsubscribe(subscription *Subscription) {
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, topic := range subscription.topics {
+		delete(h.subscribers[topic], subscription)
+		if len(h.subscribers[topic]) == 0 {
+			delete(h.subscribers, topic)
+		}
+	}
+}
+
+// Publish never blocks on a slow subscriber, it drops the subscriber instead
+func (h *Hub) Publish(event Event) error {
+
+	var lagging []*Subscription
+
+	h.mu.RLock()
+	for subscription := range h.subscribers[event.Topic] {
+		select {
+		case subscription.events <- event:
+		default:
+			lagging = append(lagging, subscription)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, subscription := range lagging {
+		subscription.once.Do(func() {
+			close(subscription.Dropped)
+		})
+		h.unsubscribe(subscription)
+	}
+
+	return nil
+}
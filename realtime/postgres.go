@@ -0,0 +1,147 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const (
+	postgresChannel = "echo_realtime"
+	// postgres rejects NOTIFY payloads of 8000 bytes or more, larger events are stored in
+	// realtime_events and only their id is sent
+	maxNotifyPayloadSize = 7999
+	// how long stored events are kept for instances to read them
+	storedEventRetention = 5 * time.Minute
+)
+
+// postgresNotification is the NOTIFY payload, either the event itself or the id of the stored event
+type postgresNotification struct {
+	Event
+	StoredEventId int64 `json:"stored_event_id,omitempty"`
+}
+
+// PostgresBroker publishes through postgres NOTIFY so that subscribers connected to
+// any instance receive the event, every instance LISTENs and fans out through its local Hub
+type PostgresBroker struct {
+	db       *sqlx.DB
+	hub      *Hub
+	listener *pq.Listener
+}
+
+func NewPostgresBroker(db *sqlx.DB, connStr string) (*PostgresBroker, error) {
+
+	listener := pq.NewListener(connStr, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("realtime listener event %d :- %v\n", event, err.Error())
+		}
+	})
+
+	if err := listener.Listen(postgresChannel); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return &PostgresBroker{db: db, hub: NewHub(), listener: listener}, nil
+}
+
+func (b *PostgresBroker) Publish(event Event) error {
+
+	payload, err := json.Marshal(postgresNotification{Event: event})
+	if err != nil {
+		return err
+	}
+
+	if len(payload) > maxNotifyPayloadSize {
+		if payload, err = b.storeEvent(event); err != nil {
+			return err
+		}
+	}
+
+	_, err = b.db.Exec(`SELECT pg_notify($1,$2)`, postgresChannel, string(payload))
+	return err
+}
+
+// storeEvent writes an event too large to notify and returns the notification that refers to it
+func (b *PostgresBroker) storeEvent(event Event) ([]byte, error) {
+
+	eventPayload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := b.db.Exec(`DELETE FROM realtime_events WHERE created_at < $1`, time.Now().Add(-storedEventRetention)); err != nil {
+		return nil, err
+	}
+
+	var storedEventId int64
+	if err := b.db.Get(&storedEventId, `INSERT INTO realtime_events(payload) VALUES($1) RETURNING id`, string(eventPayload)); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(postgresNotification{Event: Event{Topic: event.Topic, Type: event.Type}, StoredEventId: storedEventId})
+}
+
+// decode reads the event of a notification, fetching it when it was stored
+func (b *PostgresBroker) decode(payload string) (Event, error) {
+
+	var received postgresNotification
+	if err := json.Unmarshal([]byte(payload), &received); err != nil {
+		return Event{}, err
+	}
+
+	if received.StoredEventId == 0 {
+		return received.Event, nil
+	}
+
+	var eventPayload string
+	if err := b.db.Get(&eventPayload, `SELECT payload FROM realtime_events WHERE id=$1`, received.StoredEventId); err != nil {
+		return Event{}, err
+	}
+
+	var event Event
+	if err := json.Unmarshal([]byte(eventPayload), &event); err != nil {
+		return Event{}, err
+	}
+
+	return event, nil
+}
+
+func (b *PostgresBroker) Subscribe(bufferSize int, topics ...string) *Subscription {
+	return b.hub.Subscribe(bufferSize, topics...)
+}
+
+// Run relays notifications to local subscribers until ctx is cancelled
+func (b *PostgresBroker) Run(ctx context.Context) {
+
+	defer b.listener.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case notification := <-b.listener.Notify:
+			// a nil notification means the connection was re-established, events sent
+			// while it was down are lost and clients recover by refetching on reconnect
+			if notification == nil {
+				continue
+			}
+
+			event, err := b.decode(notification.Extra)
+			if err != nil {
+				log.Printf("failed to decode realtime event :- %v\n", err.Error())
+				continue
+			}
+
+			b.hub.Publish(event)
+		case <-time.After(90 * time.Second):
+			if err := b.listener.Ping(); err != nil {
+				log.Printf("realtime listener ping failed :- %v\n", err.Error())
+			}
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package realtime
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+)
+
+func newMockBroker(t *testing.T) (*PostgresBroker, sqlmock.Sqlmock) {
+
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sql mock: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return &PostgresBroker{db: sqlx.NewDb(db, "postgres"), hub: NewHub()}, mock
+}
+
+// notifyPayload captures the payload passed to pg_notify
+type notifyPayload struct {
+	value *string
+}
+
+func (p notifyPayload) Match(v driver.Value) bool {
+	payload, ok := v.(string)
+	if ok {
+		*p.value = payload
+	}
+	return ok
+}
+
+func TestPostgresBrokerPublishSmallEvent(t *testing.T) {
+
+	broker, mock := newMockBroker(t)
+
+	event, err := NewEvent(BlogTopic(1), CommentCreatedEvent, map[string]string{"content": "short"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var payload string
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_notify($1,$2)`)).
+		WithArgs(postgresChannel, notifyPayload{&payload}).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := broker.Publish(event); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	received, err := broker.decode(payload)
+	if err != nil {
+		t.Fatalf("decode() error = %v", err)
+	}
+
+	if received.Topic != event.Topic || received.Type != event.Type || string(received.Data) != string(event.Data) {
+		t.Errorf("decode() = %+v, want %+v", received, event)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPostgresBrokerPublishOversizedEvent(t *testing.T) {
+
+	broker, mock := newMockBroker(t)
+
+	event, err := NewEvent(BlogTopic(1), CommentCreatedEvent, map[string]string{"content": strings.Repeat("a", 20000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var storedPayload string
+	var payload string
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM realtime_events WHERE created_at < $1`)).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO realtime_events(payload) VALUES($1) RETURNING id`)).
+		WithArgs(notifyPayload{&storedPayload}).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42))
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_notify($1,$2)`)).
+		WithArgs(postgresChannel, notifyPayload{&payload}).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := broker.Publish(event); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	if len(payload) > maxNotifyPayloadSize {
+		t.Fatalf("notify payload is %d bytes, want at most %d", len(payload), maxNotifyPayloadSize)
+	}
+
+	var sent postgresNotification
+	if err := json.Unmarshal([]byte(payload), &sent); err != nil {
+		t.Fatal(err)
+	}
+
+	if sent.StoredEventId != 42 || sent.Topic != event.Topic || sent.Type != event.Type {
+		t.Errorf("notification = %+v, want stored event 42 of %s %s", sent, event.Topic, event.Type)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT payload FROM realtime_events WHERE id=$1`)).
+		WithArgs(42).
+		WillReturnRows(sqlmock.NewRows([]string{"payload"}).AddRow(storedPayload))
+
+	received, err := broker.decode(payload)
+	if err != nil {
+		t.Fatalf("decode() error = %v", err)
+	}
+
+	if received.Topic != event.Topic || received.Type != event.Type || string(received.Data) != string(event.Data) {
+		t.Errorf("decode() returned a different event")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,227 @@
+// Package related finds the blogs most related to every blog, from the topics they share,
+// how similar their text is and the readers that liked both
+package related
+
+import (
+	"context"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/dhruv15803/echo-blog-app/document"
+	"github.com/dhruv15803/echo-blog-app/render"
+	"github.com/dhruv15803/echo-blog-app/storage"
+)
+
+const (
+	defaultInterval = time.Hour
+	// related blogs kept for every blog, more than are shown so that some are left after
+	// a viewer's own and read blogs are taken out
+	defaultRelatedSize = 20
+)
+
+// how much shared topics, similar text and readers that liked both add to the score, each of them is between 0 and 1
+type weights struct {
+	topic      float64
+	text       float64
+	engagement float64
+}
+
+var defaultWeights = weights{
+	topic:      0.3,
+	text:       0.4,
+	engagement: 0.3,
+}
+
+// Recommender recomputes the related blogs of every blog each interval, so the related blogs
+// endpoint only reads them
+type Recommender struct {
+	storage     *storage.Storage
+	interval    time.Duration
+	relatedSize int
+	weights     weights
+}
+
+func NewRecommender(store *storage.Storage) *Recommender {
+	return &Recommender{
+		storage:     store,
+		interval:    defaultInterval,
+		relatedSize: defaultRelatedSize,
+		weights:     defaultWeights,
+	}
+}
+
+// Run recomputes the related blogs every interval until ctx is cancelled
+func (rc *Recommender) Run(ctx context.Context) {
+
+	for {
+		rc.refresh()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(rc.interval):
+		}
+	}
+}
+
+func (rc *Recommender) refresh() {
+
+	now := time.Now()
+
+	sources, err := rc.storage.GetRelatedSources()
+	if err != nil {
+		log.Printf("failed to get related blog sources :- %v\n", err.Error())
+		return
+	}
+
+	coLikes, err := rc.storage.GetBlogCoLikes()
+	if err != nil {
+		log.Printf("failed to get blog co-likes :- %v\n", err.Error())
+		return
+	}
+
+	scores := rc.score(sources, coLikes)
+
+	if err := rc.storage.ReplaceRelatedBlogs(scores, now); err != nil {
+		log.Printf("failed to replace related blogs :- %v\n", err.Error())
+	}
+}
+
+// score combines the three similarities of every pair of blogs and keeps the highest scoring related blogs of each
+func (rc *Recommender) score(sources []storage.RelatedSource, coLikes []storage.BlogCoLikes) []storage.RelatedBlogScore {
+
+	counts := make(map[int]map[string]int, len(sources))
+	for _, source := range sources {
+		counts[source.Id] = termCounts(source)
+	}
+
+	textScores := textSimilarities(tfidfVectors(counts))
+	topicScores := topicSimilarities(sources)
+
+	engagementScores := make(map[int]map[int]float64)
+	for _, pair := range coLikes {
+		if engagementScores[pair.BlogId] == nil {
+			engagementScores[pair.BlogId] = make(map[int]float64)
+		}
+		// cosine similarity of the sets of readers that liked each blog
+		engagementScores[pair.BlogId][pair.OtherBlogId] = float64(pair.CoLikes) / math.Sqrt(float64(pair.Likes)*float64(pair.OtherLikes))
+	}
+
+	var scores []storage.RelatedBlogScore
+
+	for _, source := range sources {
+
+		candidates := make(map[int]bool)
+		for relatedId := range topicScores[source.Id] {
+			candidates[relatedId] = true
+		}
+		for relatedId := range textScores[source.Id] {
+			candidates[relatedId] = true
+		}
+		for relatedId := range engagementScores[source.Id] {
+			candidates[relatedId] = true
+		}
+
+		var related []storage.RelatedBlogScore
+		for relatedId := range candidates {
+			score := storage.RelatedBlogScore{
+				BlogId:          source.Id,
+				RelatedBlogId:   relatedId,
+				TopicScore:      topicScores[source.Id][relatedId],
+				TextScore:       textScores[source.Id][relatedId],
+				EngagementScore: engagementScores[source.Id][relatedId],
+			}
+			score.Score = rc.weights.topic*score.TopicScore + rc.weights.text*score.TextScore + rc.weights.engagement*score.EngagementScore
+			if score.Score > 0 {
+				related = append(related, score)
+			}
+		}
+
+		sort.Slice(related, func(i, j int) bool {
+			if related[i].Score != related[j].Score {
+				return related[i].Score > related[j].Score
+			}
+			return related[i].RelatedBlogId > related[j].RelatedBlogId
+		})
+
+		if len(related) > rc.relatedSize {
+			related = related[:rc.relatedSize]
+		}
+
+		scores = append(scores, related...)
+	}
+
+	return scores
+}
+
+// termCounts counts the terms of the blog's title, description and content
+func termCounts(source storage.RelatedSource) map[string]int {
+
+	counts := make(map[string]int)
+
+	for _, text := range terms(source.BlogTitle) {
+		counts[text] += titleTermBoost
+	}
+
+	if source.BlogDescription != nil {
+		for _, text := range terms(*source.BlogDescription) {
+			counts[text] += descriptionTermBoost
+		}
+	}
+
+	for _, text := range terms(contentText(source.BlogContent)) {
+		counts[text]++
+	}
+
+	return counts
+}
+
+// contentText is the plain text of stored blog content, content written before it was validated is read as it is
+func contentText(content string) string {
+
+	doc, err := document.Parse([]byte(content))
+	if err != nil {
+		return content
+	}
+
+	return render.Text(*doc)
+}
+
+// topicSimilarities is the share of topics two blogs have in common, of all the topics of either,
+// for every pair of blogs with a topic in common
+func topicSimilarities(sources []storage.RelatedSource) map[int]map[int]float64 {
+
+	blogsByTopic := make(map[int64][]int)
+	topicCounts := make(map[int]int, len(sources))
+
+	for _, source := range sources {
+		topicCounts[source.Id] = len(source.BlogTopicIds)
+		for _, topicId := range source.BlogTopicIds {
+			blogsByTopic[topicId] = append(blogsByTopic[topicId], source.Id)
+		}
+	}
+
+	similarities := make(map[int]map[int]float64, len(sources))
+
+	for _, source := range sources {
+
+		shared := make(map[int]int)
+		for _, topicId := range source.BlogTopicIds {
+			for _, blogId := range blogsByTopic[topicId] {
+				if blogId != source.Id {
+					shared[blogId]++
+				}
+			}
+		}
+
+		scores := make(map[int]float64, len(shared))
+		for blogId, count := range shared {
+			scores[blogId] = float64(count) / float64(topicCounts[source.Id]+topicCounts[blogId]-count)
+		}
+		similarities[source.Id] = scores
+	}
+
+	return similarities
+}
@@ -0,0 +1,113 @@
+package related
+
+import (
+	"math"
+	"testing"
+
+	"github.com/dhruv15803/echo-blog-app/storage"
+	"github.com/lib/pq"
+)
+
+func TestTopicSimilarities(t *testing.T) {
+
+	similarities := topicSimilarities([]storage.RelatedSource{
+		{Id: 1, BlogTopicIds: pq.Int64Array{1, 2}},
+		{Id: 2, BlogTopicIds: pq.Int64Array{2, 3}},
+		{Id: 3, BlogTopicIds: pq.Int64Array{1, 2}},
+		{Id: 4, BlogTopicIds: pq.Int64Array{4}},
+		{Id: 5},
+	})
+
+	tests := []struct {
+		blogId  int
+		otherId int
+		want    float64
+	}{
+		// one shared of three topics in all
+		{blogId: 1, otherId: 2, want: 1.0 / 3},
+		{blogId: 2, otherId: 1, want: 1.0 / 3},
+		{blogId: 1, otherId: 3, want: 1},
+	}
+
+	for _, tt := range tests {
+		if got := similarities[tt.blogId][tt.otherId]; math.Abs(got-tt.want) > epsilon {
+			t.Errorf("topic similarity of blogs %d and %d = %v, want %v", tt.blogId, tt.otherId, got, tt.want)
+		}
+	}
+
+	if _, ok := similarities[1][4]; ok {
+		t.Errorf("topic similarities of blog 1 = %v, want no blog 4", similarities[1])
+	}
+
+	if len(similarities[4]) != 0 || len(similarities[5]) != 0 {
+		t.Errorf("topic similarities of blogs 4 and 5 = %v , %v, want none", similarities[4], similarities[5])
+	}
+}
+
+func TestScoreCoLikes(t *testing.T) {
+
+	rc := &Recommender{relatedSize: defaultRelatedSize, weights: defaultWeights}
+
+	// no shared topics or words, only readers that liked both
+	sources := []storage.RelatedSource{
+		{Id: 1, BlogTitle: "golang"},
+		{Id: 2, BlogTitle: "postgres"},
+		{Id: 3, BlogTitle: "kubernetes"},
+	}
+	coLikes := []storage.BlogCoLikes{
+		{BlogId: 1, OtherBlogId: 2, CoLikes: 2, Likes: 4, OtherLikes: 9},
+		{BlogId: 2, OtherBlogId: 1, CoLikes: 2, Likes: 9, OtherLikes: 4},
+	}
+
+	scores := rc.score(sources, coLikes)
+
+	if len(scores) != 2 {
+		t.Fatalf("score() = %v, want blogs 1 and 2 related to each other", scores)
+	}
+
+	for _, score := range scores {
+
+		// 2 / sqrt(4 * 9)
+		wantEngagement := 1.0 / 3
+
+		if math.Abs(score.EngagementScore-wantEngagement) > epsilon || score.TopicScore != 0 || score.TextScore != 0 {
+			t.Errorf("score of blogs %d and %d = %+v, want only an engagement score of %v", score.BlogId, score.RelatedBlogId, score, wantEngagement)
+		}
+
+		if math.Abs(score.Score-defaultWeights.engagement*wantEngagement) > epsilon {
+			t.Errorf("score of blogs %d and %d = %v, want %v", score.BlogId, score.RelatedBlogId, score.Score, defaultWeights.engagement*wantEngagement)
+		}
+	}
+}
+
+func TestScoreKeepsTheHighestScores(t *testing.T) {
+
+	rc := &Recommender{relatedSize: 2, weights: defaultWeights}
+
+	// blog 1 shares fewer topics with every next blog
+	sources := []storage.RelatedSource{
+		{Id: 1, BlogTopicIds: pq.Int64Array{1, 2, 3, 4}},
+		{Id: 2, BlogTopicIds: pq.Int64Array{1, 2, 3, 4}},
+		{Id: 3, BlogTopicIds: pq.Int64Array{1, 2, 3}},
+		{Id: 4, BlogTopicIds: pq.Int64Array{1, 2}},
+		{Id: 5, BlogTopicIds: pq.Int64Array{1}},
+	}
+
+	scores := rc.score(sources, nil)
+
+	relatedByBlog := make(map[int][]storage.RelatedBlogScore)
+	for _, score := range scores {
+		relatedByBlog[score.BlogId] = append(relatedByBlog[score.BlogId], score)
+	}
+
+	for _, source := range sources {
+		if len(relatedByBlog[source.Id]) != rc.relatedSize {
+			t.Errorf("blog %d has %d related blogs, want %d", source.Id, len(relatedByBlog[source.Id]), rc.relatedSize)
+		}
+	}
+
+	related := relatedByBlog[1]
+	if len(related) == 2 && (related[0].RelatedBlogId != 2 || related[1].RelatedBlogId != 3) {
+		t.Errorf("related blogs of blog 1 = %+v, want blogs 2 and 3 in that order", related)
+	}
+}
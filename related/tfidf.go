@@ -0,0 +1,146 @@
+package related
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// words shorter than this say little about what a blog is about
+	minTermLength = 3
+	// terms in more than this share of blogs are too common to tell blogs apart
+	maxDocumentFrequency = 0.5
+	// terms of a blog kept after weighing, the rest add noise and work but hardly change similarity
+	maxTermsPerBlog = 100
+	// a word of the title counts like this many words of the content, and a word of the description half that
+	titleTermBoost       = 3
+	descriptionTermBoost = 2
+)
+
+var stopWords = map[string]bool{
+	"about": true, "after": true, "again": true, "all": true, "also": true, "and": true, "any": true,
+	"are": true, "because": true, "been": true, "before": true, "being": true, "but": true, "can": true,
+	"could": true, "did": true, "does": true, "doing": true, "down": true, "each": true, "few": true,
+	"for": true, "from": true, "had": true, "has": true, "have": true, "her": true, "here": true,
+	"him": true, "his": true, "how": true, "into": true, "its": true, "just": true, "like": true,
+	"more": true, "most": true, "not": true, "now": true, "off": true, "once": true, "only": true,
+	"other": true, "our": true, "out": true, "over": true, "own": true, "same": true, "she": true,
+	"should": true, "some": true, "such": true, "than": true, "that": true, "the": true, "their": true,
+	"them": true, "then": true, "there": true, "these": true, "they": true, "this": true, "those": true,
+	"through": true, "too": true, "under": true, "until": true, "use": true, "very": true, "was": true,
+	"were": true, "what": true, "when": true, "where": true, "which": true, "while": true, "who": true,
+	"why": true, "will": true, "with": true, "would": true, "you": true, "your": true,
+}
+
+// weighted term of a blog
+type term struct {
+	text   string
+	weight float64
+}
+
+// terms splits text into lowercased words of letters and digits, leaving out short and common words
+func terms(text string) []string {
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	kept := words[:0]
+	for _, word := range words {
+		if len([]rune(word)) >= minTermLength && !stopWords[word] {
+			kept = append(kept, word)
+		}
+	}
+
+	return kept
+}
+
+// tfidfVectors weighs the term counts of every blog by how rare each term is across blogs, and
+// normalizes each blog's vector to unit length so a dot product of two vectors is their cosine similarity
+func tfidfVectors(counts map[int]map[string]int) map[int][]term {
+
+	documentFrequency := make(map[string]int)
+	for _, blogCounts := range counts {
+		for text := range blogCounts {
+			documentFrequency[text]++
+		}
+	}
+
+	blogs := float64(len(counts))
+	vectors := make(map[int][]term, len(counts))
+
+	for blogId, blogCounts := range counts {
+
+		var vector []term
+		for text, count := range blogCounts {
+			df := float64(documentFrequency[text])
+			if df/blogs > maxDocumentFrequency && blogs > 2 {
+				continue
+			}
+			vector = append(vector, term{text: text, weight: (1 + math.Log(float64(count))) * math.Log(1+blogs/df)})
+		}
+
+		sort.Slice(vector, func(i, j int) bool {
+			if vector[i].weight != vector[j].weight {
+				return vector[i].weight > vector[j].weight
+			}
+			return vector[i].text < vector[j].text
+		})
+
+		if len(vector) > maxTermsPerBlog {
+			vector = vector[:maxTermsPerBlog]
+		}
+
+		var norm float64
+		for _, t := range vector {
+			norm += t.weight * t.weight
+		}
+		norm = math.Sqrt(norm)
+
+		if norm == 0 {
+			continue
+		}
+
+		for i := range vector {
+			vector[i].weight /= norm
+		}
+
+		vectors[blogId] = vector
+	}
+
+	return vectors
+}
+
+// textSimilarities is the cosine similarity of every pair of blogs that share a term, by blog
+func textSimilarities(vectors map[int][]term) map[int]map[int]float64 {
+
+	type posting struct {
+		blogId int
+		weight float64
+	}
+
+	index := make(map[string][]posting)
+	for blogId, vector := range vectors {
+		for _, t := range vector {
+			index[t.text] = append(index[t.text], posting{blogId: blogId, weight: t.weight})
+		}
+	}
+
+	similarities := make(map[int]map[int]float64, len(vectors))
+
+	for blogId, vector := range vectors {
+		scores := make(map[int]float64)
+		for _, t := range vector {
+			for _, p := range index[t.text] {
+				if p.blogId != blogId {
+					scores[p.blogId] += t.weight * p.weight
+				}
+			}
+		}
+		similarities[blogId] = scores
+	}
+
+	return similarities
+}
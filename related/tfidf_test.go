@@ -0,0 +1,161 @@
+package related
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+const epsilon = 1e-9
+
+func TestTerms(t *testing.T) {
+
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{text: "The Go-lang's API, for 2 users!", want: []string{"lang", "api", "users"}},
+		// stop words are left out whatever their case
+		{text: "THIS is what YOU should KNOW about Postgres", want: []string{"know", "postgres"}},
+		// length is counted in letters, not bytes
+		{text: "Über façade 日本語 ab", want: []string{"über", "façade", "日本語"}},
+		{text: "http2 and x86 in 2026", want: []string{"http2", "x86", "2026"}},
+		{text: "a an to of", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		if got := terms(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("terms(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func vectorTerms(vector []term) map[string]float64 {
+
+	weights := make(map[string]float64, len(vector))
+	for _, t := range vector {
+		weights[t.text] = t.weight
+	}
+
+	return weights
+}
+
+func TestTfidfVectorsDropsCommonTerms(t *testing.T) {
+
+	vectors := tfidfVectors(map[int]map[string]int{
+		1: {"golang": 1, "postgres": 2, "common": 1},
+		2: {"golang": 1, "common": 1},
+		3: {"rust": 1, "common": 1},
+		4: {"only": 1, "common": 3},
+	})
+
+	for blogId, vector := range vectors {
+		// in 3 of 4 blogs
+		if _, ok := vectorTerms(vector)["common"]; ok {
+			t.Errorf("vector of blog %d has the term in most blogs", blogId)
+		}
+	}
+
+	// in half of the blogs, which is not more than the cutoff
+	if _, ok := vectorTerms(vectors[1])["golang"]; !ok {
+		t.Errorf("vector of blog 1 = %v, want it to have golang", vectors[1])
+	}
+
+	// a rarer term weighs more than one the blog has as often
+	if weights := vectorTerms(vectors[1]); weights["postgres"] <= weights["golang"] {
+		t.Errorf("vector of blog 1 = %v, want postgres to weigh more than golang", vectors[1])
+	}
+}
+
+func TestTfidfVectorsKeepsCommonTermsOfFewBlogs(t *testing.T) {
+
+	// with two blogs every shared term is in all of them, the cutoff would leave nothing to compare
+	vectors := tfidfVectors(map[int]map[string]int{
+		1: {"golang": 1},
+		2: {"golang": 1},
+	})
+
+	if len(vectors[1]) != 1 || len(vectors[2]) != 1 {
+		t.Errorf("tfidfVectors() = %v, want both blogs to keep golang", vectors)
+	}
+}
+
+func TestTfidfVectorsAreUnitLength(t *testing.T) {
+
+	many := make(map[string]int)
+	for i := 0; i < maxTermsPerBlog+50; i++ {
+		many[fmt.Sprintf("term%d", i)] = i%7 + 1
+	}
+
+	vectors := tfidfVectors(map[int]map[string]int{
+		1: {"golang": 3, "postgres": 1, "rust": 2},
+		2: {"golang": 1, "common": 2},
+		3: many,
+		4: {"common": 1},
+		5: {"common": 1},
+		6: {"common": 1},
+	})
+
+	for blogId, vector := range vectors {
+
+		var norm float64
+		for _, t := range vector {
+			norm += t.weight * t.weight
+		}
+
+		if math.Abs(norm-1) > epsilon {
+			t.Errorf("vector of blog %d has squared length %v, want 1", blogId, norm)
+		}
+	}
+
+	if len(vectors[3]) != maxTermsPerBlog {
+		t.Errorf("vector of blog 3 has %d terms, want %d", len(vectors[3]), maxTermsPerBlog)
+	}
+
+	// every term of these blogs is in 4 of 6 blogs, they have no vector
+	for _, blogId := range []int{4, 5, 6} {
+		if _, ok := vectors[blogId]; ok {
+			t.Errorf("blog %d has a vector of only common terms", blogId)
+		}
+	}
+}
+
+func TestTextSimilarities(t *testing.T) {
+
+	vectors := map[int][]term{
+		1: {{text: "golang", weight: 0.6}, {text: "postgres", weight: 0.8}},
+		2: {{text: "golang", weight: 0.6}, {text: "postgres", weight: 0.8}},
+		3: {{text: "golang", weight: 1}},
+		4: {{text: "rust", weight: 1}},
+	}
+
+	similarities := textSimilarities(vectors)
+
+	tests := []struct {
+		blogId  int
+		otherId int
+		want    float64
+	}{
+		{blogId: 1, otherId: 2, want: 1},
+		{blogId: 1, otherId: 3, want: 0.6},
+		{blogId: 3, otherId: 1, want: 0.6},
+	}
+
+	for _, tt := range tests {
+		if got := similarities[tt.blogId][tt.otherId]; math.Abs(got-tt.want) > epsilon {
+			t.Errorf("similarity of blogs %d and %d = %v, want %v", tt.blogId, tt.otherId, got, tt.want)
+		}
+	}
+
+	// blogs without a shared term, and the blog itself, are left out
+	for _, otherId := range []int{1, 4} {
+		if _, ok := similarities[1][otherId]; ok {
+			t.Errorf("similarities of blog 1 = %v, want no blog %d", similarities[1], otherId)
+		}
+	}
+
+	if len(similarities[4]) != 0 {
+		t.Errorf("similarities of blog 4 = %v, want none", similarities[4])
+	}
+}
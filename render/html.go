@@ -0,0 +1,271 @@
+package render
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/dhruv15803/echo-blog-app/document"
+)
+
+// HTML renders doc as an html fragment. all text and attributes are escaped and only urls with
+// an allowed scheme are written, so the result is safe to embed as is. headings get an id and
+// a link to it, code blocks a language-<name> class for client side syntax highlighters
+func HTML(doc document.Node) string {
+
+	r := htmlRenderer{}
+	r.blocks(doc.Content, false)
+
+	return r.b.String()
+}
+
+type htmlRenderer struct {
+	b        strings.Builder
+	headings headingIds
+}
+
+func (r *htmlRenderer) blocks(nodes []document.Node, tight bool) {
+	for _, node := range nodes {
+		r.block(node, tight)
+	}
+}
+
+func (r *htmlRenderer) block(node document.Node, tight bool) {
+
+	switch node.Type {
+	case document.ParagraphNode:
+		// paragraphs of tight list items are not wrapped
+		if tight {
+			r.inline(node.Content)
+			return
+		}
+		r.b.WriteString("<p>")
+		r.inline(node.Content)
+		r.b.WriteString("</p>\n")
+
+	case document.HeadingNode:
+		level, ok := node.IntAttr("level")
+		if !ok || level < 1 || level > 6 {
+			level = 2
+		}
+		id := html.EscapeString(r.headings.next(node))
+		fmt.Fprintf(&r.b, `<h%d id="%s"><a class="heading-anchor" href="#%s" aria-hidden="true">#</a>`, level, id, id)
+		r.inline(node.Content)
+		fmt.Fprintf(&r.b, "</h%d>\n", level)
+
+	case document.BlockquoteNode:
+		r.b.WriteString("<blockquote>\n")
+		r.blocks(node.Content, false)
+		r.b.WriteString("</blockquote>\n")
+
+	case document.BulletListNode:
+		r.b.WriteString("<ul>\n")
+		r.listItems(node)
+		r.b.WriteString("</ul>\n")
+
+	case document.OrderedListNode:
+		if start := listStart(node); start != 1 {
+			fmt.Fprintf(&r.b, "<ol start=\"%d\">\n", start)
+		} else {
+			r.b.WriteString("<ol>\n")
+		}
+		r.listItems(node)
+		r.b.WriteString("</ol>\n")
+
+	case document.TaskListNode:
+		r.b.WriteString("<ul class=\"task-list\">\n")
+		r.listItems(node)
+		r.b.WriteString("</ul>\n")
+
+	case document.TableNode:
+		r.table(node)
+
+	case document.CodeBlockNode:
+		r.b.WriteString("<pre><code")
+		if language := node.StringAttr("language"); language != "" {
+			fmt.Fprintf(&r.b, ` class="language-%s"`, html.EscapeString(strings.ToLower(language)))
+		}
+		r.b.WriteString(">")
+		r.b.WriteString(html.EscapeString(node.TextContent()))
+		r.b.WriteString("</code></pre>\n")
+
+	case document.HorizontalRuleNode:
+		r.b.WriteString("<hr>\n")
+
+	case document.ImageNode:
+		src, ok := safeUrl(node.StringAttr("src"), document.ImageSchemes, false)
+		if !ok {
+			return
+		}
+		fmt.Fprintf(&r.b, `<img src="%s" alt="%s"`, html.EscapeString(src), html.EscapeString(node.StringAttr("alt")))
+		if title := node.StringAttr("title"); title != "" {
+			fmt.Fprintf(&r.b, ` title="%s"`, html.EscapeString(title))
+		}
+		r.b.WriteString(" loading=\"lazy\">\n")
+
+	case document.EmbedNode:
+		// embeds are links, clients that support the provider turn them into players
+		embedUrl, ok := safeUrl(node.StringAttr("url"), []string{"https"}, false)
+		if !ok {
+			return
+		}
+		escaped := html.EscapeString(embedUrl)
+		fmt.Fprintf(&r.b, `<figure class="embed" data-embed-url="%s"><a href="%s" rel="nofollow noopener noreferrer" target="_blank">%s</a></figure>`+"\n", escaped, escaped, escaped)
+	}
+}
+
+func (r *htmlRenderer) listItems(list document.Node) {
+
+	tight := isTight(list)
+
+	for _, item := range list.Content {
+		if item.Type == document.TaskItemNode {
+			r.b.WriteString(`<li class="task-list-item"><input type="checkbox" disabled`)
+			if item.BoolAttr("checked") {
+				r.b.WriteString(" checked")
+			}
+			r.b.WriteString("> ")
+		} else {
+			r.b.WriteString("<li>")
+		}
+		if !tight {
+			r.b.WriteString("\n")
+		}
+		r.blocks(item.Content, tight)
+		r.b.WriteString("</li>\n")
+	}
+}
+
+// table writes the first row as the table head and the others as its body
+func (r *htmlRenderer) table(table document.Node) {
+
+	r.b.WriteString("<table>\n")
+
+	for i, row := range table.Content {
+
+		if i == 0 {
+			r.b.WriteString("<thead>\n")
+		} else if i == 1 {
+			r.b.WriteString("<tbody>\n")
+		}
+
+		r.b.WriteString("<tr>\n")
+		for _, cell := range row.Content {
+			tag := "td"
+			if cell.Type == document.TableHeaderNode {
+				tag = "th"
+			}
+			r.b.WriteString("<" + tag)
+			if align := cell.StringAttr("align"); slices.Contains(document.TableAlignments, align) {
+				fmt.Fprintf(&r.b, ` style="text-align: %s"`, align)
+			}
+			r.b.WriteString(">")
+			r.inline(cell.Content)
+			r.b.WriteString("</" + tag + ">\n")
+		}
+		r.b.WriteString("</tr>\n")
+
+		if i == 0 {
+			r.b.WriteString("</thead>\n")
+		}
+	}
+
+	if len(table.Content) > 1 {
+		r.b.WriteString("</tbody>\n")
+	}
+
+	r.b.WriteString("</table>\n")
+}
+
+func (r *htmlRenderer) inline(nodes []document.Node) {
+
+	for _, node := range nodes {
+
+		if node.Type == document.HardBreakNode {
+			r.b.WriteString("<br>\n")
+			continue
+		}
+
+		if node.Type != document.TextNode {
+			continue
+		}
+
+		var closing []string
+
+		for _, markType := range markOrder {
+
+			mark, ok := findMark(node.Marks, markType)
+			if !ok {
+				continue
+			}
+
+			open, close := htmlMarkTags(mark)
+			if open == "" {
+				continue
+			}
+
+			r.b.WriteString(open)
+			closing = append(closing, close)
+		}
+
+		r.b.WriteString(html.EscapeString(node.Text))
+
+		for _, close := range slices.Backward(closing) {
+			r.b.WriteString(close)
+		}
+	}
+}
+
+func htmlMarkTags(mark document.Mark) (string, string) {
+
+	switch mark.Type {
+	case document.BoldMark:
+		return "<strong>", "</strong>"
+	case document.ItalicMark:
+		return "<em>", "</em>"
+	case document.UnderlineMark:
+		return "<u>", "</u>"
+	case document.StrikeMark:
+		return "<s>", "</s>"
+	case document.CodeMark:
+		return "<code>", "</code>"
+	case document.LinkMark:
+		href, ok := safeUrl(mark.StringAttr("href"), document.LinkSchemes, true)
+		if !ok {
+			return "", ""
+		}
+		open := fmt.Sprintf(`<a href="%s"`, html.EscapeString(href))
+		if title := mark.StringAttr("title"); title != "" {
+			open += fmt.Sprintf(` title="%s"`, html.EscapeString(title))
+		}
+		// anchors within the page are the only links that are not external
+		if !strings.HasPrefix(href, "#") {
+			open += ` rel="nofollow noopener noreferrer"`
+		}
+		return open + ">", "</a>"
+	}
+
+	return "", ""
+}
+
+// safeUrl returns raw if it is an absolute url with one of schemes, or a relative one when allowRelative is set
+func safeUrl(raw string, schemes []string, allowRelative bool) (string, bool) {
+
+	if raw == "" || strings.ContainsFunc(raw, func(r rune) bool { return r <= ' ' || r == 0x7f }) {
+		return "", false
+	}
+
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+
+	if parsed.Scheme == "" {
+		relative := (strings.HasPrefix(raw, "/") && !strings.HasPrefix(raw, "//")) || strings.HasPrefix(raw, "#")
+		return raw, allowRelative && relative
+	}
+
+	return raw, slices.Contains(schemes, strings.ToLower(parsed.Scheme))
+}
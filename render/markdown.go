@@ -0,0 +1,385 @@
+package render
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/dhruv15803/echo-blog-app/document"
+)
+
+// Markdown renders doc as CommonMark, with GFM strikethrough. underline has no markdown syntax
+// and is written as inline html, embeds become autolinks
+func Markdown(doc document.Node) string {
+
+	r := markdownRenderer{}
+
+	return strings.TrimRight(r.blocks(doc.Content, false), "\n") + "\n"
+}
+
+type markdownRenderer struct{}
+
+func (r *markdownRenderer) blocks(nodes []document.Node, tight bool) string {
+
+	separator := "\n\n"
+	if tight {
+		separator = "\n"
+	}
+
+	var parts []string
+	for _, node := range nodes {
+		if part := r.block(node); part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	return strings.Join(parts, separator)
+}
+
+func (r *markdownRenderer) block(node document.Node) string {
+
+	switch node.Type {
+	case document.ParagraphNode:
+		return r.inline(node.Content)
+
+	case document.HeadingNode:
+		level, ok := node.IntAttr("level")
+		if !ok || level < 1 || level > 6 {
+			level = 2
+		}
+		// atx headings are a single line
+		return strings.Repeat("#", level) + " " + strings.ReplaceAll(r.inline(node.Content), "\\\n", " ")
+
+	case document.BlockquoteNode:
+		return prefixLines(r.blocks(node.Content, false), "> ", "> ")
+
+	case document.BulletListNode, document.OrderedListNode, document.TaskListNode:
+		tight := isTight(node)
+		start := listStart(node)
+
+		items := make([]string, 0, len(node.Content))
+		for i, item := range node.Content {
+			marker := "- "
+			if node.Type == document.OrderedListNode {
+				marker = strconv.Itoa(start+i) + ". "
+			}
+			indent := strings.Repeat(" ", len(marker))
+			if node.Type == document.TaskListNode {
+				marker += taskMarker(item)
+			}
+			items = append(items, prefixLines(r.blocks(item.Content, tight), marker, indent))
+		}
+
+		if tight {
+			return strings.Join(items, "\n")
+		}
+		return strings.Join(items, "\n\n")
+
+	case document.CodeBlockNode:
+		code := strings.TrimSuffix(node.TextContent(), "\n")
+		fence := strings.Repeat("`", max(3, longestRun(code, '`')+1))
+		return fence + node.StringAttr("language") + "\n" + code + "\n" + fence
+
+	case document.HorizontalRuleNode:
+		return "---"
+
+	case document.ImageNode:
+		return "![" + escapeMarkdown(node.StringAttr("alt"), false) + "](" + markdownDestination(node.StringAttr("src"), node.StringAttr("title")) + ")"
+
+	case document.EmbedNode:
+		return "<" + node.StringAttr("url") + ">"
+
+	case document.TableNode:
+		return r.table(node)
+	}
+
+	return ""
+}
+
+func taskMarker(item document.Node) string {
+
+	if item.BoolAttr("checked") {
+		return "[x] "
+	}
+
+	return "[ ] "
+}
+
+// table writes a gfm table, the delimiter row after the first row carries the alignment of every column
+func (r *markdownRenderer) table(table document.Node) string {
+
+	var lines []string
+
+	for i, row := range table.Content {
+
+		cells := make([]string, len(row.Content))
+		for j, cell := range row.Content {
+			cells[j] = tableCell(r.inline(cell.Content))
+		}
+		lines = append(lines, "| "+strings.Join(cells, " | ")+" |")
+
+		if i > 0 {
+			continue
+		}
+
+		delimiters := make([]string, len(row.Content))
+		for j, cell := range row.Content {
+			switch cell.StringAttr("align") {
+			case "left":
+				delimiters[j] = ":---"
+			case "center":
+				delimiters[j] = ":---:"
+			case "right":
+				delimiters[j] = "---:"
+			default:
+				delimiters[j] = "---"
+			}
+		}
+		lines = append(lines, "| "+strings.Join(delimiters, " | ")+" |")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// tableCell keeps a rendered cell on one line, hard breaks become <br>, and escapes the
+// pipes of code spans which would otherwise end the cell
+func tableCell(content string) string {
+
+	content = strings.ReplaceAll(content, "\\\n", "<br>")
+
+	var b strings.Builder
+	backslashes := 0
+
+	for _, c := range content {
+		if c == '|' && backslashes%2 == 0 {
+			b.WriteByte('\\')
+		}
+		if c == '\\' {
+			backslashes++
+		} else {
+			backslashes = 0
+		}
+		b.WriteRune(c)
+	}
+
+	return b.String()
+}
+
+// inline writes text nodes keeping a stack of open marks like an html renderer would, so a mark spanning
+// several text nodes is opened once. whitespace is moved outside of delimiters, "** bold**" is not bold
+func (r *markdownRenderer) inline(nodes []document.Node) string {
+
+	var b strings.Builder
+	var open []document.Mark
+	pendingSpace := ""
+	atLineStart := true
+
+	closeFrom := func(i int) {
+		for j := len(open) - 1; j >= i; j-- {
+			b.WriteString(markdownClosing(open[j]))
+		}
+		open = open[:i]
+	}
+
+	for _, node := range nodes {
+
+		if node.Type == document.HardBreakNode {
+			closeFrom(0)
+			b.WriteString("\\\n")
+			pendingSpace = ""
+			atLineStart = true
+			continue
+		}
+
+		if node.Type != document.TextNode {
+			continue
+		}
+
+		text := strings.ReplaceAll(node.Text, "\n", " ")
+		core := strings.TrimSpace(text)
+		if core == "" {
+			pendingSpace += text
+			continue
+		}
+
+		leading := text[:strings.Index(text, core)]
+		trailing := text[len(leading)+len(core):]
+
+		marks := orderedMarks(node.Marks)
+
+		// close every open mark from the first one this node does not have, the marks still open stay outermost
+		keep := 0
+		for keep < len(open) && slices.ContainsFunc(marks, func(mark document.Mark) bool { return sameMark(mark, open[keep]) }) {
+			keep++
+		}
+		closeFrom(keep)
+
+		b.WriteString(pendingSpace + leading)
+		if pendingSpace+leading != "" {
+			atLineStart = false
+		}
+		pendingSpace = trailing
+
+		for _, mark := range marks {
+			if slices.ContainsFunc(open, func(opened document.Mark) bool { return sameMark(mark, opened) }) {
+				continue
+			}
+			b.WriteString(markdownOpening(mark))
+			open = append(open, mark)
+			atLineStart = false
+		}
+
+		if _, isCode := findMark(node.Marks, document.CodeMark); isCode {
+			b.WriteString(codeSpan(core))
+		} else {
+			b.WriteString(escapeMarkdown(core, atLineStart))
+		}
+		atLineStart = false
+	}
+
+	closeFrom(0)
+	b.WriteString(pendingSpace)
+
+	return b.String()
+}
+
+// orderedMarks returns the marks that have delimiters, outermost first. code is written as a code span instead
+func orderedMarks(marks []document.Mark) []document.Mark {
+
+	var ordered []document.Mark
+
+	for _, markType := range markOrder {
+		if mark, ok := findMark(marks, markType); ok && markType != document.CodeMark {
+			ordered = append(ordered, mark)
+		}
+	}
+
+	return ordered
+}
+
+func sameMark(a, b document.Mark) bool {
+	return a.Type == b.Type && a.StringAttr("href") == b.StringAttr("href") && a.StringAttr("title") == b.StringAttr("title")
+}
+
+func markdownOpening(mark document.Mark) string {
+
+	switch mark.Type {
+	case document.BoldMark:
+		return "**"
+	case document.ItalicMark:
+		return "*"
+	case document.StrikeMark:
+		return "~~"
+	case document.UnderlineMark:
+		return "<u>"
+	case document.LinkMark:
+		return "["
+	}
+
+	return ""
+}
+
+func markdownClosing(mark document.Mark) string {
+
+	switch mark.Type {
+	case document.UnderlineMark:
+		return "</u>"
+	case document.LinkMark:
+		return "](" + markdownDestination(mark.StringAttr("href"), mark.StringAttr("title")) + ")"
+	}
+
+	return markdownOpening(mark)
+}
+
+// markdownDestination is the (url "title") part of a link or image
+func markdownDestination(destination string, title string) string {
+
+	if strings.ContainsAny(destination, "()<> ") {
+		destination = "<" + strings.NewReplacer("<", "%3C", ">", "%3E").Replace(destination) + ">"
+	}
+
+	if title != "" {
+		destination += ` "` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(title) + `"`
+	}
+
+	return destination
+}
+
+func codeSpan(code string) string {
+
+	fence := strings.Repeat("`", longestRun(code, '`')+1)
+
+	// a space on both sides keeps backticks at the edges apart from the fence, and is stripped again
+	if strings.HasPrefix(code, "`") || strings.HasSuffix(code, "`") {
+		return fence + " " + code + " " + fence
+	}
+
+	return fence + code + fence
+}
+
+var markdownEntityPattern = regexp.MustCompile(`&(#?[a-zA-Z0-9]+;)`)
+
+var orderedListMarkerPattern = regexp.MustCompile(`^(\d{1,9})([.)])`)
+
+// escapeMarkdown backslash escapes characters that could start markdown syntax. characters that
+// only have a meaning at the start of a line (headings, lists, quotes) are escaped there
+func escapeMarkdown(text string, atLineStart bool) string {
+
+	var b strings.Builder
+
+	for _, c := range text {
+		if strings.ContainsRune("\\`*_[]<>~|#", c) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+
+	escaped := markdownEntityPattern.ReplaceAllString(b.String(), `\&$1`)
+
+	if atLineStart {
+		if strings.HasPrefix(escaped, "-") || strings.HasPrefix(escaped, "+") || strings.HasPrefix(escaped, "=") {
+			escaped = "\\" + escaped
+		}
+		escaped = orderedListMarkerPattern.ReplaceAllString(escaped, `$1\$2`)
+	}
+
+	return escaped
+}
+
+// prefixLines puts first before the first line of text and rest before every other non empty line
+func prefixLines(text string, first string, rest string) string {
+
+	lines := strings.Split(text, "\n")
+
+	for i, line := range lines {
+		prefix := rest
+		if i == 0 {
+			prefix = first
+		}
+		if line == "" {
+			lines[i] = strings.TrimRightFunc(prefix, unicode.IsSpace)
+			continue
+		}
+		lines[i] = prefix + line
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func longestRun(text string, c rune) int {
+
+	longest, current := 0, 0
+
+	for _, r := range text {
+		if r == c {
+			current++
+			longest = max(longest, current)
+		} else {
+			current = 0
+		}
+	}
+
+	return longest
+}
@@ -0,0 +1,125 @@
+// Package render turns blog content documents into html, markdown and plain text
+// for the places they are shown outside the editor, like mails, feeds, search and previews.
+// documents are expected to be valid (document.Validate), urls are still checked again
+// before they are written to html
+package render
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/dhruv15803/echo-blog-app/document"
+)
+
+type Format string
+
+const (
+	HTMLFormat     Format = "html"
+	MarkdownFormat Format = "md"
+	TextFormat     Format = "text"
+)
+
+var Formats = []Format{HTMLFormat, MarkdownFormat, TextFormat}
+
+func Render(doc document.Node, format Format) string {
+
+	switch format {
+	case HTMLFormat:
+		return HTML(doc)
+	case MarkdownFormat:
+		return Markdown(doc)
+	default:
+		return Text(doc)
+	}
+}
+
+// Slugify lowercases text and joins its words with dashes, keeping letters and digits of any script
+func Slugify(text string) string {
+
+	var slug strings.Builder
+	pendingDash := false
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if pendingDash && slug.Len() > 0 {
+				slug.WriteByte('-')
+			}
+			pendingDash = false
+			slug.WriteRune(r)
+		case unicode.IsSpace(r) || r == '-' || r == '_':
+			pendingDash = true
+		}
+	}
+
+	return slug.String()
+}
+
+// headingIds gives every heading a unique anchor id from its text, in document order
+type headingIds struct {
+	seen map[string]int
+}
+
+func (h *headingIds) next(heading document.Node) string {
+
+	if h.seen == nil {
+		h.seen = map[string]int{}
+	}
+
+	id := Slugify(heading.TextContent())
+	if id == "" {
+		id = "section"
+	}
+
+	count := h.seen[id]
+	h.seen[id] = count + 1
+
+	if count > 0 {
+		return id + "-" + strconv.Itoa(count)
+	}
+
+	return id
+}
+
+// markOrder is the nesting of marks from outermost to innermost
+var markOrder = []document.MarkType{
+	document.LinkMark,
+	document.BoldMark,
+	document.ItalicMark,
+	document.StrikeMark,
+	document.UnderlineMark,
+	document.CodeMark,
+}
+
+func findMark(marks []document.Mark, markType document.MarkType) (document.Mark, bool) {
+
+	for _, mark := range marks {
+		if mark.Type == markType {
+			return mark, true
+		}
+	}
+
+	return document.Mark{}, false
+}
+
+// isTight reports whether every item of a list is a single paragraph, those are rendered without paragraph breaks
+func isTight(list document.Node) bool {
+
+	for _, item := range list.Content {
+		if len(item.Content) != 1 || item.Content[0].Type != document.ParagraphNode {
+			return false
+		}
+	}
+
+	return true
+}
+
+func listStart(list document.Node) int {
+
+	if start, ok := list.IntAttr("start"); ok {
+		return start
+	}
+
+	return 1
+}
@@ -0,0 +1,305 @@
+package render
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dhruv15803/echo-blog-app/document"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files with the current output")
+
+type attrs = map[string]any
+
+func doc(content ...document.Node) document.Node {
+	return document.Node{Type: document.DocNode, Content: content}
+}
+
+func node(nodeType document.NodeType, nodeAttrs attrs, content ...document.Node) document.Node {
+	return document.Node{Type: nodeType, Attrs: nodeAttrs, Content: content}
+}
+
+func text(value string, marks ...document.Mark) document.Node {
+	return document.Node{Type: document.TextNode, Text: value, Marks: marks}
+}
+
+func mark(markType document.MarkType) document.Mark {
+	return document.Mark{Type: markType}
+}
+
+func link(href string, title string) document.Mark {
+
+	markAttrs := attrs{"href": href}
+	if title != "" {
+		markAttrs["title"] = title
+	}
+
+	return document.Mark{Type: document.LinkMark, Attrs: markAttrs}
+}
+
+func paragraph(content ...document.Node) document.Node {
+	return node(document.ParagraphNode, nil, content...)
+}
+
+func heading(level any, content ...document.Node) document.Node {
+	return node(document.HeadingNode, attrs{"level": level}, content...)
+}
+
+func listItem(content ...document.Node) document.Node {
+	return node(document.ListItemNode, nil, content...)
+}
+
+func taskItem(checked bool, content ...document.Node) document.Node {
+	return node(document.TaskItemNode, attrs{"checked": checked}, content...)
+}
+
+var hardBreak = document.Node{Type: document.HardBreakNode}
+
+// documents are built as they are decoded from json, numbers are float64
+var renderTests = []struct {
+	name string
+	doc  document.Node
+}{
+	{
+		name: "marks",
+		doc: doc(
+			paragraph(
+				text("plain "),
+				text("bold", mark(document.BoldMark)),
+				text(" "),
+				text("italic", mark(document.ItalicMark)),
+				text(" "),
+				text("underline", mark(document.UnderlineMark)),
+				text(" "),
+				text("strike", mark(document.StrikeMark)),
+				text(" "),
+				text("code", mark(document.CodeMark)),
+				text(" "),
+				text("link", link("https://example.com/a?b=1&c=2", "a title")),
+				text("."),
+			),
+			paragraph(
+				text("bold ", mark(document.BoldMark)),
+				text("and italic", mark(document.BoldMark), mark(document.ItalicMark)),
+				text(" over nodes", mark(document.BoldMark)),
+				hardBreak,
+				text("every mark", mark(document.CodeMark), mark(document.UnderlineMark), mark(document.StrikeMark), mark(document.ItalicMark), mark(document.BoldMark), link("/blogs/1", "")),
+				text(" and "),
+				text("`ticks`", mark(document.CodeMark)),
+			),
+		),
+	},
+	{
+		name: "headings",
+		doc: doc(
+			heading(float64(1), text("Getting started")),
+			heading(float64(2), text("Getting "), text("started", mark(document.ItalicMark))),
+			heading(float64(3), text("Ünïcode & émojis 🚀")),
+			heading(float64(4), text("line"), hardBreak, text("break")),
+			heading(float64(5), text("!!!")),
+			heading(float64(6), text("six")),
+			heading(float64(9), text("invalid level")),
+		),
+	},
+	{
+		name: "lists",
+		doc: doc(
+			node(document.BulletListNode, nil,
+				listItem(paragraph(text("one"))),
+				listItem(paragraph(text("two")), node(document.BulletListNode, nil,
+					listItem(paragraph(text("nested"))),
+				)),
+			),
+			node(document.OrderedListNode, attrs{"start": float64(3)},
+				listItem(paragraph(text("three"))),
+				listItem(paragraph(text("four"))),
+			),
+			node(document.OrderedListNode, nil,
+				listItem(paragraph(text("loose")), paragraph(text("second paragraph"))),
+				listItem(paragraph(text("item"))),
+			),
+			node(document.TaskListNode, nil,
+				taskItem(true, paragraph(text("done"))),
+				taskItem(false, paragraph(text("todo"))),
+			),
+		),
+	},
+	{
+		name: "blocks",
+		doc: doc(
+			node(document.BlockquoteNode, nil,
+				paragraph(text("quoted")),
+				node(document.BulletListNode, nil, listItem(paragraph(text("quoted item")))),
+			),
+			node(document.CodeBlockNode, attrs{"language": "Go"}, text("func main() {\n\tfmt.Println(\"<hi>\")\n}\n")),
+			node(document.CodeBlockNode, nil, text("```\nfenced\n```")),
+			node(document.HorizontalRuleNode, nil),
+			node(document.ImageNode, attrs{"src": "https://cdn.example.com/a(1).png", "alt": "an [image]", "title": `"quoted"`}),
+			node(document.ImageNode, attrs{"src": "https://cdn.example.com/b.png"}),
+			node(document.EmbedNode, attrs{"url": "https://www.youtube.com/watch?v=abc&t=1"}),
+		),
+	},
+	{
+		name: "table",
+		doc: doc(
+			node(document.TableNode, nil,
+				node(document.TableRowNode, nil,
+					node(document.TableHeaderNode, attrs{"align": "left"}, text("name")),
+					node(document.TableHeaderNode, attrs{"align": "center"}, text("value", mark(document.BoldMark))),
+					node(document.TableHeaderNode, attrs{"align": "right"}, text("count")),
+					node(document.TableHeaderNode, nil, text("notes")),
+				),
+				node(document.TableRowNode, nil,
+					node(document.TableCellNode, nil, text("a | b")),
+					node(document.TableCellNode, nil, text("x|y", mark(document.CodeMark))),
+					node(document.TableCellNode, attrs{"align": "right"}, text("3")),
+					node(document.TableCellNode, nil, text("two"), hardBreak, text("lines")),
+				),
+			),
+			node(document.TableNode, nil,
+				node(document.TableRowNode, nil, node(document.TableHeaderNode, nil, text("header only"))),
+			),
+		),
+	},
+	{
+		name: "escaping",
+		doc: doc(
+			paragraph(text(`<script>alert("x")</script> & &amp; &#39; 'quotes'`)),
+			paragraph(text(`*not bold* _not italic_ [not](a link) ~~no~~ a|b \ back`)),
+			paragraph(text("# not a heading")),
+			paragraph(text("- not a list")),
+			paragraph(text("1. not ordered")),
+			paragraph(text("+ = > lines"), hardBreak, text("- after a break")),
+			heading(float64(2), text("<b>tags</b> in a heading")),
+			node(document.CodeBlockNode, attrs{"language": `x"><script>`}, text("<b>&amp;</b>")),
+			paragraph(text("title", link("https://example.com/", `"><script>alert(1)</script>`))),
+		),
+	},
+	{
+		name: "urls",
+		doc: doc(
+			paragraph(
+				text("https", link("https://example.com/", "")),
+				text(" "),
+				text("mailto", link("mailto:someone@example.com", "")),
+				text(" "),
+				text("relative", link("/blogs/42", "")),
+				text(" "),
+				text("anchor", link("#getting-started", "")),
+			),
+			paragraph(
+				text("javascript", link("javascript:alert(1)", "")),
+				text(" "),
+				text("mixed case", link("JaVaScRiPt:alert(1)", "")),
+				text(" "),
+				text("tab", link("java\tscript:alert(1)", "")),
+				text(" "),
+				text("data", link("data:text/html,<script>alert(1)</script>", "")),
+				text(" "),
+				text("protocol relative", link("//evil.example.com", "")),
+				text(" "),
+				text("vbscript", link("vbscript:msgbox(1)", "")),
+			),
+			node(document.ImageNode, attrs{"src": "data:image/png;base64,AAAA", "alt": "data image"}),
+			node(document.ImageNode, attrs{"src": "javascript:alert(1)", "alt": "script image"}),
+			node(document.ImageNode, attrs{"src": "/relative.png", "alt": "relative image"}),
+			node(document.EmbedNode, attrs{"url": "http://www.youtube.com/watch?v=abc"}),
+			node(document.EmbedNode, attrs{"url": "javascript:alert(1)"}),
+		),
+	},
+	{
+		name: "from_text",
+		doc:  document.FromText("first line\r\nsecond line\n\n\n\nnext *paragraph*"),
+	},
+}
+
+func TestRender(t *testing.T) {
+
+	for _, tt := range renderTests {
+		for _, format := range Formats {
+			t.Run(tt.name+"/"+string(format), func(t *testing.T) {
+
+				got := Render(tt.doc, format)
+				golden := filepath.Join("testdata", tt.name+"."+string(format)+".golden")
+
+				if *update {
+					if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+						t.Fatal(err)
+					}
+					return
+				}
+
+				want, err := os.ReadFile(golden)
+				if err != nil {
+					t.Fatalf("failed to read golden file, run go test ./render -update to create it: %v", err)
+				}
+
+				if got != string(want) {
+					t.Errorf("Render(%s) does not match %s\ngot:\n%s\nwant:\n%s", format, golden, got, want)
+				}
+			})
+		}
+	}
+}
+
+// TestRenderCoversEveryType keeps the golden files in step with the document schema
+func TestRenderCoversEveryType(t *testing.T) {
+
+	nodeTypes := []document.NodeType{
+		document.DocNode, document.ParagraphNode, document.HeadingNode, document.BlockquoteNode,
+		document.BulletListNode, document.OrderedListNode, document.ListItemNode, document.TaskListNode,
+		document.TaskItemNode, document.CodeBlockNode, document.HorizontalRuleNode, document.ImageNode,
+		document.EmbedNode, document.TableNode, document.TableRowNode, document.TableHeaderNode,
+		document.TableCellNode, document.TextNode, document.HardBreakNode,
+	}
+	markTypes := []document.MarkType{
+		document.BoldMark, document.ItalicMark, document.UnderlineMark,
+		document.StrikeMark, document.CodeMark, document.LinkMark,
+	}
+
+	seenNodes := map[document.NodeType]bool{}
+	seenMarks := map[document.MarkType]bool{}
+
+	for _, tt := range renderTests {
+		tt.doc.Walk(func(node document.Node) {
+			seenNodes[node.Type] = true
+			for _, mark := range node.Marks {
+				seenMarks[mark.Type] = true
+			}
+		})
+	}
+
+	for _, nodeType := range nodeTypes {
+		if !seenNodes[nodeType] {
+			t.Errorf("no render test has a %s node", nodeType)
+		}
+	}
+
+	for _, markType := range markTypes {
+		if !seenMarks[markType] {
+			t.Errorf("no render test has a %s mark", markType)
+		}
+	}
+}
+
+func TestSlugify(t *testing.T) {
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "Hello, World!", want: "hello-world"},
+		{text: "  leading and trailing  ", want: "leading-and-trailing"},
+		{text: "snake_case and-dashes", want: "snake-case-and-dashes"},
+		{text: "Ünïcode 日本語 2026", want: "ünïcode-日本語-2026"},
+		{text: "!!!", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := Slugify(tt.text); got != tt.want {
+			t.Errorf("Slugify(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
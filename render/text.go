@@ -0,0 +1,107 @@
+package render
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/dhruv15803/echo-blog-app/document"
+)
+
+// Text renders doc as plain text, blocks are separated by a blank line and marks are dropped.
+// images are written as their alt text and embeds as their url
+func Text(doc document.Node) string {
+
+	text := strings.TrimSpace(textBlocks(doc.Content, false))
+	if text == "" {
+		return ""
+	}
+
+	return text + "\n"
+}
+
+func textBlocks(nodes []document.Node, tight bool) string {
+
+	separator := "\n\n"
+	if tight {
+		separator = "\n"
+	}
+
+	var parts []string
+	for _, node := range nodes {
+		if part := textBlock(node); part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	return strings.Join(parts, separator)
+}
+
+func textBlock(node document.Node) string {
+
+	switch node.Type {
+	case document.ParagraphNode, document.HeadingNode:
+		return textInline(node.Content)
+
+	case document.BlockquoteNode:
+		return textBlocks(node.Content, false)
+
+	case document.BulletListNode, document.OrderedListNode, document.TaskListNode:
+		tight := isTight(node)
+		start := listStart(node)
+
+		items := make([]string, 0, len(node.Content))
+		for i, item := range node.Content {
+			marker := "- "
+			if node.Type == document.OrderedListNode {
+				marker = strconv.Itoa(start+i) + ". "
+			}
+			indent := strings.Repeat(" ", len(marker))
+			if node.Type == document.TaskListNode {
+				marker += taskMarker(item)
+			}
+			items = append(items, prefixLines(textBlocks(item.Content, tight), marker, indent))
+		}
+
+		if tight {
+			return strings.Join(items, "\n")
+		}
+		return strings.Join(items, "\n\n")
+
+	case document.CodeBlockNode:
+		return strings.TrimSuffix(node.TextContent(), "\n")
+
+	case document.ImageNode:
+		return node.StringAttr("alt")
+
+	case document.EmbedNode:
+		return node.StringAttr("url")
+
+	case document.TableNode:
+		rows := make([]string, 0, len(node.Content))
+		for _, row := range node.Content {
+			cells := make([]string, len(row.Content))
+			for i, cell := range row.Content {
+				cells[i] = strings.ReplaceAll(textInline(cell.Content), "\n", " ")
+			}
+			rows = append(rows, strings.Join(cells, " | "))
+		}
+		return strings.Join(rows, "\n")
+	}
+
+	return ""
+}
+
+func textInline(nodes []document.Node) string {
+
+	var b strings.Builder
+
+	for _, node := range nodes {
+		if node.Type == document.HardBreakNode {
+			b.WriteString("\n")
+			continue
+		}
+		b.WriteString(node.Text)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,28 @@
+package scripts
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/dhruv15803/echo-blog-app/storage"
+)
+
+// writes every audit log entry matching the filter to w as JSON lines, oldest first
+func (s *Scripts) ExportAuditLog(w io.Writer, filter storage.AuditLogFilter) (int, error) {
+
+	encoder := json.NewEncoder(w)
+	exportedCount := 0
+
+	err := s.storage.ForEachAuditLogEntry(filter, func(entry storage.AuditLogEntry) error {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+		exportedCount++
+		return nil
+	})
+	if err != nil {
+		return exportedCount, err
+	}
+
+	return exportedCount, nil
+}
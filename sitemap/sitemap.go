@@ -0,0 +1,72 @@
+// Package sitemap writes sitemaps and sitemap indexes in the sitemaps.org 0.9 format
+package sitemap
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+const ContentType = "application/xml; charset=utf-8"
+
+// MaxURLs is the most urls a sitemap, or sitemaps an index, may list
+const MaxURLs = 50000
+
+const namespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+type URL struct {
+	Loc string
+	// zero when the time of the last change is not known
+	LastModified time.Time
+}
+
+type urlSet struct {
+	XMLName xml.Name   `xml:"urlset"`
+	XMLNS   string     `xml:"xmlns,attr"`
+	URLs    []location `xml:"url"`
+}
+
+type index struct {
+	XMLName  xml.Name   `xml:"sitemapindex"`
+	XMLNS    string     `xml:"xmlns,attr"`
+	Sitemaps []location `xml:"sitemap"`
+}
+
+type location struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// URLSet writes a sitemap of the urls
+func URLSet(urls []URL) ([]byte, error) {
+	return marshal(urlSet{XMLNS: namespace, URLs: locations(urls)})
+}
+
+// Index writes a sitemap index, urls are the locations of the sitemaps
+func Index(sitemaps []URL) ([]byte, error) {
+	return marshal(index{XMLNS: namespace, Sitemaps: locations(sitemaps)})
+}
+
+func locations(urls []URL) []location {
+
+	locations := make([]location, 0, len(urls))
+
+	for _, url := range urls {
+		location := location{Loc: url.Loc}
+		if !url.LastModified.IsZero() {
+			location.LastMod = url.LastModified.UTC().Format(time.RFC3339)
+		}
+		locations = append(locations, location)
+	}
+
+	return locations
+}
+
+func marshal(v any) ([]byte, error) {
+
+	body, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), append(body, '\n')...), nil
+}
@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AuditLogData is a JSONB snapshot of an audit log target, kept as SQL NULL when there is none
+type AuditLogData json.RawMessage
+
+func (d AuditLogData) Value() (driver.Value, error) {
+	if len(d) == 0 {
+		return nil, nil
+	}
+	return []byte(d), nil
+}
+
+func (d *AuditLogData) Scan(src any) error {
+
+	switch data := src.(type) {
+	case []byte:
+		// the driver reuses its buffer, the data must be copied
+		*d = append(AuditLogData(nil), data...)
+		return nil
+	case string:
+		*d = AuditLogData(data)
+		return nil
+	case nil:
+		*d = nil
+		return nil
+	}
+
+	return fmt.Errorf("cannot scan %T into AuditLogData", src)
+}
+
+func (d AuditLogData) MarshalJSON() ([]byte, error) {
+	if len(d) == 0 {
+		return []byte("null"), nil
+	}
+	return json.RawMessage(d).MarshalJSON()
+}
+
+type AuditLogEntry struct {
+	Id         int64        `db:"id" json:"id"`
+	ActorId    *int         `db:"actor_id" json:"actor_id"`
+	Action     string       `db:"action" json:"action"`
+	TargetType string       `db:"target_type" json:"target_type"`
+	TargetId   *int         `db:"target_id" json:"target_id"`
+	BeforeData AuditLogData `db:"before_data" json:"before_data"`
+	AfterData  AuditLogData `db:"after_data" json:"after_data"`
+	IpAddress  *string      `db:"ip_address" json:"ip_address"`
+	UserAgent  *string      `db:"user_agent" json:"user_agent"`
+	RequestId  *string      `db:"request_id" json:"request_id"`
+	CreatedAt  string       `db:"created_at" json:"created_at"`
+}
+
+// zero values mean no filtering on that field
+type AuditLogFilter struct {
+	ActorId    int
+	Action     string
+	TargetType string
+	TargetId   int
+	From       time.Time
+	To         time.Time
+}
+
+func (f AuditLogFilter) whereClause() (string, []any) {
+
+	var conditions []string
+	var args []any
+
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if f.ActorId != 0 {
+		addCondition("actor_id=$%d", f.ActorId)
+	}
+	if f.Action != "" {
+		addCondition("action=$%d", f.Action)
+	}
+	if f.TargetType != "" {
+		addCondition("target_type=$%d", f.TargetType)
+	}
+	if f.TargetId != 0 {
+		addCondition("target_id=$%d", f.TargetId)
+	}
+	if !f.From.IsZero() {
+		addCondition("created_at >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		addCondition("created_at < $%d", f.To)
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func (s *Storage) CreateAuditLogEntry(entry AuditLogEntry) (*AuditLogEntry, error) {
+
+	var auditLogEntry AuditLogEntry
+
+	query := `INSERT INTO audit_log(actor_id,action,target_type,target_id,before_data,after_data,ip_address,user_agent,request_id)
+	VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9) RETURNING
+	id,actor_id,action,target_type,target_id,before_data,after_data,ip_address,user_agent,request_id,created_at`
+
+	row := s.db.QueryRowx(query, entry.ActorId, entry.Action, entry.TargetType, entry.TargetId, entry.BeforeData, entry.AfterData,
+		entry.IpAddress, entry.UserAgent, entry.RequestId)
+
+	if err := row.StructScan(&auditLogEntry); err != nil {
+		return nil, err
+	}
+
+	return &auditLogEntry, nil
+}
+
+func (s *Storage) GetAuditLogEntries(filter AuditLogFilter, skip int, limit int) ([]AuditLogEntry, error) {
+
+	var auditLogEntries []AuditLogEntry
+
+	whereClause, args := filter.whereClause()
+
+	query := fmt.Sprintf(`SELECT id,actor_id,action,target_type,target_id,before_data,after_data,ip_address,user_agent,request_id,created_at
+	FROM audit_log %s
+	ORDER BY id DESC
+	LIMIT $%d OFFSET $%d`, whereClause, len(args)+1, len(args)+2)
+
+	args = append(args, limit, skip)
+
+	if err := s.db.Select(&auditLogEntries, query, args...); err != nil {
+		return nil, err
+	}
+
+	return auditLogEntries, nil
+}
+
+func (s *Storage) GetAuditLogEntriesCount(filter AuditLogFilter) (int, error) {
+
+	var totalEntriesCount int
+
+	whereClause, args := filter.whereClause()
+
+	query := fmt.Sprintf(`SELECT COUNT(*) FROM audit_log %s`, whereClause)
+
+	if err := s.db.QueryRow(query, args...).Scan(&totalEntriesCount); err != nil {
+		return -1, err
+	}
+
+	return totalEntriesCount, nil
+}
+
+// streams every matching entry oldest first, used for exports
+func (s *Storage) ForEachAuditLogEntry(filter AuditLogFilter, fn func(entry AuditLogEntry) error) error {
+
+	whereClause, args := filter.whereClause()
+
+	query := fmt.Sprintf(`SELECT id,actor_id,action,target_type,target_id,before_data,after_data,ip_address,user_agent,request_id,created_at
+	FROM audit_log %s
+	ORDER BY id ASC`, whereClause)
+
+	rows, err := s.db.Queryx(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var entry AuditLogEntry
+
+		if err := rows.StructScan(&entry); err != nil {
+			return err
+		}
+
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
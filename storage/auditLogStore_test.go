@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var auditLogColumns = []string{"id", "actor_id", "action", "target_type", "target_id", "before_data", "after_data",
+	"ip_address", "user_agent", "request_id", "created_at"}
+
+func TestCreateAuditLogEntryWithoutData(t *testing.T) {
+
+	s, mock := newMockStorage(t)
+
+	targetId := 4
+
+	// a missing side is written as SQL NULL and read back
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO audit_log`)).
+		WithArgs(nil, "topic.create", "topic", &targetId, nil, []byte(`{"id":4}`), nil, nil, nil).
+		WillReturnRows(sqlmock.NewRows(auditLogColumns).
+			AddRow(1, nil, "topic.create", "topic", 4, nil, []byte(`{"id":4}`), nil, nil, nil, "2026-10-18T00:00:00Z"))
+
+	entry, err := s.CreateAuditLogEntry(AuditLogEntry{Action: "topic.create", TargetType: "topic", TargetId: &targetId, AfterData: AuditLogData(`{"id":4}`)})
+	if err != nil {
+		t.Fatalf("CreateAuditLogEntry() error = %v", err)
+	}
+
+	if entry.BeforeData != nil || string(entry.AfterData) != `{"id":4}` {
+		t.Errorf("CreateAuditLogEntry() data = %q , %q", entry.BeforeData, entry.AfterData)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAuditLogEntriesWithNullData(t *testing.T) {
+
+	rows := func() *sqlmock.Rows {
+		return sqlmock.NewRows(auditLogColumns).
+			AddRow(2, 1, "webhook.delete", "webhook", 7, []byte(`{"id":7}`), nil, nil, nil, nil, "2026-10-18T00:00:01Z").
+			AddRow(1, 1, "report.resolve", "report", 3, nil, nil, nil, nil, nil, "2026-10-18T00:00:00Z")
+	}
+
+	s, mock := newMockStorage(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM audit_log`)).WillReturnRows(rows())
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM audit_log`)).WillReturnRows(rows())
+
+	entries, err := s.GetAuditLogEntries(AuditLogFilter{}, 0, 10)
+	if err != nil {
+		t.Fatalf("GetAuditLogEntries() error = %v", err)
+	}
+
+	var exported []AuditLogEntry
+
+	if err := s.ForEachAuditLogEntry(AuditLogFilter{}, func(entry AuditLogEntry) error {
+		exported = append(exported, entry)
+		return nil
+	}); err != nil {
+		t.Fatalf("ForEachAuditLogEntry() error = %v", err)
+	}
+
+	if len(entries) != 2 || len(exported) != 2 {
+		t.Fatalf("got %d entries and %d exported entries, want 2", len(entries), len(exported))
+	}
+
+	encoded, err := json.Marshal(entries[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(decoded["before_data"]) != `{"id":7}` || string(decoded["after_data"]) != "null" {
+		t.Errorf("entry json data = %s , %s, want {\"id\":7} , null", decoded["before_data"], decoded["after_data"])
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
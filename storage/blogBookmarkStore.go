@@ -6,13 +6,27 @@ type BlogBookmark struct {
 	BookmarkedById   int    `db:"bookmarked_by_id" json:"bookmarked_by_id"`
 	BookmarkedBlogId int    `db:"bookmarked_blog_id" json:"bookmarked_blog_id"`
 	BookmarkedAt     string `db:"bookmarked_at" json:"bookmarked_at"`
+	// private to the user that bookmarked the blog
+	Note *string `db:"note" json:"note"`
+}
+
+type BookmarkedBlog struct {
+	BlogBookmark
+	BlogTitle       string  `db:"blog_title" json:"blog_title"`
+	BlogSlug        string  `db:"blog_slug" json:"blog_slug"`
+	BlogDescription *string `db:"blog_description" json:"blog_description"`
+	BlogThumbnail   *string `db:"blog_thumbnail" json:"blog_thumbnail"`
+	BlogAuthorId    int     `db:"blog_author_id" json:"blog_author_id"`
+	AuthorName      *string `db:"author_name" json:"author_name"`
+	AuthorImageUrl  *string `db:"author_image_url" json:"author_image_url"`
+	BlogCreatedAt   string  `db:"blog_created_at" json:"blog_created_at"`
 }
 
 func (s *Storage) GetBlogBookmark(bookmarkedById int, bookmarkedBlogId int) (*BlogBookmark, error) {
 
 	var blogBookmark BlogBookmark
 
-	query := `SELECT bookmarked_by_id,bookmarked_blog_id,bookmarked_at FROM blog_bookmarks WHERE bookmarked_by_id=$1 AND bookmarked_blog_id=$2`
+	query := `SELECT bookmarked_by_id,bookmarked_blog_id,bookmarked_at,note FROM blog_bookmarks WHERE bookmarked_by_id=$1 AND bookmarked_blog_id=$2`
 
 	row := s.db.QueryRowx(query, bookmarkedById, bookmarkedBlogId)
 
@@ -28,7 +42,7 @@ func (s *Storage) CreateBlogBookmark(bookmarkedById int, bookmarkedBlogId int) (
 	var blogBookmark BlogBookmark
 
 	query := `INSERT INTO blog_bookmarks(bookmarked_by_id,bookmarked_blog_id) VALUES($1,$2) 
-RETURNING bookmarked_by_id,bookmarked_blog_id,bookmarked_at`
+RETURNING bookmarked_by_id,bookmarked_blog_id,bookmarked_at,note`
 
 	row := s.db.QueryRowx(query, bookmarkedById, bookmarkedBlogId)
 
@@ -59,3 +73,54 @@ func (s *Storage) RemoveBlogBookmark(bookmarkedById int, bookmarkedBlogId int) e
 
 	return nil
 }
+
+// the user's bookmarks of blogs that are not hidden, most recently bookmarked first
+func (s *Storage) GetUserBookmarks(userId int, skip int, limit int) ([]BookmarkedBlog, error) {
+
+	var bookmarks []BookmarkedBlog
+
+	query := `SELECT bb.bookmarked_by_id,bb.bookmarked_blog_id,bb.bookmarked_at,bb.note,
+	b.blog_title,b.blog_slug,b.blog_description,b.blog_thumbnail,b.blog_author_id,b.blog_created_at,
+	u.name AS author_name,u.image_url AS author_image_url
+	FROM blog_bookmarks AS bb
+	INNER JOIN blogs AS b ON bb.bookmarked_blog_id=b.id
+	INNER JOIN users AS u ON b.blog_author_id=u.id
+	WHERE bb.bookmarked_by_id=$1 AND b.is_hidden=false
+	ORDER BY bb.bookmarked_at DESC,bb.bookmarked_blog_id DESC
+	LIMIT $2 OFFSET $3`
+
+	if err := s.db.Select(&bookmarks, query, userId, limit, skip); err != nil {
+		return nil, err
+	}
+
+	return bookmarks, nil
+}
+
+func (s *Storage) GetUserBookmarksCount(userId int) (int, error) {
+
+	var count int
+
+	query := `SELECT COUNT(*) FROM blog_bookmarks AS bb INNER JOIN blogs AS b ON bb.bookmarked_blog_id=b.id
+	WHERE bb.bookmarked_by_id=$1 AND b.is_hidden=false`
+
+	if err := s.db.Get(&count, query, userId); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// sets the note of the user's bookmark of the blog, a nil note clears it
+func (s *Storage) UpdateBlogBookmarkNote(bookmarkedById int, bookmarkedBlogId int, note *string) (*BlogBookmark, error) {
+
+	var blogBookmark BlogBookmark
+
+	query := `UPDATE blog_bookmarks SET note=$1 WHERE bookmarked_by_id=$2 AND bookmarked_blog_id=$3
+	RETURNING bookmarked_by_id,bookmarked_blog_id,bookmarked_at,note`
+
+	if err := s.db.QueryRowx(query, note, bookmarkedById, bookmarkedBlogId).StructScan(&blogBookmark); err != nil {
+		return nil, err
+	}
+
+	return &blogBookmark, nil
+}
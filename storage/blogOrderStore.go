@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// orderedBlogs is a table of blogs kept in order within a parent, e.g. the parts of a series
+type orderedBlogs struct {
+	table string
+	// column of the parent the rows belong to
+	parentColumn string
+	// returned when the new order does not list every blog that is not hidden exactly once
+	mismatchErr error
+}
+
+var (
+	seriesBlogs             = orderedBlogs{table: "series_blogs", parentColumn: "series_id", mismatchErr: ErrSeriesOrderMismatch}
+	bookmarkCollectionItems = orderedBlogs{table: "bookmark_collection_items", parentColumn: "collection_id", mismatchErr: ErrCollectionOrderMismatch}
+)
+
+// reorder puts the blogs of the parent in the order of blogIds, hidden blogs keep their order after the others
+func (o orderedBlogs) reorder(tx *sqlx.Tx, parentId int, blogIds []int) error {
+
+	type orderedBlog struct {
+		BlogId   int  `db:"blog_id"`
+		IsHidden bool `db:"is_hidden"`
+	}
+
+	// rows are locked so a blog added meanwhile can not be left without a position in the new order
+	var currentBlogs []orderedBlog
+	currentQuery := fmt.Sprintf(`SELECT ob.blog_id,COALESCE(b.is_hidden,false) AS is_hidden
+	FROM %s AS ob INNER JOIN blogs AS b ON ob.blog_id=b.id
+	WHERE ob.%s=$1
+	ORDER BY ob.position ASC
+	FOR UPDATE OF ob`, o.table, o.parentColumn)
+
+	if err := tx.Select(&currentBlogs, currentQuery, parentId); err != nil {
+		return err
+	}
+
+	seen := make(map[int]bool, len(blogIds))
+	for _, blogId := range blogIds {
+		seen[blogId] = true
+	}
+
+	order := slices.Clone(blogIds)
+	visibleCount := 0
+
+	for _, blog := range currentBlogs {
+		if blog.IsHidden {
+			order = append(order, blog.BlogId)
+			continue
+		}
+		if !seen[blog.BlogId] {
+			return o.mismatchErr
+		}
+		visibleCount++
+	}
+
+	if len(seen) != len(blogIds) || len(blogIds) != visibleCount {
+		return o.mismatchErr
+	}
+
+	ids := make(pq.Int64Array, len(order))
+	for i, blogId := range order {
+		ids[i] = int64(blogId)
+	}
+
+	reorderQuery := fmt.Sprintf(`UPDATE %s AS ob SET position=o.position
+	FROM unnest($2::int[]) WITH ORDINALITY AS o(blog_id,position)
+	WHERE ob.%s=$1 AND ob.blog_id=o.blog_id`, o.table, o.parentColumn)
+
+	_, err := tx.Exec(reorderQuery, parentId, ids)
+	return err
+}
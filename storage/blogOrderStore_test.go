@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func currentBlogsRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"blog_id", "is_hidden"}).AddRow(1, false).AddRow(2, true).AddRow(3, false)
+}
+
+func TestReorderSeriesKeepsHiddenBlogsLast(t *testing.T) {
+
+	s, mock := newMockStorage(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM series_blogs AS ob INNER JOIN blogs AS b ON ob.blog_id=b.id
+	WHERE ob.series_id=$1`)).WithArgs(4).WillReturnRows(currentBlogsRows())
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE series_blogs AS ob SET position=o.position`)).
+		WithArgs(4, pq.Int64Array{3, 1, 2}).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE series SET updated_at=NOW() WHERE id=$1`)).WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := s.ReorderSeries(4, []int{3, 1}); err != nil {
+		t.Fatalf("ReorderSeries() error = %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReorderBookmarkCollectionMismatch(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		blogIds []int
+	}{
+		{name: "missing blog", blogIds: []int{3}},
+		{name: "duplicate blog", blogIds: []int{3, 3, 1}},
+		{name: "unknown blog", blogIds: []int{3, 1, 9}},
+		{name: "hidden blog", blogIds: []int{3, 1, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			s, mock := newMockStorage(t)
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(regexp.QuoteMeta(`FROM bookmark_collection_items AS ob INNER JOIN blogs AS b ON ob.blog_id=b.id
+	WHERE ob.collection_id=$1`)).WithArgs(6).WillReturnRows(currentBlogsRows())
+			mock.ExpectRollback()
+
+			if err := s.ReorderBookmarkCollection(6, tt.blogIds); !errors.Is(err, ErrCollectionOrderMismatch) {
+				t.Errorf("ReorderBookmarkCollection() error = %v, want %v", err, ErrCollectionOrderMismatch)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -2,15 +2,28 @@ package storage
 
 import "errors"
 
+type BlogContentFormat string
+
+const (
+	JSONBlogContentFormat     BlogContentFormat = "json"
+	MarkdownBlogContentFormat BlogContentFormat = "markdown"
+)
+
+var BlogContentFormats = []BlogContentFormat{JSONBlogContentFormat, MarkdownBlogContentFormat}
+
 type Blog struct {
-	Id              int     `db:"id" json:"id"`
-	BlogTitle       string  `db:"blog_title" json:"blog_title"`
-	BlogDescription *string `db:"blog_description" json:"blog_description"`
-	BlogContent     string  `db:"blog_content" json:"blog_content"`
-	BlogThumbnail   *string `db:"blog_thumbnail" json:"blog_thumbnail"`
-	BlogAuthorId    int     `db:"blog_author_id" json:"blog_author_id"`
-	BlogCreatedAt   string  `db:"blog_created_at" json:"blog_created_at"`
-	BlogUpdatedAt   *string `db:"blog_updated_at" json:"blog_updated_at"`
+	Id                int               `db:"id" json:"id"`
+	BlogTitle         string            `db:"blog_title" json:"blog_title"`
+	BlogSlug          string            `db:"blog_slug" json:"blog_slug"`
+	BlogDescription   *string           `db:"blog_description" json:"blog_description"`
+	BlogContent       string            `db:"blog_content" json:"blog_content"`
+	BlogContentFormat BlogContentFormat `db:"blog_content_format" json:"blog_content_format"`
+	// the markdown source of blog_content, for blogs written in markdown
+	BlogContentMarkdown *string `db:"blog_content_markdown" json:"blog_content_markdown,omitempty"`
+	BlogThumbnail       *string `db:"blog_thumbnail" json:"blog_thumbnail"`
+	BlogAuthorId        int     `db:"blog_author_id" json:"blog_author_id"`
+	BlogCreatedAt       string  `db:"blog_created_at" json:"blog_created_at"`
+	BlogUpdatedAt       *string `db:"blog_updated_at" json:"blog_updated_at"`
 }
 
 type BlogTopic struct {
@@ -32,7 +45,8 @@ type BlogWithMetaData struct {
 	BlogBookmarksCount int     `json:"blog_bookmarks_count"`
 }
 
-func (s *Storage) CreateBlog(blogTitle string, blogDescription string, blogContent string, blogThumbnail string, blogAuthorId int, blogTopicIds []int) (newBlog *BlogWithTopics, err error) {
+// creates the blog with the slug, or the slug with a number appended when another blog has or had it
+func (s *Storage) CreateBlog(blogTitle string, blogSlug string, blogDescription string, blogContent string, blogContentFormat BlogContentFormat, blogContentMarkdown *string, blogThumbnail string, blogAuthorId int, blogTopicIds []int) (newBlog *BlogWithTopics, err error) {
 
 	var blogWithTopics BlogWithTopics
 	var blog Blog
@@ -50,12 +64,18 @@ func (s *Storage) CreateBlog(blogTitle string, blogDescription string, blogConte
 		}
 	}()
 
-	createBlogQuery := `INSERT INTO blogs(blog_title,blog_description,blog_content,blog_thumbnail,blog_author_id) VALUES($1,$2,$3,$4,$5) RETURNING
-	id,blog_title,blog_description,blog_content,blog_thumbnail,blog_author_id,blog_created_at,blog_updated_at`
+	blogSlug, err = availableBlogSlug(tx, blogSlug, 0)
+	if err != nil {
+		return nil, err
+	}
 
-	row := tx.QueryRowx(createBlogQuery, blogTitle, blogDescription, blogContent, blogThumbnail, blogAuthorId)
+	createBlogQuery := `INSERT INTO blogs(blog_title,blog_slug,blog_description,blog_content,blog_content_format,blog_content_markdown,blog_thumbnail,blog_author_id)
+	VALUES($1,$2,$3,$4,$5,$6,$7,$8) RETURNING
+	id,blog_title,blog_slug,blog_description,blog_content,blog_content_format,blog_content_markdown,blog_thumbnail,blog_author_id,blog_created_at,blog_updated_at`
 
-	if err := row.StructScan(&blog); err != nil {
+	row := tx.QueryRowx(createBlogQuery, blogTitle, blogSlug, blogDescription, blogContent, blogContentFormat, blogContentMarkdown, blogThumbnail, blogAuthorId)
+
+	if err = row.StructScan(&blog); err != nil {
 		return nil, err
 	}
 
@@ -82,6 +102,10 @@ func (s *Storage) CreateBlog(blogTitle string, blogDescription string, blogConte
 		topics = append(topics, *topic)
 	}
 
+	if err = replaceBlogMediaReferences(tx, blog.Id, blogThumbnail, blogContent); err != nil {
+		return nil, err
+	}
+
 	blogWithTopics.Blog = blog
 	blogWithTopics.BlogTopics = topics
 
@@ -92,11 +116,95 @@ func (s *Storage) CreateBlog(blogTitle string, blogDescription string, blogConte
 	return &blogWithTopics, nil
 }
 
+// replaces the blog's fields and topics. when the slug of the new title differs from the blog's slug,
+// the blog gets a new slug and the old one is kept in the history to redirect to it
+func (s *Storage) UpdateBlog(blogId int, blogTitle string, blogSlug string, blogDescription string, blogContent string, blogContentFormat BlogContentFormat, blogContentMarkdown *string, blogThumbnail string, blogTopicIds []int) (updatedBlog *BlogWithTopics, err error) {
+
+	var blog Blog
+	var topics []Topic
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var currentSlug string
+
+	if err = tx.Get(&currentSlug, `SELECT blog_slug FROM blogs WHERE id=$1 FOR UPDATE`, blogId); err != nil {
+		return nil, err
+	}
+
+	if hasSlugBase(currentSlug, blogSlug) {
+		blogSlug = currentSlug
+	} else {
+
+		blogSlug, err = availableBlogSlug(tx, blogSlug, blogId)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err = tx.Exec(`INSERT INTO blog_slug_history(slug,blog_id) VALUES($1,$2) ON CONFLICT (slug) DO NOTHING`, currentSlug, blogId); err != nil {
+			return nil, err
+		}
+
+		// a slug the blog takes back no longer redirects
+		if _, err = tx.Exec(`DELETE FROM blog_slug_history WHERE slug=$1`, blogSlug); err != nil {
+			return nil, err
+		}
+	}
+
+	updateBlogQuery := `UPDATE blogs SET blog_title=$1,blog_slug=$2,blog_description=$3,blog_content=$4,blog_content_format=$5,blog_content_markdown=$6,
+	blog_thumbnail=$7,blog_updated_at=NOW()
+	WHERE id=$8 RETURNING id,blog_title,blog_slug,blog_description,blog_content,blog_content_format,blog_content_markdown,blog_thumbnail,
+	blog_author_id,blog_created_at,blog_updated_at`
+
+	if err = tx.QueryRowx(updateBlogQuery, blogTitle, blogSlug, blogDescription, blogContent, blogContentFormat, blogContentMarkdown, blogThumbnail, blogId).StructScan(&blog); err != nil {
+		return nil, err
+	}
+
+	if _, err = tx.Exec(`DELETE FROM blog_topics WHERE blog_id=$1`, blogId); err != nil {
+		return nil, err
+	}
+
+	for _, topicId := range blogTopicIds {
+
+		if _, err = tx.Exec(`INSERT INTO blog_topics(blog_id,topic_id) VALUES($1,$2)`, blogId, topicId); err != nil {
+			return nil, err
+		}
+
+		var topic *Topic
+
+		topic, err = s.GetTopicById(topicId)
+		if err != nil {
+			return nil, err
+		}
+
+		topics = append(topics, *topic)
+	}
+
+	if err = replaceBlogMediaReferences(tx, blogId, blogThumbnail, blogContent); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &BlogWithTopics{Blog: blog, BlogTopics: topics}, nil
+}
+
 func (s *Storage) GetBlogById(blogId int) (*Blog, error) {
 
 	var blog Blog
 
-	query := `SELECT id,blog_title,blog_description,blog_content,blog_thumbnail,blog_author_id,blog_created_at,blog_updated_at
+	query := `SELECT id,blog_title,blog_slug,blog_description,blog_content,blog_content_format,blog_content_markdown,blog_thumbnail,blog_author_id,
+	blog_created_at,blog_updated_at
 	FROM blogs WHERE id=$1`
 
 	row := s.db.QueryRowx(query, blogId)
@@ -108,6 +216,60 @@ func (s *Storage) GetBlogById(blogId int) (*Blog, error) {
 	return &blog, nil
 }
 
+// GetBlogById for public read paths, a blog hidden by moderators is not found
+func (s *Storage) GetVisibleBlogById(blogId int) (*Blog, error) {
+
+	var blog Blog
+
+	query := `SELECT id,blog_title,blog_slug,blog_description,blog_content,blog_content_format,blog_content_markdown,blog_thumbnail,blog_author_id,
+	blog_created_at,blog_updated_at
+	FROM blogs WHERE id=$1 AND is_hidden=false`
+
+	if err := s.db.QueryRowx(query, blogId).StructScan(&blog); err != nil {
+		return nil, err
+	}
+
+	return &blog, nil
+}
+
+// a blog that is not hidden, with its author, topics and counts
+func (s *Storage) GetBlogWithMetaDataById(blogId int) (*BlogWithMetaData, error) {
+
+	var blog BlogWithMetaData
+
+	query := `SELECT b.id,b.blog_title,b.blog_slug,b.blog_description,b.blog_content,b.blog_content_format,b.blog_content_markdown,b.blog_thumbnail,
+	b.blog_author_id,b.blog_created_at,b.blog_updated_at,u.id,u.email,u.password,u.name,u.is_verified,u.image_url,
+	u.role,u.created_at,u.updated_at
+	FROM blogs AS b INNER JOIN users AS u ON b.blog_author_id=u.id
+	WHERE b.id=$1 AND b.is_hidden=false`
+
+	if err := s.db.QueryRow(query, blogId).Scan(&blog.Id, &blog.BlogTitle, &blog.BlogSlug, &blog.BlogDescription, &blog.BlogContent, &blog.BlogContentFormat,
+		&blog.BlogContentMarkdown, &blog.BlogThumbnail, &blog.BlogAuthorId,
+		&blog.BlogCreatedAt, &blog.BlogUpdatedAt, &blog.BlogAuthor.Id, &blog.BlogAuthor.Email, &blog.BlogAuthor.Password, &blog.BlogAuthor.Name,
+		&blog.BlogAuthor.IsVerified, &blog.BlogAuthor.ImageUrl, &blog.BlogAuthor.Role, &blog.BlogAuthor.CreatedAt,
+		&blog.BlogAuthor.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	blogCounts, err := s.GetBlogCounts(blogId)
+	if err != nil {
+		return nil, err
+	}
+
+	blog.BlogLikesCount = blogCounts.BlogLikesCount
+	blog.BlogCommentsCount = blogCounts.BlogCommentsCount
+	blog.BlogBookmarksCount = blogCounts.BlogBookmarksCount
+
+	blogTopicsQuery := `SELECT id,topic_title,topic_slug,topic_created_at,topic_updated_at
+	FROM topics WHERE id IN (SELECT topic_id FROM blog_topics WHERE blog_id=$1)`
+
+	if err := s.db.Select(&blog.BlogTopics, blogTopicsQuery, blogId); err != nil {
+		return nil, err
+	}
+
+	return &blog, nil
+}
+
 func (s *Storage) DeleteBlogById(blogId int) error {
 
 	query := `DELETE FROM blogs WHERE id=$1`
@@ -135,7 +297,7 @@ func (s *Storage) GetBlogsByTopic(topicId int, skip int, limit int, likesCountWt
 
 	query := `SELECT * , (($4::numeric * likes_count + $5::numeric * bookmarks_count + $6::numeric * comments_count) / ( POWER(EXTRACT (EPOCH FROM (NOW() - blog_created_at)),2))) AS activity_score FROM (
 	SELECT 
-	b.id,b.blog_title,b.blog_description,b.blog_content,b.blog_thumbnail,b.blog_author_id,
+	b.id,b.blog_title,b.blog_slug,b.blog_description,b.blog_content,b.blog_content_format,b.blog_thumbnail,b.blog_author_id,
 	b.blog_created_at,b.blog_updated_at,u.id,u.email,u.password,u.name,u.is_verified,u.image_url,
 	u.role,u.created_at,u.updated_at,
 	COUNT(DISTINCT bl.liked_by_id) AS likes_count,
@@ -145,8 +307,8 @@ FROM
 	blogs AS b INNER JOIN users AS u ON b.blog_author_id=u.id 
 	LEFT JOIN blog_likes AS bl ON bl.liked_blog_id=b.id 
 	LEFT JOIN blog_bookmarks AS bb ON bb.bookmarked_blog_id=b.id
-	LEFT JOIN blog_comments AS bc ON bc.blog_id = b.id AND bc.parent_comment_id IS NULL
-WHERE b.id IN (SELECT blog_id FROM blog_topics WHERE topic_id=$1)
+	LEFT JOIN blog_comments AS bc ON bc.blog_id = b.id AND bc.parent_comment_id IS NULL AND bc.is_hidden=false
+WHERE b.id IN (SELECT blog_id FROM blog_topics WHERE topic_id=$1) AND b.is_hidden=false
 GROUP BY 
 	b.id,u.id
 )  
@@ -164,7 +326,7 @@ LIMIT $2 OFFSET $3`
 		var blog BlogWithMetaData
 		var activityScore float64
 
-		if err := rows.Scan(&blog.Id, &blog.BlogTitle, &blog.BlogDescription, &blog.BlogContent, &blog.BlogThumbnail, &blog.BlogAuthorId,
+		if err := rows.Scan(&blog.Id, &blog.BlogTitle, &blog.BlogSlug, &blog.BlogDescription, &blog.BlogContent, &blog.BlogContentFormat, &blog.BlogThumbnail, &blog.BlogAuthorId,
 			&blog.BlogCreatedAt, &blog.BlogUpdatedAt, &blog.BlogAuthor.Id, &blog.BlogAuthor.Email, &blog.BlogAuthor.Password, &blog.BlogAuthor.Name,
 			&blog.BlogAuthor.IsVerified, &blog.BlogAuthor.ImageUrl, &blog.BlogAuthor.Role, &blog.BlogAuthor.CreatedAt,
 			&blog.BlogAuthor.UpdatedAt, &blog.BlogLikesCount, &blog.BlogBookmarksCount, &blog.BlogCommentsCount, &activityScore); err != nil {
@@ -172,7 +334,7 @@ LIMIT $2 OFFSET $3`
 		}
 
 		var blogTopics []Topic
-		blogTopicsQuery := `SELECT id,topic_title,topic_created_at,topic_updated_at 
+		blogTopicsQuery := `SELECT id,topic_title,topic_slug,topic_created_at,topic_updated_at 
 FROM topics WHERE id IN (SELECT topic_id FROM blog_topics WHERE blog_id=$1)`
 
 		topicRows, err := s.db.Queryx(blogTopicsQuery, blog.Id)
@@ -203,7 +365,8 @@ func (s *Storage) GetBlogsCountByTopic(topicId int) (int, error) {
 
 	var totalBlogsCountByTopic int
 
-	query := `SELECT COUNT(blog_id) FROM blog_topics WHERE topic_id=$1`
+	query := `SELECT COUNT(bt.blog_id) FROM blog_topics AS bt INNER JOIN blogs AS b ON bt.blog_id=b.id 
+	WHERE bt.topic_id=$1 AND b.is_hidden=false`
 
 	if err := s.db.QueryRow(query, topicId).Scan(&totalBlogsCountByTopic); err != nil {
 		return -1, err
@@ -218,7 +381,7 @@ func (s *Storage) GetBlogsByUserFollowings(userId int, skip int, limit int, like
 
 	query := `SELECT * , (($4::numeric * likes_count + $5::numeric * bookmarks_count + $6::numeric * comments_count) / ( POWER(EXTRACT (EPOCH FROM (NOW() - blog_created_at)),2))) AS activity_score FROM (
 	SELECT 
-	b.id,b.blog_title,b.blog_description,b.blog_content,b.blog_thumbnail,b.blog_author_id,
+	b.id,b.blog_title,b.blog_slug,b.blog_description,b.blog_content,b.blog_content_format,b.blog_thumbnail,b.blog_author_id,
 	b.blog_created_at,b.blog_updated_at,u.id,u.email,u.password,u.name,u.is_verified,u.image_url,
 	u.role,u.created_at,u.updated_at,
 	COUNT(DISTINCT bl.liked_by_id) AS likes_count,
@@ -228,8 +391,8 @@ FROM
 	blogs AS b INNER JOIN users AS u ON b.blog_author_id=u.id 
 	LEFT JOIN blog_likes AS bl ON bl.liked_blog_id=b.id 
 	LEFT JOIN blog_bookmarks AS bb ON bb.bookmarked_blog_id=b.id
-	LEFT JOIN blog_comments AS bc ON bc.blog_id = b.id AND bc.parent_comment_id IS NULL
-WHERE b.blog_author_id IN (SELECT following_id FROM follows WHERE follower_id=$1)
+	LEFT JOIN blog_comments AS bc ON bc.blog_id = b.id AND bc.parent_comment_id IS NULL AND bc.is_hidden=false
+WHERE b.blog_author_id IN (SELECT following_id FROM follows WHERE follower_id=$1) AND b.is_hidden=false
 GROUP BY 
 	b.id,u.id
 )  
@@ -246,7 +409,7 @@ LIMIT $2 OFFSET $3`
 		var blog BlogWithMetaData
 		var activityScore float64
 
-		err := rows.Scan(&blog.Id, &blog.BlogTitle, &blog.BlogDescription, &blog.BlogContent, &blog.BlogThumbnail, &blog.BlogAuthorId,
+		err := rows.Scan(&blog.Id, &blog.BlogTitle, &blog.BlogSlug, &blog.BlogDescription, &blog.BlogContent, &blog.BlogContentFormat, &blog.BlogThumbnail, &blog.BlogAuthorId,
 			&blog.BlogCreatedAt, &blog.BlogUpdatedAt, &blog.BlogAuthor.Id, &blog.BlogAuthor.Email, &blog.BlogAuthor.Password, &blog.BlogAuthor.Name,
 			&blog.BlogAuthor.IsVerified, &blog.BlogAuthor.ImageUrl, &blog.BlogAuthor.Role, &blog.BlogAuthor.CreatedAt,
 			&blog.BlogAuthor.UpdatedAt, &blog.BlogLikesCount, &blog.BlogBookmarksCount, &blog.BlogCommentsCount, &activityScore)
@@ -256,7 +419,7 @@ LIMIT $2 OFFSET $3`
 
 		var blogTopics []Topic
 
-		blogTopicsQuery := `SELECT id,topic_title,topic_created_at,topic_updated_at 
+		blogTopicsQuery := `SELECT id,topic_title,topic_slug,topic_created_at,topic_updated_at 
 FROM topics WHERE id IN (SELECT topic_id FROM blog_topics WHERE blog_id=$1)`
 
 		topicRows, err := s.db.Queryx(blogTopicsQuery, blog.Id)
@@ -287,7 +450,7 @@ func (s *Storage) GetBlogsCountByUserFollowings(userId int) (int, error) {
 
 	var totalBlogsCount int
 
-	query := `SELECT COUNT(*) FROM blogs WHERE blog_author_id IN (SELECT following_id FROM follows WHERE follower_id=$1)`
+	query := `SELECT COUNT(*) FROM blogs WHERE blog_author_id IN (SELECT following_id FROM follows WHERE follower_id=$1) AND is_hidden=false`
 
 	if err := s.db.QueryRow(query, userId).Scan(&totalBlogsCount); err != nil {
 		return -1, err
@@ -295,3 +458,27 @@ func (s *Storage) GetBlogsCountByUserFollowings(userId int) (int, error) {
 
 	return totalBlogsCount, nil
 }
+
+type BlogCounts struct {
+	BlogId             int `db:"blog_id" json:"blog_id"`
+	BlogLikesCount     int `db:"blog_likes_count" json:"blog_likes_count"`
+	BlogCommentsCount  int `db:"blog_comments_count" json:"blog_comments_count"`
+	BlogBookmarksCount int `db:"blog_bookmarks_count" json:"blog_bookmarks_count"`
+}
+
+// counts are computed the same way as in the feeds so that live updates match what was loaded
+func (s *Storage) GetBlogCounts(blogId int) (*BlogCounts, error) {
+
+	var blogCounts BlogCounts
+
+	query := `SELECT $1::int AS blog_id,
+	(SELECT COUNT(*) FROM blog_likes WHERE liked_blog_id=$1) AS blog_likes_count,
+	(SELECT COUNT(*) FROM blog_comments WHERE blog_id=$1 AND parent_comment_id IS NULL AND is_hidden=false) AS blog_comments_count,
+	(SELECT COUNT(*) FROM blog_bookmarks WHERE bookmarked_blog_id=$1) AS blog_bookmarks_count`
+
+	if err := s.db.QueryRowx(query, blogId).StructScan(&blogCounts); err != nil {
+		return nil, err
+	}
+
+	return &blogCounts, nil
+}
@@ -0,0 +1,194 @@
+package storage
+
+import "time"
+
+type BlogViewEvent struct {
+	BlogId      int       `db:"blog_id" json:"blog_id"`
+	ViewerHash  string    `db:"viewer_hash" json:"-"`
+	WindowStart time.Time `db:"window_start" json:"window_start"`
+	// how much of the blog the viewer read, from 0 to 100
+	ReadPercent    int       `db:"read_percent" json:"read_percent"`
+	ReferrerDomain *string   `db:"referrer_domain" json:"referrer_domain"`
+	ViewedAt       time.Time `db:"viewed_at" json:"viewed_at"`
+	// the signed in viewer, it is only kept in blog_reads and never with the view event
+	UserId *int `db:"-" json:"-"`
+}
+
+type BlogStatsBucket struct {
+	Date             string `db:"date" json:"date"`
+	Views            int    `db:"views" json:"views"`
+	Reads            int    `db:"reads" json:"reads"`
+	ReadPercentTotal int64  `db:"read_percent_total" json:"-"`
+	Likes            int    `db:"likes" json:"likes"`
+}
+
+type ReferrerViews struct {
+	ReferrerDomain string `db:"referrer_domain" json:"referrer_domain"`
+	Views          int    `db:"views" json:"views"`
+}
+
+// InsertBlogViewEvents writes a batch of view events in one transaction. an event of a viewer that already
+// viewed the blog in the window only raises its read percent, and events of blogs that were deleted are dropped.
+// events of signed in viewers also raise how far they read the blog in blog_reads
+func (s *Storage) InsertBlogViewEvents(events []BlogViewEvent) (err error) {
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	stmt, err := tx.Prepare(`INSERT INTO blog_view_events(blog_id,viewer_hash,window_start,read_percent,referrer_domain,viewed_at)
+	SELECT $1::int,$2::text,$3::timestamp,$4::smallint,$5::text,$6::timestamp WHERE EXISTS (SELECT 1 FROM blogs WHERE id=$1)
+	ON CONFLICT (blog_id,viewer_hash,window_start) DO UPDATE SET
+	read_percent=GREATEST(blog_view_events.read_percent,EXCLUDED.read_percent),
+	referrer_domain=COALESCE(blog_view_events.referrer_domain,EXCLUDED.referrer_domain)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	readStmt, err := tx.Prepare(`INSERT INTO blog_reads(user_id,blog_id,read_percent,last_read_at)
+	SELECT $1::int,$2::int,$3::smallint,$4::timestamp WHERE EXISTS (SELECT 1 FROM blogs WHERE id=$2)
+	AND EXISTS (SELECT 1 FROM users WHERE id=$1)
+	ON CONFLICT (user_id,blog_id) DO UPDATE SET
+	read_percent=GREATEST(blog_reads.read_percent,EXCLUDED.read_percent),
+	last_read_at=GREATEST(blog_reads.last_read_at,EXCLUDED.last_read_at)`)
+	if err != nil {
+		return err
+	}
+	defer readStmt.Close()
+
+	for _, event := range events {
+		if _, err = stmt.Exec(event.BlogId, event.ViewerHash, event.WindowStart, event.ReadPercent, event.ReferrerDomain, event.ViewedAt); err != nil {
+			return err
+		}
+
+		if event.UserId == nil {
+			continue
+		}
+
+		if _, err = readStmt.Exec(*event.UserId, event.BlogId, event.ReadPercent, event.ViewedAt); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// latest hour that view events were rolled up into, zero before the first rollup
+func (s *Storage) GetLatestBlogViewRollupHour() (time.Time, error) {
+
+	var hour *time.Time
+
+	if err := s.db.Get(&hour, `SELECT MAX(hour) FROM blog_view_rollups`); err != nil {
+		return time.Time{}, err
+	}
+
+	if hour == nil {
+		return time.Time{}, nil
+	}
+
+	return *hour, nil
+}
+
+// RollupBlogViews recomputes the hourly views, reads and referrers of blogs from the view events since the given hour,
+// a view counts as a read once its read percent reaches readPercent. events older than retainUntil are deleted
+func (s *Storage) RollupBlogViews(since time.Time, readPercent int, retainUntil time.Time) (err error) {
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	viewsQuery := `INSERT INTO blog_view_rollups(blog_id,hour,views,reads,read_percent_total)
+	SELECT blog_id,date_trunc('hour',viewed_at),COUNT(*),COUNT(*) FILTER (WHERE read_percent >= $2),SUM(read_percent)
+	FROM blog_view_events WHERE viewed_at >= $1
+	GROUP BY blog_id,date_trunc('hour',viewed_at)
+	ON CONFLICT (blog_id,hour) DO UPDATE SET
+	views=EXCLUDED.views,reads=EXCLUDED.reads,read_percent_total=EXCLUDED.read_percent_total`
+
+	if _, err = tx.Exec(viewsQuery, since, readPercent); err != nil {
+		return err
+	}
+
+	referrersQuery := `INSERT INTO blog_referrer_rollups(blog_id,hour,referrer_domain,views)
+	SELECT blog_id,date_trunc('hour',viewed_at),referrer_domain,COUNT(*)
+	FROM blog_view_events WHERE viewed_at >= $1 AND referrer_domain IS NOT NULL
+	GROUP BY blog_id,date_trunc('hour',viewed_at),referrer_domain
+	ON CONFLICT (blog_id,hour,referrer_domain) DO UPDATE SET views=EXCLUDED.views`
+
+	if _, err = tx.Exec(referrersQuery, since); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(`DELETE FROM blog_view_events WHERE viewed_at < $1`, retainUntil); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// views, reads and likes of the blog for every day since the given time, days without any are included
+func (s *Storage) GetBlogStats(blogId int, since time.Time) ([]BlogStatsBucket, error) {
+
+	var buckets []BlogStatsBucket
+
+	query := `SELECT to_char(d.day,'YYYY-MM-DD') AS date,COALESCE(v.views,0) AS views,COALESCE(v.reads,0) AS reads,
+	COALESCE(v.read_percent_total,0) AS read_percent_total,COALESCE(l.likes,0) AS likes
+	FROM generate_series(date_trunc('day',$2::timestamp),date_trunc('day',NOW()::timestamp),INTERVAL '1 day') AS d(day)
+	LEFT JOIN (
+		SELECT date_trunc('day',hour) AS day,SUM(views) AS views,SUM(reads) AS reads,SUM(read_percent_total) AS read_percent_total
+		FROM blog_view_rollups WHERE blog_id=$1 AND hour >= date_trunc('day',$2::timestamp)
+		GROUP BY date_trunc('day',hour)
+	) AS v ON v.day=d.day
+	LEFT JOIN (
+		SELECT date_trunc('day',liked_at) AS day,COUNT(*) AS likes
+		FROM blog_likes WHERE liked_blog_id=$1 AND liked_at >= date_trunc('day',$2::timestamp)
+		GROUP BY date_trunc('day',liked_at)
+	) AS l ON l.day=d.day
+	ORDER BY d.day ASC`
+
+	if err := s.db.Select(&buckets, query, blogId, since); err != nil {
+		return nil, err
+	}
+
+	return buckets, nil
+}
+
+// referrer domains the blog was viewed from most since the given time
+func (s *Storage) GetBlogTopReferrers(blogId int, since time.Time, limit int) ([]ReferrerViews, error) {
+
+	var referrers []ReferrerViews
+
+	query := `SELECT referrer_domain,SUM(views) AS views FROM blog_referrer_rollups
+	WHERE blog_id=$1 AND hour >= date_trunc('day',$2::timestamp)
+	GROUP BY referrer_domain
+	ORDER BY views DESC,referrer_domain ASC
+	LIMIT $3`
+
+	if err := s.db.Select(&referrers, query, blogId, since, limit); err != nil {
+		return nil, err
+	}
+
+	return referrers, nil
+}
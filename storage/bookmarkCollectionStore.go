@@ -0,0 +1,271 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+)
+
+type CollectionVisibility string
+
+const (
+	PrivateCollectionVisibility CollectionVisibility = "private"
+	PublicCollectionVisibility  CollectionVisibility = "public"
+)
+
+var CollectionVisibilities = []CollectionVisibility{PrivateCollectionVisibility, PublicCollectionVisibility}
+
+var ErrCollectionOrderMismatch = errors.New("collection order must list every blog in the collection once")
+
+type BookmarkCollection struct {
+	Id          int                  `db:"id" json:"id"`
+	OwnerId     int                  `db:"owner_id" json:"owner_id"`
+	Name        string               `db:"name" json:"name"`
+	Description *string              `db:"description" json:"description"`
+	Visibility  CollectionVisibility `db:"visibility" json:"visibility"`
+	CreatedAt   string               `db:"created_at" json:"created_at"`
+	UpdatedAt   string               `db:"updated_at" json:"updated_at"`
+}
+
+type BookmarkCollectionWithCount struct {
+	BookmarkCollection
+	ItemsCount int `db:"items_count" json:"items_count"`
+}
+
+type BookmarkCollectionItem struct {
+	BookmarkedBlog
+	Position int    `db:"position" json:"position"`
+	AddedAt  string `db:"added_at" json:"added_at"`
+}
+
+const bookmarkCollectionColumns = `id,owner_id,name,description,visibility,created_at,updated_at`
+
+func (s *Storage) CreateBookmarkCollection(ownerId int, name string, description *string, visibility CollectionVisibility) (*BookmarkCollection, error) {
+
+	var collection BookmarkCollection
+
+	query := `INSERT INTO bookmark_collections(owner_id,name,description,visibility) VALUES($1,$2,$3,$4)
+	RETURNING ` + bookmarkCollectionColumns
+
+	if err := s.db.QueryRowx(query, ownerId, name, description, visibility).StructScan(&collection); err != nil {
+		return nil, err
+	}
+
+	return &collection, nil
+}
+
+func (s *Storage) GetBookmarkCollectionById(collectionId int) (*BookmarkCollection, error) {
+
+	var collection BookmarkCollection
+
+	query := `SELECT ` + bookmarkCollectionColumns + ` FROM bookmark_collections WHERE id=$1`
+
+	if err := s.db.QueryRowx(query, collectionId).StructScan(&collection); err != nil {
+		return nil, err
+	}
+
+	return &collection, nil
+}
+
+// the user's collection with the name, to keep names unique per user
+func (s *Storage) GetBookmarkCollectionByName(ownerId int, name string) (*BookmarkCollection, error) {
+
+	var collection BookmarkCollection
+
+	query := `SELECT ` + bookmarkCollectionColumns + ` FROM bookmark_collections WHERE owner_id=$1 AND name=$2`
+
+	if err := s.db.QueryRowx(query, ownerId, name).StructScan(&collection); err != nil {
+		return nil, err
+	}
+
+	return &collection, nil
+}
+
+// the user's collections with the number of blogs that are not hidden in each, most recently updated first
+func (s *Storage) GetBookmarkCollectionsByOwner(ownerId int) ([]BookmarkCollectionWithCount, error) {
+
+	var collections []BookmarkCollectionWithCount
+
+	query := `SELECT c.id,c.owner_id,c.name,c.description,c.visibility,c.created_at,c.updated_at,
+	(SELECT COUNT(*) FROM bookmark_collection_items AS ci INNER JOIN blogs AS b ON ci.blog_id=b.id
+	WHERE ci.collection_id=c.id AND b.is_hidden=false) AS items_count
+	FROM bookmark_collections AS c
+	WHERE c.owner_id=$1
+	ORDER BY c.updated_at DESC,c.id DESC`
+
+	if err := s.db.Select(&collections, query, ownerId); err != nil {
+		return nil, err
+	}
+
+	return collections, nil
+}
+
+func (s *Storage) UpdateBookmarkCollection(collectionId int, name string, description *string, visibility CollectionVisibility) (*BookmarkCollection, error) {
+
+	var collection BookmarkCollection
+
+	query := `UPDATE bookmark_collections SET name=$1,description=$2,visibility=$3,updated_at=NOW()
+	WHERE id=$4 RETURNING ` + bookmarkCollectionColumns
+
+	if err := s.db.QueryRowx(query, name, description, visibility, collectionId).StructScan(&collection); err != nil {
+		return nil, err
+	}
+
+	return &collection, nil
+}
+
+func (s *Storage) DeleteBookmarkCollection(collectionId int) error {
+
+	result, err := s.db.Exec(`DELETE FROM bookmark_collections WHERE id=$1`, collectionId)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected != 1 {
+		return errors.New("failed to delete bookmark collection")
+	}
+
+	return nil
+}
+
+// the blogs of the collection that are not hidden in the owner's order
+func (s *Storage) GetBookmarkCollectionItems(collectionId int) ([]BookmarkCollectionItem, error) {
+
+	var items []BookmarkCollectionItem
+
+	query := `SELECT bb.bookmarked_by_id,bb.bookmarked_blog_id,bb.bookmarked_at,bb.note,
+	b.blog_title,b.blog_slug,b.blog_description,b.blog_thumbnail,b.blog_author_id,b.blog_created_at,
+	u.name AS author_name,u.image_url AS author_image_url,ci.position,ci.added_at
+	FROM bookmark_collection_items AS ci
+	INNER JOIN blog_bookmarks AS bb ON bb.bookmarked_by_id=ci.user_id AND bb.bookmarked_blog_id=ci.blog_id
+	INNER JOIN blogs AS b ON ci.blog_id=b.id
+	INNER JOIN users AS u ON b.blog_author_id=u.id
+	WHERE ci.collection_id=$1 AND b.is_hidden=false
+	ORDER BY ci.position ASC`
+
+	if err := s.db.Select(&items, query, collectionId); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// AddBookmarkCollectionItem puts the blog at the end of the user's collection, bookmarking it first if the user
+// has not. it reports whether a bookmark was created, adding a blog already in the collection changes nothing
+func (s *Storage) AddBookmarkCollectionItem(collectionId int, userId int, blogId int) (bookmarked bool, err error) {
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return false, err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	result, err := tx.Exec(`INSERT INTO blog_bookmarks(bookmarked_by_id,bookmarked_blog_id) VALUES($1,$2)
+	ON CONFLICT (bookmarked_by_id,bookmarked_blog_id) DO NOTHING`, userId, blogId)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	itemQuery := `INSERT INTO bookmark_collection_items(collection_id,user_id,blog_id,position)
+	SELECT $1::int,$2::int,$3::int,COALESCE(MAX(position),0) + 1 FROM bookmark_collection_items WHERE collection_id=$1
+	ON CONFLICT (collection_id,blog_id) DO NOTHING`
+
+	if _, err = tx.Exec(itemQuery, collectionId, userId, blogId); err != nil {
+		return false, err
+	}
+
+	if _, err = tx.Exec(`UPDATE bookmark_collections SET updated_at=NOW() WHERE id=$1`, collectionId); err != nil {
+		return false, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// takes the blog out of the collection, the user keeps the bookmark. sql.ErrNoRows is returned when the blog is not in it
+func (s *Storage) RemoveBookmarkCollectionItem(collectionId int, blogId int) (err error) {
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	result, err := tx.Exec(`DELETE FROM bookmark_collection_items WHERE collection_id=$1 AND blog_id=$2`, collectionId, blogId)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected != 1 {
+		err = sql.ErrNoRows
+		return err
+	}
+
+	if _, err = tx.Exec(`UPDATE bookmark_collections SET updated_at=NOW() WHERE id=$1`, collectionId); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ReorderBookmarkCollection puts the blogs of the collection in the order of blogIds, which must list every blog
+// in the collection that is not hidden exactly once, ErrCollectionOrderMismatch is returned otherwise. hidden blogs
+// keep their order after the others
+func (s *Storage) ReorderBookmarkCollection(collectionId int, blogIds []int) (err error) {
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = bookmarkCollectionItems.reorder(tx, collectionId, blogIds); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(`UPDATE bookmark_collections SET updated_at=NOW() WHERE id=$1`, collectionId); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,218 @@
+package storage
+
+import "time"
+
+type DashboardGranularity string
+
+const (
+	DayDashboardGranularity   DashboardGranularity = "day"
+	WeekDashboardGranularity  DashboardGranularity = "week"
+	MonthDashboardGranularity DashboardGranularity = "month"
+)
+
+var DashboardGranularities = []DashboardGranularity{DayDashboardGranularity, WeekDashboardGranularity, MonthDashboardGranularity}
+
+type DashboardBucket struct {
+	// first day of the period
+	Period          string `db:"period" json:"period"`
+	Views           int    `db:"views" json:"views"`
+	Reads           int    `db:"reads" json:"reads"`
+	Likes           int    `db:"likes" json:"likes"`
+	Comments        int    `db:"comments" json:"comments"`
+	Bookmarks       int    `db:"bookmarks" json:"bookmarks"`
+	FollowersGained int    `db:"followers_gained" json:"followers_gained"`
+	FollowersLost   int    `db:"followers_lost" json:"followers_lost"`
+}
+
+type DashboardBlog struct {
+	Id         int     `db:"id" json:"id"`
+	BlogTitle  string  `db:"blog_title" json:"blog_title"`
+	BlogSlug   string  `db:"blog_slug" json:"blog_slug"`
+	Views      int     `db:"views" json:"views"`
+	Reads      int     `db:"reads" json:"reads"`
+	Likes      int     `db:"likes" json:"likes"`
+	Comments   int     `db:"comments" json:"comments"`
+	Bookmarks  int     `db:"bookmarks" json:"bookmarks"`
+	Engagement float64 `db:"engagement" json:"engagement"`
+}
+
+type DashboardTopic struct {
+	Id         int    `db:"id" json:"id"`
+	TopicTitle string `db:"topic_title" json:"topic_title"`
+	TopicSlug  string `db:"topic_slug" json:"topic_slug"`
+	Blogs      int    `db:"blogs" json:"blogs"`
+	Views      int    `db:"views" json:"views"`
+	Likes      int    `db:"likes" json:"likes"`
+	Comments   int    `db:"comments" json:"comments"`
+}
+
+type AuthorCounts struct {
+	Followers int `db:"followers" json:"followers"`
+	Blogs     int `db:"blogs" json:"blogs"`
+}
+
+// latest day that blog activity or followers were rolled up into, zero before the first rollup
+func (s *Storage) GetLatestDailyStatsDay() (time.Time, error) {
+
+	var day *time.Time
+
+	query := `SELECT GREATEST((SELECT MAX(day) FROM blog_daily_stats),(SELECT MAX(day) FROM author_daily_follower_stats))::timestamp`
+
+	if err := s.db.Get(&day, query); err != nil {
+		return time.Time{}, err
+	}
+
+	if day == nil {
+		return time.Time{}, nil
+	}
+
+	return *day, nil
+}
+
+// RollupDailyStats recomputes the daily activity on blogs and the daily followers gained and lost by authors
+// for every day since the day of the given time. views come from the hourly view rollups
+func (s *Storage) RollupDailyStats(since time.Time) (err error) {
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// days are replaced rather than updated, so a day whose likes or bookmarks were all removed is emptied
+	if _, err = tx.Exec(`DELETE FROM blog_daily_stats WHERE day >= $1::timestamp::date`, since); err != nil {
+		return err
+	}
+
+	blogStatsQuery := `INSERT INTO blog_daily_stats(blog_id,day,views,reads,likes,comments,bookmarks)
+	SELECT blog_id,day,SUM(views),SUM(reads),SUM(likes),SUM(comments),SUM(bookmarks) FROM (
+		SELECT blog_id,hour::date AS day,views,reads,0 AS likes,0 AS comments,0 AS bookmarks
+		FROM blog_view_rollups WHERE hour >= $1::timestamp::date
+		UNION ALL
+		SELECT liked_blog_id,liked_at::date,0,0,1,0,0
+		FROM blog_likes WHERE liked_at >= $1::timestamp::date
+		UNION ALL
+		SELECT blog_id,comment_created_at::date,0,0,0,1,0
+		FROM blog_comments WHERE comment_created_at >= $1::timestamp::date AND is_hidden=false
+		UNION ALL
+		SELECT bookmarked_blog_id,bookmarked_at::date,0,0,0,0,1
+		FROM blog_bookmarks WHERE bookmarked_at >= $1::timestamp::date
+	) AS activity
+	GROUP BY blog_id,day`
+
+	if _, err = tx.Exec(blogStatsQuery, since); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(`DELETE FROM author_daily_follower_stats WHERE day >= $1::timestamp::date`, since); err != nil {
+		return err
+	}
+
+	followerStatsQuery := `INSERT INTO author_daily_follower_stats(author_id,day,gained,lost)
+	SELECT following_id,created_at::date,COUNT(*) FILTER (WHERE event_type='follow'),COUNT(*) FILTER (WHERE event_type='unfollow')
+	FROM follow_events WHERE created_at >= $1::timestamp::date
+	GROUP BY following_id,created_at::date`
+
+	if _, err = tx.Exec(followerStatsQuery, since); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// activity on the author's blogs and followers gained and lost in every period between from and to, periods without any are included
+func (s *Storage) GetAuthorDashboardSeries(authorId int, from time.Time, to time.Time, granularity DashboardGranularity) ([]DashboardBucket, error) {
+
+	var buckets []DashboardBucket
+
+	query := `SELECT to_char(p.period,'YYYY-MM-DD') AS period,
+	COALESCE(a.views,0) AS views,COALESCE(a.reads,0) AS reads,COALESCE(a.likes,0) AS likes,COALESCE(a.comments,0) AS comments,
+	COALESCE(a.bookmarks,0) AS bookmarks,COALESCE(f.gained,0) AS followers_gained,COALESCE(f.lost,0) AS followers_lost
+	FROM generate_series(date_trunc($4,$2::timestamp),date_trunc($4,$3::timestamp),('1 ' || $4)::interval) AS p(period)
+	LEFT JOIN (
+		SELECT date_trunc($4,bds.day::timestamp) AS period,SUM(bds.views) AS views,SUM(bds.reads) AS reads,SUM(bds.likes) AS likes,
+		SUM(bds.comments) AS comments,SUM(bds.bookmarks) AS bookmarks
+		FROM blog_daily_stats AS bds INNER JOIN blogs AS b ON bds.blog_id=b.id
+		WHERE b.blog_author_id=$1 AND bds.day BETWEEN $2::timestamp::date AND $3::timestamp::date
+		GROUP BY date_trunc($4,bds.day::timestamp)
+	) AS a ON a.period=p.period
+	LEFT JOIN (
+		SELECT date_trunc($4,day::timestamp) AS period,SUM(gained) AS gained,SUM(lost) AS lost
+		FROM author_daily_follower_stats
+		WHERE author_id=$1 AND day BETWEEN $2::timestamp::date AND $3::timestamp::date
+		GROUP BY date_trunc($4,day::timestamp)
+	) AS f ON f.period=p.period
+	ORDER BY p.period ASC`
+
+	if err := s.db.Select(&buckets, query, authorId, from, to, string(granularity)); err != nil {
+		return nil, err
+	}
+
+	return buckets, nil
+}
+
+// the author's blogs with the most engagement between from and to, weighing likes, comments and bookmarks
+func (s *Storage) GetAuthorTopBlogs(authorId int, from time.Time, to time.Time, limit int, likesCountWt, commentsCountWt, bookmarksCountWt float64) ([]DashboardBlog, error) {
+
+	var blogs []DashboardBlog
+
+	query := `SELECT b.id,b.blog_title,b.blog_slug,SUM(bds.views) AS views,SUM(bds.reads) AS reads,SUM(bds.likes) AS likes,
+	SUM(bds.comments) AS comments,SUM(bds.bookmarks) AS bookmarks,
+	($5::numeric * SUM(bds.likes) + $6::numeric * SUM(bds.comments) + $7::numeric * SUM(bds.bookmarks))::float8 AS engagement
+	FROM blog_daily_stats AS bds INNER JOIN blogs AS b ON bds.blog_id=b.id
+	WHERE b.blog_author_id=$1 AND bds.day BETWEEN $2::timestamp::date AND $3::timestamp::date
+	GROUP BY b.id
+	ORDER BY engagement DESC,views DESC
+	LIMIT $4`
+
+	if err := s.db.Select(&blogs, query, authorId, from, to, limit, likesCountWt, commentsCountWt, bookmarksCountWt); err != nil {
+		return nil, err
+	}
+
+	return blogs, nil
+}
+
+// the topics the author writes about, with the number of the author's blogs in each and their activity between from and to
+func (s *Storage) GetAuthorTopicBreakdown(authorId int, from time.Time, to time.Time) ([]DashboardTopic, error) {
+
+	var topics []DashboardTopic
+
+	query := `SELECT t.id,t.topic_title,t.topic_slug,COUNT(DISTINCT b.id) AS blogs,
+	COALESCE(SUM(bds.views),0) AS views,COALESCE(SUM(bds.likes),0) AS likes,COALESCE(SUM(bds.comments),0) AS comments
+	FROM blogs AS b
+	INNER JOIN blog_topics AS bt ON bt.blog_id=b.id
+	INNER JOIN topics AS t ON bt.topic_id=t.id
+	LEFT JOIN blog_daily_stats AS bds ON bds.blog_id=b.id AND bds.day BETWEEN $2::timestamp::date AND $3::timestamp::date
+	WHERE b.blog_author_id=$1
+	GROUP BY t.id
+	ORDER BY views DESC,blogs DESC,t.topic_title ASC`
+
+	if err := s.db.Select(&topics, query, authorId, from, to); err != nil {
+		return nil, err
+	}
+
+	return topics, nil
+}
+
+func (s *Storage) GetAuthorCounts(authorId int) (*AuthorCounts, error) {
+
+	var counts AuthorCounts
+
+	query := `SELECT (SELECT COUNT(*) FROM follows WHERE following_id=$1) AS followers,
+	(SELECT COUNT(*) FROM blogs WHERE blog_author_id=$1) AS blogs`
+
+	if err := s.db.Get(&counts, query, authorId); err != nil {
+		return nil, err
+	}
+
+	return &counts, nil
+}
@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type emailOutboxStatus string
+
+const (
+	PendingEmailStatus emailOutboxStatus = "pending"
+	SendingEmailStatus emailOutboxStatus = "sending"
+	SentEmailStatus    emailOutboxStatus = "sent"
+	DeadEmailStatus    emailOutboxStatus = "dead"
+)
+
+const (
+	InviteEmailTemplate        = "invite"
+	PasswordResetEmailTemplate = "password_reset"
+	DigestEmailTemplate        = "digest"
+)
+
+type OutboxEmail struct {
+	Id             int64             `db:"id" json:"id"`
+	IdempotencyKey string            `db:"idempotency_key" json:"idempotency_key"`
+	Template       string            `db:"template" json:"template"`
+	ToEmail        string            `db:"to_email" json:"to_email"`
+	Subject        *string           `db:"subject" json:"subject"`
+	Locale         string            `db:"locale" json:"locale"`
+	Payload        json.RawMessage   `db:"payload" json:"-"`
+	Status         emailOutboxStatus `db:"status" json:"status"`
+	Attempts       int               `db:"attempts" json:"attempts"`
+	MaxAttempts    int               `db:"max_attempts" json:"max_attempts"`
+	NextAttemptAt  string            `db:"next_attempt_at" json:"next_attempt_at"`
+	LockedUntil    *string           `db:"locked_until" json:"locked_until"`
+	LastError      *string           `db:"last_error" json:"last_error"`
+	CreatedAt      string            `db:"created_at" json:"created_at"`
+	SentAt         *string           `db:"sent_at" json:"sent_at"`
+}
+
+// NewOutboxEmail is a mail queued for delivery alongside the write that caused it.
+// the idempotency key makes enqueueing the same mail twice a no-op.
+// an empty Subject means the subject is rendered from the localized template
+type NewOutboxEmail struct {
+	IdempotencyKey string
+	Template       string
+	ToEmail        string
+	Locale         string
+	Subject        string
+	Payload        any
+	MaxAttempts    int
+}
+
+func insertOutboxEmail(tx *sqlx.Tx, email NewOutboxEmail) error {
+
+	payload, err := json.Marshal(email.Payload)
+	if err != nil {
+		return err
+	}
+
+	maxAttempts := email.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 8
+	}
+
+	locale := email.Locale
+	if locale == "" {
+		locale = "en"
+	}
+
+	query := `INSERT INTO email_outbox(idempotency_key,template,to_email,locale,subject,payload,max_attempts) VALUES($1,$2,$3,$4,NULLIF($5,''),$6,$7)
+	ON CONFLICT (idempotency_key) DO NOTHING`
+
+	_, err = tx.Exec(query, email.IdempotencyKey, email.Template, email.ToEmail, locale, email.Subject, payload, maxAttempts)
+	return err
+}
+
+// claims up to batchSize due emails for delivery. claimed rows are leased until leaseUntil,
+// if a worker dies mid send the row becomes claimable again once the lease expires
+func (s *Storage) ClaimOutboxEmails(batchSize int, leaseUntil time.Time) ([]OutboxEmail, error) {
+
+	var emails []OutboxEmail
+
+	query := `UPDATE email_outbox SET status='sending',attempts=attempts+1,locked_until=$2
+	WHERE id IN (
+		SELECT id FROM email_outbox
+		WHERE (status='pending' AND next_attempt_at <= NOW()) OR (status='sending' AND locked_until < NOW())
+		ORDER BY next_attempt_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	) RETURNING id,idempotency_key,template,to_email,locale,subject,payload,status,attempts,max_attempts,
+	next_attempt_at,locked_until,last_error,created_at,sent_at`
+
+	rows, err := s.db.Queryx(query, batchSize, leaseUntil)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var email OutboxEmail
+
+		if err := rows.StructScan(&email); err != nil {
+			return nil, err
+		}
+
+		emails = append(emails, email)
+	}
+
+	return emails, rows.Err()
+}
+
+// the payload holds single use tokens so it is dropped once the mail is no longer needed
+func (s *Storage) MarkOutboxEmailSent(id int64) error {
+
+	query := `UPDATE email_outbox SET status='sent',sent_at=NOW(),locked_until=NULL,last_error=NULL,payload=NULL WHERE id=$1`
+
+	_, err := s.db.Exec(query, id)
+	return err
+}
+
+func (s *Storage) MarkOutboxEmailFailed(id int64, lastError string, nextAttemptAt time.Time) error {
+
+	query := `UPDATE email_outbox SET status='pending',locked_until=NULL,last_error=$2,next_attempt_at=$3 WHERE id=$1`
+
+	_, err := s.db.Exec(query, id, lastError, nextAttemptAt)
+	return err
+}
+
+func (s *Storage) MarkOutboxEmailDead(id int64, lastError string) error {
+
+	query := `UPDATE email_outbox SET status='dead',locked_until=NULL,last_error=$2,payload=NULL WHERE id=$1`
+
+	_, err := s.db.Exec(query, id, lastError)
+	return err
+}
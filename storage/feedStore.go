@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type FeedBlog struct {
+	Id              int       `db:"id" json:"id"`
+	BlogTitle       string    `db:"blog_title" json:"blog_title"`
+	BlogSlug        string    `db:"blog_slug" json:"blog_slug"`
+	BlogDescription *string   `db:"blog_description" json:"blog_description"`
+	BlogContent     string    `db:"blog_content" json:"blog_content"`
+	BlogAuthorId    int       `db:"blog_author_id" json:"blog_author_id"`
+	AuthorName      *string   `db:"author_name" json:"author_name"`
+	BlogCreatedAt   time.Time `db:"blog_created_at" json:"blog_created_at"`
+	// the time the blog was last updated, or created if it never was
+	BlogUpdatedAt time.Time `db:"blog_updated_at" json:"blog_updated_at"`
+	BlogTopics    []string  `db:"-" json:"blog_topics"`
+}
+
+// latest published blogs for a feed, newest first. a nil topic or author does not filter on it
+func (s *Storage) GetFeedBlogs(topicId *int, authorId *int, limit int) ([]FeedBlog, error) {
+
+	var blogs []FeedBlog
+
+	query := `SELECT b.id,b.blog_title,b.blog_slug,b.blog_description,b.blog_content,b.blog_author_id,u.name AS author_name,
+	b.blog_created_at,COALESCE(b.blog_updated_at,b.blog_created_at) AS blog_updated_at
+	FROM blogs AS b
+	INNER JOIN users AS u ON b.blog_author_id=u.id
+	WHERE b.is_hidden=false
+	AND ($1::int IS NULL OR b.id IN (SELECT blog_id FROM blog_topics WHERE topic_id=$1))
+	AND ($2::int IS NULL OR b.blog_author_id=$2)
+	ORDER BY b.blog_created_at DESC
+	LIMIT $3`
+
+	if err := s.db.Select(&blogs, query, topicId, authorId, limit); err != nil {
+		return nil, err
+	}
+
+	if len(blogs) == 0 {
+		return blogs, nil
+	}
+
+	blogIds := make([]int64, len(blogs))
+	for i, blog := range blogs {
+		blogIds[i] = int64(blog.Id)
+	}
+
+	rows, err := s.db.Query(`SELECT bt.blog_id,t.topic_title FROM blog_topics AS bt
+	INNER JOIN topics AS t ON bt.topic_id=t.id
+	WHERE bt.blog_id = ANY($1)
+	ORDER BY t.topic_title ASC`, pq.Int64Array(blogIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	topics := make(map[int][]string)
+
+	for rows.Next() {
+		var blogId int
+		var topicTitle string
+		if err := rows.Scan(&blogId, &topicTitle); err != nil {
+			return nil, err
+		}
+		topics[blogId] = append(topics[blogId], topicTitle)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range blogs {
+		blogs[i].BlogTopics = topics[blogs[i].Id]
+	}
+
+	return blogs, nil
+}
@@ -1,6 +1,17 @@
 package storage
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type FollowEventType string
+
+const (
+	FollowFollowEvent   FollowEventType = "follow"
+	UnfollowFollowEvent FollowEventType = "unfollow"
+)
 
 type Follow struct {
 	FollowerId  int    `db:"follower_id" json:"follower_id"`
@@ -21,25 +32,57 @@ func (s *Storage) GetFollow(followerId int, followingId int) (*Follow, error) {
 	return &follow, nil
 }
 
-func (s *Storage) CreateFollow(followerId int, followingId int) (*Follow, error) {
+// creates the follow and records it in the follow events
+func (s *Storage) CreateFollow(followerId int, followingId int) (newFollow *Follow, err error) {
 
 	var follow Follow
 
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
 	query := `INSERT INTO follows(follower_id,following_id) VALUES($1,$2) 
 RETURNING follower_id,following_id,followed_at`
 
-	if err := s.db.QueryRowx(query, followerId, followingId).StructScan(&follow); err != nil {
+	if err = tx.QueryRowx(query, followerId, followingId).StructScan(&follow); err != nil {
+		return nil, err
+	}
+
+	if err = insertFollowEvent(tx, followerId, followingId, FollowFollowEvent); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
 		return nil, err
 	}
 
 	return &follow, nil
 }
 
-func (s *Storage) RemoveFollow(followerId int, followingId int) error {
+// removes the follow and records the unfollow in the follow events
+func (s *Storage) RemoveFollow(followerId int, followingId int) (err error) {
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
 
 	query := `DELETE FROM follows WHERE follower_id=$1 AND following_id=$2`
 
-	result, err := s.db.Exec(query, followerId, followingId)
+	result, err := tx.Exec(query, followerId, followingId)
 	if err != nil {
 		return err
 	}
@@ -53,5 +96,21 @@ func (s *Storage) RemoveFollow(followerId int, followingId int) error {
 		return errors.New("failed to remove follow")
 	}
 
+	if err = insertFollowEvent(tx, followerId, followingId, UnfollowFollowEvent); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
 	return nil
 }
+
+func insertFollowEvent(tx *sqlx.Tx, followerId int, followingId int, eventType FollowEventType) error {
+
+	query := `INSERT INTO follow_events(follower_id,following_id,event_type) VALUES($1,$2,$3)`
+
+	_, err := tx.Exec(query, followerId, followingId, eventType)
+	return err
+}
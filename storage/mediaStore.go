@@ -0,0 +1,289 @@
+package storage
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var ErrMediaQuotaExceeded = errors.New("media storage quota exceeded")
+
+type MediaVariant struct {
+	Url    string `json:"url"`
+	Key    string `json:"key"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// MediaVariants maps a variant name (original, medium, thumbnail) to the stored file, it is kept as JSONB
+type MediaVariants map[string]MediaVariant
+
+func (v MediaVariants) Value() (driver.Value, error) {
+	return json.Marshal(v)
+}
+
+func (v *MediaVariants) Scan(src any) error {
+
+	switch data := src.(type) {
+	case []byte:
+		return json.Unmarshal(data, v)
+	case string:
+		return json.Unmarshal([]byte(data), v)
+	case nil:
+		*v = nil
+		return nil
+	}
+
+	return fmt.Errorf("cannot scan %T into MediaVariants", src)
+}
+
+type Media struct {
+	Id                int           `db:"id" json:"id"`
+	OwnerId           int           `db:"owner_id" json:"owner_id"`
+	StorageKey        string        `db:"storage_key" json:"storage_key"`
+	Url               string        `db:"url" json:"url"`
+	ContentHash       string        `db:"content_hash" json:"content_hash"`
+	MimeType          string        `db:"mime_type" json:"mime_type"`
+	Size              int64         `db:"size" json:"size"`
+	Width             int           `db:"width" json:"width"`
+	Height            int           `db:"height" json:"height"`
+	Blurhash          *string       `db:"blurhash" json:"blurhash"`
+	Variants          MediaVariants `db:"variants" json:"variants"`
+	UnreferencedSince *string       `db:"unreferenced_since" json:"-"`
+	CreatedAt         string        `db:"created_at" json:"created_at"`
+}
+
+type NewMedia struct {
+	OwnerId     int
+	StorageKey  string
+	Url         string
+	ContentHash string
+	MimeType    string
+	Size        int64
+	Width       int
+	Height      int
+	Blurhash    *string
+	Variants    MediaVariants
+}
+
+const mediaColumns = `id,owner_id,storage_key,url,content_hash,mime_type,size,width,height,blurhash,variants,unreferenced_since,created_at`
+
+// a media is referenced while any url pointing into its storage key is used as a blog thumbnail,
+// inside a blog's content or as a user's image. the references are recorded when those are saved
+const mediaReferencedCondition = `EXISTS (SELECT 1 FROM media_references r WHERE r.media_id=m.id)`
+
+// storage keys of media, every variant url contains one e.g media/2026/10/<random>/thumbnail.jpg
+var mediaStorageKeyPattern = regexp.MustCompile(`media/\d{4}/\d{2}/[0-9a-f]{32}`)
+
+// mediaStorageKeys finds the storage keys of the media urls in texts
+func mediaStorageKeys(texts ...string) pq.StringArray {
+
+	seen := make(map[string]bool)
+	keys := pq.StringArray{}
+
+	for _, text := range texts {
+		for _, key := range mediaStorageKeyPattern.FindAllString(text, -1) {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	return keys
+}
+
+// replaceBlogMediaReferences records the media used by the blog's thumbnail and content in place of the ones
+// recorded before, in the transaction that saves the blog
+func replaceBlogMediaReferences(tx *sqlx.Tx, blogId int, blogThumbnail string, blogContent string) error {
+
+	if _, err := tx.Exec(`DELETE FROM media_references WHERE blog_id=$1`, blogId); err != nil {
+		return err
+	}
+
+	keys := mediaStorageKeys(blogThumbnail, blogContent)
+	if len(keys) == 0 {
+		return nil
+	}
+
+	query := `INSERT INTO media_references(media_id,blog_id)
+	SELECT id,$1 FROM media WHERE storage_key=ANY($2) ON CONFLICT DO NOTHING`
+
+	_, err := tx.Exec(query, blogId, keys)
+	return err
+}
+
+// ReuseMediaByHash returns the user's media with the content hash for an upload of the same file.
+// uploading it again counts as using it, so a media the garbage collector found unreferenced
+// gets a new grace period instead of being deleted from under the upload
+func (s *Storage) ReuseMediaByHash(ownerId int, contentHash string) (*Media, error) {
+
+	var media Media
+
+	query := `UPDATE media SET unreferenced_since=NULL WHERE owner_id=$1 AND content_hash=$2 RETURNING ` + mediaColumns
+
+	if err := s.db.QueryRowx(query, ownerId, contentHash).StructScan(&media); err != nil {
+		return nil, err
+	}
+
+	return &media, nil
+}
+
+func (s *Storage) GetMediaById(mediaId int) (*Media, error) {
+
+	var media Media
+
+	query := `SELECT ` + mediaColumns + ` FROM media WHERE id=$1`
+
+	if err := s.db.QueryRowx(query, mediaId).StructScan(&media); err != nil {
+		return nil, err
+	}
+
+	return &media, nil
+}
+
+// total size of everything the user has stored
+func (s *Storage) GetMediaUsage(ownerId int) (int64, error) {
+
+	var usage int64
+
+	if err := s.db.QueryRow(`SELECT COALESCE(SUM(size),0) FROM media WHERE owner_id=$1`, ownerId).Scan(&usage); err != nil {
+		return -1, err
+	}
+
+	return usage, nil
+}
+
+// CreateMedia records an uploaded file if it keeps the owner within quota bytes.
+// when the owner already has a media with the same content hash (e.g. a concurrent upload
+// of the same file) that one is returned with created false, and the new files are not needed
+func (s *Storage) CreateMedia(newMedia NewMedia, quota int64) (media *Media, created bool, err error) {
+
+	var createdMedia Media
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, false, err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// concurrent uploads of the same user are serialized so they cannot overshoot the quota together
+	if _, err = tx.Exec(`SELECT id FROM users WHERE id=$1 FOR UPDATE`, newMedia.OwnerId); err != nil {
+		return nil, false, err
+	}
+
+	var existing Media
+
+	existingQuery := `UPDATE media SET unreferenced_since=NULL WHERE owner_id=$1 AND content_hash=$2 RETURNING ` + mediaColumns
+
+	err = tx.QueryRowx(existingQuery, newMedia.OwnerId, newMedia.ContentHash).StructScan(&existing)
+	if err == nil {
+		if err = tx.Commit(); err != nil {
+			return nil, false, err
+		}
+		return &existing, false, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, err
+	}
+
+	var usage int64
+
+	if err = tx.QueryRow(`SELECT COALESCE(SUM(size),0) FROM media WHERE owner_id=$1`, newMedia.OwnerId).Scan(&usage); err != nil {
+		return nil, false, err
+	}
+
+	if usage+newMedia.Size > quota {
+		err = ErrMediaQuotaExceeded
+		return nil, false, err
+	}
+
+	query := `INSERT INTO media(owner_id,storage_key,url,content_hash,mime_type,size,width,height,blurhash,variants)
+	VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10) RETURNING ` + mediaColumns
+
+	if err = tx.QueryRowx(query, newMedia.OwnerId, newMedia.StorageKey, newMedia.Url, newMedia.ContentHash, newMedia.MimeType,
+		newMedia.Size, newMedia.Width, newMedia.Height, newMedia.Blurhash, newMedia.Variants).StructScan(&createdMedia); err != nil {
+		return nil, false, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, false, err
+	}
+
+	return &createdMedia, true, nil
+}
+
+func (s *Storage) GetMediaByOwner(ownerId int, skip int, limit int) ([]Media, error) {
+
+	var media []Media
+
+	query := `SELECT ` + mediaColumns + ` FROM media WHERE owner_id=$1
+	ORDER BY created_at DESC, id DESC
+	LIMIT $2 OFFSET $3`
+
+	if err := s.db.Select(&media, query, ownerId, limit, skip); err != nil {
+		return nil, err
+	}
+
+	return media, nil
+}
+
+func (s *Storage) GetMediaCount(ownerId int) (int, error) {
+
+	var totalMediaCount int
+
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM media WHERE owner_id=$1`, ownerId).Scan(&totalMediaCount); err != nil {
+		return -1, err
+	}
+
+	return totalMediaCount, nil
+}
+
+// MarkUnreferencedMedia starts the grace period of media that are no longer referenced
+// and ends it for media that are referenced again
+func (s *Storage) MarkUnreferencedMedia() error {
+
+	if _, err := s.db.Exec(`UPDATE media m SET unreferenced_since=NULL WHERE unreferenced_since IS NOT NULL AND ` + mediaReferencedCondition); err != nil {
+		return err
+	}
+
+	if _, err := s.db.Exec(`UPDATE media m SET unreferenced_since=NOW() WHERE unreferenced_since IS NULL AND NOT ` + mediaReferencedCondition); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteUnreferencedMedia deletes up to limit media that have been unreferenced for longer than gracePeriod
+// and returns them, their files still have to be deleted from the blob store
+func (s *Storage) DeleteUnreferencedMedia(gracePeriod time.Duration, limit int) ([]Media, error) {
+
+	var media []Media
+
+	// references are checked again, one may have been added since the media was marked. the grace period
+	// is checked again on the row that is deleted, an upload of the same file may have just reused it
+	query := `DELETE FROM media WHERE id IN (
+		SELECT m.id FROM media m
+		WHERE m.unreferenced_since < NOW() - make_interval(secs => $1) AND NOT ` + mediaReferencedCondition + `
+		ORDER BY m.unreferenced_since
+		LIMIT $2
+	) AND unreferenced_since < NOW() - make_interval(secs => $1) RETURNING ` + mediaColumns
+
+	if err := s.db.Select(&media, query, gracePeriod.Seconds(), limit); err != nil {
+		return nil, err
+	}
+
+	return media, nil
+}
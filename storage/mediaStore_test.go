@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+const (
+	testMediaKey      = "media/2026/10/0123456789abcdef0123456789abcdef"
+	otherTestMediaKey = "media/2026/09/fedcba9876543210fedcba9876543210"
+)
+
+func TestMediaStorageKeys(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		texts []string
+		want  pq.StringArray
+	}{
+		{name: "none", texts: []string{"", `{"type":"doc","content":[]}`}, want: pq.StringArray{}},
+		{
+			name:  "thumbnail and content",
+			texts: []string{"https://cdn.example.com/" + testMediaKey + "/original.jpg", `{"type":"image","attrs":{"src":"http://localhost:8080/uploads/` + otherTestMediaKey + `/medium.png"}}`},
+			want:  pq.StringArray{testMediaKey, otherTestMediaKey},
+		},
+		{
+			name:  "variants of the same media",
+			texts: []string{"/" + testMediaKey + "/thumbnail.jpg /" + testMediaKey + "/medium.jpg", testMediaKey + "/original.jpg"},
+			want:  pq.StringArray{testMediaKey},
+		},
+		{name: "not a media key", texts: []string{"media/2026/10/not-a-key/original.jpg", "uploads/2026/10/0123456789abcdef0123456789abcdef"}, want: pq.StringArray{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mediaStorageKeys(tt.texts...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mediaStorageKeys() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplaceBlogMediaReferences(t *testing.T) {
+
+	s, mock := newMockStorage(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM media_references WHERE blog_id=$1`)).WithArgs(3).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO media_references(media_id,blog_id)`)).
+		WithArgs(3, pq.StringArray{testMediaKey, otherTestMediaKey}).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM media_references WHERE blog_id=$1`)).WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := replaceBlogMediaReferences(tx, 3, "https://cdn.example.com/"+testMediaKey+"/original.jpg", `{"src":"/`+otherTestMediaKey+`/medium.jpg"}`); err != nil {
+		t.Fatalf("replaceBlogMediaReferences() error = %v", err)
+	}
+
+	// a blog that no longer uses any media only has its references removed
+	tx, err = s.db.Beginx()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := replaceBlogMediaReferences(tx, 4, "", `{"type":"doc"}`); err != nil {
+		t.Fatalf("replaceBlogMediaReferences() error = %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func testMediaRow() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "owner_id", "storage_key", "url", "content_hash", "mime_type", "size", "width", "height",
+		"blurhash", "variants", "unreferenced_since", "created_at"}).
+		AddRow(5, 2, testMediaKey, "/"+testMediaKey+"/original.jpg", "hash", "image/jpeg", 1024, 10, 10, nil, `{}`, nil, "2026-10-18T00:00:00Z")
+}
+
+func TestReuseMediaByHashClearsUnreferencedSince(t *testing.T) {
+
+	s, mock := newMockStorage(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE media SET unreferenced_since=NULL WHERE owner_id=$1 AND content_hash=$2 RETURNING`)).
+		WithArgs(2, "hash").
+		WillReturnRows(testMediaRow())
+
+	media, err := s.ReuseMediaByHash(2, "hash")
+	if err != nil {
+		t.Fatalf("ReuseMediaByHash() error = %v", err)
+	}
+
+	if media.Id != 5 || media.UnreferencedSince != nil {
+		t.Errorf("ReuseMediaByHash() = %+v, want media 5 without unreferenced_since", media)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCreateMediaReusesExistingMedia(t *testing.T) {
+
+	s, mock := newMockStorage(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT id FROM users WHERE id=$1 FOR UPDATE`)).WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE media SET unreferenced_since=NULL WHERE owner_id=$1 AND content_hash=$2 RETURNING`)).
+		WithArgs(2, "hash").
+		WillReturnRows(testMediaRow())
+	mock.ExpectCommit()
+
+	media, created, err := s.CreateMedia(NewMedia{OwnerId: 2, ContentHash: "hash"}, 1<<20)
+	if err != nil {
+		t.Fatalf("CreateMedia() error = %v", err)
+	}
+
+	if created || media.Id != 5 {
+		t.Errorf("CreateMedia() = %+v, %v, want the existing media 5", media, created)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
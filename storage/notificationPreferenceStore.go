@@ -0,0 +1,263 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+type NotificationChannel string
+
+const (
+	// shown in app only
+	InAppNotificationChannel NotificationChannel = "in_app"
+	// shown in app and summarized in the email digest
+	EmailNotificationChannel NotificationChannel = "email"
+	OffNotificationChannel   NotificationChannel = "off"
+)
+
+var NotificationChannels = []NotificationChannel{InAppNotificationChannel, EmailNotificationChannel, OffNotificationChannel}
+
+type DigestFrequency string
+
+const (
+	OffDigestFrequency    DigestFrequency = "off"
+	DailyDigestFrequency  DigestFrequency = "daily"
+	WeeklyDigestFrequency DigestFrequency = "weekly"
+)
+
+var DigestFrequencies = []DigestFrequency{OffDigestFrequency, DailyDigestFrequency, WeeklyDigestFrequency}
+
+// Period is how far back a digest of this frequency looks
+func (f DigestFrequency) Period() time.Duration {
+	if f == DailyDigestFrequency {
+		return time.Hour * 24
+	}
+	return time.Hour * 24 * 7
+}
+
+type NotificationPreferences struct {
+	DigestFrequency DigestFrequency                          `json:"digest_frequency"`
+	Channels        map[NotificationType]NotificationChannel `json:"channels"`
+}
+
+type DigestRecipient struct {
+	Id              int             `db:"id"`
+	Email           string          `db:"email"`
+	Language        string          `db:"language"`
+	DigestFrequency DigestFrequency `db:"digest_frequency"`
+}
+
+type DigestActivity struct {
+	NotificationType NotificationType `db:"notification_type" json:"notification_type"`
+	Count            int              `db:"count" json:"count"`
+}
+
+type DigestBlog struct {
+	Id         int     `db:"id" json:"id"`
+	BlogTitle  string  `db:"blog_title" json:"blog_title"`
+	AuthorName *string `db:"author_name" json:"author_name"`
+}
+
+// every notification type is present in the result, types without a stored preference use the in_app default
+func (s *Storage) GetNotificationPreferences(userId int) (*NotificationPreferences, error) {
+
+	preferences := NotificationPreferences{Channels: make(map[NotificationType]NotificationChannel)}
+
+	if err := s.db.QueryRow(`SELECT digest_frequency FROM users WHERE id=$1`, userId).Scan(&preferences.DigestFrequency); err != nil {
+		return nil, err
+	}
+
+	for _, notificationType := range NotificationTypes {
+		preferences.Channels[notificationType] = InAppNotificationChannel
+	}
+
+	rows, err := s.db.Query(`SELECT notification_type,channel FROM notification_preferences WHERE user_id=$1`, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var notificationType NotificationType
+		var channel NotificationChannel
+
+		if err := rows.Scan(&notificationType, &channel); err != nil {
+			return nil, err
+		}
+
+		preferences.Channels[notificationType] = channel
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &preferences, nil
+}
+
+func (s *Storage) GetNotificationChannel(userId int, notificationType NotificationType) (NotificationChannel, error) {
+
+	var channel NotificationChannel
+
+	query := `SELECT COALESCE((SELECT channel FROM notification_preferences WHERE user_id=$1 AND notification_type=$2),'in_app')`
+
+	if err := s.db.QueryRow(query, userId, notificationType).Scan(&channel); err != nil {
+		return "", err
+	}
+
+	return channel, nil
+}
+
+// a nil digestFrequency leaves it unchanged, only the channels present are updated
+func (s *Storage) UpdateNotificationPreferences(userId int, digestFrequency *DigestFrequency, channels map[NotificationType]NotificationChannel) (err error) {
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if digestFrequency != nil {
+		if _, err = tx.Exec(`UPDATE users SET digest_frequency=$1 WHERE id=$2`, *digestFrequency, userId); err != nil {
+			return err
+		}
+	}
+
+	upsertChannelQuery := `INSERT INTO notification_preferences(user_id,notification_type,channel) VALUES($1,$2,$3)
+	ON CONFLICT (user_id,notification_type) DO UPDATE SET channel=EXCLUDED.channel`
+
+	for notificationType, channel := range channels {
+		if _, err = tx.Exec(upsertChannelQuery, userId, notificationType, channel); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *Storage) UpdateDigestFrequency(userId int, digestFrequency DigestFrequency) error {
+
+	result, err := s.db.Exec(`UPDATE users SET digest_frequency=$1 WHERE id=$2`, digestFrequency, userId)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected != 1 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+const digestDueCondition = `digest_frequency<>'off' AND is_verified=true
+	AND (suspended_until IS NULL OR suspended_until < NOW())
+	AND (last_digest_sent_at IS NULL OR last_digest_sent_at <= NOW() - CASE digest_frequency WHEN 'daily' THEN INTERVAL '1 day' ELSE INTERVAL '7 days' END)`
+
+func (s *Storage) GetDueDigestRecipients(limit int) ([]DigestRecipient, error) {
+
+	var recipients []DigestRecipient
+
+	query := `SELECT id,email,language,digest_frequency FROM users WHERE ` + digestDueCondition + ` ORDER BY id LIMIT $1`
+
+	if err := s.db.Select(&recipients, query, limit); err != nil {
+		return nil, err
+	}
+
+	return recipients, nil
+}
+
+// RecordDigest marks the user's digest as sent and queues its mail in one transaction.
+// a nil mail records an empty digest that is skipped. it returns false when the digest
+// is no longer due, e.g. another instance already sent it
+func (s *Storage) RecordDigest(userId int, mail *NewOutboxEmail) (recorded bool, err error) {
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return false, err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	result, err := tx.Exec(`UPDATE users SET last_digest_sent_at=NOW() WHERE id=$1 AND `+digestDueCondition, userId)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if rowsAffected != 1 {
+		tx.Rollback()
+		return false, nil
+	}
+
+	if mail != nil {
+		if err = insertOutboxEmail(tx, *mail); err != nil {
+			return false, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// activity on the user's notifications since the given time, limited to the types the user gets by email
+func (s *Storage) GetDigestActivity(userId int, since time.Time) ([]DigestActivity, error) {
+
+	var activity []DigestActivity
+
+	query := `SELECT n.notification_type,COUNT(*) AS count FROM notification_actors AS na
+	INNER JOIN notifications AS n ON na.notification_id=n.id
+	INNER JOIN notification_preferences AS np ON np.user_id=n.recipient_id AND np.notification_type=n.notification_type
+	WHERE n.recipient_id=$1 AND na.acted_at > $2 AND np.channel='email'
+	GROUP BY n.notification_type
+	ORDER BY count DESC`
+
+	if err := s.db.Select(&activity, query, userId, since); err != nil {
+		return nil, err
+	}
+
+	return activity, nil
+}
+
+// most liked blogs published since the given time by authors the user follows or in topics the user prefers
+func (s *Storage) GetDigestBlogs(userId int, since time.Time, limit int) ([]DigestBlog, error) {
+
+	var blogs []DigestBlog
+
+	query := `SELECT b.id,b.blog_title,u.name AS author_name FROM blogs AS b
+	INNER JOIN users AS u ON b.blog_author_id=u.id
+	WHERE b.is_hidden=false AND b.blog_created_at > $2 AND b.blog_author_id<>$1
+	AND (
+		b.blog_author_id IN (SELECT following_id FROM follows WHERE follower_id=$1)
+		OR b.id IN (SELECT bt.blog_id FROM blog_topics AS bt INNER JOIN user_topic_preferences AS utp ON bt.topic_id=utp.topic_id WHERE utp.user_id=$1)
+	)
+	ORDER BY (SELECT COUNT(*) FROM blog_likes WHERE liked_blog_id=b.id) DESC, b.blog_created_at DESC
+	LIMIT $3`
+
+	if err := s.db.Select(&blogs, query, userId, since, limit); err != nil {
+		return nil, err
+	}
+
+	return blogs, nil
+}
@@ -0,0 +1,221 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+)
+
+type NotificationType string
+
+const (
+	BlogLikeNotification     NotificationType = "blog_like"
+	BlogCommentNotification  NotificationType = "blog_comment"
+	CommentReplyNotification NotificationType = "comment_reply"
+	CommentLikeNotification  NotificationType = "comment_like"
+	FollowNotification       NotificationType = "follow"
+	SeriesPartNotification   NotificationType = "series_part"
+)
+
+var NotificationTypes = []NotificationType{BlogLikeNotification, BlogCommentNotification, CommentReplyNotification, CommentLikeNotification, FollowNotification, SeriesPartNotification}
+
+type Notification struct {
+	Id                    int              `db:"id" json:"id"`
+	RecipientId           int              `db:"recipient_id" json:"recipient_id"`
+	NotificationType      NotificationType `db:"notification_type" json:"notification_type"`
+	GroupKey              string           `db:"group_key" json:"group_key"`
+	BlogId                *int             `db:"blog_id" json:"blog_id"`
+	CommentId             *int             `db:"comment_id" json:"comment_id"`
+	IsRead                bool             `db:"is_read" json:"is_read"`
+	NotificationCreatedAt string           `db:"notification_created_at" json:"notification_created_at"`
+	NotificationUpdatedAt string           `db:"notification_updated_at" json:"notification_updated_at"`
+}
+
+type NotificationActor struct {
+	Id       int     `db:"id" json:"id"`
+	Name     *string `db:"name" json:"name"`
+	ImageUrl *string `db:"image_url" json:"image_url"`
+	ActedAt  string  `db:"acted_at" json:"acted_at"`
+}
+
+type NotificationWithActors struct {
+	Notification
+	ActorsCount  int                 `db:"actors_count" json:"actors_count"`
+	LatestActors []NotificationActor `json:"latest_actors"`
+}
+
+// adds actorId to the recipient's notification for groupKey, creating it if needed.
+// a read notification that gets a new actor becomes unread again
+func (s *Storage) UpsertNotification(recipientId int, notificationType NotificationType, groupKey string, blogId *int, commentId *int, actorId int) (notification *Notification, err error) {
+
+	var newNotification Notification
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	upsertNotificationQuery := `INSERT INTO notifications(recipient_id,notification_type,group_key,blog_id,comment_id) VALUES($1,$2,$3,$4,$5)
+	ON CONFLICT (recipient_id,group_key) DO UPDATE SET is_read=false,notification_updated_at=NOW()
+	RETURNING id,recipient_id,notification_type,group_key,blog_id,comment_id,is_read,notification_created_at,notification_updated_at`
+
+	if err = tx.QueryRowx(upsertNotificationQuery, recipientId, notificationType, groupKey, blogId, commentId).StructScan(&newNotification); err != nil {
+		return nil, err
+	}
+
+	upsertActorQuery := `INSERT INTO notification_actors(notification_id,actor_id) VALUES($1,$2)
+	ON CONFLICT (notification_id,actor_id) DO UPDATE SET acted_at=NOW()`
+
+	if _, err = tx.Exec(upsertActorQuery, newNotification.Id, actorId); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &newNotification, nil
+}
+
+// removes actorId from the recipient's notification for groupKey, the notification
+// itself is deleted once it has no actors left
+func (s *Storage) RemoveNotificationActor(recipientId int, groupKey string, actorId int) (err error) {
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var notificationId int
+
+	if err = tx.QueryRow(`SELECT id FROM notifications WHERE recipient_id=$1 AND group_key=$2 FOR UPDATE`, recipientId, groupKey).Scan(&notificationId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = nil
+			tx.Rollback()
+		}
+		return err
+	}
+
+	if _, err = tx.Exec(`DELETE FROM notification_actors WHERE notification_id=$1 AND actor_id=$2`, notificationId, actorId); err != nil {
+		return err
+	}
+
+	deleteEmptyNotificationQuery := `DELETE FROM notifications WHERE id=$1
+	AND NOT EXISTS (SELECT 1 FROM notification_actors WHERE notification_id=$1)`
+
+	if _, err = tx.Exec(deleteEmptyNotificationQuery, notificationId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *Storage) GetNotifications(recipientId int, unreadOnly bool, skip int, limit int) ([]NotificationWithActors, error) {
+
+	var notifications []NotificationWithActors
+
+	query := `SELECT n.id,n.recipient_id,n.notification_type,n.group_key,n.blog_id,n.comment_id,n.is_read,
+	n.notification_created_at,n.notification_updated_at,
+	(SELECT COUNT(*) FROM notification_actors AS na WHERE na.notification_id=n.id) AS actors_count
+	FROM notifications AS n
+	WHERE n.recipient_id=$1 AND ($2=false OR n.is_read=false)
+	ORDER BY n.notification_updated_at DESC
+	LIMIT $3 OFFSET $4`
+
+	rows, err := s.db.Queryx(query, recipientId, unreadOnly, limit, skip)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var notification NotificationWithActors
+
+		if err := rows.StructScan(&notification); err != nil {
+			return nil, err
+		}
+
+		notifications = append(notifications, notification)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	latestActorsQuery := `SELECT u.id,u.name,u.image_url,na.acted_at FROM notification_actors AS na
+	INNER JOIN users AS u ON na.actor_id=u.id
+	WHERE na.notification_id=$1
+	ORDER BY na.acted_at DESC
+	LIMIT 3`
+
+	for i := range notifications {
+
+		var latestActors []NotificationActor
+
+		if err := s.db.Select(&latestActors, latestActorsQuery, notifications[i].Id); err != nil {
+			return nil, err
+		}
+
+		notifications[i].LatestActors = latestActors
+	}
+
+	return notifications, nil
+}
+
+func (s *Storage) GetNotificationsCount(recipientId int, unreadOnly bool) (int, error) {
+
+	var totalNotificationsCount int
+
+	query := `SELECT COUNT(*) FROM notifications WHERE recipient_id=$1 AND ($2=false OR is_read=false)`
+
+	if err := s.db.QueryRow(query, recipientId, unreadOnly).Scan(&totalNotificationsCount); err != nil {
+		return -1, err
+	}
+
+	return totalNotificationsCount, nil
+}
+
+func (s *Storage) MarkNotificationRead(notificationId int, recipientId int) error {
+
+	query := `UPDATE notifications SET is_read=true WHERE id=$1 AND recipient_id=$2`
+
+	result, err := s.db.Exec(query, notificationId, recipientId)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected != 1 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (s *Storage) MarkAllNotificationsRead(recipientId int) (int64, error) {
+
+	query := `UPDATE notifications SET is_read=true WHERE recipient_id=$1 AND is_read=false`
+
+	result, err := s.db.Exec(query, recipientId)
+	if err != nil {
+		return -1, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// the text and topics of a blog the related blogs job compares
+type RelatedSource struct {
+	Id              int           `db:"id"`
+	BlogTitle       string        `db:"blog_title"`
+	BlogDescription *string       `db:"blog_description"`
+	BlogContent     string        `db:"blog_content"`
+	BlogTopicIds    pq.Int64Array `db:"blog_topic_ids"`
+}
+
+// readers that liked both blogs, and how many liked each
+type BlogCoLikes struct {
+	BlogId      int `db:"blog_id"`
+	OtherBlogId int `db:"other_blog_id"`
+	CoLikes     int `db:"co_likes"`
+	Likes       int `db:"likes"`
+	OtherLikes  int `db:"other_likes"`
+}
+
+type RelatedBlogScore struct {
+	BlogId          int     `db:"blog_id"`
+	RelatedBlogId   int     `db:"related_blog_id"`
+	Score           float64 `db:"score"`
+	TopicScore      float64 `db:"topic_score"`
+	TextScore       float64 `db:"text_score"`
+	EngagementScore float64 `db:"engagement_score"`
+}
+
+type RelatedBlog struct {
+	Id              int     `db:"id" json:"id"`
+	BlogTitle       string  `db:"blog_title" json:"blog_title"`
+	BlogSlug        string  `db:"blog_slug" json:"blog_slug"`
+	BlogDescription *string `db:"blog_description" json:"blog_description"`
+	BlogThumbnail   *string `db:"blog_thumbnail" json:"blog_thumbnail"`
+	BlogAuthorId    int     `db:"blog_author_id" json:"blog_author_id"`
+	AuthorName      *string `db:"author_name" json:"author_name"`
+	AuthorImageUrl  *string `db:"author_image_url" json:"author_image_url"`
+	BlogCreatedAt   string  `db:"blog_created_at" json:"blog_created_at"`
+	Score           float64 `db:"score" json:"score"`
+}
+
+// every blog that is not hidden, with the ids of its topics
+func (s *Storage) GetRelatedSources() ([]RelatedSource, error) {
+
+	var sources []RelatedSource
+
+	query := `SELECT b.id,b.blog_title,b.blog_description,b.blog_content,
+	COALESCE(ARRAY_AGG(bt.topic_id) FILTER (WHERE bt.topic_id IS NOT NULL),'{}') AS blog_topic_ids
+	FROM blogs AS b LEFT JOIN blog_topics AS bt ON bt.blog_id=b.id
+	WHERE b.is_hidden=false
+	GROUP BY b.id
+	ORDER BY b.id ASC`
+
+	if err := s.db.Select(&sources, query); err != nil {
+		return nil, err
+	}
+
+	return sources, nil
+}
+
+// every pair of blogs that are not hidden and that at least one reader liked both of
+func (s *Storage) GetBlogCoLikes() ([]BlogCoLikes, error) {
+
+	var coLikes []BlogCoLikes
+
+	query := `WITH likes AS (
+		SELECT bl.liked_by_id,bl.liked_blog_id FROM blog_likes AS bl
+		INNER JOIN blogs AS b ON bl.liked_blog_id=b.id
+		WHERE b.is_hidden=false
+	), like_counts AS (
+		SELECT liked_blog_id,COUNT(*) AS likes FROM likes GROUP BY liked_blog_id
+	)
+	SELECT a.liked_blog_id AS blog_id,o.liked_blog_id AS other_blog_id,COUNT(*) AS co_likes,
+	MAX(ac.likes) AS likes,MAX(oc.likes) AS other_likes
+	FROM likes AS a
+	INNER JOIN likes AS o ON a.liked_by_id=o.liked_by_id AND a.liked_blog_id<>o.liked_blog_id
+	INNER JOIN like_counts AS ac ON ac.liked_blog_id=a.liked_blog_id
+	INNER JOIN like_counts AS oc ON oc.liked_blog_id=o.liked_blog_id
+	GROUP BY a.liked_blog_id,o.liked_blog_id`
+
+	if err := s.db.Select(&coLikes, query); err != nil {
+		return nil, err
+	}
+
+	return coLikes, nil
+}
+
+// ReplaceRelatedBlogs replaces all related blogs with the given scores in one transaction,
+// so readers never see a half written set
+func (s *Storage) ReplaceRelatedBlogs(scores []RelatedBlogScore, computedAt time.Time) (err error) {
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.Exec(`DELETE FROM related_blogs`); err != nil {
+		return err
+	}
+
+	// blogs deleted while the scores were computed are skipped
+	stmt, err := tx.Prepare(`INSERT INTO related_blogs(blog_id,related_blog_id,score,topic_score,text_score,engagement_score,computed_at)
+	SELECT $1::int,$2::int,$3::float8,$4::float8,$5::float8,$6::float8,$7::timestamp
+	WHERE EXISTS (SELECT 1 FROM blogs WHERE id=$1) AND EXISTS (SELECT 1 FROM blogs WHERE id=$2)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, score := range scores {
+		if _, err = stmt.Exec(score.BlogId, score.RelatedBlogId, score.Score, score.TopicScore, score.TextScore, score.EngagementScore, computedAt); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// the blogs most related to the blog that are not hidden. for a signed in viewer their own blogs
+// and the blogs they read at least readPercent of are left out
+func (s *Storage) GetRelatedBlogs(blogId int, viewerId *int, readPercent int, limit int) ([]RelatedBlog, error) {
+
+	var blogs []RelatedBlog
+
+	query := `SELECT b.id,b.blog_title,b.blog_slug,b.blog_description,b.blog_thumbnail,b.blog_author_id,b.blog_created_at,
+	u.name AS author_name,u.image_url AS author_image_url,rb.score
+	FROM related_blogs AS rb
+	INNER JOIN blogs AS b ON rb.related_blog_id=b.id
+	INNER JOIN users AS u ON b.blog_author_id=u.id
+	WHERE rb.blog_id=$1 AND b.is_hidden=false
+	AND ($2::int IS NULL OR (
+		b.blog_author_id<>$2
+		AND NOT EXISTS (SELECT 1 FROM blog_reads AS br WHERE br.user_id=$2 AND br.blog_id=b.id AND br.read_percent >= $3)
+	))
+	ORDER BY rb.score DESC,b.id DESC
+	LIMIT $4`
+
+	if err := s.db.Select(&blogs, query, blogId, viewerId, readPercent, limit); err != nil {
+		return nil, err
+	}
+
+	return blogs, nil
+}
@@ -0,0 +1,378 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type ReportTargetType string
+
+const (
+	BlogReportTarget        ReportTargetType = "blog"
+	BlogCommentReportTarget ReportTargetType = "blog_comment"
+	UserReportTarget        ReportTargetType = "user"
+)
+
+type ReportReason string
+
+const (
+	SpamReason           ReportReason = "spam"
+	HarassmentReason     ReportReason = "harassment"
+	HateSpeechReason     ReportReason = "hate_speech"
+	ViolenceReason       ReportReason = "violence"
+	SexualContentReason  ReportReason = "sexual_content"
+	MisinformationReason ReportReason = "misinformation"
+	OtherReason          ReportReason = "other"
+)
+
+type ReportStatus string
+
+const (
+	PendingReportStatus   ReportStatus = "pending"
+	DismissedReportStatus ReportStatus = "dismissed"
+	ActionedReportStatus  ReportStatus = "actioned"
+)
+
+type ModerationAction string
+
+const (
+	DismissAction ModerationAction = "dismiss"
+	HideAction    ModerationAction = "hide"
+	DeleteAction  ModerationAction = "delete"
+	WarnAction    ModerationAction = "warn"
+	SuspendAction ModerationAction = "suspend"
+)
+
+var ReportTargetTypes = []ReportTargetType{BlogReportTarget, BlogCommentReportTarget, UserReportTarget}
+
+var ReportReasons = []ReportReason{SpamReason, HarassmentReason, HateSpeechReason, ViolenceReason, SexualContentReason, MisinformationReason, OtherReason}
+
+var ModerationActions = []ModerationAction{DismissAction, HideAction, DeleteAction, WarnAction, SuspendAction}
+
+type Report struct {
+	Id               int              `db:"id" json:"id"`
+	ReporterId       int              `db:"reporter_id" json:"reporter_id"`
+	TargetType       ReportTargetType `db:"target_type" json:"target_type"`
+	TargetId         int              `db:"target_id" json:"target_id"`
+	Reason           ReportReason     `db:"reason" json:"reason"`
+	Details          *string          `db:"details" json:"details"`
+	Status           ReportStatus     `db:"status" json:"status"`
+	ResolutionAction *string          `db:"resolution_action" json:"resolution_action"`
+	ResolutionNote   *string          `db:"resolution_note" json:"resolution_note"`
+	ResolvedById     *int             `db:"resolved_by_id" json:"resolved_by_id"`
+	ResolvedAt       *string          `db:"resolved_at" json:"resolved_at"`
+	ReportedAt       string           `db:"reported_at" json:"reported_at"`
+}
+
+// ReportedTarget is a moderation queue entry, all reports against one target grouped together
+type ReportedTarget struct {
+	TargetType      ReportTargetType `db:"target_type" json:"target_type"`
+	TargetId        int              `db:"target_id" json:"target_id"`
+	ReportsCount    int              `db:"reports_count" json:"reports_count"`
+	Reasons         pq.StringArray   `db:"reasons" json:"reasons"`
+	FirstReportedAt string           `db:"first_reported_at" json:"first_reported_at"`
+	LastReportedAt  string           `db:"last_reported_at" json:"last_reported_at"`
+}
+
+type ModerationResult struct {
+	TargetType       ReportTargetType `json:"target_type"`
+	TargetId         int              `json:"target_id"`
+	TargetOwnerId    int              `json:"target_owner_id"`
+	Action           ModerationAction `json:"action"`
+	ResolvedReports  int64            `json:"resolved_reports"`
+	SuspendedUntil   *time.Time       `json:"suspended_until,omitempty"`
+	ModerationNote   string           `json:"moderation_note"`
+	ResolvedByUserId int              `json:"resolved_by_user_id"`
+}
+
+func (s *Storage) CreateReport(reporterId int, targetType ReportTargetType, targetId int, reason ReportReason, details string) (*Report, error) {
+
+	var report Report
+
+	query := `INSERT INTO reports(reporter_id,target_type,target_id,reason,details) VALUES($1,$2,$3,$4,NULLIF($5,''))
+	RETURNING id,reporter_id,target_type,target_id,reason,details,status,resolution_action,resolution_note,resolved_by_id,resolved_at,reported_at`
+
+	row := s.db.QueryRowx(query, reporterId, targetType, targetId, reason, details)
+
+	if err := row.StructScan(&report); err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
+
+func (s *Storage) GetReportByReporter(reporterId int, targetType ReportTargetType, targetId int) (*Report, error) {
+
+	var report Report
+
+	query := `SELECT id,reporter_id,target_type,target_id,reason,details,status,resolution_action,resolution_note,resolved_by_id,resolved_at,reported_at
+	FROM reports WHERE reporter_id=$1 AND target_type=$2 AND target_id=$3`
+
+	if err := s.db.QueryRowx(query, reporterId, targetType, targetId).StructScan(&report); err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
+
+func (s *Storage) GetReportsByReporter(reporterId int, skip int, limit int) ([]Report, error) {
+
+	var reports []Report
+
+	query := `SELECT id,reporter_id,target_type,target_id,reason,details,status,resolution_action,resolution_note,resolved_by_id,resolved_at,reported_at
+	FROM reports WHERE reporter_id=$1
+	ORDER BY reported_at DESC
+	LIMIT $2 OFFSET $3`
+
+	if err := s.db.Select(&reports, query, reporterId, limit, skip); err != nil {
+		return nil, err
+	}
+
+	return reports, nil
+}
+
+func (s *Storage) GetReportsCountByReporter(reporterId int) (int, error) {
+
+	var totalReportsCount int
+
+	query := `SELECT COUNT(*) FROM reports WHERE reporter_id=$1`
+
+	if err := s.db.QueryRow(query, reporterId).Scan(&totalReportsCount); err != nil {
+		return -1, err
+	}
+
+	return totalReportsCount, nil
+}
+
+func (s *Storage) GetReportsByTarget(targetType ReportTargetType, targetId int) ([]Report, error) {
+
+	var reports []Report
+
+	query := `SELECT id,reporter_id,target_type,target_id,reason,details,status,resolution_action,resolution_note,resolved_by_id,resolved_at,reported_at
+	FROM reports WHERE target_type=$1 AND target_id=$2
+	ORDER BY reported_at DESC`
+
+	if err := s.db.Select(&reports, query, targetType, targetId); err != nil {
+		return nil, err
+	}
+
+	return reports, nil
+}
+
+func (s *Storage) GetPendingReportsCountByTarget(targetType ReportTargetType, targetId int) (int, error) {
+
+	var pendingReportsCount int
+
+	query := `SELECT COUNT(*) FROM reports WHERE target_type=$1 AND target_id=$2 AND status='pending'`
+
+	if err := s.db.QueryRow(query, targetType, targetId).Scan(&pendingReportsCount); err != nil {
+		return -1, err
+	}
+
+	return pendingReportsCount, nil
+}
+
+// the moderation queue, targets with the most reports come first
+func (s *Storage) GetReportedTargets(status ReportStatus, skip int, limit int) ([]ReportedTarget, error) {
+
+	var reportedTargets []ReportedTarget
+
+	query := `SELECT target_type,target_id,COUNT(*) AS reports_count,
+	ARRAY_AGG(DISTINCT reason::TEXT) AS reasons,
+	MIN(reported_at) AS first_reported_at,
+	MAX(reported_at) AS last_reported_at
+	FROM reports WHERE status=$1
+	GROUP BY target_type,target_id
+	ORDER BY reports_count DESC, last_reported_at DESC
+	LIMIT $2 OFFSET $3`
+
+	if err := s.db.Select(&reportedTargets, query, status, limit, skip); err != nil {
+		return nil, err
+	}
+
+	return reportedTargets, nil
+}
+
+func (s *Storage) GetReportedTargetsCount(status ReportStatus) (int, error) {
+
+	var totalTargetsCount int
+
+	query := `SELECT COUNT(DISTINCT (target_type,target_id)) FROM reports WHERE status=$1`
+
+	if err := s.db.QueryRow(query, status).Scan(&totalTargetsCount); err != nil {
+		return -1, err
+	}
+
+	return totalTargetsCount, nil
+}
+
+// reportTargetOwnerQuery selects the id of the user responsible for the reported target
+func reportTargetOwnerQuery(targetType ReportTargetType) (string, error) {
+
+	switch targetType {
+	case BlogReportTarget:
+		return `SELECT blog_author_id FROM blogs WHERE id=$1`, nil
+	case BlogCommentReportTarget:
+		return `SELECT comment_author_id FROM blog_comments WHERE id=$1`, nil
+	case UserReportTarget:
+		return `SELECT id FROM users WHERE id=$1`, nil
+	}
+
+	return "", errors.New("invalid report target type")
+}
+
+// returns the id of the user responsible for the reported target
+func (s *Storage) GetReportTargetOwnerId(targetType ReportTargetType, targetId int) (int, error) {
+
+	var ownerId int
+
+	query, err := reportTargetOwnerQuery(targetType)
+	if err != nil {
+		return -1, err
+	}
+
+	if err := s.db.QueryRow(query, targetId).Scan(&ownerId); err != nil {
+		return -1, err
+	}
+
+	return ownerId, nil
+}
+
+func (s *Storage) HideReportTarget(targetType ReportTargetType, targetId int) error {
+
+	var query string
+
+	switch targetType {
+	case BlogReportTarget:
+		query = `UPDATE blogs SET is_hidden=true WHERE id=$1`
+	case BlogCommentReportTarget:
+		query = `UPDATE blog_comments SET is_hidden=true WHERE id=$1`
+	default:
+		return errors.New("report target cannot be hidden")
+	}
+
+	_, err := s.db.Exec(query, targetId)
+	return err
+}
+
+func (s *Storage) IsUserSuspended(userId int) (bool, error) {
+
+	var isSuspended bool
+
+	query := `SELECT COALESCE(suspended_until > NOW(),false) FROM users WHERE id=$1`
+
+	if err := s.db.QueryRow(query, userId).Scan(&isSuspended); err != nil {
+		return false, err
+	}
+
+	return isSuspended, nil
+}
+
+// applies a moderation action to a reported target and resolves all its pending reports in one transaction
+func (s *Storage) ApplyModerationAction(targetType ReportTargetType, targetId int, action ModerationAction, note string, suspendFor time.Duration, moderatorId int) (result *ModerationResult, err error) {
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	result = &ModerationResult{
+		TargetType:       targetType,
+		TargetId:         targetId,
+		Action:           action,
+		ModerationNote:   note,
+		ResolvedByUserId: moderatorId,
+	}
+
+	ownerQuery, err := reportTargetOwnerQuery(targetType)
+	if err != nil {
+		return nil, err
+	}
+
+	// the target is locked so its owner cannot change before the action is applied to them
+	if err = tx.QueryRow(ownerQuery+` FOR UPDATE`, targetId).Scan(&result.TargetOwnerId); err != nil {
+		return nil, err
+	}
+
+	// content the reports hid automatically is shown again when they are dismissed, unless a moderator hid it before
+	notHiddenByModeratorCondition := `NOT EXISTS (SELECT 1 FROM reports WHERE target_type=$2 AND target_id=$1
+	AND status='actioned' AND resolution_action='hide')`
+
+	switch action {
+	case DismissAction:
+		switch targetType {
+		case BlogReportTarget:
+			_, err = tx.Exec(`UPDATE blogs SET is_hidden=false WHERE id=$1 AND `+notHiddenByModeratorCondition, targetId, targetType)
+		case BlogCommentReportTarget:
+			_, err = tx.Exec(`UPDATE blog_comments SET is_hidden=false WHERE id=$1 AND `+notHiddenByModeratorCondition, targetId, targetType)
+		}
+	case HideAction:
+		switch targetType {
+		case BlogReportTarget:
+			_, err = tx.Exec(`UPDATE blogs SET is_hidden=true WHERE id=$1`, targetId)
+		case BlogCommentReportTarget:
+			_, err = tx.Exec(`UPDATE blog_comments SET is_hidden=true WHERE id=$1`, targetId)
+		default:
+			err = errors.New("report target cannot be hidden")
+		}
+	case DeleteAction:
+		switch targetType {
+		case BlogReportTarget:
+			_, err = tx.Exec(`DELETE FROM blogs WHERE id=$1`, targetId)
+		case BlogCommentReportTarget:
+			_, err = tx.Exec(`DELETE FROM blog_comments WHERE id=$1`, targetId)
+		default:
+			err = errors.New("report target cannot be deleted")
+		}
+	case WarnAction:
+		_, err = tx.Exec(`INSERT INTO user_warnings(user_id,issued_by_id,reason) VALUES($1,$2,NULLIF($3,''))`, result.TargetOwnerId, moderatorId, note)
+	case SuspendAction:
+		suspendedUntil := time.Now().Add(suspendFor)
+		result.SuspendedUntil = &suspendedUntil
+		_, err = tx.Exec(`UPDATE users SET suspended_until=$1 WHERE id=$2`, suspendedUntil, result.TargetOwnerId)
+	default:
+		err = errors.New("invalid moderation action")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	status := ActionedReportStatus
+	if action == DismissAction {
+		status = DismissedReportStatus
+	}
+
+	resolveReportsQuery := `UPDATE reports SET status=$1,resolution_action=$2,resolution_note=NULLIF($3,''),resolved_by_id=$4,resolved_at=NOW()
+	WHERE target_type=$5 AND target_id=$6 AND status='pending'`
+
+	sqlResult, err := tx.Exec(resolveReportsQuery, status, action, note, moderatorId, targetType, targetId)
+	if err != nil {
+		return nil, err
+	}
+
+	result.ResolvedReports, err = sqlResult.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if result.ResolvedReports == 0 {
+		err = sql.ErrNoRows
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+)
+
+func newMockStorage(t *testing.T) (*Storage, sqlmock.Sqlmock) {
+
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sql mock: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return NewStorage(sqlx.NewDb(db, "postgres")), mock
+}
+
+func TestApplyModerationActionDismissUnhidesTarget(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		targetType  ReportTargetType
+		ownerQuery  string
+		unhideQuery string
+	}{
+		{
+			name:        "blog",
+			targetType:  BlogReportTarget,
+			ownerQuery:  `SELECT blog_author_id FROM blogs WHERE id=$1 FOR UPDATE`,
+			unhideQuery: `UPDATE blogs SET is_hidden=false WHERE id=$1 AND NOT EXISTS`,
+		},
+		{
+			name:        "blog comment",
+			targetType:  BlogCommentReportTarget,
+			ownerQuery:  `SELECT comment_author_id FROM blog_comments WHERE id=$1 FOR UPDATE`,
+			unhideQuery: `UPDATE blog_comments SET is_hidden=false WHERE id=$1 AND NOT EXISTS`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			s, mock := newMockStorage(t)
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(regexp.QuoteMeta(tt.ownerQuery)).WithArgs(7).
+				WillReturnRows(sqlmock.NewRows([]string{"owner_id"}).AddRow(3))
+			mock.ExpectExec(regexp.QuoteMeta(tt.unhideQuery)).WithArgs(7, tt.targetType).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec(regexp.QuoteMeta(`UPDATE reports SET status=$1`)).
+				WithArgs(DismissedReportStatus, DismissAction, "", 1, tt.targetType, 7).
+				WillReturnResult(sqlmock.NewResult(0, 4))
+			mock.ExpectCommit()
+
+			result, err := s.ApplyModerationAction(tt.targetType, 7, DismissAction, "", 0, 1)
+			if err != nil {
+				t.Fatalf("ApplyModerationAction() error = %v", err)
+			}
+
+			if result.ResolvedReports != 4 {
+				t.Errorf("ResolvedReports = %d, want 4", result.ResolvedReports)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestApplyModerationActionDismissUserLeavesContent(t *testing.T) {
+
+	s, mock := newMockStorage(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id FROM users WHERE id=$1 FOR UPDATE`)).WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE reports SET status=$1`)).
+		WithArgs(DismissedReportStatus, DismissAction, "", 1, UserReportTarget, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if _, err := s.ApplyModerationAction(UserReportTarget, 5, DismissAction, "", 0, 1); err != nil {
+		t.Fatalf("ApplyModerationAction() error = %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestApplyModerationActionDismissRollsBackWithoutPendingReports(t *testing.T) {
+
+	s, mock := newMockStorage(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT blog_author_id FROM blogs WHERE id=$1 FOR UPDATE`)).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"blog_author_id"}).AddRow(3))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE blogs SET is_hidden=false`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE reports SET status=$1`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	if _, err := s.ApplyModerationAction(BlogReportTarget, 7, DismissAction, "", 0, 1); err == nil {
+		t.Fatal("ApplyModerationAction() error = nil, want sql.ErrNoRows")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
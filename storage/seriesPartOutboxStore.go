@@ -0,0 +1,73 @@
+package storage
+
+import "time"
+
+type seriesPartOutboxStatus string
+
+const (
+	PendingSeriesPartStatus seriesPartOutboxStatus = "pending"
+	SendingSeriesPartStatus seriesPartOutboxStatus = "sending"
+	SentSeriesPartStatus    seriesPartOutboxStatus = "sent"
+	DeadSeriesPartStatus    seriesPartOutboxStatus = "dead"
+)
+
+// OutboxSeriesPart is a blog added to a series whose followers still have to be notified
+type OutboxSeriesPart struct {
+	Id            int64                  `db:"id" json:"id"`
+	SeriesId      int                    `db:"series_id" json:"series_id"`
+	BlogId        int                    `db:"blog_id" json:"blog_id"`
+	Status        seriesPartOutboxStatus `db:"status" json:"status"`
+	Attempts      int                    `db:"attempts" json:"attempts"`
+	MaxAttempts   int                    `db:"max_attempts" json:"max_attempts"`
+	NextAttemptAt string                 `db:"next_attempt_at" json:"next_attempt_at"`
+	LockedUntil   *string                `db:"locked_until" json:"locked_until"`
+	LastError     *string                `db:"last_error" json:"last_error"`
+	CreatedAt     string                 `db:"created_at" json:"created_at"`
+	SentAt        *string                `db:"sent_at" json:"sent_at"`
+}
+
+// claims up to batchSize due series parts to notify the followers of. claimed rows are leased until
+// leaseUntil, if a worker dies mid fan out the row becomes claimable again once the lease expires
+func (s *Storage) ClaimOutboxSeriesParts(batchSize int, leaseUntil time.Time) ([]OutboxSeriesPart, error) {
+
+	var parts []OutboxSeriesPart
+
+	query := `UPDATE series_part_notifications SET status='sending',attempts=attempts+1,locked_until=$2
+	WHERE id IN (
+		SELECT id FROM series_part_notifications
+		WHERE (status='pending' AND next_attempt_at <= NOW()) OR (status='sending' AND locked_until < NOW())
+		ORDER BY next_attempt_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	) RETURNING id,series_id,blog_id,status,attempts,max_attempts,next_attempt_at,locked_until,last_error,created_at,sent_at`
+
+	if err := s.db.Select(&parts, query, batchSize, leaseUntil); err != nil {
+		return nil, err
+	}
+
+	return parts, nil
+}
+
+func (s *Storage) MarkOutboxSeriesPartSent(id int64) error {
+
+	query := `UPDATE series_part_notifications SET status='sent',sent_at=NOW(),locked_until=NULL,last_error=NULL WHERE id=$1`
+
+	_, err := s.db.Exec(query, id)
+	return err
+}
+
+func (s *Storage) MarkOutboxSeriesPartFailed(id int64, lastError string, nextAttemptAt time.Time) error {
+
+	query := `UPDATE series_part_notifications SET status='pending',locked_until=NULL,last_error=$2,next_attempt_at=$3 WHERE id=$1`
+
+	_, err := s.db.Exec(query, id, lastError, nextAttemptAt)
+	return err
+}
+
+func (s *Storage) MarkOutboxSeriesPartDead(id int64, lastError string) error {
+
+	query := `UPDATE series_part_notifications SET status='dead',locked_until=NULL,last_error=$2 WHERE id=$1`
+
+	_, err := s.db.Exec(query, id, lastError)
+	return err
+}
@@ -0,0 +1,340 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+)
+
+var ErrSeriesOrderMismatch = errors.New("series order must list every blog in the series once")
+
+type Series struct {
+	Id          int     `db:"id" json:"id"`
+	AuthorId    int     `db:"author_id" json:"author_id"`
+	Title       string  `db:"title" json:"title"`
+	Description *string `db:"description" json:"description"`
+	CreatedAt   string  `db:"created_at" json:"created_at"`
+	UpdatedAt   string  `db:"updated_at" json:"updated_at"`
+}
+
+// a blog of a series, part counts from 1 and leaves out hidden blogs
+type SeriesPart struct {
+	Part            int     `db:"part" json:"part"`
+	Id              int     `db:"id" json:"id"`
+	BlogTitle       string  `db:"blog_title" json:"blog_title"`
+	BlogSlug        string  `db:"blog_slug" json:"blog_slug"`
+	BlogDescription *string `db:"blog_description" json:"blog_description"`
+	BlogThumbnail   *string `db:"blog_thumbnail" json:"blog_thumbnail"`
+	BlogCreatedAt   string  `db:"blog_created_at" json:"blog_created_at"`
+}
+
+// where a blog is in its series, for moving between parts while reading
+type BlogSeriesNav struct {
+	SeriesId    int         `json:"series_id"`
+	SeriesTitle string      `json:"series_title"`
+	Part        int         `json:"part"`
+	Parts       int         `json:"parts"`
+	Previous    *SeriesPart `json:"previous"`
+	Next        *SeriesPart `json:"next"`
+}
+
+const seriesColumns = `id,author_id,title,description,created_at,updated_at`
+
+func (s *Storage) CreateSeries(authorId int, title string, description *string) (*Series, error) {
+
+	var series Series
+
+	query := `INSERT INTO series(author_id,title,description) VALUES($1,$2,$3) RETURNING ` + seriesColumns
+
+	if err := s.db.QueryRowx(query, authorId, title, description).StructScan(&series); err != nil {
+		return nil, err
+	}
+
+	return &series, nil
+}
+
+func (s *Storage) GetSeriesById(seriesId int) (*Series, error) {
+
+	var series Series
+
+	query := `SELECT ` + seriesColumns + ` FROM series WHERE id=$1`
+
+	if err := s.db.QueryRowx(query, seriesId).StructScan(&series); err != nil {
+		return nil, err
+	}
+
+	return &series, nil
+}
+
+func (s *Storage) UpdateSeries(seriesId int, title string, description *string) (*Series, error) {
+
+	var series Series
+
+	query := `UPDATE series SET title=$1,description=$2,updated_at=NOW() WHERE id=$3 RETURNING ` + seriesColumns
+
+	if err := s.db.QueryRowx(query, title, description, seriesId).StructScan(&series); err != nil {
+		return nil, err
+	}
+
+	return &series, nil
+}
+
+// deletes the series, its blogs are kept
+func (s *Storage) DeleteSeries(seriesId int) error {
+
+	result, err := s.db.Exec(`DELETE FROM series WHERE id=$1`, seriesId)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected != 1 {
+		return errors.New("failed to delete series")
+	}
+
+	return nil
+}
+
+// the blogs of the series that are not hidden, in order
+func (s *Storage) GetSeriesParts(seriesId int) ([]SeriesPart, error) {
+
+	var parts []SeriesPart
+
+	query := `SELECT ROW_NUMBER() OVER (ORDER BY sb.position ASC) AS part,
+	b.id,b.blog_title,b.blog_slug,b.blog_description,b.blog_thumbnail,b.blog_created_at
+	FROM series_blogs AS sb INNER JOIN blogs AS b ON sb.blog_id=b.id
+	WHERE sb.series_id=$1 AND b.is_hidden=false
+	ORDER BY sb.position ASC`
+
+	if err := s.db.Select(&parts, query, seriesId); err != nil {
+		return nil, err
+	}
+
+	return parts, nil
+}
+
+// the id of the series the blog is a part of, sql.ErrNoRows if it is in none
+func (s *Storage) GetSeriesIdByBlogId(blogId int) (int, error) {
+
+	var seriesId int
+
+	if err := s.db.Get(&seriesId, `SELECT series_id FROM series_blogs WHERE blog_id=$1`, blogId); err != nil {
+		return 0, err
+	}
+
+	return seriesId, nil
+}
+
+// GetBlogSeriesNav is the part the blog is of its series and the parts before and after it,
+// sql.ErrNoRows is returned when the blog is in no series
+func (s *Storage) GetBlogSeriesNav(blogId int) (*BlogSeriesNav, error) {
+
+	seriesId, err := s.GetSeriesIdByBlogId(blogId)
+	if err != nil {
+		return nil, err
+	}
+
+	series, err := s.GetSeriesById(seriesId)
+	if err != nil {
+		return nil, err
+	}
+
+	parts, err := s.GetSeriesParts(seriesId)
+	if err != nil {
+		return nil, err
+	}
+
+	index := slices.IndexFunc(parts, func(part SeriesPart) bool { return part.Id == blogId })
+	if index == -1 {
+		return nil, sql.ErrNoRows
+	}
+
+	nav := BlogSeriesNav{SeriesId: series.Id, SeriesTitle: series.Title, Part: parts[index].Part, Parts: len(parts)}
+
+	if index > 0 {
+		nav.Previous = &parts[index-1]
+	}
+	if index < len(parts)-1 {
+		nav.Next = &parts[index+1]
+	}
+
+	return &nav, nil
+}
+
+// AddSeriesBlog puts the blog at the end of the series, the blog must not be in any series yet.
+// the followers of the series are notified by queueing the part, only the first time it is added
+func (s *Storage) AddSeriesBlog(seriesId int, blogId int) (err error) {
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	query := `INSERT INTO series_blogs(series_id,blog_id,position)
+	SELECT $1::int,$2::int,COALESCE(MAX(position),0) + 1 FROM series_blogs WHERE series_id=$1`
+
+	if _, err = tx.Exec(query, seriesId, blogId); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(`UPDATE series SET updated_at=NOW() WHERE id=$1`, seriesId); err != nil {
+		return err
+	}
+
+	notificationQuery := `INSERT INTO series_part_notifications(series_id,blog_id) VALUES($1,$2) ON CONFLICT (series_id,blog_id) DO NOTHING`
+
+	if _, err = tx.Exec(notificationQuery, seriesId, blogId); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// takes the blog out of the series, sql.ErrNoRows is returned when the blog is not in it
+func (s *Storage) RemoveSeriesBlog(seriesId int, blogId int) (err error) {
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	result, err := tx.Exec(`DELETE FROM series_blogs WHERE series_id=$1 AND blog_id=$2`, seriesId, blogId)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected != 1 {
+		err = sql.ErrNoRows
+		return err
+	}
+
+	if _, err = tx.Exec(`UPDATE series SET updated_at=NOW() WHERE id=$1`, seriesId); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ReorderSeries puts the blogs of the series in the order of blogIds, which must list every blog in the series
+// that is not hidden exactly once, ErrSeriesOrderMismatch is returned otherwise. hidden blogs keep their order
+// after the others
+func (s *Storage) ReorderSeries(seriesId int, blogIds []int) (err error) {
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = seriesBlogs.reorder(tx, seriesId, blogIds); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(`UPDATE series SET updated_at=NOW() WHERE id=$1`, seriesId); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Storage) IsFollowingSeries(seriesId int, userId int) (bool, error) {
+
+	var following bool
+
+	query := `SELECT EXISTS (SELECT 1 FROM series_follows WHERE series_id=$1 AND user_id=$2)`
+
+	if err := s.db.Get(&following, query, seriesId, userId); err != nil {
+		return false, err
+	}
+
+	return following, nil
+}
+
+func (s *Storage) CreateSeriesFollow(seriesId int, userId int) error {
+
+	query := `INSERT INTO series_follows(series_id,user_id) VALUES($1,$2) ON CONFLICT (series_id,user_id) DO NOTHING`
+
+	if _, err := s.db.Exec(query, seriesId, userId); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Storage) RemoveSeriesFollow(seriesId int, userId int) error {
+
+	if _, err := s.db.Exec(`DELETE FROM series_follows WHERE series_id=$1 AND user_id=$2`, seriesId, userId); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Storage) GetSeriesFollowersCount(seriesId int) (int, error) {
+
+	var count int
+
+	if err := s.db.Get(&count, `SELECT COUNT(*) FROM series_follows WHERE series_id=$1`, seriesId); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// followers of the series that actorId is not yet an actor of the groupKey notification of,
+// so a retried fan out skips the followers it already notified
+func (s *Storage) GetSeriesFollowerIdsToNotify(seriesId int, groupKey string, actorId int) ([]int, error) {
+
+	var followerIds []int
+
+	query := `SELECT sf.user_id FROM series_follows AS sf
+	WHERE sf.series_id=$1 AND NOT EXISTS (
+		SELECT 1 FROM notifications AS n
+		INNER JOIN notification_actors AS na ON na.notification_id=n.id
+		WHERE n.recipient_id=sf.user_id AND n.group_key=$2 AND na.actor_id=$3
+	)`
+
+	if err := s.db.Select(&followerIds, query, seriesId, groupKey, actorId); err != nil {
+		return nil, err
+	}
+
+	return followerIds, nil
+}
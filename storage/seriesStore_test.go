@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestAddSeriesBlogQueuesPartNotification(t *testing.T) {
+
+	s, mock := newMockStorage(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO series_blogs(series_id,blog_id,position)`)).WithArgs(3, 9).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE series SET updated_at=NOW() WHERE id=$1`)).WithArgs(3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// adding a removed part again finds its row and does not notify twice
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO series_part_notifications(series_id,blog_id) VALUES($1,$2) ON CONFLICT (series_id,blog_id) DO NOTHING`)).
+		WithArgs(3, 9).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	if err := s.AddSeriesBlog(3, 9); err != nil {
+		t.Fatalf("AddSeriesBlog() error = %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,55 @@
+package storage
+
+import "time"
+
+type SitemapKind string
+
+const (
+	BlogSitemapKind    SitemapKind = "blogs"
+	TopicSitemapKind   SitemapKind = "topics"
+	ProfileSitemapKind SitemapKind = "profiles"
+)
+
+var SitemapKinds = []SitemapKind{BlogSitemapKind, TopicSitemapKind, ProfileSitemapKind}
+
+type SitemapEntry struct {
+	// slug of a blog or topic, id of a profile
+	Key          string    `db:"key" json:"key"`
+	LastModified time.Time `db:"last_modified" json:"last_modified"`
+}
+
+// blogs that are not hidden, every topic, and the profiles of users with at least one blog that is not hidden
+var sitemapQueries = map[SitemapKind]string{
+	BlogSitemapKind: `SELECT blog_slug AS key,COALESCE(blog_updated_at,blog_created_at) AS last_modified
+	FROM blogs WHERE is_hidden=false`,
+	TopicSitemapKind: `SELECT topic_slug AS key,COALESCE(topic_updated_at,topic_created_at) AS last_modified
+	FROM topics`,
+	ProfileSitemapKind: `SELECT blog_author_id::text AS key,MAX(COALESCE(blog_updated_at,blog_created_at)) AS last_modified
+	FROM blogs WHERE is_hidden=false GROUP BY blog_author_id`,
+}
+
+func (s *Storage) GetSitemapEntries(kind SitemapKind, skip int, limit int) ([]SitemapEntry, error) {
+
+	var entries []SitemapEntry
+
+	query := `SELECT key,last_modified FROM (` + sitemapQueries[kind] + `) AS entries
+	ORDER BY key ASC
+	LIMIT $1 OFFSET $2`
+
+	if err := s.db.Select(&entries, query, limit, skip); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (s *Storage) GetSitemapEntriesCount(kind SitemapKind) (int, error) {
+
+	var count int
+
+	if err := s.db.Get(&count, `SELECT COUNT(*) FROM (`+sitemapQueries[kind]+`) AS entries`); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type slugQueryer interface {
+	Select(dest any, query string, args ...any) error
+}
+
+// availableBlogSlug returns the slug, or the slug with the lowest number from 2 appended, that no
+// other blog has or had. a blog may take back a slug it had before.
+// the slug is locked until tx ends, so concurrent saves of blogs with the same title take turns
+// and the later one sees the slug the earlier one took
+func availableBlogSlug(tx *sqlx.Tx, slug string, blogId int) (string, error) {
+
+	var taken []string
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, "blog_slug:"+slug); err != nil {
+		return "", err
+	}
+
+	query := `SELECT blog_slug FROM blogs WHERE (blog_slug=$1 OR blog_slug LIKE $1 || '-%') AND id<>$2
+	UNION
+	SELECT slug FROM blog_slug_history WHERE (slug=$1 OR slug LIKE $1 || '-%') AND blog_id<>$2`
+
+	if err := tx.Select(&taken, query, slug, blogId); err != nil {
+		return "", err
+	}
+
+	return firstFreeSlug(slug, taken), nil
+}
+
+// availableTopicSlug is availableBlogSlug for topics, which keep no slug history
+func availableTopicSlug(q slugQueryer, slug string, topicId int) (string, error) {
+
+	var taken []string
+
+	query := `SELECT topic_slug FROM topics WHERE (topic_slug=$1 OR topic_slug LIKE $1 || '-%') AND id<>$2`
+
+	if err := q.Select(&taken, query, slug, topicId); err != nil {
+		return "", err
+	}
+
+	return firstFreeSlug(slug, taken), nil
+}
+
+func firstFreeSlug(slug string, taken []string) string {
+
+	used := make(map[string]bool, len(taken))
+	for _, t := range taken {
+		used[t] = true
+	}
+
+	candidate := slug
+	for n := 2; used[candidate]; n++ {
+		candidate = slug + "-" + strconv.Itoa(n)
+	}
+
+	return candidate
+}
+
+// hasSlugBase reports whether current is slug, or slug with a number appended to make it unique,
+// so a title change that leads to the same slug keeps the current one
+func hasSlugBase(current string, slug string) bool {
+
+	if current == slug {
+		return true
+	}
+
+	suffix, ok := strings.CutPrefix(current, slug+"-")
+	if !ok {
+		return false
+	}
+
+	n, err := strconv.Atoi(suffix)
+	return err == nil && n >= 2 && strconv.Itoa(n) == suffix
+}
+
+// id of the blog that is not hidden with the slug
+func (s *Storage) GetBlogIdBySlug(slug string) (int, error) {
+
+	var blogId int
+
+	if err := s.db.Get(&blogId, `SELECT id FROM blogs WHERE blog_slug=$1 AND is_hidden=false`, slug); err != nil {
+		return 0, err
+	}
+
+	return blogId, nil
+}
+
+// current slug of the blog that had the slug before its title changed
+func (s *Storage) GetRedirectedBlogSlug(slug string) (string, error) {
+
+	var currentSlug string
+
+	query := `SELECT b.blog_slug FROM blog_slug_history AS bsh
+	INNER JOIN blogs AS b ON bsh.blog_id=b.id
+	WHERE bsh.slug=$1 AND b.is_hidden=false`
+
+	if err := s.db.Get(&currentSlug, query, slug); err != nil {
+		return "", err
+	}
+
+	return currentSlug, nil
+}
+
+func (s *Storage) GetTopicBySlug(slug string) (*Topic, error) {
+
+	var topic Topic
+
+	query := `SELECT id,topic_title,topic_slug,topic_created_at,topic_updated_at
+	FROM topics WHERE topic_slug=$1`
+
+	if err := s.db.QueryRowx(query, slug).StructScan(&topic); err != nil {
+		return nil, err
+	}
+
+	return &topic, nil
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestAvailableBlogSlugLocksSlug(t *testing.T) {
+
+	s, mock := newMockStorage(t)
+
+	mock.ExpectBegin()
+	// the lock is taken before the lookup, a concurrent save of the same title waits for this one
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_xact_lock(hashtext($1))`)).WithArgs("blog_slug:hello-world").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT blog_slug FROM blogs`)).WithArgs("hello-world", 0).
+		WillReturnRows(sqlmock.NewRows([]string{"blog_slug"}).AddRow("hello-world"))
+	mock.ExpectRollback()
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slug, err := availableBlogSlug(tx, "hello-world", 0)
+	if err != nil {
+		t.Fatalf("availableBlogSlug() error = %v", err)
+	}
+
+	if slug != "hello-world-2" {
+		t.Errorf("availableBlogSlug() = %q, want %q", slug, "hello-world-2")
+	}
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFirstFreeSlug(t *testing.T) {
+
+	tests := []struct {
+		slug  string
+		taken []string
+		want  string
+	}{
+		{slug: "hello-world", taken: nil, want: "hello-world"},
+		{slug: "hello-world", taken: []string{"hello-world"}, want: "hello-world-2"},
+		{slug: "hello-world", taken: []string{"hello-world", "hello-world-2", "hello-world-3"}, want: "hello-world-4"},
+		// gaps are reused
+		{slug: "hello-world", taken: []string{"hello-world", "hello-world-3"}, want: "hello-world-2"},
+		// other slugs sharing the prefix do not count
+		{slug: "hello", taken: []string{"hello-world", "hello-2-again"}, want: "hello"},
+		{slug: "part", taken: []string{"part", "part-2", "part-2-2"}, want: "part-3"},
+	}
+
+	for _, tt := range tests {
+		if got := firstFreeSlug(tt.slug, tt.taken); got != tt.want {
+			t.Errorf("firstFreeSlug(%q, %q) = %q, want %q", tt.slug, tt.taken, got, tt.want)
+		}
+	}
+}
+
+func TestHasSlugBase(t *testing.T) {
+
+	tests := []struct {
+		current string
+		slug    string
+		want    bool
+	}{
+		{current: "hello-world", slug: "hello-world", want: true},
+		{current: "hello-world-2", slug: "hello-world", want: true},
+		{current: "hello-world-12", slug: "hello-world", want: true},
+		// numbers firstFreeSlug never appends
+		{current: "hello-world-1", slug: "hello-world", want: false},
+		{current: "hello-world-0", slug: "hello-world", want: false},
+		{current: "hello-world-02", slug: "hello-world", want: false},
+		{current: "hello-world--2", slug: "hello-world", want: false},
+		{current: "hello-world-+2", slug: "hello-world", want: false},
+		// a title ending in a number is another slug
+		{current: "hello-world-2-3", slug: "hello-world", want: false},
+		{current: "hello-world-two", slug: "hello-world", want: false},
+		{current: "hello", slug: "hello-world", want: false},
+		{current: "hello-worlds", slug: "hello-world", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := hasSlugBase(tt.current, tt.slug); got != tt.want {
+			t.Errorf("hasSlugBase(%q, %q) = %v, want %v", tt.current, tt.slug, got, tt.want)
+		}
+	}
+}
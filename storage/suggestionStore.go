@@ -0,0 +1,80 @@
+package storage
+
+import "time"
+
+// how much each signal adds to an author suggestion's score
+type SuggestionWeights struct {
+	MutualFollows float64
+	SharedTopics  float64
+	RecentBlogs   float64
+}
+
+type AuthorSuggestion struct {
+	Id       int     `db:"id" json:"id"`
+	Name     *string `db:"name" json:"name"`
+	ImageUrl *string `db:"image_url" json:"image_url"`
+	// users the viewer follows that follow the author
+	MutualFollows int `db:"mutual_follows" json:"mutual_follows"`
+	// topics the author writes about that the viewer prefers or liked blogs of
+	SharedTopics int `db:"shared_topics" json:"shared_topics"`
+	// blogs the author published since recentSince
+	RecentBlogs int     `db:"recent_blogs" json:"recent_blogs"`
+	Followers   int     `db:"followers" json:"followers"`
+	Score       float64 `db:"score" json:"score"`
+}
+
+// GetAuthorSuggestions ranks authors for the user to follow by how many of the users they follow follow the
+// author, how many of the topics they prefer or liked blogs of the author writes about, and how many blogs the
+// author published since recentSince. authors the user follows or dismissed, unverified or suspended users
+// and authors without a blog since activeSince are left out
+func (s *Storage) GetAuthorSuggestions(userId int, recentSince time.Time, activeSince time.Time, weights SuggestionWeights, limit int) ([]AuthorSuggestion, error) {
+
+	var suggestions []AuthorSuggestion
+
+	query := `WITH viewer_topics AS (
+		SELECT topic_id FROM user_topic_preferences WHERE user_id=$1
+		UNION
+		SELECT bt.topic_id FROM blog_likes AS bl INNER JOIN blog_topics AS bt ON bt.blog_id=bl.liked_blog_id
+		WHERE bl.liked_by_id=$1
+	), candidates AS (
+		SELECT u.id,u.name,u.image_url FROM users AS u
+		WHERE u.id<>$1 AND u.is_verified=true
+		AND (u.suspended_until IS NULL OR u.suspended_until < NOW())
+		AND NOT EXISTS (SELECT 1 FROM follows WHERE follower_id=$1 AND following_id=u.id)
+		AND NOT EXISTS (SELECT 1 FROM dismissed_suggestions WHERE user_id=$1 AND suggested_user_id=u.id)
+		AND EXISTS (SELECT 1 FROM blogs WHERE blog_author_id=u.id AND is_hidden=false AND blog_created_at >= $3)
+	), scored AS (
+		SELECT c.id,c.name,c.image_url,
+		(SELECT COUNT(*) FROM follows AS f INNER JOIN follows AS ff ON ff.follower_id=f.following_id
+		WHERE f.follower_id=$1 AND ff.following_id=c.id) AS mutual_follows,
+		(SELECT COUNT(DISTINCT bt.topic_id) FROM blogs AS b INNER JOIN blog_topics AS bt ON bt.blog_id=b.id
+		WHERE b.blog_author_id=c.id AND b.is_hidden=false AND bt.topic_id IN (SELECT topic_id FROM viewer_topics)) AS shared_topics,
+		(SELECT COUNT(*) FROM blogs AS b WHERE b.blog_author_id=c.id AND b.is_hidden=false AND b.blog_created_at >= $2) AS recent_blogs,
+		(SELECT COUNT(*) FROM follows WHERE following_id=c.id) AS followers
+		FROM candidates AS c
+	)
+	SELECT id,name,image_url,mutual_follows,shared_topics,recent_blogs,followers,
+	($4::float8 * LN(1 + mutual_follows) + $5::float8 * LN(1 + shared_topics) + $6::float8 * LN(1 + recent_blogs)) AS score
+	FROM scored
+	ORDER BY score DESC,followers DESC,id ASC
+	LIMIT $7`
+
+	if err := s.db.Select(&suggestions, query, userId, recentSince, activeSince, weights.MutualFollows, weights.SharedTopics, weights.RecentBlogs, limit); err != nil {
+		return nil, err
+	}
+
+	return suggestions, nil
+}
+
+// DismissSuggestion stops the author from being suggested to the user again
+func (s *Storage) DismissSuggestion(userId int, suggestedUserId int) error {
+
+	query := `INSERT INTO dismissed_suggestions(user_id,suggested_user_id) VALUES($1,$2)
+	ON CONFLICT (user_id,suggested_user_id) DO UPDATE SET dismissed_at=NOW()`
+
+	if _, err := s.db.Exec(query, userId, suggestedUserId); err != nil {
+		return err
+	}
+
+	return nil
+}
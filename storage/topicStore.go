@@ -8,17 +8,24 @@ import (
 type Topic struct {
 	Id             int     `db:"id" json:"id"`
 	TopicTitle     string  `db:"topic_title" json:"topic_title"`
+	TopicSlug      string  `db:"topic_slug" json:"topic_slug"`
 	TopicCreatedAt string  `db:"topic_created_at" json:"topic_created_at"`
 	TopicUpdatedAt *string `db:"topic_updated_at" json:"topic_updated_at"`
 }
 
-func (s *Storage) CreateTopic(topicTitle string) (*Topic, error) {
+// creates the topic with the slug, or the slug with a number appended when another topic has it
+func (s *Storage) CreateTopic(topicTitle string, topicSlug string) (*Topic, error) {
 
 	var topic Topic
 
-	createTopicQuery := `INSERT INTO topics(topic_title) VALUES($1) RETURNING id,topic_title,topic_created_at,topic_updated_at`
+	topicSlug, err := availableTopicSlug(s.db, topicSlug, 0)
+	if err != nil {
+		return nil, err
+	}
 
-	row := s.db.QueryRowx(createTopicQuery, topicTitle)
+	createTopicQuery := `INSERT INTO topics(topic_title,topic_slug) VALUES($1,$2) RETURNING id,topic_title,topic_slug,topic_created_at,topic_updated_at`
+
+	row := s.db.QueryRowx(createTopicQuery, topicTitle, topicSlug)
 
 	if err := row.StructScan(&topic); err != nil {
 		return nil, err
@@ -30,7 +37,7 @@ func (s *Storage) CreateTopic(topicTitle string) (*Topic, error) {
 func (s *Storage) GetTopicByTopicTitle(topicTitle string) ([]Topic, error) {
 	var topics []Topic
 
-	query := `SELECT id,topic_title,topic_created_at,topic_updated_at FROM 
+	query := `SELECT id,topic_title,topic_slug,topic_created_at,topic_updated_at FROM 
 	topics WHERE topic_title=$1`
 
 	rows, err := s.db.Queryx(query, topicTitle)
@@ -57,7 +64,7 @@ func (s *Storage) GetTopicByTopicTitle(topicTitle string) ([]Topic, error) {
 func (s *Storage) GetTopicById(id int) (*Topic, error) {
 	var topic Topic
 
-	query := `SELECT id,topic_title,topic_created_at,topic_updated_at
+	query := `SELECT id,topic_title,topic_slug,topic_created_at,topic_updated_at
 	FROM topics WHERE id=$1`
 
 	row := s.db.QueryRowx(query, id)
@@ -90,14 +97,26 @@ func (s *Storage) DeleteTopicById(id int) error {
 	return nil
 }
 
-func (s *Storage) UpdateTopicById(id int, topicTitle string) (*Topic, error) {
+// renames the topic, its slug follows the new title unless it already matches it
+func (s *Storage) UpdateTopicById(id int, topicTitle string, topicSlug string) (*Topic, error) {
 	var newTopic Topic
 
+	currentTopic, err := s.GetTopicById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if hasSlugBase(currentTopic.TopicSlug, topicSlug) {
+		topicSlug = currentTopic.TopicSlug
+	} else if topicSlug, err = availableTopicSlug(s.db, topicSlug, id); err != nil {
+		return nil, err
+	}
+
 	query := `UPDATE topics
-	SET topic_title=$1,topic_updated_at=$2 
-	WHERE id=$3 RETURNING id,topic_title,topic_created_at,topic_updated_at`
+	SET topic_title=$1,topic_slug=$2,topic_updated_at=$3 
+	WHERE id=$4 RETURNING id,topic_title,topic_slug,topic_created_at,topic_updated_at`
 
-	row := s.db.QueryRowx(query, topicTitle, time.Now(), id)
+	row := s.db.QueryRowx(query, topicTitle, topicSlug, time.Now(), id)
 
 	if err := row.StructScan(&newTopic); err != nil {
 		return nil, err
@@ -109,7 +128,7 @@ func (s *Storage) UpdateTopicById(id int, topicTitle string) (*Topic, error) {
 func (s *Storage) GetTopics(skip int, limit int) ([]Topic, error) {
 	var topics []Topic
 
-	query := `SELECT id,topic_title,topic_created_at,topic_updated_at 
+	query := `SELECT id,topic_title,topic_slug,topic_created_at,topic_updated_at 
 	FROM topics 
 	ORDER BY topic_created_at DESC
 	LIMIT $1 OFFSET $2`
@@ -153,7 +172,7 @@ func (s *Storage) GetTopicsCount() (int, error) {
 func (s *Storage) GetTopicsBySearchTitleText(searchTitleText string, skip int, limit int) ([]Topic, error) {
 	var topics []Topic
 
-	query := `SELECT id,topic_title,topic_created_at,topic_updated_at
+	query := `SELECT id,topic_title,topic_slug,topic_created_at,topic_updated_at
 	FROM topics WHERE topic_title ILIKE $1
 	ORDER BY topic_created_at DESC
 	LIMIT $2 OFFSET $3`
@@ -0,0 +1,237 @@
+package storage
+
+import "time"
+
+type TrendingWindow string
+
+const (
+	HourTrendingWindow TrendingWindow = "1h"
+	DayTrendingWindow  TrendingWindow = "24h"
+	WeekTrendingWindow TrendingWindow = "7d"
+)
+
+var TrendingWindows = []TrendingWindow{HourTrendingWindow, DayTrendingWindow, WeekTrendingWindow}
+
+func (w TrendingWindow) Duration() time.Duration {
+
+	switch w {
+	case HourTrendingWindow:
+		return time.Hour
+	case DayTrendingWindow:
+		return time.Hour * 24
+	case WeekTrendingWindow:
+		return time.Hour * 24 * 7
+	}
+
+	return 0
+}
+
+// how much each kind of activity adds to a trending score
+type TrendingWeights struct {
+	Like     float64
+	Comment  float64
+	Bookmark float64
+	View     float64
+}
+
+type TrendingActivity struct {
+	Rank      int     `db:"rank" json:"rank"`
+	Score     float64 `db:"score" json:"score"`
+	Likes     int     `db:"likes" json:"likes"`
+	Comments  int     `db:"comments" json:"comments"`
+	Bookmarks int     `db:"bookmarks" json:"bookmarks"`
+	Views     int     `db:"views" json:"views"`
+}
+
+type TrendingBlog struct {
+	TrendingActivity
+	Id              int     `db:"id" json:"id"`
+	BlogTitle       string  `db:"blog_title" json:"blog_title"`
+	BlogSlug        string  `db:"blog_slug" json:"blog_slug"`
+	BlogDescription *string `db:"blog_description" json:"blog_description"`
+	BlogThumbnail   *string `db:"blog_thumbnail" json:"blog_thumbnail"`
+	BlogAuthorId    int     `db:"blog_author_id" json:"blog_author_id"`
+	AuthorName      *string `db:"author_name" json:"author_name"`
+	AuthorImageUrl  *string `db:"author_image_url" json:"author_image_url"`
+	BlogCreatedAt   string  `db:"blog_created_at" json:"blog_created_at"`
+}
+
+type TrendingTopic struct {
+	TrendingActivity
+	Topic
+	Blogs int `db:"blogs" json:"blogs"`
+}
+
+// weighted activity on every blog that is not hidden, in the window ending at $2 and the window before it.
+// $1 is the start of the window before, $3 to $6 are the weights and $7 the hours in a window
+const trendingActivityQuery = `WITH activity AS (
+	SELECT liked_blog_id AS blog_id,liked_at AS at,$3::float8 AS weight,1 AS likes,0 AS comments,0 AS bookmarks,0 AS views
+	FROM blog_likes WHERE liked_at >= $1
+	UNION ALL
+	SELECT blog_id,comment_created_at,$4::float8,0,1,0,0
+	FROM blog_comments WHERE comment_created_at >= $1 AND is_hidden=false
+	UNION ALL
+	SELECT bookmarked_blog_id,bookmarked_at,$5::float8,0,0,1,0
+	FROM blog_bookmarks WHERE bookmarked_at >= $1
+	UNION ALL
+	SELECT blog_id,viewed_at,$6::float8,0,0,0,1
+	FROM blog_view_events WHERE viewed_at >= $1
+), blog_activity AS (
+	SELECT a.blog_id,
+	COALESCE(SUM(a.weight) FILTER (WHERE a.at >= $2),0) AS current_score,
+	COALESCE(SUM(a.weight) FILTER (WHERE a.at < $2),0) AS previous_score,
+	COALESCE(SUM(a.likes) FILTER (WHERE a.at >= $2),0) AS likes,
+	COALESCE(SUM(a.comments) FILTER (WHERE a.at >= $2),0) AS comments,
+	COALESCE(SUM(a.bookmarks) FILTER (WHERE a.at >= $2),0) AS bookmarks,
+	COALESCE(SUM(a.views) FILTER (WHERE a.at >= $2),0) AS views
+	FROM activity AS a INNER JOIN blogs AS b ON a.blog_id=b.id
+	WHERE b.is_hidden=false
+	GROUP BY a.blog_id
+)`
+
+// RefreshTrending replaces the trending blogs and topics of the window with the ones moving fastest now.
+// the score is the weighted activity per hour in the window, plus how much that grew since the window
+// before, so new activity ranks above the same amount of activity that is slowing down
+func (s *Storage) RefreshTrending(window TrendingWindow, now time.Time, weights TrendingWeights, limit int) (err error) {
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	windowStart := now.Add(-window.Duration())
+	previousStart := windowStart.Add(-window.Duration())
+	hours := window.Duration().Hours()
+
+	args := []any{previousStart, windowStart, weights.Like, weights.Comment, weights.Bookmark, weights.View, hours, window, limit}
+
+	if _, err = tx.Exec(`DELETE FROM trending_blogs WHERE trending_window=$1`, window); err != nil {
+		return err
+	}
+
+	blogsQuery := trendingActivityQuery + `
+	INSERT INTO trending_blogs(trending_window,rank,blog_id,score,likes,comments,bookmarks,views)
+	SELECT $8::trending_window,ROW_NUMBER() OVER (ORDER BY score DESC,blog_id ASC),blog_id,score,likes,comments,bookmarks,views FROM (
+		SELECT blog_id,(2 * current_score - previous_score) / $7::float8 AS score,likes,comments,bookmarks,views
+		FROM blog_activity WHERE current_score > 0
+	) AS scored
+	WHERE score > 0
+	ORDER BY score DESC,blog_id ASC
+	LIMIT $9`
+
+	if _, err = tx.Exec(blogsQuery, args...); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(`DELETE FROM trending_topics WHERE trending_window=$1`, window); err != nil {
+		return err
+	}
+
+	topicsQuery := trendingActivityQuery + `
+	INSERT INTO trending_topics(trending_window,rank,topic_id,score,blogs,likes,comments,bookmarks,views)
+	SELECT $8::trending_window,ROW_NUMBER() OVER (ORDER BY score DESC,topic_id ASC),topic_id,score,blogs,likes,comments,bookmarks,views FROM (
+		SELECT bt.topic_id,(2 * SUM(ba.current_score) - SUM(ba.previous_score)) / $7::float8 AS score,
+		COUNT(*) FILTER (WHERE ba.current_score > 0) AS blogs,
+		SUM(ba.likes) AS likes,SUM(ba.comments) AS comments,SUM(ba.bookmarks) AS bookmarks,SUM(ba.views) AS views
+		FROM blog_activity AS ba INNER JOIN blog_topics AS bt ON ba.blog_id=bt.blog_id
+		GROUP BY bt.topic_id
+		HAVING SUM(ba.current_score) > 0
+	) AS scored
+	WHERE score > 0
+	ORDER BY score DESC,topic_id ASC
+	LIMIT $9`
+
+	if _, err = tx.Exec(topicsQuery, args...); err != nil {
+		return err
+	}
+
+	snapshotQuery := `INSERT INTO trending_snapshots(trending_window,refreshed_at) VALUES($1,$2)
+	ON CONFLICT (trending_window) DO UPDATE SET refreshed_at=EXCLUDED.refreshed_at`
+
+	if _, err = tx.Exec(snapshotQuery, window, now); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// time the trending snapshot of the window was last refreshed, zero if it never was
+func (s *Storage) GetTrendingRefreshedAt(window TrendingWindow) (time.Time, error) {
+
+	var refreshedAt []time.Time
+
+	if err := s.db.Select(&refreshedAt, `SELECT refreshed_at FROM trending_snapshots WHERE trending_window=$1`, window); err != nil {
+		return time.Time{}, err
+	}
+
+	if len(refreshedAt) == 0 {
+		return time.Time{}, nil
+	}
+
+	return refreshedAt[0], nil
+}
+
+// blogs of the window's trending snapshot in rank order, blogs hidden since the snapshot was taken are left out
+func (s *Storage) GetTrendingBlogs(window TrendingWindow, skip int, limit int) ([]TrendingBlog, error) {
+
+	var blogs []TrendingBlog
+
+	query := `SELECT tb.rank,tb.score,tb.likes,tb.comments,tb.bookmarks,tb.views,
+	b.id,b.blog_title,b.blog_slug,b.blog_description,b.blog_thumbnail,b.blog_author_id,b.blog_created_at,
+	u.name AS author_name,u.image_url AS author_image_url
+	FROM trending_blogs AS tb
+	INNER JOIN blogs AS b ON tb.blog_id=b.id
+	INNER JOIN users AS u ON b.blog_author_id=u.id
+	WHERE tb.trending_window=$1 AND b.is_hidden=false
+	ORDER BY tb.rank ASC
+	LIMIT $2 OFFSET $3`
+
+	if err := s.db.Select(&blogs, query, window, limit, skip); err != nil {
+		return nil, err
+	}
+
+	return blogs, nil
+}
+
+func (s *Storage) GetTrendingTopics(window TrendingWindow, limit int) ([]TrendingTopic, error) {
+
+	var topics []TrendingTopic
+
+	query := `SELECT tt.rank,tt.score,tt.blogs,tt.likes,tt.comments,tt.bookmarks,tt.views,
+	t.id,t.topic_title,t.topic_slug,t.topic_created_at,t.topic_updated_at
+	FROM trending_topics AS tt
+	INNER JOIN topics AS t ON tt.topic_id=t.id
+	WHERE tt.trending_window=$1
+	ORDER BY tt.rank ASC
+	LIMIT $2`
+
+	if err := s.db.Select(&topics, query, window, limit); err != nil {
+		return nil, err
+	}
+
+	return topics, nil
+}
+
+func (s *Storage) GetTrendingBlogsCount(window TrendingWindow) (int, error) {
+
+	var count int
+
+	query := `SELECT COUNT(*) FROM trending_blogs AS tb INNER JOIN blogs AS b ON tb.blog_id=b.id
+	WHERE tb.trending_window=$1 AND b.is_hidden=false`
+
+	if err := s.db.Get(&count, query, window); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+type uploadIntentStatus string
+
+const (
+	// waiting for the client to upload the file and complete the intent
+	CreatedUploadIntentStatus    uploadIntentStatus = "created"
+	QueuedUploadIntentStatus     uploadIntentStatus = "queued"
+	ProcessingUploadIntentStatus uploadIntentStatus = "processing"
+	CompletedUploadIntentStatus  uploadIntentStatus = "completed"
+	FailedUploadIntentStatus     uploadIntentStatus = "failed"
+)
+
+type UploadIntent struct {
+	Id          int                `db:"id" json:"id"`
+	OwnerId     int                `db:"owner_id" json:"owner_id"`
+	StorageKey  string             `db:"storage_key" json:"storage_key"`
+	ContentType string             `db:"content_type" json:"content_type"`
+	Size        int64              `db:"size" json:"size"`
+	MediaId     *int               `db:"media_id" json:"media_id"`
+	Status      uploadIntentStatus `db:"status" json:"status"`
+	Attempts    int                `db:"attempts" json:"-"`
+	Error       *string            `db:"error" json:"error"`
+	ExpiresAt   string             `db:"expires_at" json:"expires_at"`
+	CompletedAt *string            `db:"completed_at" json:"completed_at"`
+	CreatedAt   string             `db:"created_at" json:"created_at"`
+	// whether expires_at has passed, by the database's clock
+	IsExpired bool `db:"is_expired" json:"-"`
+}
+
+const uploadIntentColumns = `id,owner_id,storage_key,content_type,size,media_id,status,attempts,error,expires_at,completed_at,created_at,expires_at < NOW() AS is_expired`
+
+// completed intents are kept for a while so a retried completion still finds its media
+const completedUploadIntentRetention = time.Hour * 24 * 7
+
+// the intent expires expiresIn from now, by the database's clock like every check of it
+func (s *Storage) CreateUploadIntent(ownerId int, storageKey string, contentType string, size int64, expiresIn time.Duration) (*UploadIntent, error) {
+
+	var intent UploadIntent
+
+	query := `INSERT INTO upload_intents(owner_id,storage_key,content_type,size,expires_at)
+	VALUES($1,$2,$3,$4,NOW() + make_interval(secs => $5))
+	RETURNING ` + uploadIntentColumns
+
+	if err := s.db.QueryRowx(query, ownerId, storageKey, contentType, size, expiresIn.Seconds()).StructScan(&intent); err != nil {
+		return nil, err
+	}
+
+	return &intent, nil
+}
+
+func (s *Storage) GetUploadIntentById(intentId int) (*UploadIntent, error) {
+
+	var intent UploadIntent
+
+	query := `SELECT ` + uploadIntentColumns + ` FROM upload_intents WHERE id=$1`
+
+	if err := s.db.QueryRowx(query, intentId).StructScan(&intent); err != nil {
+		return nil, err
+	}
+
+	return &intent, nil
+}
+
+// QueueUploadIntent queues the uploaded file of a created intent to be processed,
+// sql.ErrNoRows if it was queued in the meantime
+func (s *Storage) QueueUploadIntent(intentId int) (*UploadIntent, error) {
+
+	var intent UploadIntent
+
+	query := `UPDATE upload_intents SET status='queued',next_attempt_at=NOW() WHERE id=$1 AND status='created' RETURNING ` + uploadIntentColumns
+
+	if err := s.db.QueryRowx(query, intentId).StructScan(&intent); err != nil {
+		return nil, err
+	}
+
+	return &intent, nil
+}
+
+// ClaimUploadIntents leases up to batchSize queued intents until leaseUntil, along with intents
+// whose processing lease ran out because the worker processing them stopped
+func (s *Storage) ClaimUploadIntents(batchSize int, leaseUntil time.Time) ([]UploadIntent, error) {
+
+	var intents []UploadIntent
+
+	query := `UPDATE upload_intents SET status='processing',attempts=attempts+1,locked_until=$2
+	WHERE id IN (
+		SELECT id FROM upload_intents
+		WHERE (status='queued' AND next_attempt_at <= NOW()) OR (status='processing' AND locked_until < NOW())
+		ORDER BY next_attempt_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	) RETURNING ` + uploadIntentColumns
+
+	if err := s.db.Select(&intents, query, batchSize, leaseUntil); err != nil {
+		return nil, err
+	}
+
+	return intents, nil
+}
+
+// RetryUploadIntent queues an intent whose processing failed again for nextAttemptAt
+func (s *Storage) RetryUploadIntent(intentId int, nextAttemptAt time.Time, errorMessage string) error {
+
+	query := `UPDATE upload_intents SET status='queued',locked_until=NULL,next_attempt_at=$2,error=$3 WHERE id=$1 AND status='processing'`
+
+	_, err := s.db.Exec(query, intentId, nextAttemptAt, errorMessage)
+	return err
+}
+
+// FailUploadIntent gives up on processing an intent, errorMessage is shown to its owner
+func (s *Storage) FailUploadIntent(intentId int, errorMessage string) error {
+
+	query := `UPDATE upload_intents SET status='failed',locked_until=NULL,error=$2 WHERE id=$1 AND status='processing'`
+
+	_, err := s.db.Exec(query, intentId, errorMessage)
+	return err
+}
+
+// CompleteUploadIntent links the intent to the media created from its file,
+// sql.ErrNoRows if it was completed in the meantime
+func (s *Storage) CompleteUploadIntent(intentId int, mediaId int) error {
+
+	query := `UPDATE upload_intents SET status='completed',media_id=$1,completed_at=NOW(),locked_until=NULL,error=NULL
+	WHERE id=$2 AND completed_at IS NULL`
+
+	result, err := s.db.Exec(query, mediaId, intentId)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// DeleteStaleUploadIntents deletes up to limit intents that expired without being queued or whose
+// processing failed (their files, if any were uploaded, still have to be deleted) and old completed ones
+func (s *Storage) DeleteStaleUploadIntents(limit int) ([]UploadIntent, error) {
+
+	var intents []UploadIntent
+
+	query := `DELETE FROM upload_intents WHERE id IN (
+		SELECT id FROM upload_intents
+		WHERE (status IN ('created','failed') AND expires_at < NOW()) OR completed_at < NOW() - make_interval(secs => $1)
+		LIMIT $2
+	) RETURNING ` + uploadIntentColumns
+
+	if err := s.db.Select(&intents, query, completedUploadIntentRetention.Seconds(), limit); err != nil {
+		return nil, err
+	}
+
+	return intents, nil
+}
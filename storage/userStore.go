@@ -8,8 +8,9 @@ import (
 type userRole string
 
 const (
-	AdminRole userRole = "admin"
-	UserRole  userRole = "user"
+	AdminRole     userRole = "admin"
+	ModeratorRole userRole = "moderator"
+	UserRole      userRole = "user"
 )
 
 type User struct {
@@ -20,6 +21,7 @@ type User struct {
 	IsVerified bool     `db:"is_verified" json:"is_verified"`
 	ImageUrl   *string  `db:"image_url" json:"image_url"`
 	Role       userRole `db:"role" json:"role"`
+	Language   string   `db:"language" json:"language,omitempty"`
 	CreatedAt  string   `db:"created_at" json:"created_at"`
 	UpdatedAt  *string  `db:"updated_at" json:"updated_at"`
 }
@@ -40,7 +42,7 @@ func (s *Storage) GetUserByEmail(email string) (*User, error) {
 
 	var user User
 
-	query := `SELECT id,email,password,name,is_verified,image_url,role,created_at,updated_at 
+	query := `SELECT id,email,password,name,is_verified,image_url,role,language,created_at,updated_at 
 	FROM users WHERE email=$1`
 
 	if err := s.db.Get(&user, query, email); err != nil {
@@ -54,7 +56,7 @@ func (s *Storage) GetUserById(id int) (*User, error) {
 
 	var user User
 
-	query := `SELECT id,email,password,name,is_verified,image_url,role,created_at,updated_at 
+	query := `SELECT id,email,password,name,is_verified,image_url,role,language,created_at,updated_at 
 	FROM users WHERE id=$1`
 
 	if err := s.db.Get(&user, query, id); err != nil {
@@ -68,7 +70,7 @@ func (s *Storage) GetVerifiedUserByEmail(email string) (*User, error) {
 
 	var user User
 
-	query := `SELECT id,email,password,name,is_verified,image_url,role,created_at,updated_at 
+	query := `SELECT id,email,password,name,is_verified,image_url,role,language,created_at,updated_at 
 	FROM users WHERE email=$1 AND is_verified=true`
 
 	if err := s.db.Get(&user, query, email); err != nil {
@@ -78,7 +80,8 @@ func (s *Storage) GetVerifiedUserByEmail(email string) (*User, error) {
 	return &user, nil
 }
 
-func (s *Storage) CreateUserAndInvitation(email string, password string, token string, expiration time.Time) (user User, err error) {
+// the invitation mail is queued in the outbox in the same transaction, so a user row never exists without its invite
+func (s *Storage) CreateUserAndInvitation(email string, password string, language string, token string, expiration time.Time, invitationMail NewOutboxEmail) (user User, err error) {
 
 	tx, err := s.db.Beginx()
 	if err != nil {
@@ -91,11 +94,11 @@ func (s *Storage) CreateUserAndInvitation(email string, password string, token s
 		}
 	}()
 
-	createUserQuery := `INSERT INTO users(email,password) VALUES($1,$2) RETURNING 
-	id,email,password,name,is_verified,image_url,role,created_at,updated_at`
+	createUserQuery := `INSERT INTO users(email,password,language) VALUES($1,$2,$3) RETURNING 
+	id,email,password,name,is_verified,image_url,role,language,created_at,updated_at`
 
-	row := tx.QueryRowx(createUserQuery, email, password)
-	if err := row.StructScan(&user); err != nil {
+	row := tx.QueryRowx(createUserQuery, email, password, language)
+	if err = row.StructScan(&user); err != nil {
 		return User{}, err
 	}
 
@@ -112,10 +115,15 @@ func (s *Storage) CreateUserAndInvitation(email string, password string, token s
 	}
 
 	if rowsAffected != 1 {
-		return User{}, errors.New("failed to insert user invitation")
+		err = errors.New("failed to insert user invitation")
+		return User{}, err
 	}
 
-	if err := tx.Commit(); err != nil {
+	if err = insertOutboxEmail(tx, invitationMail); err != nil {
+		return User{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
 		return User{}, err
 	}
 
@@ -156,7 +164,7 @@ func (s *Storage) ActivateUserHandler(token string) (*User, error) {
 
 	// upate is_verified field of this user id and clean up other tries of this
 	verifyUserQuery := `UPDATE users SET is_verified=true WHERE id=$1 
-	RETURNING id,email,password,name,is_verified,image_url,role,created_at,updated_at`
+	RETURNING id,email,password,name,is_verified,image_url,role,language,created_at,updated_at`
 
 	activatedUserRow := tx.QueryRowx(verifyUserQuery, user.Id)
 	if err := activatedUserRow.StructScan(&activeUser); err != nil {
@@ -182,7 +190,7 @@ func (s *Storage) CreateAdminUser(email string, password string) (*User, error)
 	var adminUser User
 
 	createAdminUserQuery := `INSERT INTO users(email,password,is_verified,role) VALUES($1,$2,$3,$4) 
-	RETURNING  id,email,password,name,is_verified,image_url,role,created_at,updated_at`
+	RETURNING  id,email,password,name,is_verified,image_url,role,language,created_at,updated_at`
 
 	row := s.db.QueryRowx(createAdminUserQuery, email, password, true, AdminRole)
 
@@ -193,15 +201,35 @@ func (s *Storage) CreateAdminUser(email string, password string) (*User, error)
 	return &adminUser, nil
 }
 
-func (s *Storage) CreatePasswordReset(token string, userId int, expiration time.Time) (*PasswordReset, error) {
+// the password reset mail is queued in the outbox in the same transaction as the reset token
+func (s *Storage) CreatePasswordReset(token string, userId int, expiration time.Time, passwordResetMail NewOutboxEmail) (passwordResetPtr *PasswordReset, err error) {
 
 	var passwordReset PasswordReset
 
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
 	query := `INSERT INTO password_resets(token,user_id,expiration_at) VALUES($1,$2,$3) RETURNING token,user_id,expiration_at`
 
-	row := s.db.QueryRowx(query, token, userId, expiration)
+	row := tx.QueryRowx(query, token, userId, expiration)
 
-	if err := row.StructScan(&passwordReset); err != nil {
+	if err = row.StructScan(&passwordReset); err != nil {
+		return nil, err
+	}
+
+	if err = insertOutboxEmail(tx, passwordResetMail); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
 		return nil, err
 	}
 
@@ -273,3 +301,17 @@ func (s *Storage) ResetPassword(newPassword string, token string) (userPtr *User
 
 	return &user, nil
 }
+
+func (s *Storage) UpdateUserLanguage(userId int, language string) (*User, error) {
+
+	var user User
+
+	query := `UPDATE users SET language=$1,updated_at=$2 WHERE id=$3
+	RETURNING id,email,password,name,is_verified,image_url,role,language,created_at,updated_at`
+
+	if err := s.db.QueryRowx(query, language, time.Now(), userId).StructScan(&user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
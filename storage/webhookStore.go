@@ -0,0 +1,357 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type WebhookEventType string
+
+const (
+	BlogCreatedWebhookEvent    WebhookEventType = "blog.created"
+	BlogDeletedWebhookEvent    WebhookEventType = "blog.deleted"
+	CommentCreatedWebhookEvent WebhookEventType = "comment.created"
+	UserFollowedWebhookEvent   WebhookEventType = "user.followed"
+)
+
+var WebhookEventTypes = []WebhookEventType{BlogCreatedWebhookEvent, BlogDeletedWebhookEvent, CommentCreatedWebhookEvent, UserFollowedWebhookEvent}
+
+type webhookDeliveryStatus string
+
+const (
+	PendingWebhookDeliveryStatus   webhookDeliveryStatus = "pending"
+	SendingWebhookDeliveryStatus   webhookDeliveryStatus = "sending"
+	SucceededWebhookDeliveryStatus webhookDeliveryStatus = "succeeded"
+	DeadWebhookDeliveryStatus      webhookDeliveryStatus = "dead"
+)
+
+type WebhookEndpoint struct {
+	Id                  int            `db:"id" json:"id"`
+	OwnerId             int            `db:"owner_id" json:"owner_id"`
+	Url                 string         `db:"url" json:"url"`
+	Secret              string         `db:"secret" json:"-"`
+	EventTypes          pq.StringArray `db:"event_types" json:"event_types"`
+	IsGlobal            bool           `db:"is_global" json:"is_global"`
+	IsActive            bool           `db:"is_active" json:"is_active"`
+	ConsecutiveFailures int            `db:"consecutive_failures" json:"consecutive_failures"`
+	DisabledAt          *string        `db:"disabled_at" json:"disabled_at"`
+	CreatedAt           string         `db:"created_at" json:"created_at"`
+	UpdatedAt           string         `db:"updated_at" json:"updated_at"`
+}
+
+type WebhookDelivery struct {
+	Id               int64                 `db:"id" json:"id"`
+	EndpointId       int                   `db:"endpoint_id" json:"endpoint_id"`
+	EventId          string                `db:"event_id" json:"event_id"`
+	EventType        string                `db:"event_type" json:"event_type"`
+	Payload          json.RawMessage       `db:"payload" json:"payload"`
+	Status           webhookDeliveryStatus `db:"status" json:"status"`
+	Attempts         int                   `db:"attempts" json:"attempts"`
+	MaxAttempts      int                   `db:"max_attempts" json:"max_attempts"`
+	NextAttemptAt    string                `db:"next_attempt_at" json:"next_attempt_at"`
+	LockedUntil      *string               `db:"locked_until" json:"locked_until"`
+	LastResponseCode *int                  `db:"last_response_code" json:"last_response_code"`
+	LastError        *string               `db:"last_error" json:"last_error"`
+	ReplayOf         *int64                `db:"replay_of" json:"replay_of"`
+	CreatedAt        string                `db:"created_at" json:"created_at"`
+	DeliveredAt      *string               `db:"delivered_at" json:"delivered_at"`
+}
+
+// ClaimedWebhookDelivery is a delivery leased to a worker, along with where and how to sign it
+type ClaimedWebhookDelivery struct {
+	WebhookDelivery
+	Url    string `db:"url"`
+	Secret string `db:"secret"`
+}
+
+type WebhookDeliveryAttempt struct {
+	Id           int64   `db:"id" json:"id"`
+	DeliveryId   int64   `db:"delivery_id" json:"delivery_id"`
+	Attempt      int     `db:"attempt" json:"attempt"`
+	ResponseCode *int    `db:"response_code" json:"response_code"`
+	ResponseBody *string `db:"response_body" json:"response_body"`
+	Error        *string `db:"error" json:"error"`
+	DurationMs   int     `db:"duration_ms" json:"duration_ms"`
+	AttemptedAt  string  `db:"attempted_at" json:"attempted_at"`
+}
+
+const webhookEndpointColumns = `id,owner_id,url,secret,event_types,is_global,is_active,consecutive_failures,disabled_at,created_at,updated_at`
+
+const webhookDeliveryColumns = `id,endpoint_id,event_id,event_type,payload,status,attempts,max_attempts,next_attempt_at,
+locked_until,last_response_code,last_error,replay_of,created_at,delivered_at`
+
+func (s *Storage) CreateWebhookEndpoint(ownerId int, url string, secret string, eventTypes []string, isGlobal bool) (*WebhookEndpoint, error) {
+
+	var endpoint WebhookEndpoint
+
+	query := `INSERT INTO webhook_endpoints(owner_id,url,secret,event_types,is_global) VALUES($1,$2,$3,$4,$5)
+	RETURNING ` + webhookEndpointColumns
+
+	if err := s.db.QueryRowx(query, ownerId, url, secret, pq.StringArray(eventTypes), isGlobal).StructScan(&endpoint); err != nil {
+		return nil, err
+	}
+
+	return &endpoint, nil
+}
+
+func (s *Storage) GetWebhookEndpointById(endpointId int) (*WebhookEndpoint, error) {
+
+	var endpoint WebhookEndpoint
+
+	query := `SELECT ` + webhookEndpointColumns + ` FROM webhook_endpoints WHERE id=$1`
+
+	if err := s.db.QueryRowx(query, endpointId).StructScan(&endpoint); err != nil {
+		return nil, err
+	}
+
+	return &endpoint, nil
+}
+
+func (s *Storage) GetWebhookEndpointsByOwner(ownerId int) ([]WebhookEndpoint, error) {
+
+	var endpoints []WebhookEndpoint
+
+	query := `SELECT ` + webhookEndpointColumns + ` FROM webhook_endpoints WHERE owner_id=$1 ORDER BY created_at DESC`
+
+	if err := s.db.Select(&endpoints, query, ownerId); err != nil {
+		return nil, err
+	}
+
+	return endpoints, nil
+}
+
+// re-activating an endpoint resets its failure count so it gets a fresh start
+func (s *Storage) UpdateWebhookEndpoint(endpointId int, url string, eventTypes []string, isActive bool) (*WebhookEndpoint, error) {
+
+	var endpoint WebhookEndpoint
+
+	query := `UPDATE webhook_endpoints SET url=$1,event_types=$2,is_active=$3,
+	consecutive_failures=CASE WHEN $3 AND NOT is_active THEN 0 ELSE consecutive_failures END,
+	disabled_at=CASE WHEN $3 THEN NULL ELSE disabled_at END,
+	updated_at=NOW()
+	WHERE id=$4 RETURNING ` + webhookEndpointColumns
+
+	if err := s.db.QueryRowx(query, url, pq.StringArray(eventTypes), isActive, endpointId).StructScan(&endpoint); err != nil {
+		return nil, err
+	}
+
+	return &endpoint, nil
+}
+
+func (s *Storage) DeleteWebhookEndpoint(endpointId int) error {
+
+	result, err := s.db.Exec(`DELETE FROM webhook_endpoints WHERE id=$1`, endpointId)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected != 1 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// queues a delivery of the event to every active endpoint subscribed to it, global endpoints
+// and endpoints owned by the user the event concerns. returns the number of deliveries queued
+func (s *Storage) EnqueueWebhookEvent(eventId string, eventType WebhookEventType, concernedUserId int, payload json.RawMessage) (int64, error) {
+
+	query := `INSERT INTO webhook_deliveries(endpoint_id,event_id,event_type,payload)
+	SELECT id,$1::text,$2::text,$3::jsonb FROM webhook_endpoints
+	WHERE is_active=true AND $2::text=ANY(event_types) AND (is_global=true OR owner_id=$4)`
+
+	result, err := s.db.Exec(query, eventId, eventType, payload, concernedUserId)
+	if err != nil {
+		return -1, err
+	}
+
+	return result.RowsAffected()
+}
+
+// claims up to batchSize due deliveries of active endpoints, leased until leaseUntil like outbox emails
+func (s *Storage) ClaimWebhookDeliveries(batchSize int, leaseUntil time.Time) ([]ClaimedWebhookDelivery, error) {
+
+	var deliveries []ClaimedWebhookDelivery
+
+	query := `WITH claimed AS (
+		UPDATE webhook_deliveries SET status='sending',attempts=attempts+1,locked_until=$2
+		WHERE id IN (
+			SELECT wd.id FROM webhook_deliveries AS wd
+			INNER JOIN webhook_endpoints AS we ON wd.endpoint_id=we.id
+			WHERE we.is_active=true
+			AND ((wd.status='pending' AND wd.next_attempt_at <= NOW()) OR (wd.status='sending' AND wd.locked_until < NOW()))
+			ORDER BY wd.next_attempt_at
+			LIMIT $1
+			FOR UPDATE OF wd SKIP LOCKED
+		) RETURNING ` + webhookDeliveryColumns + `
+	)
+	SELECT claimed.*,we.url,we.secret FROM claimed INNER JOIN webhook_endpoints AS we ON claimed.endpoint_id=we.id`
+
+	if err := s.db.Select(&deliveries, query, batchSize, leaseUntil); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func insertWebhookAttempt(tx *sqlx.Tx, attempt WebhookDeliveryAttempt) error {
+
+	query := `INSERT INTO webhook_delivery_attempts(delivery_id,attempt,response_code,response_body,error,duration_ms) VALUES($1,$2,$3,$4,$5,$6)`
+
+	_, err := tx.Exec(query, attempt.DeliveryId, attempt.Attempt, attempt.ResponseCode, attempt.ResponseBody, attempt.Error, attempt.DurationMs)
+	return err
+}
+
+func (s *Storage) MarkWebhookDeliverySucceeded(delivery ClaimedWebhookDelivery, attempt WebhookDeliveryAttempt) (err error) {
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = insertWebhookAttempt(tx, attempt); err != nil {
+		return err
+	}
+
+	query := `UPDATE webhook_deliveries SET status='succeeded',delivered_at=NOW(),locked_until=NULL,last_response_code=$2,last_error=NULL WHERE id=$1`
+
+	if _, err = tx.Exec(query, delivery.Id, attempt.ResponseCode); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(`UPDATE webhook_endpoints SET consecutive_failures=0 WHERE id=$1`, delivery.EndpointId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// MarkWebhookDeliveryFailed records a failed attempt. a nil nextAttemptAt means the delivery is out of attempts.
+// the endpoint is disabled once it has failed disableAfter attempts in a row, the returned bool tells whether that happened
+func (s *Storage) MarkWebhookDeliveryFailed(delivery ClaimedWebhookDelivery, attempt WebhookDeliveryAttempt, nextAttemptAt *time.Time, disableAfter int) (disabled bool, err error) {
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return false, err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = insertWebhookAttempt(tx, attempt); err != nil {
+		return false, err
+	}
+
+	if nextAttemptAt == nil {
+		query := `UPDATE webhook_deliveries SET status='dead',locked_until=NULL,last_response_code=$2,last_error=$3 WHERE id=$1`
+		_, err = tx.Exec(query, delivery.Id, attempt.ResponseCode, attempt.Error)
+	} else {
+		query := `UPDATE webhook_deliveries SET status='pending',locked_until=NULL,last_response_code=$2,last_error=$3,next_attempt_at=$4 WHERE id=$1`
+		_, err = tx.Exec(query, delivery.Id, attempt.ResponseCode, attempt.Error, *nextAttemptAt)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	query := `UPDATE webhook_endpoints SET consecutive_failures=consecutive_failures+1,
+	is_active=CASE WHEN consecutive_failures+1 >= $2 THEN false ELSE is_active END,
+	disabled_at=CASE WHEN consecutive_failures+1 >= $2 AND is_active THEN NOW() ELSE disabled_at END
+	WHERE id=$1 RETURNING NOT is_active`
+
+	if err = tx.QueryRow(query, delivery.EndpointId, disableAfter).Scan(&disabled); err != nil {
+		return false, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+
+	return disabled, nil
+}
+
+func (s *Storage) GetWebhookDeliveries(endpointId int, skip int, limit int) ([]WebhookDelivery, error) {
+
+	var deliveries []WebhookDelivery
+
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries WHERE endpoint_id=$1
+	ORDER BY created_at DESC, id DESC
+	LIMIT $2 OFFSET $3`
+
+	if err := s.db.Select(&deliveries, query, endpointId, limit, skip); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (s *Storage) GetWebhookDeliveriesCount(endpointId int) (int, error) {
+
+	var totalDeliveriesCount int
+
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM webhook_deliveries WHERE endpoint_id=$1`, endpointId).Scan(&totalDeliveriesCount); err != nil {
+		return -1, err
+	}
+
+	return totalDeliveriesCount, nil
+}
+
+func (s *Storage) GetWebhookDeliveryById(endpointId int, deliveryId int64) (*WebhookDelivery, error) {
+
+	var delivery WebhookDelivery
+
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries WHERE id=$1 AND endpoint_id=$2`
+
+	if err := s.db.QueryRowx(query, deliveryId, endpointId).StructScan(&delivery); err != nil {
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+func (s *Storage) GetWebhookDeliveryAttempts(deliveryId int64) ([]WebhookDeliveryAttempt, error) {
+
+	var attempts []WebhookDeliveryAttempt
+
+	query := `SELECT id,delivery_id,attempt,response_code,response_body,error,duration_ms,attempted_at
+	FROM webhook_delivery_attempts WHERE delivery_id=$1 ORDER BY attempt`
+
+	if err := s.db.Select(&attempts, query, deliveryId); err != nil {
+		return nil, err
+	}
+
+	return attempts, nil
+}
+
+// queues the same event again as a new delivery to the same endpoint, with fresh attempts
+func (s *Storage) ReplayWebhookDelivery(endpointId int, deliveryId int64) (*WebhookDelivery, error) {
+
+	var delivery WebhookDelivery
+
+	query := `INSERT INTO webhook_deliveries(endpoint_id,event_id,event_type,payload,replay_of)
+	SELECT endpoint_id,event_id,event_type,payload,id FROM webhook_deliveries WHERE id=$1 AND endpoint_id=$2
+	RETURNING ` + webhookDeliveryColumns
+
+	if err := s.db.QueryRowx(query, deliveryId, endpointId).StructScan(&delivery); err != nil {
+		return nil, err
+	}
+
+	return &delivery, nil
+}
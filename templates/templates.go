@@ -0,0 +1,8 @@
+package templates
+
+import "embed"
+
+// FS holds the mail layouts and per locale mail templates so they ship inside the binary
+//
+//go:embed layouts locales
+var FS embed.FS
@@ -0,0 +1,73 @@
+// Package trending keeps snapshots of the blogs and topics whose activity is growing fastest
+package trending
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/dhruv15803/echo-blog-app/storage"
+)
+
+const (
+	defaultInterval = time.Minute * 5
+	// blogs and topics kept in the snapshot of every window
+	defaultSnapshotSize = 100
+)
+
+// likes, comments and bookmarks are weighed like blog engagement elsewhere, a view counts for much less
+// since it takes no more than opening the blog
+var defaultWeights = storage.TrendingWeights{
+	Like:     0.3,
+	Comment:  0.5,
+	Bookmark: 0.2,
+	View:     0.05,
+}
+
+// Refresher recomputes the trending snapshots of every window each interval, so the
+// trending endpoints only read the snapshots
+type Refresher struct {
+	storage      *storage.Storage
+	interval     time.Duration
+	snapshotSize int
+	weights      storage.TrendingWeights
+}
+
+func NewRefresher(store *storage.Storage) *Refresher {
+	return &Refresher{
+		storage:      store,
+		interval:     defaultInterval,
+		snapshotSize: defaultSnapshotSize,
+		weights:      defaultWeights,
+	}
+}
+
+// Run refreshes the snapshots every interval until ctx is cancelled
+func (r *Refresher) Run(ctx context.Context) {
+
+	for {
+		r.refresh(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.interval):
+		}
+	}
+}
+
+func (r *Refresher) refresh(ctx context.Context) {
+
+	now := time.Now()
+
+	for _, window := range storage.TrendingWindows {
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err := r.storage.RefreshTrending(window, now, r.weights, r.snapshotSize); err != nil {
+			log.Printf("failed to refresh trending for window %s :- %v\n", window, err.Error())
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrDisallowedAddress is returned for webhook urls that reach loopback, private, link-local
+// or other addresses that are not on the public internet
+var ErrDisallowedAddress = errors.New("webhook url must resolve to a public address")
+
+// ranges that are not reachable on the public internet and that netip does not already classify
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// IsPublicAddr reports whether webhooks may be delivered to addr
+func IsPublicAddr(addr netip.Addr) bool {
+
+	addr = addr.Unmap()
+
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// publicAddrControl refuses connections to addresses that are not public. it runs after the
+// host is resolved, so a name that resolves to a public address when the webhook is registered
+// and to a private one later is still refused
+func publicAddrControl(network string, address string, c syscall.RawConn) error {
+
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+
+	if !IsPublicAddr(addrPort.Addr()) {
+		return fmt.Errorf("%w, %s is not", ErrDisallowedAddress, addrPort.Addr())
+	}
+
+	return nil
+}
+
+// NewClient is the http client deliveries are sent with. it does not follow redirects and, unless
+// allowPrivateNetworks, only connects to public addresses
+func NewClient(allowPrivateNetworks bool) *http.Client {
+
+	dialer := &net.Dialer{Timeout: deliveryTimeout}
+	if !allowPrivateNetworks {
+		dialer.Control = publicAddrControl
+	}
+
+	return &http.Client{
+		Timeout: deliveryTimeout,
+		// no proxy, it would be the one connecting to the endpoint and the address check would not apply
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   deliveryTimeout,
+			ResponseHeaderTimeout: deliveryTimeout,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// ValidateURLHost resolves the host of a webhook url and returns ErrDisallowedAddress if any of its
+// addresses is not public. deliveries are checked again when they connect, this only lets owners
+// know when they register the webhook
+func ValidateURLHost(ctx context.Context, webhookUrl *url.URL) error {
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", webhookUrl.Hostname())
+	if err != nil {
+		return err
+	}
+
+	for _, addr := range addrs {
+		if !IsPublicAddr(addr) {
+			return ErrDisallowedAddress
+		}
+	}
+
+	return nil
+}
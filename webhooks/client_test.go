@@ -0,0 +1,115 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+)
+
+func TestIsPublicAddr(t *testing.T) {
+
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:4700:4700::1111", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"100.64.0.1", false},
+		{"224.0.0.1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:169.254.169.254", false},
+		{"255.255.255.255", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsPublicAddr(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("IsPublicAddr(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestNewClientRefusesPrivateAddresses(t *testing.T) {
+
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	_, err := NewClient(false).Post(server.URL, "application/json", nil)
+	if !errors.Is(err, ErrDisallowedAddress) {
+		t.Fatalf("Post() error = %v, want %v", err, ErrDisallowedAddress)
+	}
+
+	if called {
+		t.Error("the loopback receiver was reached")
+	}
+
+	res, err := NewClient(true).Post(server.URL, "application/json", nil)
+	if err != nil {
+		t.Fatalf("Post() with private networks allowed error = %v", err)
+	}
+	res.Body.Close()
+}
+
+func TestNewClientDoesNotFollowRedirects(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/hook" {
+			http.Redirect(w, r, "/internal", http.StatusFound)
+			return
+		}
+		t.Error("the redirect was followed")
+	}))
+	defer server.Close()
+
+	res, err := NewClient(true).Post(server.URL+"/hook", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusFound {
+		t.Errorf("status = %d, want %d", res.StatusCode, http.StatusFound)
+	}
+}
+
+func TestValidateURLHost(t *testing.T) {
+
+	tests := []struct {
+		url     string
+		wantErr error
+	}{
+		{"https://93.184.216.34/hook", nil},
+		{"http://169.254.169.254/latest/meta-data", ErrDisallowedAddress},
+		{"http://127.0.0.1:8080/hook", ErrDisallowedAddress},
+		{"http://[::1]/hook", ErrDisallowedAddress},
+		{"http://localhost/hook", ErrDisallowedAddress},
+		{"http://10.0.0.5/hook", ErrDisallowedAddress},
+	}
+
+	for _, tt := range tests {
+		parsedUrl, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ValidateURLHost(context.Background(), parsedUrl); !errors.Is(err, tt.wantErr) {
+			t.Errorf("ValidateURLHost(%s) error = %v, want %v", tt.url, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dhruv15803/echo-blog-app/outbox"
+	"github.com/dhruv15803/echo-blog-app/storage"
+)
+
+const (
+	defaultPollInterval = time.Second * 2
+	defaultBatchSize    = 10
+	deliveryLease       = time.Minute
+	deliveryTimeout     = time.Second * 10
+	// only the start of a response is kept in the delivery log
+	maxLoggedResponseBody = 1024
+)
+
+// Dispatcher posts queued webhook deliveries using a pool of workers. failed deliveries are
+// retried with the same backoff as mails, endpoints failing disableAfter times in a row are disabled
+type Dispatcher struct {
+	storage      *storage.Storage
+	client       *http.Client
+	workers      int
+	disableAfter int
+	pollInterval time.Duration
+	batchSize    int
+}
+
+// NewDispatcher uses client to send deliveries, a nil client uses NewClient which only
+// connects to public addresses
+func NewDispatcher(storage *storage.Storage, client *http.Client, workers int, disableAfter int) *Dispatcher {
+
+	if client == nil {
+		client = NewClient(false)
+	}
+
+	if workers <= 0 {
+		workers = 1
+	}
+
+	return &Dispatcher{
+		storage:      storage,
+		client:       client,
+		workers:      workers,
+		disableAfter: disableAfter,
+		pollInterval: defaultPollInterval,
+		batchSize:    defaultBatchSize,
+	}
+}
+
+// Run starts the workers and blocks until ctx is cancelled and every worker has stopped
+func (d *Dispatcher) Run(ctx context.Context) {
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < d.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.work(ctx)
+		}()
+	}
+
+	wg.Wait()
+}
+
+func (d *Dispatcher) work(ctx context.Context) {
+
+	for {
+		deliveries, err := d.storage.ClaimWebhookDeliveries(d.batchSize, time.Now().Add(deliveryLease))
+		if err != nil {
+			log.Printf("failed to claim webhook deliveries :- %v\n", err.Error())
+		}
+
+		for _, delivery := range deliveries {
+			d.process(delivery)
+		}
+
+		if len(deliveries) == d.batchSize && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(d.pollInterval):
+		}
+	}
+}
+
+func (d *Dispatcher) process(delivery storage.ClaimedWebhookDelivery) {
+
+	attempt := d.Send(delivery)
+
+	if attempt.Error == nil {
+		if err := d.storage.MarkWebhookDeliverySucceeded(delivery, attempt); err != nil {
+			log.Printf("failed to mark webhook delivery %d as succeeded :- %v\n", delivery.Id, err.Error())
+		}
+		return
+	}
+
+	log.Printf("failed to deliver webhook %d , attempt %d of %d :- %v\n", delivery.Id, delivery.Attempts, delivery.MaxAttempts, *attempt.Error)
+
+	var nextAttemptAt *time.Time
+	if delivery.Attempts < delivery.MaxAttempts {
+		next := time.Now().Add(outbox.Backoff(delivery.Attempts))
+		nextAttemptAt = &next
+	}
+
+	disabled, err := d.storage.MarkWebhookDeliveryFailed(delivery, attempt, nextAttemptAt, d.disableAfter)
+	if err != nil {
+		log.Printf("failed to record webhook delivery %d failure :- %v\n", delivery.Id, err.Error())
+		return
+	}
+
+	if disabled {
+		log.Printf("disabled webhook endpoint %d after %d consecutive failures\n", delivery.EndpointId, d.disableAfter)
+	}
+}
+
+// Send posts a single delivery and reports how it went, a non 2xx response is a failure
+func (d *Dispatcher) Send(delivery storage.ClaimedWebhookDelivery) storage.WebhookDeliveryAttempt {
+
+	attempt := storage.WebhookDeliveryAttempt{DeliveryId: delivery.Id, Attempt: delivery.Attempts}
+
+	start := time.Now()
+	responseCode, responseBody, err := d.post(delivery)
+	attempt.DurationMs = int(time.Since(start).Milliseconds())
+
+	if responseCode != 0 {
+		attempt.ResponseCode = &responseCode
+		attempt.ResponseBody = &responseBody
+	}
+
+	if err == nil && (responseCode < 200 || responseCode > 299) {
+		err = fmt.Errorf("endpoint responded with status %d", responseCode)
+	}
+
+	if err != nil {
+		errMsg := err.Error()
+		attempt.Error = &errMsg
+	}
+
+	return attempt
+}
+
+func (d *Dispatcher) post(delivery storage.ClaimedWebhookDelivery) (int, string, error) {
+
+	if len(delivery.Payload) == 0 {
+		return 0, "", errors.New("webhook delivery has no payload")
+	}
+
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequest(http.MethodPost, delivery.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "EchoBlog-Webhooks/1.0")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.Id, 10))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(delivery.Secret, timestamp, delivery.Payload))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxLoggedResponseBody))
+	if err != nil {
+		return res.StatusCode, "", err
+	}
+
+	// the body is stored as text, postgres rejects invalid utf-8 and NUL bytes
+	loggedBody := strings.ReplaceAll(strings.ToValidUTF8(string(body), "\uFFFD"), "\x00", "")
+
+	return res.StatusCode, loggedBody, nil
+}
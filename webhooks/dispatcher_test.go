@@ -0,0 +1,214 @@
+package webhooks
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dhruv15803/echo-blog-app/storage"
+	"github.com/jmoiron/sqlx"
+)
+
+const testSecret = "whsec_test"
+
+// receiver is a webhook endpoint that checks the signature of every request and fails
+// the first failures requests
+type receiver struct {
+	mu       sync.Mutex
+	failures int
+	requests int
+	verified int
+	events   []string
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.requests++
+
+	if _, err := VerifyRequest(r, testSecret, time.Minute); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	rc.verified++
+	rc.events = append(rc.events, r.Header.Get(EventHeader))
+
+	if rc.requests <= rc.failures {
+		http.Error(w, "try again later", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// timeBetween matches a time argument within [from, to]
+type timeBetween struct {
+	from time.Time
+	to   time.Time
+}
+
+func (tb timeBetween) Match(v driver.Value) bool {
+	t, ok := v.(time.Time)
+	return ok && !t.Before(tb.from) && !t.After(tb.to)
+}
+
+func newTestDispatcher(t *testing.T, client *http.Client, disableAfter int) (*Dispatcher, sqlmock.Sqlmock) {
+
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sql mock: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return NewDispatcher(storage.NewStorage(sqlx.NewDb(db, "postgres")), client, 1, disableAfter), mock
+}
+
+func testDelivery(url string, attempts int) storage.ClaimedWebhookDelivery {
+	return storage.ClaimedWebhookDelivery{
+		WebhookDelivery: storage.WebhookDelivery{
+			Id:          9,
+			EndpointId:  4,
+			EventType:   string(storage.BlogCreatedWebhookEvent),
+			Payload:     []byte(`{"id":"evt_1","type":"blog.created","data":{}}`),
+			Attempts:    attempts,
+			MaxAttempts: 3,
+		},
+		Url:    url,
+		Secret: testSecret,
+	}
+}
+
+func TestDispatcherRetriesWithBackoffThenSucceeds(t *testing.T) {
+
+	rc := &receiver{failures: 1}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	dispatcher, mock := newTestDispatcher(t, server.Client(), 20)
+
+	// the first attempt fails and is retried after the first backoff step
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO webhook_delivery_attempts`)).
+		WithArgs(int64(9), 1, http.StatusServiceUnavailable, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	start := time.Now()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE webhook_deliveries SET status='pending'`)).
+		WithArgs(int64(9), http.StatusServiceUnavailable, sqlmock.AnyArg(), timeBetween{start.Add(5 * time.Second), start.Add(7 * time.Second)}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE webhook_endpoints SET consecutive_failures=consecutive_failures+1`)).
+		WithArgs(4, 20).
+		WillReturnRows(sqlmock.NewRows([]string{"disabled"}).AddRow(false))
+	mock.ExpectCommit()
+
+	dispatcher.process(testDelivery(server.URL, 1))
+
+	// the retry succeeds and resets the endpoint's failures
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO webhook_delivery_attempts`)).
+		WithArgs(int64(9), 2, http.StatusNoContent, sqlmock.AnyArg(), nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE webhook_deliveries SET status='succeeded'`)).
+		WithArgs(int64(9), http.StatusNoContent).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE webhook_endpoints SET consecutive_failures=0`)).
+		WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	dispatcher.process(testDelivery(server.URL, 2))
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	if rc.requests != 2 || rc.verified != 2 {
+		t.Errorf("receiver got %d requests with %d valid signatures, want 2 and 2", rc.requests, rc.verified)
+	}
+
+	for _, event := range rc.events {
+		if event != string(storage.BlogCreatedWebhookEvent) {
+			t.Errorf("%s header = %q, want %q", EventHeader, event, storage.BlogCreatedWebhookEvent)
+		}
+	}
+}
+
+func TestDispatcherGivesUpAndDisablesEndpoint(t *testing.T) {
+
+	rc := &receiver{failures: 100}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	dispatcher, mock := newTestDispatcher(t, server.Client(), 3)
+
+	// the last attempt fails, the delivery is dead and the endpoint reached disableAfter failures in a row
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO webhook_delivery_attempts`)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE webhook_deliveries SET status='dead'`)).
+		WithArgs(int64(9), http.StatusServiceUnavailable, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE webhook_endpoints SET consecutive_failures=consecutive_failures+1`)).
+		WithArgs(4, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"disabled"}).AddRow(true))
+	mock.ExpectCommit()
+
+	dispatcher.process(testDelivery(server.URL, 3))
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	if rc.verified != 1 {
+		t.Errorf("receiver verified %d signatures, want 1", rc.verified)
+	}
+}
+
+func TestDispatcherSendsVerifiableSignature(t *testing.T) {
+
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	dispatcher, _ := newTestDispatcher(t, server.Client(), 20)
+
+	attempt := dispatcher.Send(testDelivery(server.URL, 1))
+	if attempt.Error != nil {
+		t.Fatalf("Send() error = %s", *attempt.Error)
+	}
+
+	// a delivery signed with another secret is rejected by the receiver
+	delivery := testDelivery(server.URL, 1)
+	delivery.Secret = "whsec_other"
+
+	attempt = dispatcher.Send(delivery)
+	if attempt.Error == nil || attempt.ResponseCode == nil || *attempt.ResponseCode != http.StatusUnauthorized {
+		t.Errorf("Send() with the wrong secret = %+v, want a 401 failure", attempt)
+	}
+}
+
+func TestDispatcherRefusesPrivateAddresses(t *testing.T) {
+
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	dispatcher, _ := newTestDispatcher(t, nil, 20)
+
+	attempt := dispatcher.Send(testDelivery(server.URL, 1))
+	if attempt.Error == nil || attempt.ResponseCode != nil {
+		t.Errorf("Send() to a loopback address = %+v, want a connection error", attempt)
+	}
+
+	if rc.requests != 0 {
+		t.Error("the loopback receiver was reached")
+	}
+}
@@ -0,0 +1,48 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/dhruv15803/echo-blog-app/storage"
+)
+
+// Event is the body posted to webhook endpoints
+type Event struct {
+	Id        string                   `json:"id"`
+	Type      storage.WebhookEventType `json:"type"`
+	CreatedAt time.Time                `json:"created_at"`
+	Data      any                      `json:"data"`
+}
+
+// Publisher queues webhook deliveries for events, the Dispatcher sends them.
+// like notifications, publishing is best effort and never fails the action that caused it
+type Publisher struct {
+	storage *storage.Storage
+}
+
+func NewPublisher(storage *storage.Storage) *Publisher {
+	return &Publisher{storage: storage}
+}
+
+// Publish queues the event for global endpoints and for the endpoints of concernedUserId,
+// e.g. the author of a blog that was commented on
+func (p *Publisher) Publish(eventType storage.WebhookEventType, concernedUserId int, data any) {
+
+	eventId, err := newEventId()
+	if err != nil {
+		log.Printf("failed to create webhook event id :- %v\n", err.Error())
+		return
+	}
+
+	payload, err := json.Marshal(Event{Id: eventId, Type: eventType, CreatedAt: time.Now().UTC(), Data: data})
+	if err != nil {
+		log.Printf("failed to encode %s webhook event :- %v\n", eventType, err.Error())
+		return
+	}
+
+	if _, err := p.storage.EnqueueWebhookEvent(eventId, eventType, concernedUserId, payload); err != nil {
+		log.Printf("failed to queue %s webhook event :- %v\n", eventType, err.Error())
+	}
+}
@@ -0,0 +1,76 @@
+package webhooks
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+
+	// HMAC-SHA256 of "1700000000.{}" keyed with "secret"
+	want := "sha256=b8569b78799ff9e3cbff0fc2d63a33a2b57f3282abd07c37ae5e8e7d79a5f163"
+
+	got := Sign("secret", 1700000000, []byte("{}"))
+	if got != want {
+		t.Fatalf("Sign() = %q, want %q", got, want)
+	}
+
+	if Sign("secret", 1700000001, []byte("{}")) == got {
+		t.Error("Sign() does not depend on the timestamp")
+	}
+
+	if Sign("other", 1700000000, []byte("{}")) == got {
+		t.Error("Sign() does not depend on the secret")
+	}
+}
+
+func signedRequest(secret string, timestamp int64, body []byte) *http.Request {
+
+	r := httptest.NewRequest(http.MethodPost, "/hook", bytes.NewReader(body))
+	r.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	r.Header.Set(SignatureHeader, Sign(secret, timestamp, body))
+
+	return r
+}
+
+func TestVerifyRequest(t *testing.T) {
+
+	body := []byte(`{"type":"blog.created"}`)
+	now := time.Now().Unix()
+
+	tampered := signedRequest("secret", now, body)
+	tampered.Body = io.NopCloser(strings.NewReader(`{"type":"blog.deleted"}`))
+
+	tests := []struct {
+		name    string
+		r       *http.Request
+		wantErr error
+	}{
+		{name: "valid", r: signedRequest("secret", now, body)},
+		{name: "wrong secret", r: signedRequest("other", now, body), wantErr: ErrInvalidSignature},
+		{name: "tampered body", r: tampered, wantErr: ErrInvalidSignature},
+		{name: "too old", r: signedRequest("secret", now-600, body), wantErr: ErrInvalidSignature},
+		{name: "too far ahead", r: signedRequest("secret", now+600, body), wantErr: ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			got, err := VerifyRequest(tt.r, "secret", 5*time.Minute)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyRequest() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if err == nil && !bytes.Equal(got, body) {
+				t.Errorf("VerifyRequest() body = %s, want %s", got, body)
+			}
+		})
+	}
+}